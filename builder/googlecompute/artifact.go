@@ -4,20 +4,48 @@
 package googlecompute
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"maps"
 	"strconv"
 	"strings"
 
 	"github.com/hashicorp/packer-plugin-googlecompute/lib/common"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 	registryimage "github.com/hashicorp/packer-plugin-sdk/packer/registry/image"
+	compute "google.golang.org/api/compute/v1"
 )
 
-// Artifact represents a GCE image as the result of a Packer build.
+// The kinds of artifacts a build can produce.
+const (
+	ArtifactTypeImage        = "image"
+	ArtifactTypeMachineImage = "machine_image"
+	ArtifactTypeSnapshot     = "snapshot"
+)
+
+// artifactTypeName returns the human-readable name of a kind of artifact.
+func artifactTypeName(artifactType string) string {
+	switch artifactType {
+	case ArtifactTypeMachineImage:
+		return "machine image"
+	case ArtifactTypeSnapshot:
+		return "disk snapshot"
+	}
+	return "disk image"
+}
+
+// Artifact represents a GCE image, machine image or snapshot as the result of
+// a Packer build, along with the copies of the image and the images of the
+// attached disks.
 type Artifact struct {
-	image  *common.Image
-	driver common.Driver
-	config *Config
+	image        *common.Image
+	machineImage *compute.MachineImage
+	snapshot     *compute.Snapshot
+	copies       []*common.Image
+	diskImages   []*common.Image
+	driver       common.Driver
+	config       *Config
 	// StateData should store data such as GeneratedData
 	// to be shared with post-processors
 	StateData map[string]interface{}
@@ -28,11 +56,64 @@ func (*Artifact) BuilderId() string {
 	return BuilderId
 }
 
-// Destroy destroys the GCE image represented by the artifact.
+// artifactType returns the kind of resource represented by the artifact.
+func (a *Artifact) artifactType() string {
+	switch {
+	case a.machineImage != nil:
+		return ArtifactTypeMachineImage
+	case a.snapshot != nil:
+		return ArtifactTypeSnapshot
+	}
+	return ArtifactTypeImage
+}
+
+// Destroy destroys the GCE resource represented by the artifact.
 func (a *Artifact) Destroy() error {
-	log.Printf("Destroying image: %s", a.image.Name)
-	errCh := a.driver.DeleteImage(a.config.ImageProjectId, a.image.Name)
-	return <-errCh
+	// Destroy is not given a context, and deleting the artifact should not be
+	// interrupted once started.
+	ctx := context.Background()
+	var errs error
+	// A failure to remove the bindings must not leave the images behind.
+	if a.artifactType() == ArtifactTypeImage && len(a.config.ImageIAMBindings) > 0 {
+		log.Printf("Removing IAM policy bindings from image: %s", a.Id())
+		if err := a.driver.RemoveImageIAMBindings(ctx, a.config.ImageProjectId, a.Id(), a.config.ImageIAMBindings); err != nil {
+			errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("Error removing IAM policy bindings from image %s: %s", a.Id(), err))
+		}
+	}
+
+	for _, c := range a.copies {
+		log.Printf("Destroying image copy: %s/%s", c.ProjectId, c.Name)
+		if err := <-a.driver.DeleteImage(ctx, c.ProjectId, c.Name); err != nil {
+			errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("Error deleting image copy %s in project %s: %s",
+				c.Name, c.ProjectId, err))
+		}
+	}
+
+	for _, di := range a.diskImages {
+		log.Printf("Destroying disk image: %s/%s", di.ProjectId, di.Name)
+		if err := <-a.driver.DeleteImage(ctx, di.ProjectId, di.Name); err != nil {
+			errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("Error deleting disk image %s in project %s: %s",
+				di.Name, di.ProjectId, err))
+		}
+	}
+
+	log.Printf("Destroying %s: %s", artifactTypeName(a.artifactType()), a.Id())
+	errCh := deleteArtifact(ctx, a.driver, a.artifactType(), a.config.ImageProjectId, a.Id())
+	if err := <-errCh; err != nil {
+		errs = packersdk.MultiErrorAppend(errs, err)
+	}
+	return errs
+}
+
+// deleteArtifact deletes the resource of the given kind and name.
+func deleteArtifact(ctx context.Context, driver common.Driver, artifactType, project, name string) <-chan error {
+	switch artifactType {
+	case ArtifactTypeMachineImage:
+		return driver.DeleteMachineImage(ctx, project, name)
+	case ArtifactTypeSnapshot:
+		return driver.DeleteSnapshot(ctx, project, name)
+	}
+	return driver.DeleteImage(ctx, project, name)
 }
 
 // Files returns the files represented by the artifact.
@@ -40,15 +121,28 @@ func (*Artifact) Files() []string {
 	return nil
 }
 
-// Id returns the GCE image name.
+// Id returns the name of the GCE image, machine image or snapshot.
 func (a *Artifact) Id() string {
+	switch {
+	case a.machineImage != nil:
+		return a.machineImage.Name
+	case a.snapshot != nil:
+		return a.snapshot.Name
+	}
 	return a.image.Name
 }
 
 // String returns the string representation of the artifact.
 func (a *Artifact) String() string {
-	return fmt.Sprintf("A disk image was created in the '%v' project: %v",
-		a.config.ImageProjectId, a.image.Name)
+	s := fmt.Sprintf("A %s was created in the '%v' project: %v",
+		artifactTypeName(a.artifactType()), a.config.ImageProjectId, a.Id())
+	for _, c := range a.copies {
+		s += fmt.Sprintf("\nA copy was created in the '%v' project: %v", c.ProjectId, c.Name)
+	}
+	for _, di := range a.diskImages {
+		s += fmt.Sprintf("\nAn image of an attached disk was created in the '%v' project: %v", di.ProjectId, di.Name)
+	}
+	return s
 }
 
 func (a *Artifact) State(name string) interface{} {
@@ -60,11 +154,32 @@ func (a *Artifact) State(name string) interface{} {
 		)
 
 		labels := map[string]string{
-			"self_link":    a.image.SelfLink,
-			"project_id":   a.image.ProjectId,
-			"disk_size_gb": strconv.FormatInt(a.image.SizeGb, 10),
-			"machine_type": a.config.MachineType,
-			"licenses":     strings.Join(a.image.Licenses, ","),
+			"artifact_type": a.artifactType(),
+			"machine_type":  a.config.MachineType,
+		}
+		var resourceLabels map[string]string
+		switch a.artifactType() {
+		case ArtifactTypeMachineImage:
+			labels["self_link"] = a.machineImage.SelfLink
+			labels["project_id"] = a.config.ImageProjectId
+			labels["source_instance"] = a.machineImage.SourceInstance
+			labels["total_storage_bytes"] = strconv.FormatInt(a.machineImage.TotalStorageBytes, 10)
+			labels["storage_locations"] = strings.Join(a.machineImage.StorageLocations, ",")
+			resourceLabels = a.machineImage.Labels
+		case ArtifactTypeSnapshot:
+			labels["self_link"] = a.snapshot.SelfLink
+			labels["project_id"] = a.config.ImageProjectId
+			labels["source_disk"] = a.snapshot.SourceDisk
+			labels["disk_size_gb"] = strconv.FormatInt(a.snapshot.DiskSizeGb, 10)
+			labels["storage_locations"] = strings.Join(a.snapshot.StorageLocations, ",")
+			labels["licenses"] = strings.Join(a.snapshot.Licenses, ",")
+			resourceLabels = a.snapshot.Labels
+		default:
+			labels["self_link"] = a.image.SelfLink
+			labels["project_id"] = a.image.ProjectId
+			labels["disk_size_gb"] = strconv.FormatInt(a.image.SizeGb, 10)
+			labels["licenses"] = strings.Join(a.image.Licenses, ",")
+			resourceLabels = a.image.Labels
 		}
 
 		// Set source image and/or family as labels
@@ -74,72 +189,101 @@ func (a *Artifact) State(name string) interface{} {
 		if a.config.SourceImageFamily != "" {
 			labels["source_image_family"] = a.config.SourceImageFamily
 		}
+		if a.config.SourceSnapshot != "" {
+			labels["source_snapshot"] = a.config.SourceSnapshot
+		}
+		// A snapshot artifact already records the disk it was taken from.
+		if _, ok := labels["source_disk"]; !ok && a.config.SourceDisk != "" {
+			labels["source_disk"] = a.config.SourceDisk
+		}
+		if a.config.SourceMachineImage != "" {
+			labels["source_machine_image"] = a.config.SourceMachineImage
+		}
 
 		// Set PARtifact's source image name from state; this is set regardless
 		// of whether image or image family were used:
 		data, ok := a.StateData["generated_data"].(map[string]interface{})
 		if ok {
-			img.SourceImageID = data["SourceImageName"].(string)
+			img.SourceImageID, _ = data["SourceImageName"].(string)
 		}
 
 		if len(a.config.SourceImageProjectId) > 0 {
 			labels["source_image_project_ids"] = strings.Join(a.config.SourceImageProjectId, ",")
 		}
 
-		for k, v := range a.image.Labels {
+		for k, v := range resourceLabels {
 			labels["tags"] = labels["tags"] + fmt.Sprintf("%s:%s", k, v)
 		}
 
 		img.Labels = labels
-		return img
-	}
-
-	if name == registryimage.ArtifactStateURI {
-		img, _ := registryimage.FromArtifact(a,
-			registryimage.WithID(a.Id()),
-			registryimage.WithProvider("gce"),
-			registryimage.WithRegion(a.config.Zone),
-		)
-
-		labels := map[string]string{
-			"self_link":    a.image.SelfLink,
-			"project_id":   a.image.ProjectId,
-			"disk_size_gb": strconv.FormatInt(a.image.SizeGb, 10),
-			"machine_type": a.config.MachineType,
-			"licenses":     strings.Join(a.image.Licenses, ","),
-		}
-
-		// Set source image and/or family as labels
-		if a.config.SourceImage != "" {
-			labels["source_image"] = a.config.SourceImage
-		}
-		if a.config.SourceImageFamily != "" {
-			labels["source_image_family"] = a.config.SourceImageFamily
+		if len(a.copies) == 0 && len(a.diskImages) == 0 {
+			return img
 		}
 
-		// Set PARtifact's source image name from state; this is set regardless
-		// of whether image or image family were used:
-		data, ok := a.StateData["generated_data"].(map[string]interface{})
-		if ok {
-			img.SourceImageID = data["SourceImageName"].(string)
+		// Each copy of the image and each disk image is reported as an image
+		// of its own.
+		images := []*registryimage.Image{img}
+		for _, c := range a.copies {
+			copyImg, _ := registryimage.FromArtifact(a,
+				registryimage.WithID(c.Name),
+				registryimage.WithProvider("gce"),
+				registryimage.WithRegion(a.config.Zone),
+			)
+			copyLabels := maps.Clone(labels)
+			copyLabels["self_link"] = c.SelfLink
+			copyLabels["project_id"] = c.ProjectId
+			copyLabels["copy_of"] = a.image.SelfLink
+			copyImg.Labels = copyLabels
+			copyImg.SourceImageID = img.SourceImageID
+			images = append(images, copyImg)
 		}
-
-		if len(a.config.SourceImageProjectId) > 0 {
-			labels["source_image_project_ids"] = strings.Join(a.config.SourceImageProjectId, ",")
+		for _, di := range a.diskImages {
+			diskImg, _ := registryimage.FromArtifact(a,
+				registryimage.WithID(di.Name),
+				registryimage.WithProvider("gce"),
+				registryimage.WithRegion(a.config.Zone),
+			)
+			diskLabels := maps.Clone(labels)
+			diskLabels["self_link"] = di.SelfLink
+			diskLabels["project_id"] = di.ProjectId
+			diskLabels["disk_size_gb"] = strconv.FormatInt(di.SizeGb, 10)
+			diskLabels["built_with"] = a.image.SelfLink
+			delete(diskLabels, "licenses")
+			delete(diskLabels, "tags")
+			for k, v := range di.Labels {
+				diskLabels["tags"] = diskLabels["tags"] + fmt.Sprintf("%s:%s", k, v)
+			}
+			diskImg.Labels = diskLabels
+			diskImg.SourceImageID = img.SourceImageID
+			images = append(images, diskImg)
 		}
-
-		for k, v := range a.image.Labels {
-			labels["tags"] = labels["tags"] + fmt.Sprintf("%s:%s", k, v)
-		}
-
-		img.Labels = labels
-		return img
+		return images
 	}
 
 	switch name {
+	case "ArtifactType":
+		return a.artifactType()
 	case "ImageName":
-		return a.image.Name
+		return a.Id()
+	case "ImageCopies":
+		var selfLinks []string
+		for _, c := range a.copies {
+			selfLinks = append(selfLinks, c.SelfLink)
+		}
+		return selfLinks
+	case "DiskImages":
+		var selfLinks []string
+		for _, di := range a.diskImages {
+			selfLinks = append(selfLinks, di.SelfLink)
+		}
+		return selfLinks
 	case "ImageSizeGb":
+		switch a.artifactType() {
+		case ArtifactTypeMachineImage:
+			return a.machineImage.TotalStorageBytes >> 30
+		case ArtifactTypeSnapshot:
+			return a.snapshot.DiskSizeGb
+		}
 		return a.image.SizeGb
 	case "ProjectId":
 		return a.config.ProjectId
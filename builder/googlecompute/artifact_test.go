@@ -4,12 +4,16 @@
 package googlecompute
 
 import (
+	"errors"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/hashicorp/packer-plugin-googlecompute/lib/common"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 	registryimage "github.com/hashicorp/packer-plugin-sdk/packer/registry/image"
 	"github.com/mitchellh/mapstructure"
+	compute "google.golang.org/api/compute/v1"
 )
 
 func TestArtifact_impl(t *testing.T) {
@@ -73,3 +77,211 @@ func TestArtifactState_RegistryImageMetadata(t *testing.T) {
 	}
 
 }
+
+func TestArtifact_machineImage(t *testing.T) {
+	driver := &common.DriverMock{}
+	artifact := &Artifact{
+		config: &Config{Zone: "us1", ImageProjectId: "5678"},
+		driver: driver,
+		machineImage: &compute.MachineImage{
+			Name:              "test-machine-image",
+			SelfLink:          "https://www.googleapis.com/compute/v1/projects/5678/global/machineImages/test-machine-image",
+			TotalStorageBytes: 30 << 30,
+		},
+	}
+
+	if artifact.Id() != "test-machine-image" {
+		t.Errorf("Bad: unexpected Id %q", artifact.Id())
+	}
+	if artifact.State("ArtifactType") != ArtifactTypeMachineImage {
+		t.Errorf("Bad: unexpected ArtifactType %v", artifact.State("ArtifactType"))
+	}
+	if artifact.State("ImageSizeGb") != int64(30) {
+		t.Errorf("Bad: unexpected ImageSizeGb %v", artifact.State("ImageSizeGb"))
+	}
+
+	var image registryimage.Image
+	if err := mapstructure.Decode(artifact.State(registryimage.ArtifactStateURI), &image); err != nil {
+		t.Fatalf("Bad: unexpected error when trying to decode state into registryimage.Image %v", err)
+	}
+	if image.ImageID != "test-machine-image" {
+		t.Errorf("Bad: unexpected value for ImageID %q", image.ImageID)
+	}
+	if image.Labels["artifact_type"] != ArtifactTypeMachineImage {
+		t.Errorf("Bad: unexpected value for artifact_type label %q", image.Labels["artifact_type"])
+	}
+	if image.Labels["self_link"] != artifact.machineImage.SelfLink {
+		t.Errorf("Bad: unexpected value for self_link label %q", image.Labels["self_link"])
+	}
+
+	if err := artifact.Destroy(); err != nil {
+		t.Fatalf("Bad: unexpected error destroying artifact: %s", err)
+	}
+	if driver.DeleteMachineImageProjectId != "5678" || driver.DeleteMachineImageName != "test-machine-image" {
+		t.Errorf("Bad: machine image not deleted: %q/%q", driver.DeleteMachineImageProjectId, driver.DeleteMachineImageName)
+	}
+	if driver.DeleteImageName != "" {
+		t.Errorf("Bad: disk image %q should not be deleted", driver.DeleteImageName)
+	}
+}
+
+func TestArtifact_snapshot(t *testing.T) {
+	driver := &common.DriverMock{}
+	artifact := &Artifact{
+		config: &Config{Zone: "us1", ImageProjectId: "5678"},
+		driver: driver,
+		snapshot: &compute.Snapshot{
+			Name:       "test-snapshot",
+			DiskSizeGb: 20,
+			SourceDisk: "https://www.googleapis.com/compute/v1/projects/5678/zones/us1/disks/packer-disk",
+		},
+	}
+
+	if artifact.Id() != "test-snapshot" {
+		t.Errorf("Bad: unexpected Id %q", artifact.Id())
+	}
+	if artifact.State("ImageSizeGb") != int64(20) {
+		t.Errorf("Bad: unexpected ImageSizeGb %v", artifact.State("ImageSizeGb"))
+	}
+
+	var image registryimage.Image
+	if err := mapstructure.Decode(artifact.State(registryimage.ArtifactStateURI), &image); err != nil {
+		t.Fatalf("Bad: unexpected error when trying to decode state into registryimage.Image %v", err)
+	}
+	if image.Labels["source_disk"] != artifact.snapshot.SourceDisk {
+		t.Errorf("Bad: unexpected value for source_disk label %q", image.Labels["source_disk"])
+	}
+
+	if err := artifact.Destroy(); err != nil {
+		t.Fatalf("Bad: unexpected error destroying artifact: %s", err)
+	}
+	if driver.DeleteSnapshotName != "test-snapshot" {
+		t.Errorf("Bad: snapshot not deleted: %q", driver.DeleteSnapshotName)
+	}
+}
+
+func TestArtifact_destroyRemovesIAMBindings(t *testing.T) {
+	driver := &common.DriverMock{}
+	bindings := []common.IAMBinding{
+		{Role: "roles/compute.imageUser", Members: []string{"group:builders@example.com"}},
+	}
+	artifact := &Artifact{
+		config: &Config{ImageProjectId: "5678", ImageIAMBindings: bindings},
+		driver: driver,
+		image:  &common.Image{Name: "test-image", ProjectId: "5678"},
+	}
+
+	if err := artifact.Destroy(); err != nil {
+		t.Fatalf("Bad: unexpected error destroying artifact: %s", err)
+	}
+	if driver.RemoveImageIAMBindingsProjectId != "5678" || driver.RemoveImageIAMBindingsName != "test-image" {
+		t.Errorf("Bad: bindings not removed from image: %q/%q",
+			driver.RemoveImageIAMBindingsProjectId, driver.RemoveImageIAMBindingsName)
+	}
+	if len(driver.RemoveImageIAMBindingsBindings) != 1 {
+		t.Errorf("Bad: unexpected bindings removed: %#v", driver.RemoveImageIAMBindingsBindings)
+	}
+	if driver.DeleteImageName != "test-image" {
+		t.Errorf("Bad: image not deleted: %q", driver.DeleteImageName)
+	}
+}
+
+func TestArtifact_destroyIAMBindingsError(t *testing.T) {
+	driver := &common.DriverMock{RemoveImageIAMBindingsErr: errors.New("permission denied")}
+	artifact := &Artifact{
+		config: &Config{
+			ImageProjectId:   "5678",
+			ImageIAMBindings: []common.IAMBinding{{Role: "roles/compute.imageUser", Members: []string{"allUsers"}}},
+		},
+		driver: driver,
+		image:  &common.Image{Name: "test-image", ProjectId: "5678"},
+		copies: []*common.Image{{Name: "test-image", ProjectId: "other"}},
+	}
+
+	err := artifact.Destroy()
+	if err == nil || !strings.Contains(err.Error(), "permission denied") {
+		t.Errorf("Bad: expected the error removing the bindings, got %v", err)
+	}
+	if len(driver.DeleteImageNames) != 2 {
+		t.Errorf("Bad: images not deleted: %#v", driver.DeleteImageNames)
+	}
+}
+
+func TestArtifact_copies(t *testing.T) {
+	driver := &common.DriverMock{}
+	artifact := &Artifact{
+		config: &Config{Zone: "us1", ImageProjectId: "5678"},
+		driver: driver,
+		image:  &common.Image{Name: "test-image", ProjectId: "5678", SelfLink: "projects/5678/global/images/test-image"},
+		copies: []*common.Image{
+			{Name: "test-image", ProjectId: "production", SelfLink: "projects/production/global/images/test-image"},
+			{Name: "test-image-eu", ProjectId: "5678", SelfLink: "projects/5678/global/images/test-image-eu"},
+		},
+	}
+
+	expected := []string{"projects/production/global/images/test-image", "projects/5678/global/images/test-image-eu"}
+	if copies := artifact.State("ImageCopies").([]string); !reflect.DeepEqual(copies, expected) {
+		t.Errorf("Bad: unexpected ImageCopies %v", copies)
+	}
+
+	var images []registryimage.Image
+	if err := mapstructure.Decode(artifact.State(registryimage.ArtifactStateURI), &images); err != nil {
+		t.Fatalf("Bad: unexpected error when trying to decode state into []registryimage.Image %v", err)
+	}
+	if len(images) != 3 {
+		t.Fatalf("Bad: expected an image per copy along with the image, got %d", len(images))
+	}
+	if images[1].Labels["project_id"] != "production" || images[1].Labels["copy_of"] != artifact.image.SelfLink {
+		t.Errorf("Bad: unexpected labels for copy %v", images[1].Labels)
+	}
+	if images[2].ImageID != "test-image-eu" {
+		t.Errorf("Bad: unexpected value for ImageID %q", images[2].ImageID)
+	}
+
+	if err := artifact.Destroy(); err != nil {
+		t.Fatalf("Bad: unexpected error destroying artifact: %s", err)
+	}
+	if !reflect.DeepEqual(driver.DeleteImageNames, []string{"test-image", "test-image-eu", "test-image"}) {
+		t.Errorf("Bad: every copy and the image should be deleted, got %v", driver.DeleteImageNames)
+	}
+}
+
+func TestArtifact_diskImages(t *testing.T) {
+	driver := &common.DriverMock{}
+	artifact := &Artifact{
+		config: &Config{Zone: "us1", ImageProjectId: "5678"},
+		driver: driver,
+		image:  &common.Image{Name: "test-image", ProjectId: "5678", SelfLink: "projects/5678/global/images/test-image"},
+		diskImages: []*common.Image{
+			{Name: "db-image", ProjectId: "5678", SelfLink: "projects/5678/global/images/db-image", SizeGb: 100,
+				Labels: map[string]string{"role": "db"}},
+		},
+	}
+
+	expected := []string{"projects/5678/global/images/db-image"}
+	if diskImages := artifact.State("DiskImages").([]string); !reflect.DeepEqual(diskImages, expected) {
+		t.Errorf("Bad: unexpected DiskImages %v", diskImages)
+	}
+
+	var images []registryimage.Image
+	if err := mapstructure.Decode(artifact.State(registryimage.ArtifactStateURI), &images); err != nil {
+		t.Fatalf("Bad: unexpected error when trying to decode state into []registryimage.Image %v", err)
+	}
+	if len(images) != 2 {
+		t.Fatalf("Bad: expected an image per disk image along with the image, got %d", len(images))
+	}
+	if images[1].ImageID != "db-image" {
+		t.Errorf("Bad: unexpected value for ImageID %q", images[1].ImageID)
+	}
+	labels := images[1].Labels
+	if labels["built_with"] != artifact.image.SelfLink || labels["disk_size_gb"] != "100" || labels["tags"] != "role:db" {
+		t.Errorf("Bad: unexpected labels for disk image %v", labels)
+	}
+
+	if err := artifact.Destroy(); err != nil {
+		t.Fatalf("Bad: unexpected error destroying artifact: %s", err)
+	}
+	if !reflect.DeepEqual(driver.DeleteImageNames, []string{"db-image", "test-image"}) {
+		t.Errorf("Bad: every disk image and the image should be deleted, got %v", driver.DeleteImageNames)
+	}
+}
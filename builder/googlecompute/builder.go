@@ -17,6 +17,7 @@ import (
 	"github.com/hashicorp/packer-plugin-sdk/multistep/commonsteps"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 	"github.com/hashicorp/packer-plugin-sdk/packerbuilderdata"
+	compute "google.golang.org/api/compute/v1"
 )
 
 // The unique ID for this builder.
@@ -37,8 +38,12 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, []string, error) {
 	}
 	generatedDataKeys := []string{
 		// This will be set with the source image name even if the config
-		// uses source image family instead of source image id.
+		// uses source image family instead of source image id, or with the
+		// name of the snapshot, disk or machine image used instead.
 		"SourceImageName",
+		// The kind of source the instance was created from, one of image,
+		// snapshot, disk or machine_image.
+		"SourceType",
 	}
 
 	return generatedDataKeys, warnings, nil
@@ -55,91 +60,169 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 		CustomEndpoints: b.config.CustomEndpoints,
 	}
 	b.config.Authentication.ApplyDriverConfig(cfg)
+	b.config.RetryConfig.ApplyDriverConfig(cfg)
 
 	driver, err := common.NewDriverGCE(*cfg)
 	if err != nil {
 		return nil, err
 	}
 
-	// Set up the state.
-	state := new(multistep.BasicStateBag)
-	state.Put("config", &b.config)
-	state.Put("driver", driver)
-	state.Put("hook", hook)
-	state.Put("ui", ui)
-	generatedData := &packerbuilderdata.GeneratedData{State: state}
+	config, state, err := b.runAttempts(ctx, ui, hook, driver, b.steps)
+	if err != nil {
+		return nil, err
+	}
+
+	// Report any errors.
+	if rawErr, ok := state.GetOk("error"); ok {
+		return nil, rawErr.(error)
+	}
+
+	artifact := &Artifact{
+		driver:    driver,
+		config:    config,
+		StateData: map[string]interface{}{"generated_data": state.Get("generated_data")},
+	}
+	switch config.ArtifactType {
+	case ArtifactTypeMachineImage:
+		machineImage, ok := state.GetOk("machine_image")
+		if !ok {
+			log.Println("Failed to find machine image in state. Bug?")
+			return nil, nil
+		}
+		artifact.machineImage = machineImage.(*compute.MachineImage)
+	case ArtifactTypeSnapshot:
+		snapshot, ok := state.GetOk("snapshot")
+		if !ok {
+			log.Println("Failed to find snapshot in state. Bug?")
+			return nil, nil
+		}
+		artifact.snapshot = snapshot.(*compute.Snapshot)
+	default:
+		image, ok := state.GetOk("image")
+		if !ok {
+			log.Println("Failed to find image in state. Bug?")
+			return nil, nil
+		}
+		artifact.image = image.(*common.Image)
+		if copies, ok := state.GetOk("image_copies"); ok {
+			artifact.copies = copies.([]*common.Image)
+		}
+		if diskImages, ok := state.GetOk("disk_images"); ok {
+			artifact.diskImages = diskImages.([]*common.Image)
+		}
+	}
+	return artifact, nil
+}
+
+// runAttempts runs the steps of the build over with a new instance each time
+// the instance is preempted, up to preemption_attempts times. It returns the
+// configuration and the state of the last attempt.
+func (b *Builder) runAttempts(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook, driver common.Driver,
+	steps func(*Config, *packerbuilderdata.GeneratedData, context.CancelFunc) []multistep.Step,
+) (*Config, *multistep.BasicStateBag, error) {
+	var config *Config
+	var state *multistep.BasicStateBag
+	for attempt := 1; ; attempt++ {
+		// Every attempt starts over from the prepared configuration, as the
+		// steps of a previous attempt may have modified it.
+		config = b.config.clone()
+
+		runCtx, cancel := context.WithCancel(ctx)
+
+		// Set up the state.
+		state = new(multistep.BasicStateBag)
+		state.Put("config", config)
+		state.Put("driver", driver)
+		state.Put("hook", hook)
+		state.Put("ui", ui)
+		state.Put("build_attempt", attempt)
+		generatedData := &packerbuilderdata.GeneratedData{State: state}
+
+		// Run the steps.
+		b.runner = commonsteps.NewRunner(steps(config, generatedData, cancel), config.PackerConfig, ui)
+		b.runner.Run(runCtx, state)
+		cancel()
+
+		if _, preempted := state.GetOk("instance_preempted"); !preempted || ctx.Err() != nil {
+			break
+		}
+		if attempt >= config.PreemptionAttempts {
+			return nil, nil, fmt.Errorf("Instance was preempted during each of the %d build attempts", attempt)
+		}
+		ui.Say(fmt.Sprintf("Starting the build over with a new instance (attempt %d of %d)...",
+			attempt+1, config.PreemptionAttempts))
+	}
+
+	return config, state, nil
+}
+
+// steps returns the steps of a build attempt with config. cancel cancels the
+// attempt.
+func (b *Builder) steps(config *Config, generatedData *packerbuilderdata.GeneratedData, cancel context.CancelFunc) []multistep.Step {
+	createDisks := &StepCreateDisks{
+		DiskConfiguration: config.ExtraBlockDevices,
+	}
 
-	// Build the steps.
 	steps := []multistep.Step{
 		new(StepCheckExistingImage),
 		&communicator.StepSSHKeyGen{
-			CommConf:            &b.config.Comm,
-			SSHTemporaryKeyPair: b.config.Comm.SSH.SSHTemporaryKeyPair,
+			CommConf:            &config.Comm,
+			SSHTemporaryKeyPair: config.Comm.SSH.SSHTemporaryKeyPair,
 		},
-		multistep.If(b.config.PackerDebug && b.config.Comm.SSHPrivateKeyFile == "",
+		multistep.If(config.PackerDebug && config.Comm.SSHPrivateKeyFile == "",
 			&communicator.StepDumpSSHKey{
-				Path: fmt.Sprintf("gce_%s.pem", b.config.PackerBuildName),
-				SSH:  &b.config.Comm.SSH,
+				Path: fmt.Sprintf("gce_%s.pem", config.PackerBuildName),
+				SSH:  &config.Comm.SSH,
 			},
 		),
-		&StepCreateDisks{
-			DiskConfiguration: b.config.ExtraBlockDevices,
-		},
+		createDisks,
 		&StepImportOSLoginSSHKey{
-			Debug: b.config.PackerDebug,
+			Debug: config.PackerDebug,
 		},
 		&StepCreateInstance{
-			Debug:         b.config.PackerDebug,
+			Debug:         config.PackerDebug,
 			GeneratedData: generatedData,
+			Disks:         createDisks,
+		},
+		new(StepFollowSerialPort),
+		&StepWatchPreemption{
+			Cancel: cancel,
 		},
 		&StepCreateWindowsPassword{
-			Debug:        b.config.PackerDebug,
-			DebugKeyPath: fmt.Sprintf("gce_windows_%s.pem", b.config.PackerBuildName),
+			Debug:        config.PackerDebug,
+			DebugKeyPath: fmt.Sprintf("gce_windows_%s.pem", config.PackerBuildName),
 		},
 		&StepInstanceInfo{
-			Debug: b.config.PackerDebug,
+			Debug: config.PackerDebug,
 		},
 		&StepStartTunnel{
-			IAPConf:            &b.config.IAPConfig,
-			CommConf:           &b.config.Comm,
-			AccountFile:        b.config.CredentialsFile,
-			ImpersonateAccount: b.config.ImpersonateServiceAccount,
-			ProjectId:          b.config.ProjectId,
+			IAPConf:   &config.IAPConfig,
+			CommConf:  &config.Comm,
+			ProjectId: config.ProjectId,
 		},
 		&communicator.StepConnect{
-			Config:      &b.config.Comm,
-			Host:        communicator.CommHost(b.config.Comm.Host(), "instance_ip"),
-			SSHConfig:   b.config.Comm.SSHConfigFunc(),
+			Config:      &config.Comm,
+			Host:        communicator.CommHost(config.Comm.Host(), "instance_ip"),
+			SSHConfig:   config.Comm.SSHConfigFunc(),
 			WinRMConfig: winrmConfig,
 		},
+		new(StepWaitCloudInit),
 		new(commonsteps.StepProvision),
 		&commonsteps.StepCleanupTempKeys{
-			Comm: &b.config.Comm,
+			Comm: &config.Comm,
 		},
 	}
-	if _, exists := b.config.Metadata[StartupScriptKey]; exists || b.config.StartupScriptFile != "" {
+	if _, exists := config.Metadata[StartupScriptKey]; exists || config.StartupScriptFile != "" || config.StartupScriptURL != "" ||
+		config.hasWindowsStartupScript() {
 		steps = append(steps, new(StepWaitStartupScript))
 	}
-	steps = append(steps, new(StepTeardownInstance), new(StepCreateImage))
-
-	// Run the steps.
-	b.runner = commonsteps.NewRunner(steps, b.config.PackerConfig, ui)
-	b.runner.Run(ctx, state)
-
-	// Report any errors.
-	if rawErr, ok := state.GetOk("error"); ok {
-		return nil, rawErr.(error)
-	}
-	if _, ok := state.GetOk("image"); !ok {
-		log.Println("Failed to find image in state. Bug?")
-		return nil, nil
+	if config.ArtifactType == ArtifactTypeMachineImage {
+		// Machine images are created from the instance itself.
+		steps = append(steps, new(StepStopInstance), new(StepCreateImage), new(StepTeardownInstance))
+	} else {
+		steps = append(steps, new(StepTeardownInstance), new(StepCreateImage), new(StepSetImageIAMPolicy),
+			new(StepCopyImage), new(StepApplyFamilyRetention))
 	}
 
-	artifact := &Artifact{
-		image:     state.Get("image").(*common.Image),
-		driver:    driver,
-		config:    &b.config,
-		StateData: map[string]interface{}{"generated_data": state.Get("generated_data")},
-	}
-	return artifact, nil
+	return steps
 }
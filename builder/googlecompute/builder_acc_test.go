@@ -372,7 +372,7 @@ func TestAccBuilder_ImageArchVariations(t *testing.T) {
 						return fmt.Errorf("failed to create GCE driver: %s", err)
 					}
 
-					chErr := driver.DeleteImage(os.Getenv("GOOGLE_PROJECT_ID"), imageName)
+					chErr := driver.DeleteImage(context.Background(), os.Getenv("GOOGLE_PROJECT_ID"), imageName)
 					for err := range chErr {
 						return err
 					}
@@ -390,7 +390,7 @@ func TestAccBuilder_ImageArchVariations(t *testing.T) {
 						return fmt.Errorf("failed to create GCE driver: %s", err)
 					}
 
-					img, err := driver.GetImageFromProject(os.Getenv("GOOGLE_PROJECT_ID"), imageName, false)
+					img, err := driver.GetImageFromProject(context.Background(), os.Getenv("GOOGLE_PROJECT_ID"), imageName, false)
 					if err != nil {
 						return fmt.Errorf("failed to get image: %s", err)
 					}
@@ -444,7 +444,7 @@ func TestAccBuilder_NetworkIP(t *testing.T) {
 				return fmt.Errorf("failed to create GCE driver: %s", err)
 			}
 
-			chErr := driver.DeleteImage(os.Getenv("GOOGLE_PROJECT_ID"), imageName)
+			chErr := driver.DeleteImage(context.Background(), os.Getenv("GOOGLE_PROJECT_ID"), imageName)
 			for err := range chErr {
 				return err
 			}
@@ -542,7 +542,7 @@ func TestAccBuilder_CustomEndpointsAndUniverse(t *testing.T) {
 						return nil
 					}
 
-					chErr := driver.DeleteImage(os.Getenv("GOOGLE_PROJECT_ID"), imageName)
+					chErr := driver.DeleteImage(context.Background(), os.Getenv("GOOGLE_PROJECT_ID"), imageName)
 					err = <-chErr
 					if err != nil {
 						t.Logf("Error during image cleanup for %s: %s", imageName, err)
@@ -569,7 +569,7 @@ func TestAccBuilder_CustomEndpointsAndUniverse(t *testing.T) {
 							return fmt.Errorf("failed to create GCE driver for verification: %s", err)
 						}
 
-						_, err = driver.GetImageFromProject(os.Getenv("GOOGLE_PROJECT_ID"), imageName, false)
+						_, err = driver.GetImageFromProject(context.Background(), os.Getenv("GOOGLE_PROJECT_ID"), imageName, false)
 						if err != nil {
 							return fmt.Errorf("failed to get the created image '%s' for verification: %s", imageName, err)
 						}
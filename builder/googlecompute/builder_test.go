@@ -0,0 +1,81 @@
+// Copyright IBM Corp. 2013, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package googlecompute
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-googlecompute/lib/common"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/packerbuilderdata"
+	"github.com/stretchr/testify/assert"
+)
+
+// preemptedStep stands for the steps of a build attempt, whose instance is
+// preempted in the first attempts.
+type preemptedStep struct {
+	// preempted is the number of attempts whose instance is preempted.
+	preempted int
+	// attempts counts the attempts that ran.
+	attempts int
+	// onPreempted is called when the instance of an attempt is preempted.
+	onPreempted func()
+}
+
+func (s *preemptedStep) Run(_ context.Context, state multistep.StateBag) multistep.StepAction {
+	s.attempts++
+	if s.attempts > s.preempted {
+		return multistep.ActionContinue
+	}
+	if s.onPreempted != nil {
+		s.onPreempted()
+	}
+	state.Put("instance_preempted", true)
+	return multistep.ActionHalt
+}
+
+func (s *preemptedStep) Cleanup(multistep.StateBag) {}
+
+func testRunAttempts(ctx context.Context, t *testing.T, step *preemptedStep, preemptionAttempts int) (*multistep.BasicStateBag, error) {
+	b := &Builder{config: *testConfigStruct(t)}
+	b.config.PreemptionAttempts = preemptionAttempts
+	ui := &packersdk.BasicUi{Reader: new(bytes.Buffer), Writer: new(bytes.Buffer), ErrorWriter: new(bytes.Buffer)}
+
+	_, state, err := b.runAttempts(ctx, ui, &packersdk.MockHook{}, &common.DriverMock{},
+		func(*Config, *packerbuilderdata.GeneratedData, context.CancelFunc) []multistep.Step {
+			return []multistep.Step{step}
+		})
+	return state, err
+}
+
+func TestBuilder_runAttempts(t *testing.T) {
+	step := &preemptedStep{preempted: 2}
+	state, err := testRunAttempts(context.Background(), t, step, 3)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, step.attempts, "The build should have started over after each preemption.")
+	_, preempted := state.GetOk("instance_preempted")
+	assert.False(t, preempted, "The state should be the one of the last attempt.")
+}
+
+func TestBuilder_runAttemptsExhausted(t *testing.T) {
+	step := &preemptedStep{preempted: 5}
+	_, err := testRunAttempts(context.Background(), t, step, 2)
+
+	assert.EqualError(t, err, "Instance was preempted during each of the 2 build attempts")
+	assert.Equal(t, 2, step.attempts, "The build should stop at preemption_attempts.")
+}
+
+func TestBuilder_runAttemptsCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	step := &preemptedStep{preempted: 5, onPreempted: cancel}
+	_, err := testRunAttempts(ctx, t, step, 3)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, step.attempts, "A cancelled build should not start over.")
+}
@@ -9,10 +9,9 @@ package googlecompute
 import (
 	"errors"
 	"fmt"
-	"net"
 	"os"
 	"regexp"
-	"runtime"
+	"slices"
 	"strings"
 	"time"
 
@@ -35,6 +34,7 @@ var validImageName = regexp.MustCompile(`^[a-z]([-a-z0-9]{0,61}[a-z0-9])?$`)
 type Config struct {
 	sdk_common.PackerConfig `mapstructure:",squash"`
 	common.Authentication   `mapstructure:",squash"`
+	common.RetryConfig      `mapstructure:",squash"`
 
 	Comm communicator.Config `mapstructure:",squash"`
 
@@ -50,6 +50,17 @@ type Config struct {
 	// The name of a pre-allocated static external IP address. Note, must be
 	// the name and not the actual IP address.
 	Address string `mapstructure:"address" required:"false"`
+	// Build on a Confidential VM of the given type: `SEV`, `SEV_SNP` or
+	// `TDX`. The machine type must support it (`n2d`, `c2d` or `c3d` for
+	// `SEV`, `n2d` for `SEV_SNP` and `c3` for `TDX`), and the source image
+	// must have the matching `SEV_CAPABLE`, `SEV_SNP_CAPABLE` or
+	// `TDX_CAPABLE` guest OS feature, which is then also added to the
+	// resulting image.
+	//
+	// Only `SEV` instances on `n2d` machines support live migration, all
+	// others default to and require an `on_host_maintenance` of `TERMINATE`.
+	// Please see [Confidential VM](https://cloud.google.com/confidential-computing/confidential-vm/docs/supported-configurations)
+	ConfidentialInstanceType string `mapstructure:"confidential_instance_type" required:"false"`
 	// If true, the default service account will not be used if
 	// service_account_email is not specified. Set this value to true and omit
 	// service_account_email to provision a VM with no service account.
@@ -61,6 +72,25 @@ type Config struct {
 	// Type of disk used to back your instance, like pd-ssd or pd-standard.
 	// Defaults to pd-standard.
 	DiskType string `mapstructure:"disk_type" required:"false"`
+	// The IOPS to provision for the boot disk. Only `pd-extreme`,
+	// `hyperdisk-balanced` and `hyperdisk-extreme` disks support it. Defaults
+	// to the default performance of the disk type and size.
+	DiskProvisionedIops int64 `mapstructure:"disk_provisioned_iops" required:"false"`
+	// The throughput in MiB per second to provision for the boot disk. Only
+	// `hyperdisk-balanced`, `hyperdisk-ml` and `hyperdisk-throughput` disks
+	// support it. Defaults to the default performance of the disk type and
+	// size.
+	DiskProvisionedThroughput int64 `mapstructure:"disk_provisioned_throughput" required:"false"`
+	// Key/value pair labels to apply to the boot disk. They are not applied
+	// to the resulting image, see `image_labels`.
+	DiskLabels map[string]string `mapstructure:"disk_labels" required:"false"`
+	// The storage pool to create the boot disk in, either its name in the
+	// zone of the build or its URL. Only `hyperdisk-balanced` and
+	// `hyperdisk-throughput` disks can be created in a storage pool.
+	DiskStoragePool string `mapstructure:"disk_storage_pool" required:"false"`
+	// The resource policies to apply to the boot disk, e.g. a snapshot
+	// schedule, either their names in the region of the build or their URLs.
+	DiskResourcePolicies []string `mapstructure:"disk_resource_policies" required:"false"`
 	// Disk encryption key to apply to the created boot disk. Possible values:
 	// * kmsKeyName -  The name of the encryption key that is stored in Google Cloud KMS.
 	// * RawKey: - A 256-bit customer-supplied encryption key, encodes in RFC 4648 base64.
@@ -118,6 +148,22 @@ type Config struct {
 	IAPConfig `mapstructure:",squash"`
 	// Skip creating the image. Useful for setting to `true` during a build test stage. Defaults to `false`.
 	SkipCreateImage bool `mapstructure:"skip_create_image" required:"false"`
+	// The kind of artifact to create from the instance. Possible values are:
+	// * `image` - A disk image of the boot disk, or of the `disk_attachment`
+	//   with `create_image` set. This is the default.
+	// * `machine_image` - A machine image of the whole instance, capturing
+	//   all its disks along with its properties like the machine type,
+	//   network interfaces and metadata. The instance is stopped before
+	//   creating it.
+	// * `snapshot` - A snapshot of the boot disk, or of the `disk_attachment`
+	//   with `create_image` set, which can then be stored regionally and
+	//   managed by snapshot schedules.
+	//
+	// `image_name`, `image_description`, `image_labels`,
+	// `image_encryption_key`, `image_project_id` and `image_storage_locations`
+	// apply to all kinds of artifacts. The other `image_*` options, along with
+	// the deprecation options, only apply to images.
+	ArtifactType string `mapstructure:"artifact_type" required:"false"`
 	// The architecture of the resulting image.
 	//
 	// Defaults to unset: GCE will use the origin image architecture.
@@ -156,6 +202,20 @@ type Config struct {
 	ImageLabels map[string]string `mapstructure:"image_labels" required:"false"`
 	// Licenses to apply to the created image.
 	ImageLicenses []string `mapstructure:"image_licenses" required:"false"`
+	// IAM policy bindings to add to the created image, to share it with other
+	// projects, groups or service accounts. The bindings are removed if the
+	// artifact is destroyed. This is a repeatable block, e.g.
+	//
+	// ```hcl
+	//   image_iam_binding {
+	//     role    = "roles/compute.imageUser"
+	//     members = ["group:builders@example.com", "serviceAccount:deployer@my-project.iam.gserviceaccount.com"]
+	//   }
+	// ```
+	//
+	// The members of the bindings are merged with the existing policy of the
+	// image, which is otherwise left unchanged.
+	ImageIAMBindings []common.IAMBinding `mapstructure:"image_iam_binding" required:"false"`
 	// Guest OS features to apply to the created image.
 	ImageGuestOsFeatures []string `mapstructure:"image_guest_os_features" required:"false"`
 	// The project ID to push the build image into. Defaults to project_id.
@@ -169,6 +229,14 @@ type Config struct {
 	ImageKeyExchangeKey []string `mapstructure:"image_key_exchange_key" required:"false"`
 	// A database of certificates that have been revoked and will cause the system to stop booting if a boot file is signed with one of them. You may specify single or multiple comma-separated values for this value.
 	ImageForbiddenSignaturesDB []string `mapstructure:"image_forbidden_signatures_db" required:"false"`
+	// Fail the build if a Secure Boot key or certificate is neither a DER or
+	// PEM encoded X.509 certificate nor an EFI signature list, or if a
+	// certificate has expired. By default, these are reported as warnings,
+	// and keys of an unknown format are sent as binary content.
+	//
+	// Refer to [Secure Boot Keys](#secure-boot-keys) for the sources of the
+	// keys.
+	ImageSecureBootStrict bool `mapstructure:"image_secure_boot_strict" required:"false"`
 
 	// Storage location, either regional or multi-regional, where snapshot
 	// content is to be stored and only accepts 1 value. Always defaults to a nearby regional or multi-regional
@@ -189,6 +257,40 @@ type Config struct {
 	//  }
 	//  ```
 	ImageStorageLocations []string `mapstructure:"image_storage_locations" required:"false"`
+	// Copies of the image to create in other projects or storage locations,
+	// once the image is built. The copies are made at once, and are all part
+	// of the artifact: destroying it deletes every copy. This is a
+	// repeatable block, e.g.
+	//
+	// ```hcl
+	//   image_copy {
+	//     project_id       = "production"
+	//     storage_location = "eu"
+	//   }
+	//   image_copy {
+	//     storage_location = "asia"
+	//     name_suffix      = "-asia"
+	//   }
+	// ```
+	//
+	// Refer to [Image Copies](#image-copies) for the options of the block.
+	ImageCopies []ImageCopy `mapstructure:"image_copy" required:"false"`
+	// Manages the previous images of `image_family` once the image is built:
+	// they can be deprecated in favor of the new image, made obsolete, or
+	// deleted past a count or an age. Packer prints the plan before acting on
+	// the images. e.g.
+	//
+	// ```hcl
+	//   image_family_retention {
+	//     deprecate_previous = true
+	//     obsolete_after     = 3
+	//     keep_last          = 10
+	//   }
+	// ```
+	//
+	// Refer to [Image Family Retention](#image-family-retention) for the
+	// options of the block.
+	ImageFamilyRetention *FamilyRetention `mapstructure:"image_family_retention" required:"false"`
 	// A name to give the launched instance. Beware that this must be unique.
 	// Defaults to `packer-{{uuid}}`.
 	InstanceName string `mapstructure:"instance_name" required:"false"`
@@ -203,6 +305,16 @@ type Config struct {
 	Metadata map[string]string `mapstructure:"metadata" required:"false"`
 	// Metadata applied to the launched instance. Values are files.
 	MetadataFiles map[string]string `mapstructure:"metadata_files"`
+	// The user data to pass to cloud-init through the `user-data` instance
+	// metadata, e.g. a `#cloud-config` document, which is checked to be
+	// valid YAML. Build variables like `{{ build_name }}` are rendered.
+	// When set, the builder waits for cloud-init to finish before running
+	// the provisioners.
+	UserData string `mapstructure:"user_data" required:"false"`
+	// The path to a file with the user data to pass to cloud-init, in place
+	// of `user_data`. The file is rendered as a template with build
+	// variables.
+	UserDataFile string `mapstructure:"user_data_file" required:"false"`
 	// A Minimum CPU Platform for VM Instance. Availability and default CPU
 	// platforms vary across zones, based on the hardware available in each GCP
 	// zone.
@@ -217,9 +329,32 @@ type Config struct {
 	// The project ID for the network and subnetwork to use for launched
 	// instance. Defaults to project_id.
 	NetworkProjectId string `mapstructure:"network_project_id" required:"false"`
+	// The network interfaces of the instance, the first one being the
+	// primary interface. Refer to the [Network Interfaces](#network-interfaces)
+	// section for more information.
+	//
+	// When set, `network`, `subnetwork`, `network_ip`, `address` and
+	// `omit_external_ip` cannot be set, as they only describe a single
+	// interface.
+	NetworkInterfaces []common.NetworkInterface `mapstructure:"network_interface" required:"false"`
+	// The index of the network interface whose IP address Packer connects
+	// to, and publishes as the instance IP. Defaults to 0, the primary
+	// interface.
+	InstanceIPNetworkInterface int `mapstructure:"instance_ip_network_interface" required:"false"`
 	// If true, the instance will not have an external IP. use_internal_ip must
 	// be true if this property is true.
 	OmitExternalIP bool `mapstructure:"omit_external_ip" required:"false"`
+	// The IP stack of the instance network interface, one of `IPV4_ONLY`,
+	// `IPV4_IPV6` for dual-stack, or `IPV6_ONLY`. The subnetwork must support
+	// it. Defaults to `IPV4_ONLY`.
+	//
+	// With `IPV6_ONLY`, the instance has no IPv4 address at all, so
+	// `use_ipv6` must be true and no external IPv4 address is requested.
+	StackType string `mapstructure:"stack_type" required:"false"`
+	// If true, the instance gets an external IPv6 address from the
+	// subnetwork. Requires `stack_type` to be `IPV4_IPV6` or `IPV6_ONLY`,
+	// and the subnetwork to have an external IPv6 access type.
+	ExternalIPv6 bool `mapstructure:"external_ipv6" required:"false"`
 	// Sets Host Maintenance Option. Valid choices are `MIGRATE` and
 	// `TERMINATE`. Please see [GCE Instance Scheduling
 	// Options](https://cloud.google.com/compute/docs/instances/setting-instance-scheduling-options),
@@ -236,8 +371,19 @@ type Config struct {
 	// the instance will be deleted. Defaults to `STOP` when max_run_duration_in_seconds is specified.
 	// Please see [GCE Limit VM Runtime](https://cloud.google.com/compute/docs/instances/limit-vm-runtime)
 	InstanceTerminationAction string `mapstructure:"instance_termination_action" required:"false"`
-	// If true, launch a preemptible instance.
+	// If true, launch a preemptible instance. Preemptible instances are the
+	// previous version of Spot VMs, see `provisioning_model`.
 	Preemptible bool `mapstructure:"preemptible" required:"false"`
+	// The provisioning model of the instance, either `STANDARD` or `SPOT`.
+	// Spot VMs cost much less, but Compute Engine can preempt them at any
+	// time, in which case Packer deletes the instance and starts the build
+	// over, up to `preemption_attempts` times.
+	// Please see [Spot VMs](https://cloud.google.com/compute/docs/instances/spot)
+	ProvisioningModel string `mapstructure:"provisioning_model" required:"false"`
+	// How many times to attempt the build of a preemptible or Spot instance
+	// before giving up, when Compute Engine keeps preempting the instance.
+	// Defaults to 3.
+	PreemptionAttempts int `mapstructure:"preemption_attempts" required:"false"`
 	// Sets a node affinity label for the launched instance (eg. for sole tenancy).
 	// Please see [Provisioning VMs on
 	// sole-tenant nodes](https://cloud.google.com/compute/docs/nodes/provisioning-sole-tenant-vms)
@@ -258,6 +404,17 @@ type Config struct {
 	ReservationAffinity *common.ReservationAffinity `mapstructure:"reservation_affinity" required:"false"`
 	// The time to wait for instance state changes. Defaults to "5m".
 	StateTimeout time.Duration `mapstructure:"state_timeout" required:"false"`
+	// The time to wait for the image, machine image or snapshot to be
+	// created, along with the copies of the image and the images of the
+	// attached disks. Large images can take much longer to create than
+	// the instance state changes. Defaults to `state_timeout`.
+	ImageCreateTimeout time.Duration `mapstructure:"image_create_timeout" required:"false"`
+	// The time to wait for the instance to be created. Defaults to
+	// `state_timeout`.
+	InstanceCreateTimeout time.Duration `mapstructure:"instance_create_timeout" required:"false"`
+	// The time to wait for the disks of the build to be deleted. Defaults
+	// to `state_timeout`.
+	DiskDeleteTimeout time.Duration `mapstructure:"disk_delete_timeout" required:"false"`
 	// The region in which to launch the instance. Defaults to the region
 	// hosting the specified zone.
 	Region string `mapstructure:"region" required:"false"`
@@ -276,9 +433,23 @@ type Config struct {
 	// project's default service account unless disable_default_service_account
 	// is true.
 	ServiceAccountEmail string `mapstructure:"service_account_email" required:"false"`
+	// If true, show the serial port output of the instance in the Packer UI,
+	// prefixed by `[serial]`, while the instance boots and while waiting for
+	// the startup script to complete. Defaults to false.
+	StreamSerialPortOutput bool `mapstructure:"stream_serial_port_output" required:"false"`
+	// The local file to write the whole serial port output of the instance
+	// to. The output is followed from the creation of the instance until it
+	// is deleted. When the build starts over after a preemption, the output
+	// of the new instance is appended after a line naming the attempt.
+	SerialPortLogFile string `mapstructure:"serial_port_log_file" required:"false"`
+	// The number of lines of the serial port output to show when the startup
+	// script fails. Defaults to 20.
+	SerialPortTailLines int `mapstructure:"serial_port_tail_lines" required:"false"`
 	// The source image to use to create the new image from. You can also
 	// specify source_image_family instead. If both source_image and
 	// source_image_family are specified, source_image takes precedence.
+	// One of them, `source_snapshot`, `source_disk` or
+	// `source_machine_image` must be set.
 	// Example: `"debian-8-jessie-v20161027"`
 	SourceImage string `mapstructure:"source_image" required:"true"`
 	// The source image family to use to create the new image from. The image
@@ -288,6 +459,23 @@ type Config struct {
 	// A list of project IDs to search for the source image. Packer will search the first
 	// project ID in the list first, and fall back to the next in the list, until it finds the source image.
 	SourceImageProjectId []string `mapstructure:"source_image_project_id" required:"false"`
+	// The snapshot to create the boot disk of the instance from, instead of
+	// an image. Either the name of a snapshot of `project_id`, or its partial
+	// or full URL, e.g. `projects/golden/global/snapshots/web-20240101`.
+	SourceSnapshot string `mapstructure:"source_snapshot" required:"false"`
+	// The name of an existing disk in the `zone` of `project_id` to clone as
+	// the boot disk of the instance, instead of creating it from an image.
+	// It cannot be used along with `fallback_zones`.
+	SourceDisk string `mapstructure:"source_disk" required:"false"`
+	// The machine image to create the instance from, instead of an image.
+	// The instance gets the disks and properties saved in the machine image,
+	// which the options of the build override. Either the name of a machine
+	// image of `project_id`, or its partial or full URL.
+	//
+	// It cannot be used along with `disk_attachment`, and `disk_name`,
+	// `disk_size` and `disk_type` do not apply as the disks come from the
+	// machine image.
+	SourceMachineImage string `mapstructure:"source_machine_image" required:"false"`
 	// The path to a startup script to run on the launched instance from which the image will
 	// be made. When set, the contents of the startup script file will be added to the instance metadata
 	// under the `"startup_script"` metadata property. See [Providing startup script contents directly](https://cloud.google.com/compute/docs/startupscript#providing_startup_script_contents_directly) for more details.
@@ -295,16 +483,50 @@ type Config struct {
 	// When using `startup_script_file` the following rules apply:
 	// - The contents of the script file will overwrite the value of the `"startup_script"` metadata property at runtime.
 	// - The contents of the script file will be wrapped in Packer's startup script wrapper, unless `wrap_startup_script` is disabled. See `wrap_startup_script` for more details.
-	// - Not supported by Windows instances. See [Startup Scripts for Windows](https://cloud.google.com/compute/docs/startupscript#providing_a_startup_script_for_windows_instances) for more details.
+	// - On Windows instances, the script is a PowerShell script run under the `"windows-startup-script-ps1"` metadata property,
+	//   in place of any script set there. See [Startup Scripts for Windows](https://cloud.google.com/compute/docs/startupscript#providing_a_startup_script_for_windows_instances) for more details.
 	StartupScriptFile string `mapstructure:"startup_script_file" required:"false"`
+	// The Cloud Storage URL of a startup script to run on the launched
+	// instance, either `gs://bucket/object` or
+	// `https://storage.googleapis.com/bucket/object`. Like for
+	// `startup_script_file`, the script is wrapped in Packer's startup script
+	// wrapper, unless `wrap_startup_script` is disabled, which downloads it
+	// with the service account of the instance and tracks its completion. It
+	// cannot be used along with `startup_script_file`.
+	StartupScriptURL string `mapstructure:"startup_script_url" required:"false"`
+	// The path to a shutdown script to run when the instance is torn down,
+	// e.g. to clean up the instance before the image is created. The
+	// instance is stopped before it is deleted, to wait for the script to
+	// finish, and the build fails if the script fails or does not finish
+	// before Compute Engine stops the instance, about 90 seconds after the
+	// stop request. The script is a PowerShell script on Windows instances.
+	ShutdownScriptFile string `mapstructure:"shutdown_script_file" required:"false"`
+	// The time to wait for the wrapped startup script to finish, e.g. `30m`.
+	// The build fails when the script does not finish in time. Defaults to
+	// 0, to wait until it finishes.
+	StartupScriptTimeout time.Duration `mapstructure:"startup_script_timeout" required:"false"`
+	// What to do when the wrapped startup script fails: `abort` the build,
+	// or `continue` it after reporting the error. Defaults to `abort`.
+	//
+	// The wrapper publishes the exit code, the duration and the last lines
+	// of the output of the script in the `packer/exit-code`,
+	// `packer/duration` and `packer/log-tail` guest attributes, which are
+	// shown in the error.
+	OnStartupScriptFailure string `mapstructure:"on_startup_script_failure" required:"false"`
 	// The time to wait for windows password to be retrieved. Defaults to "3m".
 	WindowsPasswordTimeout time.Duration `mapstructure:"windows_password_timeout" required:"false"`
 	// For backwards compatibility this option defaults to `"true"` in the future it will default to `"false"`.
 	// If "true", the contents of `startup_script_file` or `"startup_script"` in the instance metadata
 	// is wrapped in a Packer specific script that tracks the execution and completion of the provided
 	// startup script. The wrapper ensures that the builder will not continue until the startup script has been executed.
-	// - The use of the wrapped script file requires that the user or service account
-	// running the build has the compute.instance.Metadata role.
+	// - On Windows instances, the PowerShell script of `startup_script_file`, or else of `"windows-startup-script-ps1"`
+	// or `"sysprep-specialize-script-ps1"` in the instance metadata, is wrapped in a PowerShell wrapper instead.
+	// It requires the Google Cloud CLI, which the public Windows images include.
+	// - The wrapper reports the status of the script in the `packer/status` guest attribute of the instance,
+	// through the metadata server, and enables guest attributes on the instance for this purpose.
+	// - If guest attributes are not available, the wrapper falls back to setting the `"startup-script-status"`
+	// instance metadata with the Google Cloud CLI, which requires the CLI in the image and the
+	// compute.instances.setMetadata permission for the service account of the instance.
 	WrapStartupScriptFile config.Trilean `mapstructure:"wrap_startup_script" required:"false"`
 	// The Google Compute subnetwork id or URL to use for the launched
 	// instance. Only required if the network has been created with custom
@@ -320,6 +542,10 @@ type Config struct {
 	// If true, use the instance's internal IP instead of its external IP
 	// during building.
 	UseInternalIP bool `mapstructure:"use_internal_ip" required:"false"`
+	// If true, use the instance's IPv6 address instead of its IPv4 address
+	// during building: its external IPv6 address, or its internal one if
+	// `use_internal_ip` is true. Required when `stack_type` is `IPV6_ONLY`.
+	UseIPv6 bool `mapstructure:"use_ipv6" required:"false"`
 	// If true, OSLogin will be used to manage SSH access to the compute instance by
 	// dynamically importing a temporary SSH key to the Google account's login profile,
 	// and setting the `enable-oslogin` to `TRUE` in the instance metadata.
@@ -364,7 +590,8 @@ type Config struct {
 	//    fingerprint: 000000000000000000000000000000000000000000000000000000000000000a
 	//```
 	UseOSLogin config.Trilean `mapstructure:"use_os_login" required:"false"`
-	// The network IP address reserved to use for the launched instance.
+	// The network IP address reserved to use for the launched instance. It
+	// can be an IPv6 address if `stack_type` is `IPV4_IPV6` or `IPV6_ONLY`.
 	NetworkIP string `mapstructure:"network_ip" required:"false"`
 	// OSLoginSSHUsername specifies the username to be used with OS Login when importing the SSH public key.
 	//
@@ -403,6 +630,20 @@ type Config struct {
 	// The zone in which to launch the instance used to create the image.
 	// Example: `"us-central1-a"`
 	Zone string `mapstructure:"zone" required:"true"`
+	// Zones to try, in order, when the instance cannot be created in `zone`
+	// because the zone lacks the resources or the quota for it (e.g.
+	// `ZONE_RESOURCE_POOL_EXHAUSTED`). The region, subnetwork and extra disks
+	// follow the zone the instance is eventually created in, except for disks
+	// attached from an existing `source_volume`. Regional disks are kept, so
+	// the fallback zones must be in their `replica_zones`. Once the quota of a
+	// region is exceeded, the other zones of that region are skipped.
+	//
+	// Falling back to a zone from another region is only possible when none of
+	// `region`, `address`, `network_ip` or resource policies of the disks are
+	// set, and `subnetwork` is a name rather than a URL. Fallback zones cannot
+	// be used along with storage pools, which are zonal.
+	// Example: `["us-central1-b", "us-central1-c"]`
+	FallbackZones []string `mapstructure:"fallback_zones" required:"false"`
 
 	// Time when the image is considered as deprecated.
 	// In UTC, in the following RFC3339 format: YYYY-MM-DDTHH:MM:SSZ.
@@ -424,7 +665,8 @@ type Config struct {
 	UniverseDomain string `mapstructure:"universe_domain"`
 	// Custom service endpoints, typically used to configure the Google provider to
 	// communicate with GCP-like APIs such as the Cloud Functions emulator.
-	//  Supported keys are `compute`, `storage`, `oslogin` and `oauth2`.
+	//  Supported keys are `compute`, `storage`, `oslogin`, `oauth2` and `iap`,
+	//  the latter being the websocket endpoint of the IAP TCP forwarding service.
 	//
 	// Example:
 	//   custom_endpoints = {
@@ -436,6 +678,7 @@ type Config struct {
 
 	ctx                  interpolate.Context
 	imageSourceDisk      string
+	userData             string
 	imageAlreadyExists   bool
 	loginProfileUsername string
 }
@@ -473,8 +716,12 @@ func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
 		c.ExtraBlockDevices[i] = bd
 	}
 
+	if niErrs := c.prepareNetworkInterfaces(); len(niErrs) > 0 {
+		errs = packersdk.MultiErrorAppend(errs, niErrs...)
+	}
+
 	// Set defaults.
-	if c.Network == "" && c.Subnetwork == "" {
+	if c.Network == "" && c.Subnetwork == "" && len(c.NetworkInterfaces) == 0 {
 		c.Network = "default"
 	}
 
@@ -490,6 +737,11 @@ func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
 		c.DiskType = "pd-standard"
 	}
 
+	for _, err := range common.ValidateDiskPerformance(c.DiskType, c.DiskProvisionedIops,
+		c.DiskProvisionedThroughput, c.DiskStoragePool) {
+		errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("boot disk: %s", err))
+	}
+
 	if c.ImageProjectId == "" {
 		c.ImageProjectId = c.ProjectId
 	}
@@ -507,14 +759,49 @@ func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
 		c.ImageDescription = "Created by Packer"
 	}
 
-	if c.OnHostMaintenance == "MIGRATE" && c.Preemptible {
+	switch c.ProvisioningModel {
+	case "", common.ProvisioningModelStandard, common.ProvisioningModelSpot:
+	default:
 		errs = packersdk.MultiErrorAppend(errs,
-			errors.New("on_host_maintenance must be TERMINATE when using preemptible instances."))
+			errors.New("provisioning_model must be one of STANDARD or SPOT."))
+	}
+	if c.Preemptible && c.ProvisioningModel == common.ProvisioningModelStandard {
+		errs = packersdk.MultiErrorAppend(errs,
+			errors.New("provisioning_model cannot be STANDARD when using preemptible instances."))
+	}
+
+	if c.PreemptionAttempts < 0 {
+		errs = packersdk.MultiErrorAppend(errs,
+			errors.New("preemption_attempts must be greater than 0"))
+	}
+	if c.PreemptionAttempts == 0 {
+		c.PreemptionAttempts = 3
+	}
+
+	if c.ConfidentialInstanceType != "" {
+		if c.MachineType == "" {
+			errs = packersdk.MultiErrorAppend(errs,
+				errors.New("machine_type must be set when using confidential_instance_type."))
+		} else if err := common.ValidateConfidentialInstance(c.ConfidentialInstanceType, c.MachineType); err != nil {
+			errs = packersdk.MultiErrorAppend(errs, err)
+		} else if !common.ConfidentialInstanceCanMigrate(c.ConfidentialInstanceType, c.MachineType) {
+			if c.OnHostMaintenance == "MIGRATE" {
+				errs = packersdk.MultiErrorAppend(errs,
+					fmt.Errorf("on_host_maintenance must be TERMINATE when using %s confidential instances on %s.",
+						c.ConfidentialInstanceType, c.MachineType))
+			}
+			c.OnHostMaintenance = "TERMINATE"
+		}
+	}
+
+	if c.OnHostMaintenance == "MIGRATE" && c.isPreemptible() {
+		errs = packersdk.MultiErrorAppend(errs,
+			errors.New("on_host_maintenance must be TERMINATE when using preemptible or Spot instances."))
 	}
 	// Setting OnHostMaintenance Correct Defaults
-	//   "MIGRATE" : Possible and default if Preemptible is false
-	//   "TERMINATE": Required if Preemptible is true
-	if c.Preemptible {
+	//   "MIGRATE" : Possible and default if the instance is not preemptible
+	//   "TERMINATE": Required if the instance is preemptible or Spot
+	if c.isPreemptible() {
 		c.OnHostMaintenance = "TERMINATE"
 	} else {
 		if c.OnHostMaintenance == "" {
@@ -575,6 +862,12 @@ func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
 			fmt.Errorf("Invalid image architecture %q: Must be either X86_64 or ARM64", c.ImageArchitecture))
 	}
 
+	for _, source := range c.secureBootKeys() {
+		if err := common.ValidateSecureBootKeySource(source); err != nil {
+			errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("Invalid Secure Boot key: %s", err))
+		}
+	}
+
 	if len(c.ImageFamily) > 63 {
 		errs = packersdk.MultiErrorAppend(errs,
 			errors.New("Invalid image family: Must not be longer than 63 characters"))
@@ -586,6 +879,29 @@ func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
 		}
 	}
 
+	if artifactErrs := c.prepareArtifactType(); len(artifactErrs) > 0 {
+		errs = packersdk.MultiErrorAppend(errs, artifactErrs...)
+	}
+
+	for i := range c.ImageIAMBindings {
+		if bindingErrs := c.ImageIAMBindings[i].Prepare(); len(bindingErrs) > 0 {
+			errs = packersdk.MultiErrorAppend(errs, bindingErrs...)
+		}
+	}
+
+	if c.ImageFamilyRetention != nil {
+		if c.ImageFamily == "" {
+			errs = packersdk.MultiErrorAppend(errs, errors.New("image_family_retention requires image_family to be set"))
+		}
+		if retentionErrs := c.ImageFamilyRetention.Prepare(); len(retentionErrs) > 0 {
+			errs = packersdk.MultiErrorAppend(errs, retentionErrs...)
+		}
+	}
+
+	if copyErrs := c.prepareImageCopies(); len(copyErrs) > 0 {
+		errs = packersdk.MultiErrorAppend(errs, copyErrs...)
+	}
+
 	if len(c.ImageStorageLocations) > 1 {
 		errs = packersdk.MultiErrorAppend(errs,
 			errors.New("Invalid image storage locations: Must not have more than 1 region"))
@@ -600,12 +916,14 @@ func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
 	}
 
 	for _, bd := range c.ExtraBlockDevices {
-		if !bd.CreateImage {
+		// Disks with an image_name of their own are imaged separately.
+		if !bd.CreateImage || bd.ImageName != "" {
 			continue
 		}
 
 		if c.imageSourceDisk != "" {
-			errs = packersdk.MultiErrorAppend(errs, errors.New("create_image cannot be enabled on multiple disks."))
+			errs = packersdk.MultiErrorAppend(errs, errors.New("create_image cannot be enabled on multiple disks "+
+				"without image_name, set image_name on the disks to image separately."))
 		}
 
 		c.imageSourceDisk = bd.DiskName
@@ -615,6 +933,10 @@ func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
 		c.imageSourceDisk = c.DiskName
 	}
 
+	if diskImageErrs := c.prepareDiskImages(); len(diskImageErrs) > 0 {
+		errs = packersdk.MultiErrorAppend(errs, diskImageErrs...)
+	}
+
 	if c.MachineType == "" {
 		c.MachineType = "e2-standard-2"
 	}
@@ -622,6 +944,33 @@ func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
 	if c.StateTimeout == 0 {
 		c.StateTimeout = 5 * time.Minute
 	}
+	if c.ImageCreateTimeout < 0 {
+		errs = packersdk.MultiErrorAppend(errs, errors.New("image_create_timeout must not be negative"))
+	}
+	if c.InstanceCreateTimeout < 0 {
+		errs = packersdk.MultiErrorAppend(errs, errors.New("instance_create_timeout must not be negative"))
+	}
+	if c.DiskDeleteTimeout < 0 {
+		errs = packersdk.MultiErrorAppend(errs, errors.New("disk_delete_timeout must not be negative"))
+	}
+
+	switch c.OnStartupScriptFailure {
+	case "":
+		c.OnStartupScriptFailure = StartupScriptFailureAbort
+	case StartupScriptFailureAbort, StartupScriptFailureContinue:
+	default:
+		errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("invalid on_startup_script_failure %q, valid values are %s or %s",
+			c.OnStartupScriptFailure, StartupScriptFailureAbort, StartupScriptFailureContinue))
+	}
+	if c.StartupScriptTimeout < 0 {
+		errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("startup_script_timeout must be positive"))
+	}
+
+	if c.SerialPortTailLines == 0 {
+		c.SerialPortTailLines = 20
+	} else if c.SerialPortTailLines < 0 {
+		errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("serial_port_tail_lines must be positive"))
+	}
 
 	// Set up communicator
 	if es := c.Comm.Prepare(&c.ctx); len(es) > 0 {
@@ -629,17 +978,9 @@ func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
 	}
 
 	// set defaults for IAP
-	if c.IAPConfig.IAPHashBang == "" {
-		if runtime.GOOS == "windows" {
-			c.IAPConfig.IAPHashBang = ""
-		} else {
-			c.IAPConfig.IAPHashBang = "/bin/sh"
-		}
-	}
-	if c.IAPConfig.IAPExt == "" {
-		if runtime.GOOS == "windows" {
-			c.IAPConfig.IAPExt = ".cmd"
-		}
+	if c.IAPConfig.IAPHashBang != "" || c.IAPConfig.IAPExt != "" {
+		warnings = append(warnings,
+			"iap_hashbang and iap_ext are deprecated and ignored: the IAP tunnel no longer uses gcloud.")
 	}
 	if c.IAPConfig.IAPTunnelLaunchWait == 0 {
 		if c.Comm.Type == "winrm" {
@@ -680,15 +1021,21 @@ func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
 		}
 	}
 
-	if c.SourceImage == "" && c.SourceImageFamily == "" {
-		errs = packersdk.MultiErrorAppend(
-			errs, errors.New("a source_image or source_image_family must be specified"))
+	if sourceErrs := c.prepareSource(); len(sourceErrs) > 0 {
+		errs = packersdk.MultiErrorAppend(errs, sourceErrs...)
+	}
+
+	if userDataErrs := c.prepareUserData(); len(userDataErrs) > 0 {
+		errs = packersdk.MultiErrorAppend(errs, userDataErrs...)
 	}
 
 	if c.Zone == "" {
 		errs = packersdk.MultiErrorAppend(
 			errs, errors.New("a zone must be specified"))
 	}
+	if zoneErrs := c.prepareFallbackZones(); len(zoneErrs) > 0 {
+		errs = packersdk.MultiErrorAppend(errs, zoneErrs...)
+	}
 	if c.Region == "" && len(c.Zone) > 2 {
 		// get region from Zone
 		region := c.Zone[:len(c.Zone)-2]
@@ -699,6 +1046,9 @@ func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
 	if err != nil {
 		errs = packersdk.MultiErrorAppend(errs, err)
 	}
+	if retryErrs := c.RetryConfig.Prepare(); len(retryErrs) > 0 {
+		errs = packersdk.MultiErrorAppend(errs, retryErrs...)
+	}
 	if len(warns) > 0 {
 		warnings = append(warnings, warns...)
 	}
@@ -707,7 +1057,7 @@ func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
 		errs = packersdk.MultiErrorAppend(fmt.Errorf("you can not specify an external address when 'omit_external_ip' is true"))
 	}
 
-	if c.OmitExternalIP && !c.UseInternalIP {
+	if c.OmitExternalIP && !c.UseInternalIP && !c.UseIPv6 {
 		errs = packersdk.MultiErrorAppend(fmt.Errorf("'use_internal_ip' must be true if 'omit_external_ip' is true"))
 	}
 
@@ -734,6 +1084,29 @@ func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
 			c.WrapStartupScriptFile = config.TriTrue
 		}
 	}
+
+	if c.StartupScriptURL != "" {
+		if c.StartupScriptFile != "" {
+			errs = packersdk.MultiErrorAppend(errs,
+				errors.New("only one of startup_script_file or startup_script_url can be specified"))
+		}
+		if !strings.HasPrefix(c.StartupScriptURL, "gs://") &&
+			!strings.HasPrefix(c.StartupScriptURL, "https://storage.googleapis.com/") {
+			errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("invalid startup_script_url %q, it must start with "+
+				"gs:// or https://storage.googleapis.com/", c.StartupScriptURL))
+		}
+
+		if c.WrapStartupScriptFile == config.TriUnset {
+			c.WrapStartupScriptFile = config.TriTrue
+		}
+	}
+
+	if c.ShutdownScriptFile != "" {
+		if _, err := os.Stat(c.ShutdownScriptFile); err != nil {
+			errs = packersdk.MultiErrorAppend(
+				errs, fmt.Errorf("shutdown_script_file: %v", err))
+		}
+	}
 	// Check windows password timeout is provided
 	if c.WindowsPasswordTimeout == 0 {
 		c.WindowsPasswordTimeout = 3 * time.Minute
@@ -745,14 +1118,16 @@ func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
 		}
 	}
 
-	if c.NetworkIP != "" {
-		if ip := net.ParseIP(c.NetworkIP); ip == nil || ip.To4() == nil {
-			// Check if the IP is a valid IPv4 address
-			// If not, return an error
-			errs = packer.MultiErrorAppend(errs, fmt.Errorf("network_ip must be a valid IPv4 address"))
+	if len(c.NetworkInterfaces) == 0 {
+		if stackErrs := common.ValidateIPStack(c.StackType, c.NetworkIP, c.Address != "", c.ExternalIPv6); len(stackErrs) > 0 {
+			errs = packer.MultiErrorAppend(errs, stackErrs...)
 		}
 	}
 
+	if ipErrs := c.prepareInstanceIP(); len(ipErrs) > 0 {
+		errs = packer.MultiErrorAppend(errs, ipErrs...)
+	}
+
 	return warnings, errs
 }
 
@@ -778,18 +1153,315 @@ func ApplyIAPTunnel(c *communicator.Config, port int) error {
 	}
 }
 
+// secureBootKeys returns the sources of all the Secure Boot keys and
+// certificates of the image.
+func (c *Config) secureBootKeys() []string {
+	var keys []string
+	if c.ImagePlatformKey != "" {
+		keys = append(keys, c.ImagePlatformKey)
+	}
+	return slices.Concat(keys, c.ImageKeyExchangeKey, c.ImageSignaturesDB, c.ImageForbiddenSignaturesDB)
+}
+
 var labelKeyRegex = regexp.MustCompile(`^\p{Ll}[\p{Ll}0-9_-]{0,62}$`)
 var labelValueRegex = regexp.MustCompile(`^[\p{Ll}0-9_-]{0,63}$`)
 
 func (c *Config) AreLabelsValid() []error {
 	var errs []error
-	for key, value := range c.ImageLabels {
-		if !labelKeyRegex.MatchString(key) {
-			errs = append(errs, fmt.Errorf("key %q must match regex %q", key, labelKeyRegex))
+	labelSets := []map[string]string{c.ImageLabels, c.DiskLabels}
+	for _, bd := range c.ExtraBlockDevices {
+		labelSets = append(labelSets, bd.Labels, bd.ImageLabels)
+	}
+	for _, labels := range labelSets {
+		for key, value := range labels {
+			if !labelKeyRegex.MatchString(key) {
+				errs = append(errs, fmt.Errorf("key %q must match regex %q", key, labelKeyRegex))
+			}
+			if !labelValueRegex.MatchString(value) {
+				errs = append(errs, fmt.Errorf("value %q for key %q must match regex %q", value, key, labelValueRegex))
+			}
+		}
+	}
+	return errs
+}
+
+// clone returns a copy of the config that steps can modify without affecting
+// the original, e.g. to start a build over.
+func (c *Config) clone() *Config {
+	clone := *c
+	clone.ExtraBlockDevices = append([]common.BlockDevice(nil), c.ExtraBlockDevices...)
+	return &clone
+}
+
+func (c *Config) prepareNetworkInterfaces() []error {
+	var errs []error
+
+	if len(c.NetworkInterfaces) == 0 {
+		if c.InstanceIPNetworkInterface != 0 {
+			errs = append(errs, errors.New("instance_ip_network_interface must be 0 without network_interface blocks"))
+		}
+		return errs
+	}
+
+	if c.Network != "" || c.Subnetwork != "" || c.NetworkIP != "" || c.Address != "" || c.OmitExternalIP ||
+		c.StackType != "" || c.ExternalIPv6 {
+		errs = append(errs, errors.New("network, subnetwork, network_ip, address, omit_external_ip, stack_type "+
+			"and external_ipv6 cannot be set along with network_interface blocks, set them in the blocks instead"))
+	}
+
+	for i := range c.NetworkInterfaces {
+		ni := &c.NetworkInterfaces[i]
+		errs = append(errs, ni.Prepare()...)
+		if ni.NetworkProjectId == "" {
+			ni.NetworkProjectId = c.NetworkProjectId
+		}
+		if ni.NetworkProjectId == "" {
+			ni.NetworkProjectId = c.ProjectId
+		}
+	}
+
+	if c.InstanceIPNetworkInterface < 0 || c.InstanceIPNetworkInterface >= len(c.NetworkInterfaces) {
+		errs = append(errs, fmt.Errorf("instance_ip_network_interface must be the index of one of the %d network_interface blocks",
+			len(c.NetworkInterfaces)))
+	}
+
+	return errs
+}
+
+// prepareInstanceIP checks that the network interface Packer connects to has
+// the kind of address the build uses.
+func (c *Config) prepareInstanceIP() []error {
+	var errs []error
+
+	ni := common.NetworkInterface{
+		StackType: c.StackType,
+		// A missing external IP is reported along with omit_external_ip.
+		ExternalIP:   true,
+		ExternalIPv6: c.ExternalIPv6,
+	}
+	if len(c.NetworkInterfaces) > 0 {
+		if c.InstanceIPNetworkInterface < 0 || c.InstanceIPNetworkInterface >= len(c.NetworkInterfaces) {
+			// Already reported by prepareNetworkInterfaces.
+			return nil
+		}
+		ni = c.NetworkInterfaces[c.InstanceIPNetworkInterface]
+	}
+
+	switch {
+	case c.UseIPv6 && !ni.HasIPv6():
+		errs = append(errs, fmt.Errorf("'use_ipv6' requires the stack_type of network interface %d to be %s or %s",
+			c.InstanceIPNetworkInterface, common.StackTypeIPv4IPv6, common.StackTypeIPv6Only))
+	case c.UseIPv6 && !c.UseInternalIP && !ni.ExternalIPv6:
+		errs = append(errs, fmt.Errorf("'use_internal_ip' must be true if network interface %d has no external IPv6 address",
+			c.InstanceIPNetworkInterface))
+	case !c.UseIPv6 && !ni.HasIPv4():
+		errs = append(errs, fmt.Errorf("'use_ipv6' must be true if network interface %d has stack_type %s",
+			c.InstanceIPNetworkInterface, common.StackTypeIPv6Only))
+	case !c.UseIPv6 && !c.UseInternalIP && !ni.ExternalIP:
+		errs = append(errs, fmt.Errorf("'use_internal_ip' must be true if network interface %d has no external IP",
+			c.InstanceIPNetworkInterface))
+	}
+
+	return errs
+}
+
+// The kinds of sources the boot disk of the instance can be created from.
+const (
+	sourceTypeImage        = "image"
+	sourceTypeSnapshot     = "snapshot"
+	sourceTypeDisk         = "disk"
+	sourceTypeMachineImage = "machine_image"
+)
+
+// sourceType returns the kind of source the boot disk of the instance is
+// created from.
+func (c *Config) sourceType() string {
+	switch {
+	case c.SourceSnapshot != "":
+		return sourceTypeSnapshot
+	case c.SourceDisk != "":
+		return sourceTypeDisk
+	case c.SourceMachineImage != "":
+		return sourceTypeMachineImage
+	}
+	return sourceTypeImage
+}
+
+// prepareSource checks that exactly one source is set for the boot disk of
+// the instance, along with the options that apply to it.
+func (c *Config) prepareSource() []error {
+	var sources []string
+	if c.SourceImage != "" || c.SourceImageFamily != "" {
+		sources = append(sources, "source_image")
+	}
+	if c.SourceSnapshot != "" {
+		sources = append(sources, "source_snapshot")
+	}
+	if c.SourceDisk != "" {
+		sources = append(sources, "source_disk")
+	}
+	if c.SourceMachineImage != "" {
+		sources = append(sources, "source_machine_image")
+	}
+
+	switch len(sources) {
+	case 0:
+		return []error{errors.New("a source_image, source_image_family, source_snapshot, source_disk or " +
+			"source_machine_image must be specified")}
+	case 1:
+	default:
+		return []error{fmt.Errorf("only one of source_image or source_image_family, source_snapshot, source_disk "+
+			"and source_machine_image can be specified, got %s", strings.Join(sources, ", "))}
+	}
+
+	var errs []error
+	if len(c.SourceImageProjectId) > 0 && c.sourceType() != sourceTypeImage {
+		errs = append(errs, errors.New("source_image_project_id only applies to source_image and source_image_family"))
+	}
+
+	if c.SourceDisk != "" {
+		if strings.Contains(c.SourceDisk, "/") {
+			errs = append(errs, errors.New("source_disk must be the name of a disk in the zone of the build"))
+		}
+		if len(c.FallbackZones) > 0 {
+			errs = append(errs, errors.New("fallback_zones cannot be used along with source_disk, "+
+				"as the disk can only be cloned in its own zone"))
+		}
+	}
+
+	if c.SourceMachineImage != "" && len(c.ExtraBlockDevices) > 0 {
+		errs = append(errs, errors.New("disk_attachment cannot be used along with source_machine_image, "+
+			"as the disks of the instance come from the machine image"))
+	}
+
+	return errs
+}
+
+// prepareArtifactType checks that the options set apply to the kind of
+// artifact to create.
+func (c *Config) prepareArtifactType() []error {
+	switch c.ArtifactType {
+	case "":
+		c.ArtifactType = ArtifactTypeImage
+	case ArtifactTypeImage, ArtifactTypeMachineImage, ArtifactTypeSnapshot:
+	default:
+		return []error{fmt.Errorf("artifact_type must be one of %s, %s or %s",
+			ArtifactTypeImage, ArtifactTypeMachineImage, ArtifactTypeSnapshot)}
+	}
+
+	if c.ArtifactType == ArtifactTypeImage {
+		return nil
+	}
+
+	var errs []error
+	var imageOnly []string
+	for option, set := range map[string]bool{
+		"image_architecture":            c.ImageArchitecture != "",
+		"image_family":                  c.ImageFamily != "",
+		"image_licenses":                len(c.ImageLicenses) > 0,
+		"image_iam_binding":             len(c.ImageIAMBindings) > 0,
+		"image_copy":                    len(c.ImageCopies) > 0,
+		"image_family_retention":        c.ImageFamilyRetention != nil,
+		"image_guest_os_features":       len(c.ImageGuestOsFeatures) > 0,
+		"image_signatures_db":           len(c.ImageSignaturesDB) > 0,
+		"image_platform_key":            c.ImagePlatformKey != "",
+		"image_key_exchange_key":        len(c.ImageKeyExchangeKey) > 0,
+		"image_forbidden_signatures_db": len(c.ImageForbiddenSignaturesDB) > 0,
+		"image_secure_boot_strict":      c.ImageSecureBootStrict,
+		"deprecate_at":                  c.DeprecateAt != "",
+		"obsolete_at":                   c.ObsoleteAt != "",
+		"delete_at":                     c.DeleteAt != "",
+	} {
+		if set {
+			imageOnly = append(imageOnly, option)
+		}
+	}
+	if len(imageOnly) > 0 {
+		slices.Sort(imageOnly)
+		errs = append(errs, fmt.Errorf("%s only apply to images, not to artifact_type %s",
+			strings.Join(imageOnly, ", "), c.ArtifactType))
+	}
+
+	if c.ArtifactType == ArtifactTypeMachineImage {
+		for _, bd := range c.ExtraBlockDevices {
+			if bd.CreateImage {
+				errs = append(errs, errors.New("create_image cannot be set on a disk_attachment when artifact_type is "+
+					"machine_image, as the machine image captures all the disks of the instance"))
+				break
+			}
+		}
+	}
+
+	return errs
+}
+
+// isPreemptible returns true if Compute Engine may preempt the instance.
+func (c *Config) isPreemptible() bool {
+	return c.Preemptible || c.ProvisioningModel == common.ProvisioningModelSpot
+}
+
+func (c *Config) prepareFallbackZones() []error {
+	var errs []error
+
+	if len(c.FallbackZones) == 0 {
+		return nil
+	}
+
+	// Storage pools are zonal, the disks cannot be created in other zones.
+	storagePool := c.DiskStoragePool != ""
+	for _, bd := range c.ExtraBlockDevices {
+		if bd.SourceVolume == "" && bd.StoragePool != "" {
+			storagePool = true
 		}
-		if !labelValueRegex.MatchString(value) {
-			errs = append(errs, fmt.Errorf("value %q for key %q must match regex %q", value, key, labelValueRegex))
+	}
+	if storagePool {
+		errs = append(errs, errors.New("fallback_zones cannot be used along with disk_storage_pool "+
+			"or the storage_pool of a disk_attachment, storage pools are zonal"))
+	}
+
+	region, _ := common.GetRegionFromZone(c.Zone)
+	// Any setting tied to the region of the zone pins the fallback zones to it.
+	sameRegion := c.Region != "" || c.Address != "" || c.NetworkIP != "" || strings.Contains(c.Subnetwork, "/") ||
+		len(c.DiskResourcePolicies) != 0
+	for _, ni := range c.NetworkInterfaces {
+		if ni.Address != "" || ni.NetworkIP != "" || strings.Contains(ni.Subnetwork, "/") {
+			sameRegion = true
+		}
+	}
+	for _, bd := range c.ExtraBlockDevices {
+		if bd.SourceVolume == "" && len(bd.ResourcePolicies) != 0 {
+			sameRegion = true
 		}
 	}
+	if c.Region != "" {
+		region = c.Region
+	}
+
+	seen := map[string]bool{c.Zone: true}
+	for _, zone := range c.FallbackZones {
+		if seen[zone] {
+			errs = append(errs, fmt.Errorf("fallback_zones: zone %q is listed more than once", zone))
+			continue
+		}
+		seen[zone] = true
+
+		zoneRegion, err := common.GetRegionFromZone(zone)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("fallback_zones: %s", err))
+			continue
+		}
+		if sameRegion && zoneRegion != region {
+			errs = append(errs, fmt.Errorf("fallback_zones: zone %q is not in region %q, "+
+				"which region, address, network_ip, a subnetwork URL or resource policies require", zone, region))
+		}
+		// Regional disks are kept rather than moved, the instance must be
+		// created in one of their replica zones.
+		for _, bd := range c.ExtraBlockDevices {
+			if bd.SourceVolume == "" && len(bd.ReplicaZones) != 0 && !slices.Contains(bd.ReplicaZones, zone) {
+				errs = append(errs, fmt.Errorf("fallback_zones: zone %q is not in the replica_zones of disk %q", zone, bd.DiskName))
+			}
+		}
+	}
+
 	return errs
 }
@@ -25,6 +25,9 @@ type FlatConfig struct {
 	CredentialsJSON              *string                           `mapstructure:"credentials_json" required:"false" cty:"credentials_json" hcl:"credentials_json"`
 	ImpersonateServiceAccount    *string                           `mapstructure:"impersonate_service_account" required:"false" cty:"impersonate_service_account" hcl:"impersonate_service_account"`
 	VaultGCPOauthEngine          *string                           `mapstructure:"vault_gcp_oauth_engine" cty:"vault_gcp_oauth_engine" hcl:"vault_gcp_oauth_engine"`
+	APIRetryAttempts             *int                              `mapstructure:"api_retry_attempts" required:"false" cty:"api_retry_attempts" hcl:"api_retry_attempts"`
+	APIRetryInitialBackoff       *string                           `mapstructure:"api_retry_initial_backoff" required:"false" cty:"api_retry_initial_backoff" hcl:"api_retry_initial_backoff"`
+	APIRetryMaxBackoff           *string                           `mapstructure:"api_retry_max_backoff" required:"false" cty:"api_retry_max_backoff" hcl:"api_retry_max_backoff"`
 	Type                         *string                           `mapstructure:"communicator" cty:"communicator" hcl:"communicator"`
 	PauseBeforeConnect           *string                           `mapstructure:"pause_before_connecting" cty:"pause_before_connecting" hcl:"pause_before_connecting"`
 	SSHHost                      *string                           `mapstructure:"ssh_host" cty:"ssh_host" hcl:"ssh_host"`
@@ -78,10 +81,16 @@ type FlatConfig struct {
 	AcceleratorType              *string                           `mapstructure:"accelerator_type" required:"false" cty:"accelerator_type" hcl:"accelerator_type"`
 	AcceleratorCount             *int64                            `mapstructure:"accelerator_count" required:"false" cty:"accelerator_count" hcl:"accelerator_count"`
 	Address                      *string                           `mapstructure:"address" required:"false" cty:"address" hcl:"address"`
+	ConfidentialInstanceType     *string                           `mapstructure:"confidential_instance_type" required:"false" cty:"confidential_instance_type" hcl:"confidential_instance_type"`
 	DisableDefaultServiceAccount *bool                             `mapstructure:"disable_default_service_account" required:"false" cty:"disable_default_service_account" hcl:"disable_default_service_account"`
 	DiskName                     *string                           `mapstructure:"disk_name" required:"false" cty:"disk_name" hcl:"disk_name"`
 	DiskSizeGb                   *int64                            `mapstructure:"disk_size" required:"false" cty:"disk_size" hcl:"disk_size"`
 	DiskType                     *string                           `mapstructure:"disk_type" required:"false" cty:"disk_type" hcl:"disk_type"`
+	DiskProvisionedIops          *int64                            `mapstructure:"disk_provisioned_iops" required:"false" cty:"disk_provisioned_iops" hcl:"disk_provisioned_iops"`
+	DiskProvisionedThroughput    *int64                            `mapstructure:"disk_provisioned_throughput" required:"false" cty:"disk_provisioned_throughput" hcl:"disk_provisioned_throughput"`
+	DiskLabels                   map[string]string                 `mapstructure:"disk_labels" required:"false" cty:"disk_labels" hcl:"disk_labels"`
+	DiskStoragePool              *string                           `mapstructure:"disk_storage_pool" required:"false" cty:"disk_storage_pool" hcl:"disk_storage_pool"`
+	DiskResourcePolicies         []string                          `mapstructure:"disk_resource_policies" required:"false" cty:"disk_resource_policies" hcl:"disk_resource_policies"`
 	DiskEncryptionKey            *common.FlatCustomerEncryptionKey `mapstructure:"disk_encryption_key" required:"false" cty:"disk_encryption_key" hcl:"disk_encryption_key"`
 	EnableNestedVirtualization   *bool                             `mapstructure:"enable_nested_virtualization" required:"false" cty:"enable_nested_virtualization" hcl:"enable_nested_virtualization"`
 	EnableSecureBoot             *bool                             `mapstructure:"enable_secure_boot" required:"false" cty:"enable_secure_boot" hcl:"enable_secure_boot"`
@@ -94,6 +103,7 @@ type FlatConfig struct {
 	IAPExt                       *string                           `mapstructure:"iap_ext" required:"false" cty:"iap_ext" hcl:"iap_ext"`
 	IAPTunnelLaunchWait          *int                              `mapstructure:"iap_tunnel_launch_wait" required:"false" cty:"iap_tunnel_launch_wait" hcl:"iap_tunnel_launch_wait"`
 	SkipCreateImage              *bool                             `mapstructure:"skip_create_image" required:"false" cty:"skip_create_image" hcl:"skip_create_image"`
+	ArtifactType                 *string                           `mapstructure:"artifact_type" required:"false" cty:"artifact_type" hcl:"artifact_type"`
 	ImageArchitecture            *string                           `mapstructure:"image_architecture" required:"false" cty:"image_architecture" hcl:"image_architecture"`
 	ImageName                    *string                           `mapstructure:"image_name" required:"false" cty:"image_name" hcl:"image_name"`
 	ImageDescription             *string                           `mapstructure:"image_description" required:"false" cty:"image_description" hcl:"image_description"`
@@ -101,48 +111,75 @@ type FlatConfig struct {
 	ImageFamily                  *string                           `mapstructure:"image_family" required:"false" cty:"image_family" hcl:"image_family"`
 	ImageLabels                  map[string]string                 `mapstructure:"image_labels" required:"false" cty:"image_labels" hcl:"image_labels"`
 	ImageLicenses                []string                          `mapstructure:"image_licenses" required:"false" cty:"image_licenses" hcl:"image_licenses"`
+	ImageIAMBindings             []common.FlatIAMBinding           `mapstructure:"image_iam_binding" required:"false" cty:"image_iam_binding" hcl:"image_iam_binding"`
 	ImageGuestOsFeatures         []string                          `mapstructure:"image_guest_os_features" required:"false" cty:"image_guest_os_features" hcl:"image_guest_os_features"`
 	ImageProjectId               *string                           `mapstructure:"image_project_id" required:"false" cty:"image_project_id" hcl:"image_project_id"`
 	ImageSignaturesDB            []string                          `mapstructure:"image_signatures_db" required:"false" cty:"image_signatures_db" hcl:"image_signatures_db"`
 	ImagePlatformKey             *string                           `mapstructure:"image_platform_key" required:"false" cty:"image_platform_key" hcl:"image_platform_key"`
 	ImageKeyExchangeKey          []string                          `mapstructure:"image_key_exchange_key" required:"false" cty:"image_key_exchange_key" hcl:"image_key_exchange_key"`
 	ImageForbiddenSignaturesDB   []string                          `mapstructure:"image_forbidden_signatures_db" required:"false" cty:"image_forbidden_signatures_db" hcl:"image_forbidden_signatures_db"`
+	ImageSecureBootStrict        *bool                             `mapstructure:"image_secure_boot_strict" required:"false" cty:"image_secure_boot_strict" hcl:"image_secure_boot_strict"`
 	ImageStorageLocations        []string                          `mapstructure:"image_storage_locations" required:"false" cty:"image_storage_locations" hcl:"image_storage_locations"`
+	ImageCopies                  []FlatImageCopy                   `mapstructure:"image_copy" required:"false" cty:"image_copy" hcl:"image_copy"`
+	ImageFamilyRetention         *FlatFamilyRetention              `mapstructure:"image_family_retention" required:"false" cty:"image_family_retention" hcl:"image_family_retention"`
 	InstanceName                 *string                           `mapstructure:"instance_name" required:"false" cty:"instance_name" hcl:"instance_name"`
 	Labels                       map[string]string                 `mapstructure:"labels" required:"false" cty:"labels" hcl:"labels"`
 	MachineType                  *string                           `mapstructure:"machine_type" required:"false" cty:"machine_type" hcl:"machine_type"`
 	Metadata                     map[string]string                 `mapstructure:"metadata" required:"false" cty:"metadata" hcl:"metadata"`
 	MetadataFiles                map[string]string                 `mapstructure:"metadata_files" cty:"metadata_files" hcl:"metadata_files"`
+	UserData                     *string                           `mapstructure:"user_data" required:"false" cty:"user_data" hcl:"user_data"`
+	UserDataFile                 *string                           `mapstructure:"user_data_file" required:"false" cty:"user_data_file" hcl:"user_data_file"`
 	MinCpuPlatform               *string                           `mapstructure:"min_cpu_platform" required:"false" cty:"min_cpu_platform" hcl:"min_cpu_platform"`
 	Network                      *string                           `mapstructure:"network" required:"false" cty:"network" hcl:"network"`
 	NetworkProjectId             *string                           `mapstructure:"network_project_id" required:"false" cty:"network_project_id" hcl:"network_project_id"`
+	NetworkInterfaces            []common.FlatNetworkInterface     `mapstructure:"network_interface" required:"false" cty:"network_interface" hcl:"network_interface"`
+	InstanceIPNetworkInterface   *int                              `mapstructure:"instance_ip_network_interface" required:"false" cty:"instance_ip_network_interface" hcl:"instance_ip_network_interface"`
 	OmitExternalIP               *bool                             `mapstructure:"omit_external_ip" required:"false" cty:"omit_external_ip" hcl:"omit_external_ip"`
+	StackType                    *string                           `mapstructure:"stack_type" required:"false" cty:"stack_type" hcl:"stack_type"`
+	ExternalIPv6                 *bool                             `mapstructure:"external_ipv6" required:"false" cty:"external_ipv6" hcl:"external_ipv6"`
 	OnHostMaintenance            *string                           `mapstructure:"on_host_maintenance" required:"false" cty:"on_host_maintenance" hcl:"on_host_maintenance"`
 	MaxRunDurationInSeconds      *int64                            `mapstructure:"max_run_duration_in_seconds" required:"false" cty:"max_run_duration_in_seconds" hcl:"max_run_duration_in_seconds"`
 	InstanceTerminationAction    *string                           `mapstructure:"instance_termination_action" required:"false" cty:"instance_termination_action" hcl:"instance_termination_action"`
 	Preemptible                  *bool                             `mapstructure:"preemptible" required:"false" cty:"preemptible" hcl:"preemptible"`
+	ProvisioningModel            *string                           `mapstructure:"provisioning_model" required:"false" cty:"provisioning_model" hcl:"provisioning_model"`
+	PreemptionAttempts           *int                              `mapstructure:"preemption_attempts" required:"false" cty:"preemption_attempts" hcl:"preemption_attempts"`
 	NodeAffinities               []common.FlatNodeAffinity         `mapstructure:"node_affinity" required:"false" cty:"node_affinity" hcl:"node_affinity"`
 	ReservationAffinity          *common.FlatReservationAffinity   `mapstructure:"reservation_affinity" required:"false" cty:"reservation_affinity" hcl:"reservation_affinity"`
 	StateTimeout                 *string                           `mapstructure:"state_timeout" required:"false" cty:"state_timeout" hcl:"state_timeout"`
+	ImageCreateTimeout           *string                           `mapstructure:"image_create_timeout" required:"false" cty:"image_create_timeout" hcl:"image_create_timeout"`
+	InstanceCreateTimeout        *string                           `mapstructure:"instance_create_timeout" required:"false" cty:"instance_create_timeout" hcl:"instance_create_timeout"`
+	DiskDeleteTimeout            *string                           `mapstructure:"disk_delete_timeout" required:"false" cty:"disk_delete_timeout" hcl:"disk_delete_timeout"`
 	Region                       *string                           `mapstructure:"region" required:"false" cty:"region" hcl:"region"`
 	Scopes                       []string                          `mapstructure:"scopes" required:"false" cty:"scopes" hcl:"scopes"`
 	ServiceAccountEmail          *string                           `mapstructure:"service_account_email" required:"false" cty:"service_account_email" hcl:"service_account_email"`
+	StreamSerialPortOutput       *bool                             `mapstructure:"stream_serial_port_output" required:"false" cty:"stream_serial_port_output" hcl:"stream_serial_port_output"`
+	SerialPortLogFile            *string                           `mapstructure:"serial_port_log_file" required:"false" cty:"serial_port_log_file" hcl:"serial_port_log_file"`
+	SerialPortTailLines          *int                              `mapstructure:"serial_port_tail_lines" required:"false" cty:"serial_port_tail_lines" hcl:"serial_port_tail_lines"`
 	SourceImage                  *string                           `mapstructure:"source_image" required:"true" cty:"source_image" hcl:"source_image"`
 	SourceImageFamily            *string                           `mapstructure:"source_image_family" required:"true" cty:"source_image_family" hcl:"source_image_family"`
 	SourceImageProjectId         []string                          `mapstructure:"source_image_project_id" required:"false" cty:"source_image_project_id" hcl:"source_image_project_id"`
+	SourceSnapshot               *string                           `mapstructure:"source_snapshot" required:"false" cty:"source_snapshot" hcl:"source_snapshot"`
+	SourceDisk                   *string                           `mapstructure:"source_disk" required:"false" cty:"source_disk" hcl:"source_disk"`
+	SourceMachineImage           *string                           `mapstructure:"source_machine_image" required:"false" cty:"source_machine_image" hcl:"source_machine_image"`
 	StartupScriptFile            *string                           `mapstructure:"startup_script_file" required:"false" cty:"startup_script_file" hcl:"startup_script_file"`
+	StartupScriptURL             *string                           `mapstructure:"startup_script_url" required:"false" cty:"startup_script_url" hcl:"startup_script_url"`
+	ShutdownScriptFile           *string                           `mapstructure:"shutdown_script_file" required:"false" cty:"shutdown_script_file" hcl:"shutdown_script_file"`
+	StartupScriptTimeout         *string                           `mapstructure:"startup_script_timeout" required:"false" cty:"startup_script_timeout" hcl:"startup_script_timeout"`
+	OnStartupScriptFailure       *string                           `mapstructure:"on_startup_script_failure" required:"false" cty:"on_startup_script_failure" hcl:"on_startup_script_failure"`
 	WindowsPasswordTimeout       *string                           `mapstructure:"windows_password_timeout" required:"false" cty:"windows_password_timeout" hcl:"windows_password_timeout"`
 	WrapStartupScriptFile        *bool                             `mapstructure:"wrap_startup_script" required:"false" cty:"wrap_startup_script" hcl:"wrap_startup_script"`
 	Subnetwork                   *string                           `mapstructure:"subnetwork" required:"false" cty:"subnetwork" hcl:"subnetwork"`
 	Tags                         []string                          `mapstructure:"tags" required:"false" cty:"tags" hcl:"tags"`
 	ResourceManagerTags          map[string]string                 `mapstructure:"resource_manager_tags" required:"false" cty:"resource_manager_tags" hcl:"resource_manager_tags"`
 	UseInternalIP                *bool                             `mapstructure:"use_internal_ip" required:"false" cty:"use_internal_ip" hcl:"use_internal_ip"`
+	UseIPv6                      *bool                             `mapstructure:"use_ipv6" required:"false" cty:"use_ipv6" hcl:"use_ipv6"`
 	UseOSLogin                   *bool                             `mapstructure:"use_os_login" required:"false" cty:"use_os_login" hcl:"use_os_login"`
 	NetworkIP                    *string                           `mapstructure:"network_ip" required:"false" cty:"network_ip" hcl:"network_ip"`
 	OSLoginSSHUsername           *string                           `mapstructure:"oslogin_ssh_username" required:"false" cty:"oslogin_ssh_username" hcl:"oslogin_ssh_username"`
 	OSLoginSSHKeyExpireAfter     *string                           `mapstructure:"oslogin_ssh_key_expire_after" required:"false" cty:"oslogin_ssh_key_expire_after" hcl:"oslogin_ssh_key_expire_after"`
 	WaitToAddSSHKeys             *string                           `mapstructure:"wait_to_add_ssh_keys" cty:"wait_to_add_ssh_keys" hcl:"wait_to_add_ssh_keys"`
 	Zone                         *string                           `mapstructure:"zone" required:"true" cty:"zone" hcl:"zone"`
+	FallbackZones                []string                          `mapstructure:"fallback_zones" required:"false" cty:"fallback_zones" hcl:"fallback_zones"`
 	DeprecateAt                  *string                           `mapstructure:"deprecate_at" required:"false" cty:"deprecate_at" hcl:"deprecate_at"`
 	ObsoleteAt                   *string                           `mapstructure:"obsolete_at" required:"false" cty:"obsolete_at" hcl:"obsolete_at"`
 	DeleteAt                     *string                           `mapstructure:"delete_at" required:"false" cty:"delete_at" hcl:"delete_at"`
@@ -176,6 +213,9 @@ func (*FlatConfig) HCL2Spec() map[string]hcldec.Spec {
 		"credentials_json":                &hcldec.AttrSpec{Name: "credentials_json", Type: cty.String, Required: false},
 		"impersonate_service_account":     &hcldec.AttrSpec{Name: "impersonate_service_account", Type: cty.String, Required: false},
 		"vault_gcp_oauth_engine":          &hcldec.AttrSpec{Name: "vault_gcp_oauth_engine", Type: cty.String, Required: false},
+		"api_retry_attempts":              &hcldec.AttrSpec{Name: "api_retry_attempts", Type: cty.Number, Required: false},
+		"api_retry_initial_backoff":       &hcldec.AttrSpec{Name: "api_retry_initial_backoff", Type: cty.String, Required: false},
+		"api_retry_max_backoff":           &hcldec.AttrSpec{Name: "api_retry_max_backoff", Type: cty.String, Required: false},
 		"communicator":                    &hcldec.AttrSpec{Name: "communicator", Type: cty.String, Required: false},
 		"pause_before_connecting":         &hcldec.AttrSpec{Name: "pause_before_connecting", Type: cty.String, Required: false},
 		"ssh_host":                        &hcldec.AttrSpec{Name: "ssh_host", Type: cty.String, Required: false},
@@ -229,10 +269,16 @@ func (*FlatConfig) HCL2Spec() map[string]hcldec.Spec {
 		"accelerator_type":                &hcldec.AttrSpec{Name: "accelerator_type", Type: cty.String, Required: false},
 		"accelerator_count":               &hcldec.AttrSpec{Name: "accelerator_count", Type: cty.Number, Required: false},
 		"address":                         &hcldec.AttrSpec{Name: "address", Type: cty.String, Required: false},
+		"confidential_instance_type":      &hcldec.AttrSpec{Name: "confidential_instance_type", Type: cty.String, Required: false},
 		"disable_default_service_account": &hcldec.AttrSpec{Name: "disable_default_service_account", Type: cty.Bool, Required: false},
 		"disk_name":                       &hcldec.AttrSpec{Name: "disk_name", Type: cty.String, Required: false},
 		"disk_size":                       &hcldec.AttrSpec{Name: "disk_size", Type: cty.Number, Required: false},
 		"disk_type":                       &hcldec.AttrSpec{Name: "disk_type", Type: cty.String, Required: false},
+		"disk_provisioned_iops":           &hcldec.AttrSpec{Name: "disk_provisioned_iops", Type: cty.Number, Required: false},
+		"disk_provisioned_throughput":     &hcldec.AttrSpec{Name: "disk_provisioned_throughput", Type: cty.Number, Required: false},
+		"disk_labels":                     &hcldec.AttrSpec{Name: "disk_labels", Type: cty.Map(cty.String), Required: false},
+		"disk_storage_pool":               &hcldec.AttrSpec{Name: "disk_storage_pool", Type: cty.String, Required: false},
+		"disk_resource_policies":          &hcldec.AttrSpec{Name: "disk_resource_policies", Type: cty.List(cty.String), Required: false},
 		"disk_encryption_key":             &hcldec.BlockSpec{TypeName: "disk_encryption_key", Nested: hcldec.ObjectSpec((*common.FlatCustomerEncryptionKey)(nil).HCL2Spec())},
 		"enable_nested_virtualization":    &hcldec.AttrSpec{Name: "enable_nested_virtualization", Type: cty.Bool, Required: false},
 		"enable_secure_boot":              &hcldec.AttrSpec{Name: "enable_secure_boot", Type: cty.Bool, Required: false},
@@ -245,6 +291,7 @@ func (*FlatConfig) HCL2Spec() map[string]hcldec.Spec {
 		"iap_ext":                         &hcldec.AttrSpec{Name: "iap_ext", Type: cty.String, Required: false},
 		"iap_tunnel_launch_wait":          &hcldec.AttrSpec{Name: "iap_tunnel_launch_wait", Type: cty.Number, Required: false},
 		"skip_create_image":               &hcldec.AttrSpec{Name: "skip_create_image", Type: cty.Bool, Required: false},
+		"artifact_type":                   &hcldec.AttrSpec{Name: "artifact_type", Type: cty.String, Required: false},
 		"image_architecture":              &hcldec.AttrSpec{Name: "image_architecture", Type: cty.String, Required: false},
 		"image_name":                      &hcldec.AttrSpec{Name: "image_name", Type: cty.String, Required: false},
 		"image_description":               &hcldec.AttrSpec{Name: "image_description", Type: cty.String, Required: false},
@@ -252,48 +299,75 @@ func (*FlatConfig) HCL2Spec() map[string]hcldec.Spec {
 		"image_family":                    &hcldec.AttrSpec{Name: "image_family", Type: cty.String, Required: false},
 		"image_labels":                    &hcldec.AttrSpec{Name: "image_labels", Type: cty.Map(cty.String), Required: false},
 		"image_licenses":                  &hcldec.AttrSpec{Name: "image_licenses", Type: cty.List(cty.String), Required: false},
+		"image_iam_binding":               &hcldec.BlockListSpec{TypeName: "image_iam_binding", Nested: hcldec.ObjectSpec((*common.FlatIAMBinding)(nil).HCL2Spec())},
 		"image_guest_os_features":         &hcldec.AttrSpec{Name: "image_guest_os_features", Type: cty.List(cty.String), Required: false},
 		"image_project_id":                &hcldec.AttrSpec{Name: "image_project_id", Type: cty.String, Required: false},
 		"image_signatures_db":             &hcldec.AttrSpec{Name: "image_signatures_db", Type: cty.List(cty.String), Required: false},
 		"image_platform_key":              &hcldec.AttrSpec{Name: "image_platform_key", Type: cty.String, Required: false},
 		"image_key_exchange_key":          &hcldec.AttrSpec{Name: "image_key_exchange_key", Type: cty.List(cty.String), Required: false},
 		"image_forbidden_signatures_db":   &hcldec.AttrSpec{Name: "image_forbidden_signatures_db", Type: cty.List(cty.String), Required: false},
+		"image_secure_boot_strict":        &hcldec.AttrSpec{Name: "image_secure_boot_strict", Type: cty.Bool, Required: false},
 		"image_storage_locations":         &hcldec.AttrSpec{Name: "image_storage_locations", Type: cty.List(cty.String), Required: false},
+		"image_copy":                      &hcldec.BlockListSpec{TypeName: "image_copy", Nested: hcldec.ObjectSpec((*FlatImageCopy)(nil).HCL2Spec())},
+		"image_family_retention":          &hcldec.BlockSpec{TypeName: "image_family_retention", Nested: hcldec.ObjectSpec((*FlatFamilyRetention)(nil).HCL2Spec())},
 		"instance_name":                   &hcldec.AttrSpec{Name: "instance_name", Type: cty.String, Required: false},
 		"labels":                          &hcldec.AttrSpec{Name: "labels", Type: cty.Map(cty.String), Required: false},
 		"machine_type":                    &hcldec.AttrSpec{Name: "machine_type", Type: cty.String, Required: false},
 		"metadata":                        &hcldec.AttrSpec{Name: "metadata", Type: cty.Map(cty.String), Required: false},
 		"metadata_files":                  &hcldec.AttrSpec{Name: "metadata_files", Type: cty.Map(cty.String), Required: false},
+		"user_data":                       &hcldec.AttrSpec{Name: "user_data", Type: cty.String, Required: false},
+		"user_data_file":                  &hcldec.AttrSpec{Name: "user_data_file", Type: cty.String, Required: false},
 		"min_cpu_platform":                &hcldec.AttrSpec{Name: "min_cpu_platform", Type: cty.String, Required: false},
 		"network":                         &hcldec.AttrSpec{Name: "network", Type: cty.String, Required: false},
 		"network_project_id":              &hcldec.AttrSpec{Name: "network_project_id", Type: cty.String, Required: false},
+		"network_interface":               &hcldec.BlockListSpec{TypeName: "network_interface", Nested: hcldec.ObjectSpec((*common.FlatNetworkInterface)(nil).HCL2Spec())},
+		"instance_ip_network_interface":   &hcldec.AttrSpec{Name: "instance_ip_network_interface", Type: cty.Number, Required: false},
 		"omit_external_ip":                &hcldec.AttrSpec{Name: "omit_external_ip", Type: cty.Bool, Required: false},
+		"stack_type":                      &hcldec.AttrSpec{Name: "stack_type", Type: cty.String, Required: false},
+		"external_ipv6":                   &hcldec.AttrSpec{Name: "external_ipv6", Type: cty.Bool, Required: false},
 		"on_host_maintenance":             &hcldec.AttrSpec{Name: "on_host_maintenance", Type: cty.String, Required: false},
 		"max_run_duration_in_seconds":     &hcldec.AttrSpec{Name: "max_run_duration_in_seconds", Type: cty.Number, Required: false},
 		"instance_termination_action":     &hcldec.AttrSpec{Name: "instance_termination_action", Type: cty.String, Required: false},
 		"preemptible":                     &hcldec.AttrSpec{Name: "preemptible", Type: cty.Bool, Required: false},
+		"provisioning_model":              &hcldec.AttrSpec{Name: "provisioning_model", Type: cty.String, Required: false},
+		"preemption_attempts":             &hcldec.AttrSpec{Name: "preemption_attempts", Type: cty.Number, Required: false},
 		"node_affinity":                   &hcldec.BlockListSpec{TypeName: "node_affinity", Nested: hcldec.ObjectSpec((*common.FlatNodeAffinity)(nil).HCL2Spec())},
 		"reservation_affinity":            &hcldec.BlockSpec{TypeName: "reservation_affinity", Nested: hcldec.ObjectSpec((*common.FlatReservationAffinity)(nil).HCL2Spec())},
 		"state_timeout":                   &hcldec.AttrSpec{Name: "state_timeout", Type: cty.String, Required: false},
+		"image_create_timeout":            &hcldec.AttrSpec{Name: "image_create_timeout", Type: cty.String, Required: false},
+		"instance_create_timeout":         &hcldec.AttrSpec{Name: "instance_create_timeout", Type: cty.String, Required: false},
+		"disk_delete_timeout":             &hcldec.AttrSpec{Name: "disk_delete_timeout", Type: cty.String, Required: false},
 		"region":                          &hcldec.AttrSpec{Name: "region", Type: cty.String, Required: false},
 		"scopes":                          &hcldec.AttrSpec{Name: "scopes", Type: cty.List(cty.String), Required: false},
 		"service_account_email":           &hcldec.AttrSpec{Name: "service_account_email", Type: cty.String, Required: false},
+		"stream_serial_port_output":       &hcldec.AttrSpec{Name: "stream_serial_port_output", Type: cty.Bool, Required: false},
+		"serial_port_log_file":            &hcldec.AttrSpec{Name: "serial_port_log_file", Type: cty.String, Required: false},
+		"serial_port_tail_lines":          &hcldec.AttrSpec{Name: "serial_port_tail_lines", Type: cty.Number, Required: false},
 		"source_image":                    &hcldec.AttrSpec{Name: "source_image", Type: cty.String, Required: false},
 		"source_image_family":             &hcldec.AttrSpec{Name: "source_image_family", Type: cty.String, Required: false},
 		"source_image_project_id":         &hcldec.AttrSpec{Name: "source_image_project_id", Type: cty.List(cty.String), Required: false},
+		"source_snapshot":                 &hcldec.AttrSpec{Name: "source_snapshot", Type: cty.String, Required: false},
+		"source_disk":                     &hcldec.AttrSpec{Name: "source_disk", Type: cty.String, Required: false},
+		"source_machine_image":            &hcldec.AttrSpec{Name: "source_machine_image", Type: cty.String, Required: false},
 		"startup_script_file":             &hcldec.AttrSpec{Name: "startup_script_file", Type: cty.String, Required: false},
+		"startup_script_url":              &hcldec.AttrSpec{Name: "startup_script_url", Type: cty.String, Required: false},
+		"shutdown_script_file":            &hcldec.AttrSpec{Name: "shutdown_script_file", Type: cty.String, Required: false},
+		"startup_script_timeout":          &hcldec.AttrSpec{Name: "startup_script_timeout", Type: cty.String, Required: false},
+		"on_startup_script_failure":       &hcldec.AttrSpec{Name: "on_startup_script_failure", Type: cty.String, Required: false},
 		"windows_password_timeout":        &hcldec.AttrSpec{Name: "windows_password_timeout", Type: cty.String, Required: false},
 		"wrap_startup_script":             &hcldec.AttrSpec{Name: "wrap_startup_script", Type: cty.Bool, Required: false},
 		"subnetwork":                      &hcldec.AttrSpec{Name: "subnetwork", Type: cty.String, Required: false},
 		"tags":                            &hcldec.AttrSpec{Name: "tags", Type: cty.List(cty.String), Required: false},
 		"resource_manager_tags":           &hcldec.AttrSpec{Name: "resource_manager_tags", Type: cty.Map(cty.String), Required: false},
 		"use_internal_ip":                 &hcldec.AttrSpec{Name: "use_internal_ip", Type: cty.Bool, Required: false},
+		"use_ipv6":                        &hcldec.AttrSpec{Name: "use_ipv6", Type: cty.Bool, Required: false},
 		"use_os_login":                    &hcldec.AttrSpec{Name: "use_os_login", Type: cty.Bool, Required: false},
 		"network_ip":                      &hcldec.AttrSpec{Name: "network_ip", Type: cty.String, Required: false},
 		"oslogin_ssh_username":            &hcldec.AttrSpec{Name: "oslogin_ssh_username", Type: cty.String, Required: false},
 		"oslogin_ssh_key_expire_after":    &hcldec.AttrSpec{Name: "oslogin_ssh_key_expire_after", Type: cty.String, Required: false},
 		"wait_to_add_ssh_keys":            &hcldec.AttrSpec{Name: "wait_to_add_ssh_keys", Type: cty.String, Required: false},
 		"zone":                            &hcldec.AttrSpec{Name: "zone", Type: cty.String, Required: false},
+		"fallback_zones":                  &hcldec.AttrSpec{Name: "fallback_zones", Type: cty.List(cty.String), Required: false},
 		"deprecate_at":                    &hcldec.AttrSpec{Name: "deprecate_at", Type: cty.String, Required: false},
 		"obsolete_at":                     &hcldec.AttrSpec{Name: "obsolete_at", Type: cty.String, Required: false},
 		"delete_at":                       &hcldec.AttrSpec{Name: "delete_at", Type: cty.String, Required: false},
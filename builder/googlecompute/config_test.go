@@ -6,10 +6,12 @@ package googlecompute
 import (
 	"fmt"
 	"os"
-	"runtime"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 
+	"github.com/hashicorp/packer-plugin-googlecompute/lib/common"
 	"github.com/hashicorp/packer-plugin-sdk/communicator"
 )
 
@@ -106,6 +108,81 @@ func TestConfigPrepare(t *testing.T) {
 			"5s",
 			false,
 		},
+		{
+			"api_retry_attempts",
+			3,
+			false,
+		},
+		{
+			"api_retry_attempts",
+			-1,
+			true,
+		},
+		{
+			"api_retry_initial_backoff",
+			"2s",
+			false,
+		},
+		{
+			"api_retry_max_backoff",
+			"-1s",
+			true,
+		},
+		{
+			"image_create_timeout",
+			"1h",
+			false,
+		},
+		{
+			"image_create_timeout",
+			"-1m",
+			true,
+		},
+		{
+			"instance_create_timeout",
+			"10m",
+			false,
+		},
+		{
+			"disk_delete_timeout",
+			"-1m",
+			true,
+		},
+		{
+			"startup_script_timeout",
+			"30m",
+			false,
+		},
+		{
+			"startup_script_timeout",
+			"-5m",
+			true,
+		},
+		{
+			"on_startup_script_failure",
+			"continue",
+			false,
+		},
+		{
+			"startup_script_url",
+			"gs://bucket/setup.sh",
+			false,
+		},
+		{
+			"startup_script_url",
+			"https://example.com/setup.sh",
+			true,
+		},
+		{
+			"shutdown_script_file",
+			"/tmp/i/should/not/exist",
+			true,
+		},
+		{
+			"on_startup_script_failure",
+			"ignore",
+			true,
+		},
 		{
 			"use_internal_ip",
 			nil,
@@ -151,6 +228,31 @@ func TestConfigPrepare(t *testing.T) {
 			"SO VERY BAD",
 			true,
 		},
+		{
+			"provisioning_model",
+			"SPOT",
+			false,
+		},
+		{
+			"provisioning_model",
+			"STANDARD",
+			false,
+		},
+		{
+			"provisioning_model",
+			"SO VERY BAD",
+			true,
+		},
+		{
+			"preemption_attempts",
+			5,
+			false,
+		},
+		{
+			"preemption_attempts",
+			-1,
+			true,
+		},
 		{
 			"node_affinity",
 			nil,
@@ -310,19 +412,1081 @@ func TestConfigPrepareAccelerator(t *testing.T) {
 			false,
 		},
 		{
-			[]string{"accelerator_count", "on_host_maintenance", "accelerator_type"},
-			[]interface{}{1, "TERMINATE", nil},
+			[]string{"accelerator_count", "on_host_maintenance", "accelerator_type"},
+			[]interface{}{1, "TERMINATE", nil},
+			true,
+		},
+		{
+			[]string{"accelerator_count", "on_host_maintenance", "accelerator_type"},
+			[]interface{}{1, "TERMINATE", ""},
+			true,
+		},
+		{
+			[]string{"accelerator_count", "on_host_maintenance", "accelerator_type"},
+			[]interface{}{1, "TERMINATE", "something_valid"},
+			false,
+		},
+	}
+
+	for _, tc := range cases {
+		raw, tempfile := testConfig(t)
+		defer os.Remove(tempfile)
+
+		errStr := ""
+		for k := range tc.Keys {
+
+			// Create the string for error reporting
+			// convert value to string if it can be converted
+			errStr += fmt.Sprintf("%s:%v, ", tc.Keys[k], tc.Values[k])
+			if tc.Values[k] == nil {
+				delete(raw, tc.Keys[k])
+			} else {
+				raw[tc.Keys[k]] = tc.Values[k]
+			}
+		}
+
+		var c Config
+		warns, errs := c.Prepare(raw)
+
+		if tc.Err {
+			testConfigErr(t, warns, errs, strings.TrimRight(errStr, ", "))
+		} else {
+			testConfigOk(t, warns, errs)
+		}
+	}
+}
+
+func TestConfigPrepareServiceAccount(t *testing.T) {
+	cases := []struct {
+		Keys   []string
+		Values []interface{}
+		Err    bool
+	}{
+		{
+			[]string{"disable_default_service_account", "service_account_email"},
+			[]interface{}{true, "service@account.email.com"},
+			true,
+		},
+		{
+			[]string{"disable_default_service_account", "service_account_email"},
+			[]interface{}{false, "service@account.email.com"},
+			false,
+		},
+		{
+			[]string{"disable_default_service_account", "service_account_email"},
+			[]interface{}{true, ""},
+			false,
+		},
+	}
+
+	for _, tc := range cases {
+		raw, tempfile := testConfig(t)
+		defer os.Remove(tempfile)
+
+		errStr := ""
+		for k := range tc.Keys {
+
+			// Create the string for error reporting
+			// convert value to string if it can be converted
+			errStr += fmt.Sprintf("%s:%v, ", tc.Keys[k], tc.Values[k])
+			if tc.Values[k] == nil {
+				delete(raw, tc.Keys[k])
+			} else {
+				raw[tc.Keys[k]] = tc.Values[k]
+			}
+		}
+
+		var c Config
+		warns, errs := c.Prepare(raw)
+
+		if tc.Err {
+			testConfigErr(t, warns, errs, strings.TrimRight(errStr, ", "))
+		} else {
+			testConfigOk(t, warns, errs)
+		}
+	}
+}
+
+func TestConfigPrepareStartupScriptFile(t *testing.T) {
+	config := map[string]interface{}{
+		"project_id":          "project",
+		"source_image":        "foo",
+		"ssh_username":        "packer",
+		"startup_script_file": "no-such-file",
+		"zone":                "us-central1-a",
+	}
+
+	var c Config
+	_, errs := c.Prepare(config)
+
+	if errs == nil || !strings.Contains(errs.Error(), "startup_script_file") {
+		t.Fatalf("should error: startup_script_file")
+	}
+}
+
+func TestConfigPrepareIAP_SSH(t *testing.T) {
+	config := map[string]interface{}{
+		"project_id":   "project",
+		"source_image": "foo",
+		"ssh_username": "packer",
+		"zone":         "us-central1-a",
+		"communicator": "ssh",
+		"use_iap":      true,
+	}
+
+	var c Config
+	_, err := c.Prepare(config)
+	if err != nil {
+		t.Fatalf("Shouldn't have errors. Err = %s", err)
+	}
+	if c.Comm.SSHHost != "localhost" {
+		t.Fatalf("Should have set SSHHost")
+	}
+	if c.IAPTunnelLaunchWait != 30 {
+		t.Fatalf("IAP tunnel launch wait didn't default correctly to 30 seconds.")
+	}
+}
+
+func TestConfigPrepareIAP_WinRM(t *testing.T) {
+	config := map[string]interface{}{
+		"project_id":     "project",
+		"source_image":   "foo",
+		"winrm_username": "packer",
+		"zone":           "us-central1-a",
+		"communicator":   "winrm",
+		"use_iap":        true,
+	}
+
+	var c Config
+	_, err := c.Prepare(config)
+	if err != nil {
+		t.Fatalf("Shouldn't have errors. Err = %s", err)
+	}
+	if c.Comm.WinRMHost != "localhost" {
+		t.Fatalf("Should have set WinRMHost")
+	}
+	if c.IAPTunnelLaunchWait != 40 {
+		t.Fatalf("IAP tunnel launch wait didn't default correctly to 40 seconds.")
+	}
+}
+
+func TestConfigPrepareIAP_failures(t *testing.T) {
+	config := map[string]interface{}{
+		"project_id":     "project",
+		"source_image":   "foo",
+		"winrm_username": "packer",
+		"zone":           "us-central1-a",
+		"communicator":   "none",
+		"iap_hashbang":   "/bin/bash",
+		"iap_ext":        ".ps1",
+		"use_iap":        true,
+	}
+
+	var c Config
+	warns, errs := c.Prepare(config)
+	if errs == nil {
+		t.Fatalf("Should have errored because we're using none.")
+	}
+	if len(warns) == 0 || !strings.Contains(strings.Join(warns, "\n"), "iap_hashbang") {
+		t.Fatalf("Should have warned about the deprecated iap_hashbang and iap_ext.")
+	}
+	if c.IAPHashBang != "/bin/bash" {
+		t.Fatalf("IAP hashbang defaulted even though set.")
+	}
+	if c.IAPExt != ".ps1" {
+		t.Fatalf("IAP tempfile defaulted even though set.")
+	}
+}
+
+func TestConfigDefaults(t *testing.T) {
+	cases := []struct {
+		Read  func(c *Config) interface{}
+		Value interface{}
+	}{
+		{
+			func(c *Config) interface{} { return c.Comm.Type },
+			"ssh",
+		},
+
+		{
+			func(c *Config) interface{} { return c.Comm.SSHPort },
+			22,
+		},
+	}
+
+	for _, tc := range cases {
+		raw, tempfile := testConfig(t)
+		defer os.Remove(tempfile)
+
+		var c Config
+		warns, errs := c.Prepare(raw)
+		testConfigOk(t, warns, errs)
+
+		actual := tc.Read(&c)
+		if actual != tc.Value {
+			t.Fatalf("bad: %#v", actual)
+		}
+	}
+}
+
+func TestImageName(t *testing.T) {
+	raw, tempfile := testConfig(t)
+	defer os.Remove(tempfile)
+
+	var c Config
+	_, err := c.Prepare(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(c.ImageName, "packer-") {
+		t.Fatalf("ImageName should have 'packer-' prefix, found %s", c.ImageName)
+	}
+	if strings.Contains(c.ImageName, "{{timestamp}}") {
+		t.Errorf("ImageName should be interpolated; found %s", c.ImageName)
+	}
+}
+
+func TestRegion(t *testing.T) {
+	raw, tempfile := testConfig(t)
+	defer func() {
+		err := os.Remove(tempfile)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	var c Config
+	_, err := c.Prepare(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Region != "us-east1" {
+		t.Fatalf("Region should be 'us-east1' given Zone of 'us-east1-a', but is %s", c.Region)
+	}
+}
+
+func TestConfigPrepareSpot(t *testing.T) {
+	raw, tempfile := testConfig(t)
+	defer os.Remove(tempfile)
+	raw["provisioning_model"] = "SPOT"
+
+	var c Config
+	warns, errs := c.Prepare(raw)
+	testConfigOk(t, warns, errs)
+	if c.OnHostMaintenance != "TERMINATE" {
+		t.Errorf("Spot instances should default to on_host_maintenance TERMINATE, got %q", c.OnHostMaintenance)
+	}
+	if c.PreemptionAttempts != 3 {
+		t.Errorf("preemption_attempts should default to 3, got %d", c.PreemptionAttempts)
+	}
+
+	raw["on_host_maintenance"] = "MIGRATE"
+	c = Config{}
+	warns, errs = c.Prepare(raw)
+	testConfigErr(t, warns, errs, "Spot instances cannot use on_host_maintenance MIGRATE")
+
+	delete(raw, "on_host_maintenance")
+	raw["provisioning_model"] = "STANDARD"
+	raw["preemptible"] = true
+	c = Config{}
+	warns, errs = c.Prepare(raw)
+	testConfigErr(t, warns, errs, "preemptible instances cannot use the STANDARD provisioning_model")
+}
+
+func TestConfigPrepareConfidentialInstanceType(t *testing.T) {
+	cases := []struct {
+		Keys   []string
+		Values []interface{}
+		Err    bool
+	}{
+		{
+			[]string{"confidential_instance_type", "machine_type"},
+			[]interface{}{"SEV", "n2d-standard-2"},
+			false,
+		},
+		{
+			[]string{"confidential_instance_type", "machine_type", "on_host_maintenance"},
+			[]interface{}{"SEV", "n2d-standard-2", "MIGRATE"},
+			false,
+		},
+		{
+			[]string{"confidential_instance_type", "machine_type", "on_host_maintenance"},
+			[]interface{}{"SEV", "c2d-standard-2", "MIGRATE"},
+			true,
+		},
+		{
+			[]string{"confidential_instance_type", "machine_type"},
+			[]interface{}{"SEV_SNP", "n2d-custom-4-8192"},
+			false,
+		},
+		{
+			[]string{"confidential_instance_type", "machine_type"},
+			[]interface{}{"SEV_SNP", "c2d-standard-2"},
+			true,
+		},
+		{
+			[]string{"confidential_instance_type", "machine_type"},
+			[]interface{}{"TDX", "c3-standard-4"},
+			false,
+		},
+		{
+			[]string{"confidential_instance_type", "machine_type", "on_host_maintenance"},
+			[]interface{}{"TDX", "c3-standard-4", "MIGRATE"},
+			true,
+		},
+		{
+			[]string{"confidential_instance_type", "machine_type"},
+			[]interface{}{"TDX", "n2d-standard-2"},
+			true,
+		},
+		{
+			[]string{"confidential_instance_type"},
+			[]interface{}{"TDX"},
+			true,
+		},
+		{
+			[]string{"confidential_instance_type", "machine_type"},
+			[]interface{}{"SO VERY BAD", "n2d-standard-2"},
+			true,
+		},
+	}
+
+	for _, tc := range cases {
+		raw, tempfile := testConfig(t)
+		defer os.Remove(tempfile)
+
+		errStr := ""
+		for k := range tc.Keys {
+			errStr += fmt.Sprintf("%s:%v, ", tc.Keys[k], tc.Values[k])
+			raw[tc.Keys[k]] = tc.Values[k]
+		}
+
+		var c Config
+		warns, errs := c.Prepare(raw)
+
+		if tc.Err {
+			testConfigErr(t, warns, errs, strings.TrimRight(errStr, ", "))
+			continue
+		}
+		testConfigOk(t, warns, errs)
+		if _, set := raw["on_host_maintenance"]; !set &&
+			!common.ConfidentialInstanceCanMigrate(c.ConfidentialInstanceType, c.MachineType) &&
+			c.OnHostMaintenance != "TERMINATE" {
+			t.Errorf("%s: on_host_maintenance should default to TERMINATE, got %q", errStr, c.OnHostMaintenance)
+		}
+	}
+}
+
+func TestConfigPrepareFallbackZones(t *testing.T) {
+	cases := []struct {
+		Keys   []string
+		Values []interface{}
+		Err    bool
+	}{
+		{
+			[]string{"fallback_zones"},
+			[]interface{}{[]string{"us-east1-b", "us-east4-a"}},
+			false,
+		},
+		{
+			[]string{"fallback_zones"},
+			[]interface{}{[]string{"us-east1-b", "us-east1-b"}},
+			true,
+		},
+		{
+			[]string{"fallback_zones"},
+			[]interface{}{[]string{"us-east1-a"}},
+			true,
+		},
+		{
+			[]string{"fallback_zones"},
+			[]interface{}{[]string{"not a zone"}},
+			true,
+		},
+		{
+			[]string{"fallback_zones", "region"},
+			[]interface{}{[]string{"us-east1-b"}, "us-east1"},
+			false,
+		},
+		{
+			[]string{"fallback_zones", "region"},
+			[]interface{}{[]string{"us-east4-a"}, "us-east1"},
+			true,
+		},
+		{
+			[]string{"fallback_zones", "address"},
+			[]interface{}{[]string{"us-east4-a"}, "my-address"},
+			true,
+		},
+		{
+			[]string{"fallback_zones", "network_ip"},
+			[]interface{}{[]string{"us-east4-a"}, "10.0.0.2"},
+			true,
+		},
+		{
+			[]string{"fallback_zones", "subnetwork"},
+			[]interface{}{[]string{"us-east4-a"}, "my-subnet"},
+			false,
+		},
+		{
+			[]string{"fallback_zones", "subnetwork"},
+			[]interface{}{[]string{"us-east4-a"}, "projects/hashicorp/regions/us-east1/subnetworks/my-subnet"},
+			true,
+		},
+		{
+			[]string{"fallback_zones", "disk_attachment"},
+			[]interface{}{[]string{"us-east1-b"}, []map[string]interface{}{
+				{"volume_type": "pd-ssd", "volume_size": 10, "replica_zones": []string{"us-east1-b"}},
+			}},
+			false,
+		},
+		{
+			[]string{"fallback_zones", "disk_storage_pool"},
+			[]interface{}{[]string{"us-east1-b"}, "my-pool"},
+			true,
+		},
+		{
+			[]string{"fallback_zones", "disk_attachment"},
+			[]interface{}{[]string{"us-east1-b"}, []map[string]interface{}{
+				{"volume_type": "hyperdisk-balanced", "volume_size": 10, "storage_pool": "my-pool"},
+			}},
+			true,
+		},
+		{
+			[]string{"fallback_zones", "disk_resource_policies"},
+			[]interface{}{[]string{"us-east1-b"}, []string{"my-schedule"}},
+			false,
+		},
+		{
+			[]string{"fallback_zones", "disk_resource_policies"},
+			[]interface{}{[]string{"us-east4-a"}, []string{"my-schedule"}},
+			true,
+		},
+		{
+			[]string{"fallback_zones", "disk_attachment"},
+			[]interface{}{[]string{"us-east4-a"}, []map[string]interface{}{
+				{"volume_type": "pd-ssd", "volume_size": 10, "resource_policies": []string{"my-schedule"}},
+			}},
+			true,
+		},
+		{
+			// The regional disk has no replica in the fallback zone.
+			[]string{"fallback_zones", "disk_attachment"},
+			[]interface{}{[]string{"us-east1-c"}, []map[string]interface{}{
+				{"volume_type": "pd-ssd", "volume_size": 10, "replica_zones": []string{"us-east1-b"}},
+			}},
+			true,
+		},
+	}
+
+	for _, tc := range cases {
+		raw, tempfile := testConfig(t)
+		defer os.Remove(tempfile)
+
+		errStr := ""
+		for k := range tc.Keys {
+			errStr += fmt.Sprintf("%s:%v, ", tc.Keys[k], tc.Values[k])
+			raw[tc.Keys[k]] = tc.Values[k]
+		}
+
+		var c Config
+		warns, errs := c.Prepare(raw)
+
+		if tc.Err {
+			testConfigErr(t, warns, errs, strings.TrimRight(errStr, ", "))
+		} else {
+			testConfigOk(t, warns, errs)
+		}
+	}
+}
+
+func TestConfigPrepareNetworkInterfaces(t *testing.T) {
+	nic := func(network string, externalIP bool) map[string]interface{} {
+		return map[string]interface{}{"network": network, "external_ip": externalIP}
+	}
+
+	cases := []struct {
+		Keys   []string
+		Values []interface{}
+		Err    bool
+	}{
+		{
+			[]string{"network_interface"},
+			[]interface{}{[]map[string]interface{}{nic("default", true), nic("other", false)}},
+			false,
+		},
+		{
+			[]string{"network_interface", "instance_ip_network_interface"},
+			[]interface{}{[]map[string]interface{}{nic("default", true), nic("other", false)}, 1},
+			true,
+		},
+		{
+			[]string{"network_interface", "instance_ip_network_interface", "use_internal_ip"},
+			[]interface{}{[]map[string]interface{}{nic("default", true), nic("other", false)}, 1, true},
+			false,
+		},
+		{
+			[]string{"network_interface", "instance_ip_network_interface"},
+			[]interface{}{[]map[string]interface{}{nic("default", true)}, 1},
+			true,
+		},
+		{
+			[]string{"instance_ip_network_interface"},
+			[]interface{}{1},
+			true,
+		},
+		{
+			[]string{"network_interface", "network"},
+			[]interface{}{[]map[string]interface{}{nic("default", true)}, "default"},
+			true,
+		},
+		{
+			[]string{"network_interface", "omit_external_ip", "use_internal_ip"},
+			[]interface{}{[]map[string]interface{}{nic("default", false)}, true, true},
+			true,
+		},
+		{
+			[]string{"network_interface"},
+			[]interface{}{[]map[string]interface{}{{"external_ip": true}}},
+			true,
+		},
+	}
+
+	for _, tc := range cases {
+		raw, tempfile := testConfig(t)
+		defer os.Remove(tempfile)
+
+		errStr := ""
+		for k := range tc.Keys {
+			errStr += fmt.Sprintf("%s:%v, ", tc.Keys[k], tc.Values[k])
+			raw[tc.Keys[k]] = tc.Values[k]
+		}
+
+		var c Config
+		warns, errs := c.Prepare(raw)
+
+		if tc.Err {
+			testConfigErr(t, warns, errs, strings.TrimRight(errStr, ", "))
+		} else {
+			testConfigOk(t, warns, errs)
+		}
+	}
+}
+
+func TestConfigPrepareNetworkInterfaces_defaults(t *testing.T) {
+	raw, tempfile := testConfig(t)
+	defer os.Remove(tempfile)
+
+	raw["network_interface"] = []map[string]interface{}{
+		{"network": "default", "external_ip": true},
+		{"subnetwork": "shared-subnet", "network_project_id": "host-project"},
+	}
+
+	var c Config
+	warns, errs := c.Prepare(raw)
+	testConfigOk(t, warns, errs)
+
+	if c.Network != "" {
+		t.Errorf("network should not default along with network_interface blocks, got %q", c.Network)
+	}
+	if got := c.NetworkInterfaces[0].NetworkProjectId; got != "hashicorp" {
+		t.Errorf("expected the first interface to default to the project, got %q", got)
+	}
+	if got := c.NetworkInterfaces[1].NetworkProjectId; got != "host-project" {
+		t.Errorf("expected the second interface to keep its project, got %q", got)
+	}
+}
+
+func TestConfigPrepareIPv6(t *testing.T) {
+	cases := []struct {
+		Keys   []string
+		Values []interface{}
+		Err    bool
+	}{
+		{
+			[]string{"stack_type"},
+			[]interface{}{"IPV4_IPV6"},
+			false,
+		},
+		{
+			[]string{"stack_type"},
+			[]interface{}{"IPV5"},
+			true,
+		},
+		{
+			[]string{"stack_type", "external_ipv6", "use_ipv6"},
+			[]interface{}{"IPV4_IPV6", true, true},
+			false,
+		},
+		{
+			[]string{"stack_type", "use_ipv6"},
+			[]interface{}{"IPV4_IPV6", true},
+			true,
+		},
+		{
+			[]string{"stack_type", "use_ipv6", "use_internal_ip"},
+			[]interface{}{"IPV4_IPV6", true, true},
+			false,
+		},
+		{
+			[]string{"use_ipv6", "use_internal_ip"},
+			[]interface{}{true, true},
+			true,
+		},
+		{
+			[]string{"external_ipv6"},
+			[]interface{}{true},
+			true,
+		},
+		{
+			[]string{"stack_type"},
+			[]interface{}{"IPV6_ONLY"},
+			true,
+		},
+		{
+			[]string{"stack_type", "external_ipv6", "use_ipv6"},
+			[]interface{}{"IPV6_ONLY", true, true},
+			false,
+		},
+		{
+			[]string{"stack_type", "external_ipv6", "use_ipv6", "address"},
+			[]interface{}{"IPV6_ONLY", true, true, "my-address"},
+			true,
+		},
+		{
+			[]string{"stack_type", "use_ipv6", "use_internal_ip", "network_ip"},
+			[]interface{}{"IPV6_ONLY", true, true, "fd20::2"},
+			false,
+		},
+		{
+			[]string{"stack_type", "use_ipv6", "use_internal_ip", "network_ip"},
+			[]interface{}{"IPV6_ONLY", true, true, "10.0.0.2"},
+			true,
+		},
+		{
+			[]string{"network_ip"},
+			[]interface{}{"fd20::2"},
+			true,
+		},
+		{
+			[]string{"stack_type", "network_ip"},
+			[]interface{}{"IPV4_IPV6", "fd20::2"},
+			false,
+		},
+		{
+			[]string{"omit_external_ip", "stack_type", "external_ipv6", "use_ipv6"},
+			[]interface{}{true, "IPV4_IPV6", true, true},
+			false,
+		},
+		{
+			[]string{"network_interface", "use_ipv6"},
+			[]interface{}{[]map[string]interface{}{{"network": "default", "stack_type": "IPV6_ONLY", "external_ipv6": true}}, true},
+			false,
+		},
+		{
+			[]string{"network_interface"},
+			[]interface{}{[]map[string]interface{}{{"network": "default", "stack_type": "IPV6_ONLY", "external_ipv6": true}}},
+			true,
+		},
+		{
+			[]string{"network_interface", "stack_type", "use_ipv6"},
+			[]interface{}{[]map[string]interface{}{{"network": "default", "stack_type": "IPV6_ONLY", "external_ipv6": true}}, "IPV6_ONLY", true},
+			true,
+		},
+	}
+
+	for _, tc := range cases {
+		raw, tempfile := testConfig(t)
+		defer os.Remove(tempfile)
+
+		errStr := ""
+		for k := range tc.Keys {
+			errStr += fmt.Sprintf("%s:%v, ", tc.Keys[k], tc.Values[k])
+			raw[tc.Keys[k]] = tc.Values[k]
+		}
+
+		var c Config
+		warns, errs := c.Prepare(raw)
+
+		if tc.Err {
+			testConfigErr(t, warns, errs, strings.TrimRight(errStr, ", "))
+		} else {
+			testConfigOk(t, warns, errs)
+		}
+	}
+}
+
+func TestConfigPrepareSecureBootKeys(t *testing.T) {
+	cases := []struct {
+		Keys   []string
+		Values []interface{}
+		Err    bool
+	}{
+		{
+			[]string{"image_platform_key", "image_signatures_db", "image_secure_boot_strict"},
+			[]interface{}{"gs://keys/pk.der", []string{"secretmanager://projects/p/secrets/db", "file:///keys/db.esl"}, true},
+			false,
+		},
+		{
+			[]string{"image_platform_key"},
+			[]interface{}{"gs://keys"},
+			true,
+		},
+		{
+			[]string{"image_key_exchange_key"},
+			[]interface{}{[]string{"secretmanager://kek"}},
+			true,
+		},
+		{
+			[]string{"image_forbidden_signatures_db"},
+			[]interface{}{[]string{"https://example.com/dbx.esl"}},
+			true,
+		},
+	}
+
+	for _, tc := range cases {
+		raw, tempfile := testConfig(t)
+		defer os.Remove(tempfile)
+
+		errStr := ""
+		for k := range tc.Keys {
+			errStr += fmt.Sprintf("%s:%v, ", tc.Keys[k], tc.Values[k])
+			raw[tc.Keys[k]] = tc.Values[k]
+		}
+
+		var c Config
+		warns, errs := c.Prepare(raw)
+
+		if tc.Err {
+			testConfigErr(t, warns, errs, strings.TrimRight(errStr, ", "))
+		} else {
+			testConfigOk(t, warns, errs)
+		}
+	}
+}
+
+func TestConfigPrepareArtifactType(t *testing.T) {
+	// The test configuration sets Shielded VM keys, which only apply to
+	// images.
+	imageOnly := []string{"image_family", "image_licenses", "image_platform_key", "image_key_exchange_key",
+		"image_signatures_db", "image_forbidden_signatures_db"}
+
+	cases := []struct {
+		Keys   []string
+		Values []interface{}
+		Err    bool
+	}{
+		{
+			[]string{"artifact_type"},
+			[]interface{}{"disk"},
+			true,
+		},
+		{
+			[]string{"artifact_type"},
+			[]interface{}{"snapshot"},
+			false,
+		},
+		{
+			[]string{"artifact_type"},
+			[]interface{}{"machine_image"},
+			false,
+		},
+		{
+			[]string{"artifact_type", "image_family"},
+			[]interface{}{"snapshot", "bar"},
+			true,
+		},
+		{
+			[]string{"artifact_type", "image_iam_binding"},
+			[]interface{}{"snapshot", []map[string]interface{}{{"role": "roles/compute.imageUser", "members": []string{"domain:example.com"}}}},
+			true,
+		},
+		{
+			[]string{"artifact_type", "image_copy"},
+			[]interface{}{"snapshot", []map[string]interface{}{{"project_id": "production"}}},
+			true,
+		},
+		{
+			[]string{"artifact_type", "image_family_retention"},
+			[]interface{}{"snapshot", map[string]interface{}{"keep_last": 3}},
+			true,
+		},
+		{
+			[]string{"artifact_type", "deprecate_at"},
+			[]interface{}{"machine_image", "2100-01-01T00:00:00Z"},
+			true,
+		},
+		{
+			[]string{"artifact_type", "disk_attachment"},
+			[]interface{}{"snapshot", []map[string]interface{}{{"volume_type": "pd-ssd", "volume_size": 10, "create_image": true}}},
+			false,
+		},
+		{
+			[]string{"artifact_type", "disk_attachment"},
+			[]interface{}{"machine_image", []map[string]interface{}{{"volume_type": "pd-ssd", "volume_size": 10, "create_image": true}}},
+			true,
+		},
+	}
+
+	for _, tc := range cases {
+		raw, tempfile := testConfig(t)
+		defer os.Remove(tempfile)
+
+		for _, k := range imageOnly {
+			delete(raw, k)
+		}
+		errStr := ""
+		for k := range tc.Keys {
+			errStr += fmt.Sprintf("%s:%v, ", tc.Keys[k], tc.Values[k])
+			raw[tc.Keys[k]] = tc.Values[k]
+		}
+
+		var c Config
+		warns, errs := c.Prepare(raw)
+
+		if tc.Err {
+			testConfigErr(t, warns, errs, strings.TrimRight(errStr, ", "))
+		} else {
+			testConfigOk(t, warns, errs)
+		}
+	}
+
+	// The test configuration defaults to images.
+	c := testConfigStruct(t)
+	if c.ArtifactType != ArtifactTypeImage {
+		t.Errorf("expected artifact_type to default to image, got %q", c.ArtifactType)
+	}
+}
+
+func TestConfigPrepareImageIAMBindings(t *testing.T) {
+	cases := []struct {
+		Keys   []string
+		Values []interface{}
+		Err    bool
+	}{
+		{
+			[]string{"image_iam_binding"},
+			[]interface{}{[]map[string]interface{}{{"role": "roles/compute.imageUser", "members": []string{"group:builders@example.com"}}}},
+			false,
+		},
+		{
+			[]string{"image_iam_binding"},
+			[]interface{}{[]map[string]interface{}{{"role": "roles/compute.imageUser"}}},
+			true,
+		},
+		{
+			[]string{"image_iam_binding"},
+			[]interface{}{[]map[string]interface{}{{"role": "roles/compute.imageUser", "members": []string{"builders@example.com"}}}},
+			true,
+		},
+	}
+
+	for _, tc := range cases {
+		raw, tempfile := testConfig(t)
+		defer os.Remove(tempfile)
+
+		errStr := ""
+		for k := range tc.Keys {
+			errStr += fmt.Sprintf("%s:%v, ", tc.Keys[k], tc.Values[k])
+			raw[tc.Keys[k]] = tc.Values[k]
+		}
+
+		var c Config
+		warns, errs := c.Prepare(raw)
+
+		if tc.Err {
+			testConfigErr(t, warns, errs, strings.TrimRight(errStr, ", "))
+		} else {
+			testConfigOk(t, warns, errs)
+		}
+	}
+}
+
+func TestConfigPrepareImageCopies(t *testing.T) {
+	cases := []struct {
+		Keys   []string
+		Values []interface{}
+		Err    bool
+	}{
+		{
+			[]string{"image_copy"},
+			[]interface{}{[]map[string]interface{}{{"project_id": "production", "storage_location": "eu"}}},
+			false,
+		},
+		{
+			[]string{"image_copy"},
+			[]interface{}{[]map[string]interface{}{{"storage_location": "eu", "name_suffix": "-eu"}}},
+			false,
+		},
+		{
+			// The copy would take the name of the image.
+			[]string{"image_copy"},
+			[]interface{}{[]map[string]interface{}{{"storage_location": "eu"}}},
+			true,
+		},
+		{
+			[]string{"image_copy"},
+			[]interface{}{[]map[string]interface{}{{"project_id": "production"}, {"project_id": "production"}}},
+			true,
+		},
+		{
+			[]string{"image_copy"},
+			[]interface{}{[]map[string]interface{}{{"name_suffix": "_EU"}}},
+			true,
+		},
+		{
+			[]string{"image_copy"},
+			[]interface{}{[]map[string]interface{}{{"project_id": "production", "family": "Web"}}},
+			true,
+		},
+	}
+
+	for _, tc := range cases {
+		raw, tempfile := testConfig(t)
+		defer os.Remove(tempfile)
+
+		errStr := ""
+		for k := range tc.Keys {
+			errStr += fmt.Sprintf("%s:%v, ", tc.Keys[k], tc.Values[k])
+			raw[tc.Keys[k]] = tc.Values[k]
+		}
+
+		var c Config
+		warns, errs := c.Prepare(raw)
+
+		if tc.Err {
+			testConfigErr(t, warns, errs, strings.TrimRight(errStr, ", "))
+		} else {
+			testConfigOk(t, warns, errs)
+		}
+	}
+
+	raw, tempfile := testConfig(t)
+	defer os.Remove(tempfile)
+	raw["image_family"] = "web"
+	raw["image_copy"] = []map[string]interface{}{
+		{"storage_location": "eu", "name_suffix": "-eu"},
+		{"project_id": "other-project"},
+	}
+	var c Config
+	warns, errs := c.Prepare(raw)
+	testConfigOk(t, warns, errs)
+	if c.ImageCopies[0].ProjectId != c.ImageProjectId || c.ImageCopies[0].Family != "" {
+		t.Errorf("expected the copy to default to the project of the image, without family, got %#v", c.ImageCopies[0])
+	}
+	if c.ImageCopies[1].Family != "web" {
+		t.Errorf("expected the copy into another project to default to the family of the image, got %#v", c.ImageCopies[1])
+	}
+
+	raw["image_copy"] = []map[string]interface{}{{"name_suffix": "-eu", "family": "web"}}
+	c = Config{}
+	warns, errs = c.Prepare(raw)
+	testConfigErr(t, warns, errs, "image_copy in image_family")
+}
+
+func TestConfigPrepareImageFamilyRetention(t *testing.T) {
+	cases := []struct {
+		Keys   []string
+		Values []interface{}
+		Err    bool
+	}{
+		{
+			[]string{"image_family_retention"},
+			[]interface{}{map[string]interface{}{"deprecate_previous": true, "keep_last": 5, "delete_older_than": "2160h"}},
+			false,
+		},
+		{
+			[]string{"image_family_retention"},
+			[]interface{}{map[string]interface{}{"obsolete_after": 5, "keep_last": 2}},
+			true,
+		},
+		{
+			[]string{"image_family_retention", "image_family"},
+			[]interface{}{map[string]interface{}{"keep_last": 5}, ""},
+			true,
+		},
+	}
+
+	for _, tc := range cases {
+		raw, tempfile := testConfig(t)
+		defer os.Remove(tempfile)
+
+		errStr := ""
+		for k := range tc.Keys {
+			errStr += fmt.Sprintf("%s:%v, ", tc.Keys[k], tc.Values[k])
+			raw[tc.Keys[k]] = tc.Values[k]
+		}
+
+		var c Config
+		warns, errs := c.Prepare(raw)
+
+		if tc.Err {
+			testConfigErr(t, warns, errs, strings.TrimRight(errStr, ", "))
+		} else {
+			testConfigOk(t, warns, errs)
+		}
+	}
+}
+
+func TestConfigPrepareSource(t *testing.T) {
+	cases := []struct {
+		Keys   []string
+		Values []interface{}
+		Err    bool
+	}{
+		{
+			[]string{},
+			[]interface{}{},
+			true,
+		},
+		{
+			[]string{"source_image"},
+			[]interface{}{"foo"},
+			false,
+		},
+		{
+			[]string{"source_snapshot"},
+			[]interface{}{"projects/golden/global/snapshots/foo"},
+			false,
+		},
+		{
+			[]string{"source_disk"},
+			[]interface{}{"foo"},
+			false,
+		},
+		{
+			[]string{"source_disk"},
+			[]interface{}{"zones/us-east1-a/disks/foo"},
+			true,
+		},
+		{
+			[]string{"source_machine_image"},
+			[]interface{}{"foo"},
+			false,
+		},
+		{
+			[]string{"source_image_family", "source_snapshot"},
+			[]interface{}{"foo", "bar"},
+			true,
+		},
+		{
+			[]string{"source_disk", "source_machine_image"},
+			[]interface{}{"foo", "bar"},
+			true,
+		},
+		{
+			[]string{"source_snapshot", "source_image_project_id"},
+			[]interface{}{"foo", []string{"bar"}},
 			true,
 		},
 		{
-			[]string{"accelerator_count", "on_host_maintenance", "accelerator_type"},
-			[]interface{}{1, "TERMINATE", ""},
+			[]string{"source_disk", "fallback_zones"},
+			[]interface{}{"foo", []string{"us-east1-b"}},
 			true,
 		},
 		{
-			[]string{"accelerator_count", "on_host_maintenance", "accelerator_type"},
-			[]interface{}{1, "TERMINATE", "something_valid"},
-			false,
+			[]string{"source_machine_image", "disk_attachment"},
+			[]interface{}{"foo", []map[string]interface{}{{"volume_type": "pd-ssd", "volume_size": 10}}},
+			true,
 		},
 	}
 
@@ -330,17 +1494,11 @@ func TestConfigPrepareAccelerator(t *testing.T) {
 		raw, tempfile := testConfig(t)
 		defer os.Remove(tempfile)
 
+		delete(raw, "source_image")
 		errStr := ""
 		for k := range tc.Keys {
-
-			// Create the string for error reporting
-			// convert value to string if it can be converted
 			errStr += fmt.Sprintf("%s:%v, ", tc.Keys[k], tc.Values[k])
-			if tc.Values[k] == nil {
-				delete(raw, tc.Keys[k])
-			} else {
-				raw[tc.Keys[k]] = tc.Values[k]
-			}
+			raw[tc.Keys[k]] = tc.Values[k]
 		}
 
 		var c Config
@@ -354,27 +1512,47 @@ func TestConfigPrepareAccelerator(t *testing.T) {
 	}
 }
 
-func TestConfigPrepareServiceAccount(t *testing.T) {
+func TestConfigPrepareBootDisk(t *testing.T) {
 	cases := []struct {
 		Keys   []string
 		Values []interface{}
 		Err    bool
 	}{
 		{
-			[]string{"disable_default_service_account", "service_account_email"},
-			[]interface{}{true, "service@account.email.com"},
+			[]string{"disk_type", "disk_provisioned_iops", "disk_provisioned_throughput"},
+			[]interface{}{"hyperdisk-balanced", 5000, 300},
+			false,
+		},
+		{
+			[]string{"disk_type", "disk_provisioned_iops"},
+			[]interface{}{"pd-ssd", 5000},
 			true,
 		},
 		{
-			[]string{"disable_default_service_account", "service_account_email"},
-			[]interface{}{false, "service@account.email.com"},
+			[]string{"disk_type", "disk_provisioned_throughput"},
+			[]interface{}{"hyperdisk-extreme", 300},
+			true,
+		},
+		{
+			[]string{"disk_type", "disk_storage_pool"},
+			[]interface{}{"hyperdisk-balanced", "my-pool"},
 			false,
 		},
 		{
-			[]string{"disable_default_service_account", "service_account_email"},
-			[]interface{}{true, ""},
+			[]string{"disk_storage_pool"},
+			[]interface{}{"my-pool"},
+			true,
+		},
+		{
+			[]string{"disk_labels", "disk_resource_policies"},
+			[]interface{}{map[string]string{"team": "images"}, []string{"daily-snapshots"}},
 			false,
 		},
+		{
+			[]string{"disk_labels"},
+			[]interface{}{map[string]string{"Team": "images"}},
+			true,
+		},
 	}
 
 	for _, tc := range cases {
@@ -383,15 +1561,8 @@ func TestConfigPrepareServiceAccount(t *testing.T) {
 
 		errStr := ""
 		for k := range tc.Keys {
-
-			// Create the string for error reporting
-			// convert value to string if it can be converted
 			errStr += fmt.Sprintf("%s:%v, ", tc.Keys[k], tc.Values[k])
-			if tc.Values[k] == nil {
-				delete(raw, tc.Keys[k])
-			} else {
-				raw[tc.Keys[k]] = tc.Values[k]
-			}
+			raw[tc.Keys[k]] = tc.Values[k]
 		}
 
 		var c Config
@@ -405,105 +1576,65 @@ func TestConfigPrepareServiceAccount(t *testing.T) {
 	}
 }
 
-func TestConfigPrepareStartupScriptFile(t *testing.T) {
-	config := map[string]interface{}{
-		"project_id":          "project",
-		"source_image":        "foo",
-		"ssh_username":        "packer",
-		"startup_script_file": "no-such-file",
-		"zone":                "us-central1-a",
-	}
-
-	var c Config
-	_, errs := c.Prepare(config)
-
-	if errs == nil || !strings.Contains(errs.Error(), "startup_script_file") {
-		t.Fatalf("should error: startup_script_file")
-	}
-}
-
-func TestConfigPrepareIAP_SSH(t *testing.T) {
-	config := map[string]interface{}{
-		"project_id":   "project",
-		"source_image": "foo",
-		"ssh_username": "packer",
-		"zone":         "us-central1-a",
-		"communicator": "ssh",
-		"use_iap":      true,
-	}
-
-	var c Config
-	_, err := c.Prepare(config)
-	if err != nil {
-		t.Fatalf("Shouldn't have errors. Err = %s", err)
-	}
-	if c.Comm.SSHHost != "localhost" {
-		t.Fatalf("Should have set SSHHost")
-	}
-
-	testIAPScript(t, &c)
-}
-
-func TestConfigPrepareIAP_WinRM(t *testing.T) {
-	config := map[string]interface{}{
-		"project_id":     "project",
-		"source_image":   "foo",
-		"winrm_username": "packer",
-		"zone":           "us-central1-a",
-		"communicator":   "winrm",
-		"use_iap":        true,
-	}
-
-	var c Config
-	_, err := c.Prepare(config)
-	if err != nil {
-		t.Fatalf("Shouldn't have errors. Err = %s", err)
-	}
-	if c.Comm.WinRMHost != "localhost" {
-		t.Fatalf("Should have set WinRMHost")
-	}
-
-	testIAPScript(t, &c)
-}
-
-func TestConfigPrepareIAP_failures(t *testing.T) {
-	config := map[string]interface{}{
-		"project_id":     "project",
-		"source_image":   "foo",
-		"winrm_username": "packer",
-		"zone":           "us-central1-a",
-		"communicator":   "none",
-		"iap_hashbang":   "/bin/bash",
-		"iap_ext":        ".ps1",
-		"use_iap":        true,
-	}
-
-	var c Config
-	_, errs := c.Prepare(config)
-	if errs == nil {
-		t.Fatalf("Should have errored because we're using none.")
-	}
-	if c.IAPHashBang != "/bin/bash" {
-		t.Fatalf("IAP hashbang defaulted even though set.")
-	}
-	if c.IAPExt != ".ps1" {
-		t.Fatalf("IAP tempfile defaulted even though set.")
+func TestConfigPrepareUserData(t *testing.T) {
+	cloudConfig := filepath.Join(t.TempDir(), "cloud-config.yaml")
+	if err := os.WriteFile(cloudConfig, []byte("#cloud-config\nhostname: {{ build_name }}\n"), 0600); err != nil {
+		t.Fatalf("failed to write user data file: %s", err)
 	}
-}
 
-func TestConfigDefaults(t *testing.T) {
 	cases := []struct {
-		Read  func(c *Config) interface{}
-		Value interface{}
+		Keys     []string
+		Values   []interface{}
+		Err      bool
+		UserData string
 	}{
 		{
-			func(c *Config) interface{} { return c.Comm.Type },
-			"ssh",
+			[]string{"user_data"},
+			[]interface{}{"#cloud-config\npackages:\n  - nginx\n"},
+			false,
+			"#cloud-config\npackages:\n  - nginx\n",
 		},
-
 		{
-			func(c *Config) interface{} { return c.Comm.SSHPort },
-			22,
+			[]string{"user_data"},
+			[]interface{}{"#!/bin/bash\necho {{ build_type }}\n"},
+			false,
+			"#!/bin/bash\necho googlecompute\n",
+		},
+		{
+			[]string{"user_data_file"},
+			[]interface{}{cloudConfig},
+			false,
+			"#cloud-config\nhostname: web\n",
+		},
+		{
+			[]string{"user_data"},
+			[]interface{}{"#cloud-config\npackages: [nginx\n"},
+			true,
+			"",
+		},
+		{
+			[]string{"user_data"},
+			[]interface{}{"#cloud-config\njust a string\n"},
+			true,
+			"",
+		},
+		{
+			[]string{"user_data_file"},
+			[]interface{}{"/tmp/i/should/not/exist"},
+			true,
+			"",
+		},
+		{
+			[]string{"user_data", "user_data_file"},
+			[]interface{}{"#cloud-config\n", cloudConfig},
+			true,
+			"",
+		},
+		{
+			[]string{"user_data", "metadata"},
+			[]interface{}{"#cloud-config\n", map[string]string{"user-data": "#cloud-config\n"}},
+			true,
+			"",
 		},
 	}
 
@@ -511,49 +1642,25 @@ func TestConfigDefaults(t *testing.T) {
 		raw, tempfile := testConfig(t)
 		defer os.Remove(tempfile)
 
+		raw["packer_build_name"] = "web"
+		raw["packer_builder_type"] = "googlecompute"
+		errStr := ""
+		for k := range tc.Keys {
+			errStr += fmt.Sprintf("%s:%v, ", tc.Keys[k], tc.Values[k])
+			raw[tc.Keys[k]] = tc.Values[k]
+		}
+
 		var c Config
 		warns, errs := c.Prepare(raw)
-		testConfigOk(t, warns, errs)
 
-		actual := tc.Read(&c)
-		if actual != tc.Value {
-			t.Fatalf("bad: %#v", actual)
+		if tc.Err {
+			testConfigErr(t, warns, errs, strings.TrimRight(errStr, ", "))
+			continue
 		}
-	}
-}
-
-func TestImageName(t *testing.T) {
-	raw, tempfile := testConfig(t)
-	defer os.Remove(tempfile)
-
-	var c Config
-	_, err := c.Prepare(raw)
-	if err != nil {
-		t.Fatal(err)
-	}
-	if !strings.HasPrefix(c.ImageName, "packer-") {
-		t.Fatalf("ImageName should have 'packer-' prefix, found %s", c.ImageName)
-	}
-	if strings.Contains(c.ImageName, "{{timestamp}}") {
-		t.Errorf("ImageName should be interpolated; found %s", c.ImageName)
-	}
-}
-
-func TestRegion(t *testing.T) {
-	raw, tempfile := testConfig(t)
-	defer func() {
-		err := os.Remove(tempfile)
-		if err != nil {
-			t.Fatal(err)
+		testConfigOk(t, warns, errs)
+		if c.userData != tc.UserData {
+			t.Fatalf("bad user data for %s: %q", errStr, c.userData)
 		}
-	}()
-	var c Config
-	_, err := c.Prepare(raw)
-	if err != nil {
-		t.Fatal(err)
-	}
-	if c.Region != "us-east1" {
-		t.Fatalf("Region should be 'us-east1' given Zone of 'us-east1-a', but is %s", c.Region)
 	}
 }
 
@@ -683,6 +1790,113 @@ func TestConfigExtraBlockDevice_create_image_multiple(t *testing.T) {
 	}
 }
 
+func TestConfigExtraBlockDevice_diskImages(t *testing.T) {
+	disk := func(name, imageName string, extra map[string]interface{}) map[string]interface{} {
+		d := map[string]interface{}{
+			"volume_type":  "pd-standard",
+			"volume_size":  20,
+			"disk_name":    name,
+			"create_image": true,
+			"image_name":   imageName,
+		}
+		for k, v := range extra {
+			d[k] = v
+		}
+		return d
+	}
+
+	cases := []struct {
+		Name        string
+		Disks       []map[string]interface{}
+		Extra       map[string]interface{}
+		ExpectError bool
+		// The disks imaged separately, and the disk of image_name
+		DiskImages      []string
+		ImageSourceDisk string
+	}{
+		{
+			Name: "images of several disks along with the boot disk",
+			Disks: []map[string]interface{}{
+				disk("db-disk", "db-image", map[string]interface{}{"image_family": "db", "image_labels": map[string]string{"role": "db"}}),
+				disk("logs-disk", "logs-image", nil),
+			},
+			DiskImages:      []string{"db-disk", "logs-disk"},
+			ImageSourceDisk: "packer-boot",
+		},
+		{
+			Name: "images of several disks along with a disk replacing the boot disk",
+			Disks: []map[string]interface{}{
+				disk("os-disk", "", nil),
+				disk("db-disk", "db-image", nil),
+			},
+			DiskImages:      []string{"db-disk"},
+			ImageSourceDisk: "os-disk",
+		},
+		{
+			Name:        "image name taken by image_name",
+			Disks:       []map[string]interface{}{disk("db-disk", "packer-image", nil)},
+			Extra:       map[string]interface{}{"image_name": "packer-image"},
+			ExpectError: true,
+		},
+		{
+			Name:        "image name used twice",
+			Disks:       []map[string]interface{}{disk("db-disk", "db-image", nil), disk("logs-disk", "db-image", nil)},
+			ExpectError: true,
+		},
+		{
+			Name:        "invalid image name",
+			Disks:       []map[string]interface{}{disk("db-disk", "DB_image", nil)},
+			ExpectError: true,
+		},
+		{
+			Name:        "invalid image label",
+			Disks:       []map[string]interface{}{disk("db-disk", "db-image", map[string]interface{}{"image_labels": map[string]string{"Role": "db"}})},
+			ExpectError: true,
+		},
+		{
+			Name:        "not an image artifact",
+			Disks:       []map[string]interface{}{disk("db-disk", "db-image", nil)},
+			Extra:       map[string]interface{}{"artifact_type": "snapshot"},
+			ExpectError: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			raw, tempfile := testConfig(t)
+			defer os.Remove(tempfile)
+
+			raw["disk_attachment"] = tc.Disks
+			raw["disk_name"] = "packer-boot"
+			for k, v := range tc.Extra {
+				raw[k] = v
+			}
+
+			var c Config
+			_, err := c.Prepare(raw)
+			if tc.ExpectError {
+				if err == nil {
+					t.Fatal("expected an error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to prepare config: %s", err)
+			}
+			var diskImages []string
+			for _, bd := range c.diskImages() {
+				diskImages = append(diskImages, bd.DiskName)
+			}
+			if !reflect.DeepEqual(diskImages, tc.DiskImages) {
+				t.Errorf("expected disk images %v, got %v", tc.DiskImages, diskImages)
+			}
+			if c.imageSourceDisk != tc.ImageSourceDisk {
+				t.Errorf("expected image source disk %q, got %q", tc.ImageSourceDisk, c.imageSourceDisk)
+			}
+		})
+	}
+}
+
 func TestConfigPrepareImageArchitecture(t *testing.T) {
 	cases := []struct {
 		Architecture string
@@ -951,24 +2165,6 @@ func testAccountFile(t *testing.T) string {
 	return tf.Name()
 }
 
-func testIAPScript(t *testing.T, c *Config) {
-	if runtime.GOOS == "windows" {
-		if c.IAPExt != ".cmd" {
-			t.Fatalf("IAP tempfile extension didn't default correctly to .cmd")
-		}
-		if c.IAPHashBang != "" {
-			t.Fatalf("IAP hashbang didn't default correctly to nothing.")
-		}
-	} else {
-		if c.IAPExt != "" {
-			t.Fatalf("IAP tempfile extension should default to empty on unix mahcines")
-		}
-		if c.IAPHashBang != "/bin/sh" {
-			t.Fatalf("IAP hashbang didn't default correctly to /bin/sh.")
-		}
-	}
-}
-
 const testMetadataFileContent = `testMetadata`
 
 func testMetadataFile(t *testing.T) string {
@@ -0,0 +1,89 @@
+// Copyright IBM Corp. 2013, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package googlecompute
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/hashicorp/packer-plugin-googlecompute/lib/common"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"google.golang.org/api/compute/v1"
+)
+
+// extraImage is an image created along with the built image, such as a copy
+// of it or the image of an attached disk.
+type extraImage struct {
+	// desc describes the image in messages, e.g. "copy foo-eu in project bar".
+	desc    string
+	project string
+	spec    *compute.Image
+}
+
+// createExtraImages creates the images at once, and waits for all of them.
+// Under -force, the previous images of the same names are deleted first. If
+// any image fails, the ones created are deleted and the errors returned.
+func createExtraImages(ctx context.Context, state multistep.StateBag, images []extraImage) ([]*common.Image, error) {
+	config := state.Get("config").(*Config)
+	driver := state.Get("driver").(common.Driver)
+	ui := state.Get("ui").(packersdk.Ui)
+
+	if config.PackerForce {
+		for _, ei := range images {
+			if !driver.ImageExists(ctx, ei.project, ei.spec.Name) {
+				continue
+			}
+			ui.Message(fmt.Sprintf("Deleting previous %s...", ei.desc))
+			if err := <-driver.DeleteImage(ctx, ei.project, ei.spec.Name); err != nil {
+				return nil, fmt.Errorf("deleting previous %s: %s", ei.desc, err)
+			}
+		}
+	}
+
+	type pendingImage struct {
+		desc    string
+		imageCh <-chan *common.Image
+		errCh   <-chan error
+	}
+
+	// The images share image_create_timeout, as they are created at once.
+	createCtx, cancel := context.WithTimeout(ctx, config.operationTimeout(config.ImageCreateTimeout))
+	defer cancel()
+	var pending []pendingImage
+	for _, ei := range images {
+		ui.Message(fmt.Sprintf("Creating %s", ei.desc))
+		imageCh, errCh := driver.CreateImage(createCtx, ei.project, ei.spec)
+		pending = append(pending, pendingImage{ei.desc, imageCh, errCh})
+	}
+
+	var created []*common.Image
+	var errs error
+	for _, p := range pending {
+		err := waitForOperation(createCtx, p.errCh, "time out while waiting for image to register")
+		if err != nil {
+			errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("%s: %s", p.desc, err))
+			continue
+		}
+		created = append(created, <-p.imageCh)
+	}
+
+	if errs != nil {
+		deleteImages(ctx, driver, created)
+		return nil, errs
+	}
+	return created, nil
+}
+
+// deleteImages deletes the images of a failed build. The failed build reports
+// no artifact, so the images would be left behind, even if the build was
+// cancelled.
+func deleteImages(ctx context.Context, driver common.Driver, images []*common.Image) {
+	for _, image := range images {
+		if err := <-driver.DeleteImage(context.WithoutCancel(ctx), image.ProjectId, image.Name); err != nil {
+			log.Printf("[WARN] Failed to delete image %s in project %s: %s", image.Name, image.ProjectId, err)
+		}
+	}
+}
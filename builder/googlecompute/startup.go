@@ -10,7 +10,46 @@ import (
 const StartupScriptKey string = "startup-script"
 const StartupScriptStatusKey string = "startup-script-status"
 const StartupWrappedScriptKey string = "packer-wrapped-startup-script"
+const StartupScriptURLKey string = "startup-script-url"
+const StartupWrappedScriptURLKey string = "packer-wrapped-startup-script-url"
+const ShutdownScriptKey string = "shutdown-script"
+const ShutdownWrappedScriptKey string = "packer-wrapped-shutdown-script"
 const EnableOSLoginKey string = "enable-oslogin"
+const EnableGuestAttributesKey string = "enable-guest-attributes"
+
+// The startup script wrappers report the status of the script in the
+// guest attribute packer/status, or in the startup-script-status metadata if
+// guest attributes are not available.
+const StartupScriptGuestAttributesNamespace string = "packer"
+const StartupScriptGuestAttributesKey string = "status"
+
+// The startup script wrappers also publish the result of the script in the
+// guest attributes namespace: its exit code, its duration in seconds, and the
+// last lines of its output.
+const StartupScriptExitCodeKey string = "exit-code"
+const StartupScriptDurationKey string = "duration"
+const StartupScriptLogTailKey string = "log-tail"
+
+// startupScriptLogTailLines is the number of lines of the output of the
+// startup script the wrappers publish.
+const startupScriptLogTailLines = 20
+
+// The metadata keys of the PowerShell scripts Windows instances run at each
+// boot, and once during the sysprep specialize phase of the first boot.
+const WindowsStartupScriptKey string = "windows-startup-script-ps1"
+const WindowsSysprepScriptKey string = "sysprep-specialize-script-ps1"
+const WindowsStartupScriptURLKey string = "windows-startup-script-url"
+const WindowsShutdownScriptKey string = "windows-shutdown-script-ps1"
+
+// The shutdown script wrappers log one of these to the serial port once the
+// script is done, as neither metadata nor guest attributes can be relied on
+// while the instance shuts down.
+const ShutdownScriptDoneMarker string = "Packer shutdown script done."
+const ShutdownScriptErrorMarker string = "Packer shutdown script exited with exit code: "
+
+// What to do when the startup script fails, see on_startup_script_failure.
+const StartupScriptFailureAbort string = "abort"
+const StartupScriptFailureContinue string = "continue"
 
 const StartupScriptStatusDone string = "done"
 const StartupScriptStatusError string = "error"
@@ -31,8 +70,22 @@ SetMetadata () {
   gcloud compute instances add-metadata ${HOSTNAME} --metadata ${1}=${2} --zone ${ZONE}
 }
 
+SetGuestAttribute () {
+  curl -f -X PUT --data-binary @- -H "Metadata-Flavor: Google" ${BASEMETADATAURL}/guest-attributes/%[5]s/${1} 2> /dev/null
+}
+
+SetStatus () {
+  if echo -n "${1}" | SetGuestAttribute %[6]s; then
+    return
+  fi
+  echo "Guest attributes are not available, falling back to instance metadata."
+  SetMetadata %[2]s ${1}
+}
+
 STARTUPSCRIPT=$(GetMetadata attributes/%[1]s)
+STARTUPSCRIPTURL=$(GetMetadata attributes/%[11]s)
 STARTUPSCRIPTPATH=/packer-wrapped-startup-script
+STARTUPSCRIPTOUTPUTPATH=/packer-wrapped-startup-script.log
 if [ -f "/var/log/startupscript.log" ]; then
   STARTUPSCRIPTLOGPATH=/var/log/startupscript.log
 else
@@ -40,30 +93,179 @@ else
 fi
 STARTUPSCRIPTLOGDEST=$(GetMetadata attributes/startup-script-log-dest)
 
+if [[ ! -z $STARTUPSCRIPTURL ]]; then
+  echo "Downloading user-provided startup script from ${STARTUPSCRIPTURL}..."
+  TOKEN=$(GetMetadata service-accounts/default/token | grep -o '"access_token":"[^"]*"' | cut -d '"' -f 4)
+  if ! STARTUPSCRIPT=$(curl -sf -H "Authorization: Bearer ${TOKEN}" "${STARTUPSCRIPTURL/#gs:\/\//https://storage.googleapis.com/}"); then
+    echo "Failed to download the startup script from ${STARTUPSCRIPTURL}."
+    RETVAL=1
+  fi
+fi
+
 if [[ ! -z $STARTUPSCRIPT ]]; then
   echo "Executing user-provided startup script..."
   echo "${STARTUPSCRIPT}" > ${STARTUPSCRIPTPATH}
   chmod +x ${STARTUPSCRIPTPATH}
-  ${STARTUPSCRIPTPATH}
-  RETVAL=$?
+  STARTTIME=$(date +%%s)
+  ${STARTUPSCRIPTPATH} 2>&1 | tee ${STARTUPSCRIPTOUTPUTPATH}
+  RETVAL=${PIPESTATUS[0]}
+  DURATION=$(( $(date +%%s) - STARTTIME ))
+
+  echo -n "${RETVAL}" | SetGuestAttribute %[7]s
+  echo -n "${DURATION}" | SetGuestAttribute %[8]s
+  tail -n %[10]d ${STARTUPSCRIPTOUTPUTPATH} | SetGuestAttribute %[9]s
 
   if [[ ! -z $STARTUPSCRIPTLOGDEST ]]; then
     echo "Uploading user-provided startup script log to ${STARTUPSCRIPTLOGDEST}..."
     gsutil -h "Content-Type:text/plain" cp ${STARTUPSCRIPTLOGPATH} ${STARTUPSCRIPTLOGDEST}
   fi
 
-  rm ${STARTUPSCRIPTPATH}
+  rm ${STARTUPSCRIPTPATH} ${STARTUPSCRIPTOUTPUTPATH}
 fi
 
 if [ $RETVAL -ne 0  ]; then
   echo "Packer startup script exited with exit code: ${RETVAL}"
-  SetMetadata %[2]s %[4]s
+  SetStatus %[4]s
 else
   echo "Packer startup script done."
-  SetMetadata %[2]s %[3]s
+  SetStatus %[3]s
 fi
 
 exit $RETVAL
-`, StartupWrappedScriptKey, StartupScriptStatusKey, StartupScriptStatusDone, StartupScriptStatusError)
+`, StartupWrappedScriptKey, StartupScriptStatusKey, StartupScriptStatusDone, StartupScriptStatusError,
+	StartupScriptGuestAttributesNamespace, StartupScriptGuestAttributesKey,
+	StartupScriptExitCodeKey, StartupScriptDurationKey, StartupScriptLogTailKey, startupScriptLogTailLines,
+	StartupWrappedScriptURLKey)
+
+var StartupScriptWindows string = fmt.Sprintf(`Write-Output "Packer startup script starting."
+$RetVal = 0
+$BaseMetadataUrl = "http://metadata.google.internal/computeMetadata/v1/instance"
+
+function Get-Metadata ($Path) {
+  try {
+    return Invoke-RestMethod -Headers @{"Metadata-Flavor" = "Google"} -Uri "$BaseMetadataUrl/$Path"
+  } catch {
+    return ""
+  }
+}
+
+# The computer name of Windows instances may be truncated, the instance name
+# comes from the metadata server instead.
+$InstanceName = Get-Metadata "name"
+$Zone = (Get-Metadata "zone").Split("/")[-1]
+
+function Set-Metadata ($Key, $Value) {
+  & gcloud compute instances add-metadata $InstanceName --metadata "$Key=$Value" --zone $Zone
+}
+
+function Set-GuestAttribute ($Key, $Value) {
+  $Uri = "$BaseMetadataUrl/guest-attributes/%[5]s/$Key"
+  Invoke-RestMethod -Method Put -Headers @{"Metadata-Flavor" = "Google"} -Body $Value -Uri $Uri | Out-Null
+}
+
+function Set-Status ($Value) {
+  try {
+    Set-GuestAttribute %[6]s $Value
+  } catch {
+    Write-Output "Guest attributes are not available, falling back to instance metadata."
+    Set-Metadata %[2]s $Value
+  }
+}
+
+$StartupScript = Get-Metadata "attributes/%[1]s"
+$StartupScriptUrl = Get-Metadata "attributes/%[11]s"
+$StartupScriptPath = Join-Path $env:TEMP "packer-wrapped-startup-script.ps1"
+$StartupScriptLogPath = Join-Path $env:TEMP "packer-wrapped-startup-script.log"
+$StartupScriptLogDest = Get-Metadata "attributes/startup-script-log-dest"
+
+if ($StartupScriptUrl) {
+  Write-Output "Downloading user-provided startup script from $StartupScriptUrl..."
+  try {
+    $Token = (Invoke-RestMethod -Headers @{"Metadata-Flavor" = "Google"} -Uri "$BaseMetadataUrl/service-accounts/default/token").access_token
+    $Url = $StartupScriptUrl -replace "^gs://", "https://storage.googleapis.com/"
+    Invoke-WebRequest -UseBasicParsing -Headers @{"Authorization" = "Bearer $Token"} -Uri $Url -OutFile $StartupScriptPath
+    $StartupScript = Get-Content -Path $StartupScriptPath -Raw
+  } catch {
+    Write-Output "Failed to download the startup script from ${StartupScriptUrl}: $_"
+    $RetVal = 1
+  }
+}
+
+if ($StartupScript) {
+  Write-Output "Executing user-provided startup script..."
+  Set-Content -Path $StartupScriptPath -Value $StartupScript
+  $StartTime = Get-Date
+  & powershell.exe -NoProfile -NonInteractive -ExecutionPolicy Bypass -File $StartupScriptPath *>&1 |
+    Tee-Object -FilePath $StartupScriptLogPath
+  $RetVal = $LASTEXITCODE
+  $Duration = [int]((Get-Date) - $StartTime).TotalSeconds
+
+  try {
+    Set-GuestAttribute %[7]s "$RetVal"
+    Set-GuestAttribute %[8]s "$Duration"
+    Set-GuestAttribute %[9]s ((Get-Content -Path $StartupScriptLogPath -Tail %[10]d) -join "`+"`"+`n")
+  } catch {
+    Write-Output "Guest attributes are not available, the result of the startup script is not published."
+  }
+
+  if ($StartupScriptLogDest) {
+    Write-Output "Uploading user-provided startup script log to $StartupScriptLogDest..."
+    & gsutil -h "Content-Type:text/plain" cp $StartupScriptLogPath $StartupScriptLogDest
+  }
+
+  Remove-Item -Path $StartupScriptPath
+}
+
+if ($RetVal -ne 0) {
+  Write-Output "Packer startup script exited with exit code: $RetVal"
+  Set-Status %[4]s
+} else {
+  Write-Output "Packer startup script done."
+  Set-Status %[3]s
+}
+
+exit $RetVal
+`, StartupWrappedScriptKey, StartupScriptStatusKey, StartupScriptStatusDone, StartupScriptStatusError,
+	StartupScriptGuestAttributesNamespace, StartupScriptGuestAttributesKey,
+	StartupScriptExitCodeKey, StartupScriptDurationKey, StartupScriptLogTailKey, startupScriptLogTailLines,
+	StartupWrappedScriptURLKey)
+
+var ShutdownScriptLinux string = fmt.Sprintf(`#!/usr/bin/env bash
+echo "Packer shutdown script starting."
+SHUTDOWNSCRIPT=$(curl -f -H "Metadata-Flavor: Google" http://metadata.google.internal/computeMetadata/v1/instance/attributes/%[1]s 2> /dev/null)
+SHUTDOWNSCRIPTPATH=/packer-wrapped-shutdown-script
+
+echo "Executing user-provided shutdown script..."
+echo "${SHUTDOWNSCRIPT}" > ${SHUTDOWNSCRIPTPATH}
+chmod +x ${SHUTDOWNSCRIPTPATH}
+${SHUTDOWNSCRIPTPATH}
+RETVAL=$?
+rm ${SHUTDOWNSCRIPTPATH}
+
+if [ $RETVAL -ne 0 ]; then
+  echo "%[3]s${RETVAL}"
+else
+  echo "%[2]s"
+fi
+
+exit $RETVAL
+`, ShutdownWrappedScriptKey, ShutdownScriptDoneMarker, ShutdownScriptErrorMarker)
+
+var ShutdownScriptWindows string = fmt.Sprintf(`Write-Output "Packer shutdown script starting."
+$ShutdownScript = Invoke-RestMethod -Headers @{"Metadata-Flavor" = "Google"} -Uri "http://metadata.google.internal/computeMetadata/v1/instance/attributes/%[1]s"
+$ShutdownScriptPath = Join-Path $env:TEMP "packer-wrapped-shutdown-script.ps1"
+
+Write-Output "Executing user-provided shutdown script..."
+Set-Content -Path $ShutdownScriptPath -Value $ShutdownScript
+& powershell.exe -NoProfile -NonInteractive -ExecutionPolicy Bypass -File $ShutdownScriptPath
+$RetVal = $LASTEXITCODE
+Remove-Item -Path $ShutdownScriptPath
+
+if ($RetVal -ne 0) {
+  Write-Output "%[3]s$RetVal"
+} else {
+  Write-Output "%[2]s"
+}
 
-var StartupScriptWindows string = ""
+exit $RetVal
+`, ShutdownWrappedScriptKey, ShutdownScriptDoneMarker, ShutdownScriptErrorMarker)
@@ -0,0 +1,220 @@
+// Copyright IBM Corp. 2013, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc struct-markdown
+//go:generate packer-sdc mapstructure-to-hcl2 -type FamilyRetention
+
+package googlecompute
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-googlecompute/lib/common"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"google.golang.org/api/compute/v1"
+)
+
+// FamilyRetention manages the previous images of `image_family` once a new
+// image is built. The images are ranked from the newest, the built image
+// being the first.
+type FamilyRetention struct {
+	// If true, mark the previous images of the family `DEPRECATED`, with the
+	// built image as their replacement.
+	DeprecatePrevious bool `mapstructure:"deprecate_previous"`
+	// Mark the images ranked after the given count `OBSOLETE`, so that no
+	// new disk can be created from them. Defaults to 0, to never make images
+	// obsolete.
+	ObsoleteAfter int `mapstructure:"obsolete_after"`
+	// Delete the images ranked after the given count. Defaults to 0, to keep
+	// all images.
+	KeepLast int `mapstructure:"keep_last"`
+	// Delete the images created longer ago than the given duration, e.g.
+	// `2160h` for 90 days. Defaults to 0, to keep all images.
+	DeleteOlderThan time.Duration `mapstructure:"delete_older_than"`
+	// The label marking images that must be left unchanged: the images with
+	// this label set to `true` are neither deprecated, made obsolete nor
+	// deleted. Defaults to `packer-protected`.
+	ProtectionLabel string `mapstructure:"protection_label"`
+}
+
+// Prepare sets the defaults of the retention and checks its settings.
+func (r *FamilyRetention) Prepare() []error {
+	var errs []error
+
+	if r.ProtectionLabel == "" {
+		r.ProtectionLabel = "packer-protected"
+	}
+	if r.ObsoleteAfter < 0 {
+		errs = append(errs, fmt.Errorf("image_family_retention: obsolete_after must be positive"))
+	}
+	if r.KeepLast < 0 {
+		errs = append(errs, fmt.Errorf("image_family_retention: keep_last must be positive"))
+	}
+	if r.ObsoleteAfter > 0 && r.KeepLast > 0 && r.ObsoleteAfter >= r.KeepLast {
+		errs = append(errs, fmt.Errorf("image_family_retention: obsolete_after must be lower than keep_last, "+
+			"as images beyond keep_last are deleted"))
+	}
+	if r.DeleteOlderThan < 0 {
+		errs = append(errs, fmt.Errorf("image_family_retention: delete_older_than must be positive"))
+	}
+
+	return errs
+}
+
+// The actions the retention takes on a previous image of the family.
+const (
+	retentionKeep      = "keep"
+	retentionProtected = "keep (protected)"
+	retentionDeprecate = "deprecate"
+	retentionObsolete  = "obsolete"
+	retentionDelete    = "delete"
+)
+
+// retentionAction is the action planned on a previous image of the family.
+type retentionAction struct {
+	image  *compute.Image
+	action string
+}
+
+// plan returns the actions to take on the images of the family once the
+// image named newImage is built, from the newest image to the oldest. The
+// images named in built, made by the same build, are left out.
+func (r *FamilyRetention) plan(images []*compute.Image, newImage string, built map[string]bool, now time.Time) []retentionAction {
+	sorted := make([]*compute.Image, 0, len(images))
+	for _, image := range images {
+		if image.Name != newImage && !built[image.Name] {
+			sorted = append(sorted, image)
+		}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return creationTime(sorted[i]).After(creationTime(sorted[j]))
+	})
+
+	var actions []retentionAction
+	for i, image := range sorted {
+		// The built image ranks first.
+		rank := i + 2
+		state := ""
+		if image.Deprecated != nil {
+			state = image.Deprecated.State
+		}
+
+		action := retentionKeep
+		created := creationTime(image)
+		switch {
+		case image.Labels[r.ProtectionLabel] == "true":
+			action = retentionProtected
+		case r.KeepLast > 0 && rank > r.KeepLast,
+			r.DeleteOlderThan > 0 && !created.IsZero() && now.Sub(created) > r.DeleteOlderThan:
+			action = retentionDelete
+		case state == "OBSOLETE" || state == "DELETED":
+		case r.ObsoleteAfter > 0 && rank > r.ObsoleteAfter:
+			action = retentionObsolete
+		case state == "DEPRECATED":
+		case r.DeprecatePrevious:
+			action = retentionDeprecate
+		}
+		actions = append(actions, retentionAction{image: image, action: action})
+	}
+	return actions
+}
+
+// creationTime returns the time the image was created, or the zero time if
+// it cannot be parsed.
+func creationTime(image *compute.Image) time.Time {
+	t, err := time.Parse(time.RFC3339, image.CreationTimestamp)
+	if err != nil {
+		log.Printf("[WARN] Failed to parse the creation time %q of image %s: %s", image.CreationTimestamp, image.Name, err)
+	}
+	return t
+}
+
+// StepApplyFamilyRetention represents a Packer build step that deprecates,
+// makes obsolete or deletes the previous images of the family of the built
+// image.
+type StepApplyFamilyRetention int
+
+// Run executes the Packer build step that applies the retention of the image
+// family. It prints the plan before acting on the images. Failing to act on
+// an image does not fail the build, as the built image is ready.
+func (s *StepApplyFamilyRetention) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	config := state.Get("config").(*Config)
+	driver := state.Get("driver").(common.Driver)
+	ui := state.Get("ui").(packersdk.Ui)
+
+	retention := config.ImageFamilyRetention
+	if retention == nil {
+		return multistep.ActionContinue
+	}
+
+	// No image is created with skip_create_image.
+	rawImage, ok := state.GetOk("image")
+	if !ok {
+		ui.Say("Skipping retention of the image family, as no image was created...")
+		return multistep.ActionContinue
+	}
+	image := rawImage.(*common.Image)
+
+	ui.Say(fmt.Sprintf("Applying retention to image family %s...", config.ImageFamily))
+	images, err := driver.ListImagesInFamily(ctx, config.ImageProjectId, config.ImageFamily)
+	if err != nil {
+		ui.Error(fmt.Sprintf("Error listing the images of family %s, skipping retention: %s", config.ImageFamily, err))
+		return multistep.ActionContinue
+	}
+
+	// The copies and disk images of the build in the same project may share
+	// the family, they are not previous images.
+	built := map[string]bool{}
+	for _, key := range []string{"image_copies", "disk_images"} {
+		if images, ok := state.GetOk(key); ok {
+			for _, i := range images.([]*common.Image) {
+				if i.ProjectId == config.ImageProjectId {
+					built[i.Name] = true
+				}
+			}
+		}
+	}
+
+	actions := retention.plan(images, image.Name, built, time.Now().UTC())
+	if len(actions) == 0 {
+		ui.Message("No previous image in the family")
+		return multistep.ActionContinue
+	}
+	for _, a := range actions {
+		ui.Message(fmt.Sprintf("%-16s %s (created %s)", a.action, a.image.Name, a.image.CreationTimestamp))
+	}
+
+	for _, a := range actions {
+		switch a.action {
+		case retentionDeprecate, retentionObsolete:
+			state := "DEPRECATED"
+			if a.action == retentionObsolete {
+				state = "OBSOLETE"
+			}
+			err = driver.SetImageDeprecationStatus(ctx, config.ImageProjectId, a.image.Name, &compute.DeprecationStatus{
+				State:       state,
+				Replacement: image.SelfLink,
+			})
+		case retentionDelete:
+			err = <-driver.DeleteImage(ctx, config.ImageProjectId, a.image.Name)
+		default:
+			continue
+		}
+
+		if err != nil {
+			ui.Error(fmt.Sprintf("Error applying %s to image %s: %s", a.action, a.image.Name, err))
+			continue
+		}
+		log.Printf("Applied %s to image %s", a.action, a.image.Name)
+	}
+
+	return multistep.ActionContinue
+}
+
+// Cleanup.
+func (s *StepApplyFamilyRetention) Cleanup(state multistep.StateBag) {}
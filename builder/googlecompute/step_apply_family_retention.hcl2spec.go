@@ -0,0 +1,39 @@
+// Code generated by "packer-sdc mapstructure-to-hcl2"; DO NOT EDIT.
+
+package googlecompute
+
+import (
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// FlatFamilyRetention is an auto-generated flat version of FamilyRetention.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatFamilyRetention struct {
+	DeprecatePrevious *bool   `mapstructure:"deprecate_previous" cty:"deprecate_previous" hcl:"deprecate_previous"`
+	ObsoleteAfter     *int    `mapstructure:"obsolete_after" cty:"obsolete_after" hcl:"obsolete_after"`
+	KeepLast          *int    `mapstructure:"keep_last" cty:"keep_last" hcl:"keep_last"`
+	DeleteOlderThan   *string `mapstructure:"delete_older_than" cty:"delete_older_than" hcl:"delete_older_than"`
+	ProtectionLabel   *string `mapstructure:"protection_label" cty:"protection_label" hcl:"protection_label"`
+}
+
+// FlatMapstructure returns a new FlatFamilyRetention.
+// FlatFamilyRetention is an auto-generated flat version of FamilyRetention.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*FamilyRetention) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatFamilyRetention)
+}
+
+// HCL2Spec returns the hcl spec of a FamilyRetention.
+// This spec is used by HCL to read the fields of FamilyRetention.
+// The decoded values from this spec will then be applied to a FlatFamilyRetention.
+func (*FlatFamilyRetention) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"deprecate_previous": &hcldec.AttrSpec{Name: "deprecate_previous", Type: cty.Bool, Required: false},
+		"obsolete_after":     &hcldec.AttrSpec{Name: "obsolete_after", Type: cty.Number, Required: false},
+		"keep_last":          &hcldec.AttrSpec{Name: "keep_last", Type: cty.Number, Required: false},
+		"delete_older_than":  &hcldec.AttrSpec{Name: "delete_older_than", Type: cty.String, Required: false},
+		"protection_label":   &hcldec.AttrSpec{Name: "protection_label", Type: cty.String, Required: false},
+	}
+	return s
+}
@@ -0,0 +1,170 @@
+// Copyright IBM Corp. 2013, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package googlecompute
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-googlecompute/lib/common"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/api/compute/v1"
+)
+
+func TestStepApplyFamilyRetention_impl(t *testing.T) {
+	var _ multistep.Step = new(StepApplyFamilyRetention)
+}
+
+func familyImage(name string, age time.Duration, now time.Time) *compute.Image {
+	return &compute.Image{Name: name, CreationTimestamp: now.Add(-age).Format(time.RFC3339)}
+}
+
+func TestFamilyRetention_plan(t *testing.T) {
+	now := time.Now().UTC()
+	day := 24 * time.Hour
+
+	protected := familyImage("image-5", 5*day, now)
+	protected.Labels = map[string]string{"packer-protected": "true"}
+	deprecated := familyImage("image-2", 2*day, now)
+	deprecated.Deprecated = &compute.DeprecationStatus{State: "DEPRECATED"}
+	obsolete := familyImage("image-4", 4*day, now)
+	obsolete.Deprecated = &compute.DeprecationStatus{State: "OBSOLETE"}
+
+	// Listed out of order, along with the built image.
+	images := []*compute.Image{
+		familyImage("image-3", 3*day, now),
+		familyImage("image-0", 0, now),
+		protected,
+		familyImage("image-1", day, now),
+		obsolete,
+		familyImage("image-6", 6*day, now),
+		deprecated,
+		familyImage("image-7", 100*day, now),
+	}
+
+	r := &FamilyRetention{DeprecatePrevious: true, ObsoleteAfter: 3, KeepLast: 6, DeleteOlderThan: 90 * day}
+	assert.Empty(t, r.Prepare())
+
+	var got []string
+	for _, a := range r.plan(images, "image-0", nil, now) {
+		got = append(got, a.image.Name+": "+a.action)
+	}
+	assert.Equal(t, []string{
+		"image-1: " + retentionDeprecate,
+		"image-2: " + retentionKeep, // already deprecated
+		"image-3: " + retentionObsolete,
+		"image-4: " + retentionKeep, // already obsolete
+		"image-5: " + retentionProtected,
+		"image-6: " + retentionDelete,
+		"image-7: " + retentionDelete,
+	}, got)
+}
+
+func TestFamilyRetention_Prepare(t *testing.T) {
+	r := &FamilyRetention{}
+	assert.Empty(t, r.Prepare())
+	assert.Equal(t, "packer-protected", r.ProtectionLabel)
+
+	r = &FamilyRetention{ObsoleteAfter: 5, KeepLast: 5}
+	assert.Len(t, r.Prepare(), 1, "Images should be deleted after they are made obsolete.")
+
+	r = &FamilyRetention{KeepLast: -1, DeleteOlderThan: -time.Hour}
+	assert.Len(t, r.Prepare(), 2)
+}
+
+func TestStepApplyFamilyRetention(t *testing.T) {
+	state := testState(t)
+	step := new(StepApplyFamilyRetention)
+	defer step.Cleanup(state)
+
+	now := time.Now().UTC()
+	c := state.Get("config").(*Config)
+	c.ImageFamily = "web"
+	c.ImageFamilyRetention = &FamilyRetention{DeprecatePrevious: true, KeepLast: 2, ProtectionLabel: "packer-protected"}
+	selfLink := "https://compute.googleapis.com/compute/v1/projects/hashicorp/global/images/image-0"
+	state.Put("image", &common.Image{Name: "image-0", SelfLink: selfLink})
+
+	d := state.Get("driver").(*common.DriverMock)
+	d.ListImagesInFamilyResult = []*compute.Image{
+		familyImage("image-0", 0, now),
+		familyImage("image-1", time.Hour, now),
+		familyImage("image-2", 2*time.Hour, now),
+	}
+
+	assert.Equal(t, multistep.ActionContinue, step.Run(context.Background(), state), "Step should have passed and continued.")
+
+	assert.Equal(t, c.ImageProjectId, d.ListImagesInFamilyProjectId)
+	assert.Equal(t, "web", d.ListImagesInFamilyFamily)
+	assert.Equal(t, &compute.DeprecationStatus{State: "DEPRECATED", Replacement: selfLink}, d.DeprecatedImages["image-1"])
+	assert.Equal(t, []string{"image-2"}, d.DeleteImageNames)
+}
+
+func TestStepApplyFamilyRetention_imageCopies(t *testing.T) {
+	state := testState(t)
+	step := new(StepApplyFamilyRetention)
+	defer step.Cleanup(state)
+
+	now := time.Now().UTC()
+	c := state.Get("config").(*Config)
+	c.ImageFamily = "web"
+	c.ImageFamilyRetention = &FamilyRetention{KeepLast: 1, ProtectionLabel: "packer-protected"}
+	state.Put("image", &common.Image{Name: "image-0", ProjectId: c.ImageProjectId})
+	state.Put("image_copies", []*common.Image{{Name: "image-0-eu", ProjectId: c.ImageProjectId}})
+	state.Put("disk_images", []*common.Image{{Name: "image-0-data", ProjectId: c.ImageProjectId}})
+
+	d := state.Get("driver").(*common.DriverMock)
+	d.ListImagesInFamilyResult = []*compute.Image{
+		familyImage("image-0", 0, now),
+		familyImage("image-0-eu", 0, now),
+		familyImage("image-0-data", 0, now),
+		familyImage("image-1", time.Hour, now),
+	}
+
+	assert.Equal(t, multistep.ActionContinue, step.Run(context.Background(), state), "Step should have passed and continued.")
+	assert.Equal(t, []string{"image-1"}, d.DeleteImageNames, "The images of the build should have been kept.")
+}
+
+func TestStepApplyFamilyRetention_skipCreateImage(t *testing.T) {
+	state := testState(t)
+	step := new(StepApplyFamilyRetention)
+	defer step.Cleanup(state)
+
+	now := time.Now().UTC()
+	c := state.Get("config").(*Config)
+	c.SkipCreateImage = true
+	c.ImageFamily = "web"
+	c.ImageFamilyRetention = &FamilyRetention{KeepLast: 1, DeprecatePrevious: true}
+
+	d := state.Get("driver").(*common.DriverMock)
+	d.ListImagesInFamilyResult = []*compute.Image{
+		familyImage("image-1", time.Hour, now),
+		familyImage("image-2", 2*time.Hour, now),
+	}
+
+	assert.Equal(t, multistep.ActionContinue, new(StepCreateImage).Run(context.Background(), state))
+	assert.Equal(t, multistep.ActionContinue, step.Run(context.Background(), state), "Step should have passed and continued.")
+	assert.Empty(t, d.DeleteImageNames, "No image should be deleted without a new image.")
+	assert.Empty(t, d.DeprecatedImages, "No image should be deprecated without a new image.")
+}
+
+func TestStepApplyFamilyRetention_listError(t *testing.T) {
+	state := testState(t)
+	step := new(StepApplyFamilyRetention)
+	defer step.Cleanup(state)
+
+	c := state.Get("config").(*Config)
+	c.ImageFamily = "web"
+	c.ImageFamilyRetention = &FamilyRetention{KeepLast: 1}
+	state.Put("image", &common.Image{Name: "image-0"})
+
+	d := state.Get("driver").(*common.DriverMock)
+	d.ListImagesInFamilyErr = errors.New("error")
+
+	assert.Equal(t, multistep.ActionContinue, step.Run(context.Background(), state),
+		"The built image is ready, the build should not fail on retention errors.")
+	assert.Empty(t, d.DeleteImageNames)
+}
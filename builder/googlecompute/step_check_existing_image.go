@@ -6,6 +6,7 @@ package googlecompute
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/hashicorp/packer-plugin-googlecompute/lib/common"
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
@@ -22,15 +23,44 @@ func (s *StepCheckExistingImage) Run(ctx context.Context, state multistep.StateB
 	d := state.Get("driver").(common.Driver)
 	ui := state.Get("ui").(packersdk.Ui)
 
-	ui.Say("Checking image does not exist...")
-	c.imageAlreadyExists = d.ImageExists(c.ImageProjectId, c.ImageName)
+	kind := artifactTypeName(c.ArtifactType)
+	ui.Say(fmt.Sprintf("Checking %s does not exist...", kind))
+	switch c.ArtifactType {
+	case ArtifactTypeMachineImage:
+		machineImage, err := d.GetMachineImage(ctx, c.ImageProjectId, c.ImageName)
+		c.imageAlreadyExists = err == nil && machineImage != nil
+	case ArtifactTypeSnapshot:
+		snapshot, err := d.GetSnapshot(ctx, c.ImageProjectId, c.ImageName)
+		c.imageAlreadyExists = err == nil && snapshot != nil
+	default:
+		c.imageAlreadyExists = d.ImageExists(ctx, c.ImageProjectId, c.ImageName)
+	}
 	if !c.PackerForce && c.imageAlreadyExists {
-		err := fmt.Errorf("Image %s already exists in project %s.\n"+
-			"Use the force flag to delete it prior to building.", c.ImageName, c.ImageProjectId)
+		err := fmt.Errorf("%s %s already exists in project %s.\n"+
+			"Use the force flag to delete it prior to building.", strings.ToUpper(kind[:1])+kind[1:], c.ImageName, c.ImageProjectId)
 		state.Put("error", err)
 		ui.Error(err.Error())
 		return multistep.ActionHalt
 	}
+	for _, ic := range c.ImageCopies {
+		name := ic.name(c.ImageName)
+		if !c.PackerForce && d.ImageExists(ctx, ic.ProjectId, name) {
+			err := fmt.Errorf("Image copy %s already exists in project %s.\n"+
+				"Use the force flag to delete it prior to building.", name, ic.ProjectId)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+	for _, bd := range c.diskImages() {
+		if !c.PackerForce && d.ImageExists(ctx, c.ImageProjectId, bd.ImageName) {
+			err := fmt.Errorf("Disk image %s already exists in project %s.\n"+
+				"Use the force flag to delete it prior to building.", bd.ImageName, c.ImageProjectId)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
 	return multistep.ActionContinue
 }
 
@@ -5,10 +5,12 @@ package googlecompute
 
 import (
 	"context"
+	"errors"
 	"testing"
 
 	"github.com/hashicorp/packer-plugin-googlecompute/lib/common"
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	compute "google.golang.org/api/compute/v1"
 )
 
 func TestStepCheckExistingImage_impl(t *testing.T) {
@@ -36,3 +38,90 @@ func TestStepCheckExistingImage(t *testing.T) {
 		t.Fatalf("bad: %#v", driver.ImageExistsName)
 	}
 }
+
+func TestStepCheckExistingImage_snapshot(t *testing.T) {
+	state := testState(t)
+	step := new(StepCheckExistingImage)
+	defer step.Cleanup(state)
+
+	config := state.Get("config").(*Config)
+	config.ArtifactType = ArtifactTypeSnapshot
+	driver := state.Get("driver").(*common.DriverMock)
+	driver.ImageExistsResult = true
+	driver.GetSnapshotResult = &compute.Snapshot{Name: config.ImageName}
+
+	// run the step
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	// Verify state
+	if driver.GetSnapshotName != config.ImageName {
+		t.Fatalf("bad: %#v", driver.GetSnapshotName)
+	}
+	if driver.ImageExistsName != "" {
+		t.Fatal("should not look for a disk image")
+	}
+}
+
+func TestStepCheckExistingImage_machineImageMissing(t *testing.T) {
+	state := testState(t)
+	step := new(StepCheckExistingImage)
+	defer step.Cleanup(state)
+
+	config := state.Get("config").(*Config)
+	config.ArtifactType = ArtifactTypeMachineImage
+	driver := state.Get("driver").(*common.DriverMock)
+	driver.GetMachineImageErr = errors.New("googleapi: Error 404: not found")
+
+	// run the step
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	if driver.GetMachineImageName != config.ImageName {
+		t.Fatalf("bad: %#v", driver.GetMachineImageName)
+	}
+}
+
+func TestStepCheckExistingImage_diskImage(t *testing.T) {
+	state := testState(t)
+	step := new(StepCheckExistingImage)
+	defer step.Cleanup(state)
+
+	config := state.Get("config").(*Config)
+	config.ExtraBlockDevices = []common.BlockDevice{
+		{DiskName: "db-disk", CreateImage: true, ImageName: "db-image"},
+	}
+	driver := state.Get("driver").(*common.DriverMock)
+
+	// run the step
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	// Verify the disk image was checked after the image of image_name
+	if driver.ImageExistsName != "db-image" {
+		t.Fatalf("bad: %#v", driver.ImageExistsName)
+	}
+}
+
+func TestStepCheckExistingImage_imageCopy(t *testing.T) {
+	state := testState(t)
+	step := new(StepCheckExistingImage)
+	defer step.Cleanup(state)
+
+	config := state.Get("config").(*Config)
+	config.ImageCopies = []ImageCopy{{ProjectId: "production", NameSuffix: "-eu"}}
+	driver := state.Get("driver").(*common.DriverMock)
+
+	// run the step
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	// Verify the copy was checked after the image of image_name
+	if driver.ImageExistsProjectId != "production" || driver.ImageExistsName != config.ImageName+"-eu" {
+		t.Fatalf("bad: %#v/%#v", driver.ImageExistsProjectId, driver.ImageExistsName)
+	}
+}
@@ -0,0 +1,147 @@
+// Copyright IBM Corp. 2013, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc struct-markdown
+//go:generate packer-sdc mapstructure-to-hcl2 -type ImageCopy
+
+package googlecompute
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/packer-plugin-googlecompute/lib/common"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"google.golang.org/api/compute/v1"
+)
+
+// ImageCopy is a copy of the built image, in another project or storage
+// location.
+type ImageCopy struct {
+	// The project to copy the image into. Defaults to `image_project_id`.
+	ProjectId string `mapstructure:"project_id"`
+	// The storage location of the copy, either regional or multi-regional,
+	// e.g. `eu`. Defaults to a location near the built image.
+	StorageLocation string `mapstructure:"storage_location"`
+	// A suffix appended to `image_name` to name the copy, e.g. `-eu`. It must
+	// be set for copies in `image_project_id`, where the name is taken by the
+	// built image.
+	NameSuffix string `mapstructure:"name_suffix"`
+	// The image family of the copy. Defaults to `image_family` for copies
+	// into another project. Copies in `image_project_id` have no family
+	// unless it is set, so that the built image alone ranks first in
+	// `image_family`; the family of such a copy must then differ from
+	// `image_family`.
+	Family string `mapstructure:"family"`
+	// The customer-supplied encryption key to encrypt the copy with. The copy
+	// is not encrypted with `image_encryption_key`, which only applies to the
+	// built image.
+	EncryptionKey *common.CustomerEncryptionKey `mapstructure:"encryption_key"`
+}
+
+// name returns the name of the copy of the image named imageName.
+func (ic *ImageCopy) name(imageName string) string {
+	return imageName + ic.NameSuffix
+}
+
+// prepareImageCopies sets the defaults of the image copies, and checks that
+// their names are valid and do not clash.
+func (c *Config) prepareImageCopies() []error {
+	var errs []error
+
+	names := map[string]bool{c.ImageProjectId + "/" + c.ImageName: true}
+	for i := range c.ImageCopies {
+		ic := &c.ImageCopies[i]
+		if ic.ProjectId == "" {
+			ic.ProjectId = c.ImageProjectId
+		}
+		switch {
+		case ic.Family == "":
+			if ic.ProjectId != c.ImageProjectId {
+				ic.Family = c.ImageFamily
+			}
+		case !validImageName.MatchString(ic.Family):
+			errs = append(errs, fmt.Errorf("image_copy: invalid family %q", ic.Family))
+		case ic.ProjectId == c.ImageProjectId && ic.Family == c.ImageFamily:
+			errs = append(errs, fmt.Errorf("image_copy: the copy in project %s cannot be in image_family %s, "+
+				"where it would replace the built image", ic.ProjectId, ic.Family))
+		}
+
+		name := ic.name(c.ImageName)
+		if !validImageName.MatchString(name) {
+			errs = append(errs, fmt.Errorf("image_copy: invalid name %q for the copy in project %s, "+
+				"name_suffix must only have dashes, lowercase letters or digits, and not end with a dash", name, ic.ProjectId))
+		}
+		if names[ic.ProjectId+"/"+name] {
+			errs = append(errs, fmt.Errorf("image_copy: image %s is already created in project %s, "+
+				"set a different name_suffix", name, ic.ProjectId))
+		}
+		names[ic.ProjectId+"/"+name] = true
+	}
+
+	return errs
+}
+
+// StepCopyImage represents a Packer build step that copies the built image
+// into other projects and storage locations.
+type StepCopyImage int
+
+// Run executes the Packer build step that copies the image. The copies are
+// all created at once, and the step waits for all of them.
+func (s *StepCopyImage) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	config := state.Get("config").(*Config)
+	ui := state.Get("ui").(packersdk.Ui)
+
+	if len(config.ImageCopies) == 0 {
+		return multistep.ActionContinue
+	}
+
+	// No image is created with skip_create_image.
+	rawImage, ok := state.GetOk("image")
+	if !ok {
+		ui.Say("Skipping image copies, as no image was created...")
+		return multistep.ActionContinue
+	}
+	image := rawImage.(*common.Image)
+
+	ui.Say(fmt.Sprintf("Copying image into %d project(s) and location(s)...", len(config.ImageCopies)))
+	var images []extraImage
+	for _, ic := range config.ImageCopies {
+		var storageLocations []string
+		if ic.StorageLocation != "" {
+			storageLocations = []string{ic.StorageLocation}
+		}
+
+		name := ic.name(config.ImageName)
+		images = append(images, extraImage{
+			desc:    fmt.Sprintf("copy %s in project %s", name, ic.ProjectId),
+			project: ic.ProjectId,
+			spec: &compute.Image{
+				Description:              config.ImageDescription,
+				Family:                   ic.Family,
+				ImageEncryptionKey:       ic.EncryptionKey.ComputeType(),
+				Labels:                   config.ImageLabels,
+				Name:                     name,
+				SourceImage:              image.SelfLink,
+				SourceImageEncryptionKey: config.ImageEncryptionKey.ComputeType(),
+				StorageLocations:         storageLocations,
+			},
+		})
+	}
+
+	copies, err := createExtraImages(ctx, state, images)
+	if err != nil {
+		err := fmt.Errorf("Error copying image: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	ui.Message(fmt.Sprintf("Image has been copied %d time(s)!", len(copies)))
+	state.Put("image_copies", copies)
+	return multistep.ActionContinue
+}
+
+// Cleanup.
+func (s *StepCopyImage) Cleanup(state multistep.StateBag) {}
@@ -0,0 +1,40 @@
+// Code generated by "packer-sdc mapstructure-to-hcl2"; DO NOT EDIT.
+
+package googlecompute
+
+import (
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/hashicorp/packer-plugin-googlecompute/lib/common"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// FlatImageCopy is an auto-generated flat version of ImageCopy.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatImageCopy struct {
+	ProjectId       *string                           `mapstructure:"project_id" cty:"project_id" hcl:"project_id"`
+	StorageLocation *string                           `mapstructure:"storage_location" cty:"storage_location" hcl:"storage_location"`
+	NameSuffix      *string                           `mapstructure:"name_suffix" cty:"name_suffix" hcl:"name_suffix"`
+	Family          *string                           `mapstructure:"family" cty:"family" hcl:"family"`
+	EncryptionKey   *common.FlatCustomerEncryptionKey `mapstructure:"encryption_key" cty:"encryption_key" hcl:"encryption_key"`
+}
+
+// FlatMapstructure returns a new FlatImageCopy.
+// FlatImageCopy is an auto-generated flat version of ImageCopy.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*ImageCopy) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatImageCopy)
+}
+
+// HCL2Spec returns the hcl spec of a ImageCopy.
+// This spec is used by HCL to read the fields of ImageCopy.
+// The decoded values from this spec will then be applied to a FlatImageCopy.
+func (*FlatImageCopy) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"project_id":       &hcldec.AttrSpec{Name: "project_id", Type: cty.String, Required: false},
+		"storage_location": &hcldec.AttrSpec{Name: "storage_location", Type: cty.String, Required: false},
+		"name_suffix":      &hcldec.AttrSpec{Name: "name_suffix", Type: cty.String, Required: false},
+		"family":           &hcldec.AttrSpec{Name: "family", Type: cty.String, Required: false},
+		"encryption_key":   &hcldec.BlockSpec{TypeName: "encryption_key", Nested: hcldec.ObjectSpec((*common.FlatCustomerEncryptionKey)(nil).HCL2Spec())},
+	}
+	return s
+}
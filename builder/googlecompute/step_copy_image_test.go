@@ -0,0 +1,122 @@
+// Copyright IBM Corp. 2013, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package googlecompute
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-googlecompute/lib/common"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStepCopyImage_impl(t *testing.T) {
+	var _ multistep.Step = new(StepCopyImage)
+}
+
+func TestStepCopyImage(t *testing.T) {
+	state := testState(t)
+	step := new(StepCopyImage)
+	defer step.Cleanup(state)
+
+	c := state.Get("config").(*Config)
+	c.ImageName = "test-image"
+	c.ImageCopies = []ImageCopy{
+		{ProjectId: "production", StorageLocation: "eu", Family: "web"},
+		{ProjectId: c.ImageProjectId, NameSuffix: "-asia", StorageLocation: "asia"},
+	}
+	selfLink := "https://compute.googleapis.com/compute/v1/projects/hashicorp/global/images/test-image"
+	state.Put("image", &common.Image{Name: "test-image", ProjectId: c.ImageProjectId, SelfLink: selfLink})
+	d := state.Get("driver").(*common.DriverMock)
+
+	assert.Equal(t, multistep.ActionContinue, step.Run(context.Background(), state), "Step should have passed and continued.")
+
+	if assert.Len(t, d.CreateImageSpecs, 2) {
+		assert.Equal(t, "test-image", d.CreateImageSpecs[0].Name)
+		assert.Equal(t, selfLink, d.CreateImageSpecs[0].SourceImage)
+		assert.Equal(t, []string{"eu"}, d.CreateImageSpecs[0].StorageLocations)
+		assert.Equal(t, "web", d.CreateImageSpecs[0].Family)
+		assert.Equal(t, "test-image-asia", d.CreateImageSpecs[1].Name)
+		assert.Equal(t, []string{"asia"}, d.CreateImageSpecs[1].StorageLocations)
+	}
+
+	copies := state.Get("image_copies").([]*common.Image)
+	if assert.Len(t, copies, 2) {
+		assert.Equal(t, "production", copies[0].ProjectId)
+		assert.Equal(t, "test-image-asia", copies[1].Name)
+	}
+}
+
+func TestStepCopyImage_noCopies(t *testing.T) {
+	state := testState(t)
+	step := new(StepCopyImage)
+	defer step.Cleanup(state)
+
+	assert.Equal(t, multistep.ActionContinue, step.Run(context.Background(), state), "Step should have passed and continued.")
+
+	d := state.Get("driver").(*common.DriverMock)
+	assert.Empty(t, d.CreateImageSpecs, "No image should be created without copies.")
+	_, ok := state.GetOk("image_copies")
+	assert.False(t, ok)
+}
+
+func TestStepCopyImage_skipCreateImage(t *testing.T) {
+	state := testState(t)
+	step := new(StepCopyImage)
+	defer step.Cleanup(state)
+
+	c := state.Get("config").(*Config)
+	c.SkipCreateImage = true
+	c.ImageCopies = []ImageCopy{{ProjectId: "production"}}
+
+	assert.Equal(t, multistep.ActionContinue, new(StepCreateImage).Run(context.Background(), state))
+	assert.Equal(t, multistep.ActionContinue, step.Run(context.Background(), state), "Step should have passed and continued.")
+
+	d := state.Get("driver").(*common.DriverMock)
+	assert.Empty(t, d.CreateImageSpecs, "No copy should be created without an image.")
+	_, ok := state.GetOk("image_copies")
+	assert.False(t, ok)
+}
+
+func TestStepCopyImage_force(t *testing.T) {
+	state := testState(t)
+	step := new(StepCopyImage)
+	defer step.Cleanup(state)
+
+	c := state.Get("config").(*Config)
+	c.PackerForce = true
+	c.ImageName = "test-image"
+	c.ImageCopies = []ImageCopy{{ProjectId: "production", NameSuffix: "-eu"}}
+	state.Put("image", &common.Image{Name: "test-image", ProjectId: c.ImageProjectId})
+	d := state.Get("driver").(*common.DriverMock)
+	d.ImageExistsResult = true
+
+	assert.Equal(t, multistep.ActionContinue, step.Run(context.Background(), state), "Step should have passed and continued.")
+	assert.Equal(t, []string{"test-image-eu"}, d.DeleteImageNames, "The previous copy should be deleted.")
+	assert.Equal(t, "production", d.DeleteProjectId)
+	assert.Len(t, d.CreateImageSpecs, 1)
+}
+
+func TestStepCopyImage_error(t *testing.T) {
+	state := testState(t)
+	step := new(StepCopyImage)
+	defer step.Cleanup(state)
+
+	c := state.Get("config").(*Config)
+	c.ImageCopies = []ImageCopy{{ProjectId: "production"}}
+	state.Put("image", &common.Image{Name: "test-image"})
+
+	errCh := make(chan error, 1)
+	errCh <- errors.New("error")
+	d := state.Get("driver").(*common.DriverMock)
+	d.CreateImageErrCh = errCh
+
+	assert.Equal(t, multistep.ActionHalt, step.Run(context.Background(), state), "Step should have failed and halted.")
+	_, ok := state.GetOk("error")
+	assert.True(t, ok, "State should have an error.")
+	_, ok = state.GetOk("image_copies")
+	assert.False(t, ok, "State should not have image copies.")
+}
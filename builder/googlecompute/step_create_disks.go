@@ -5,10 +5,8 @@ package googlecompute
 
 import (
 	"context"
-	"errors"
 	"fmt"
-	"strings"
-	"time"
+	"slices"
 
 	"github.com/hashicorp/packer-plugin-googlecompute/lib/common"
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
@@ -17,6 +15,9 @@ import (
 
 type StepCreateDisks struct {
 	DiskConfiguration []common.BlockDevice
+
+	// created holds the indices of the disks created by the step.
+	created []int
 }
 
 func (s *StepCreateDisks) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
@@ -27,9 +28,6 @@ func (s *StepCreateDisks) Run(ctx context.Context, state multistep.StateBag) mul
 		return multistep.ActionContinue
 	}
 
-	driver := state.Get("driver").(common.Driver)
-	config := state.Get("config").(*Config)
-
 	for i, disk := range s.DiskConfiguration {
 		if disk.VolumeType == common.LocalScratch {
 			continue
@@ -39,40 +37,95 @@ func (s *StepCreateDisks) Run(ctx context.Context, state multistep.StateBag) mul
 			continue
 		}
 
-		ui.Say(fmt.Sprintf("Creating persistent disk %s", disk.DiskName))
-
-		_, errCh := driver.CreateDisk(disk)
-
-		var err error
-		select {
-		case err = <-errCh:
-		case <-time.After(config.StateTimeout):
-			err = errors.New("time out while waiting for disk to create")
-		}
-		if err != nil {
+		if err := s.createDisk(ctx, state, i); err != nil {
 			err := fmt.Errorf("failed to create disk: %s", err)
 			ui.Say(err.Error())
 			state.Put("error", err)
 			return multistep.ActionHalt
 		}
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *StepCreateDisks) createDisk(ctx context.Context, state multistep.StateBag, i int) error {
+	ui := state.Get("ui").(packersdk.Ui)
+	driver := state.Get("driver").(common.Driver)
+	config := state.Get("config").(*Config)
+
+	disk := s.DiskConfiguration[i]
+	ui.Say(fmt.Sprintf("Creating persistent disk %s", disk.DiskName))
+
+	ctx, cancel := context.WithTimeout(ctx, config.StateTimeout)
+	defer cancel()
+	_, errCh := driver.CreateDisk(ctx, disk)
+	if err := waitForOperation(ctx, errCh, "time out while waiting for disk to create"); err != nil {
+		return err
+	}
+	s.created = append(s.created, i)
+
+	if len(disk.ReplicaZones) != 0 {
+		region, _ := common.GetRegionFromZone(disk.Zone)
+		// Generate the source URI for attachment later
+		s.DiskConfiguration[i].SourceVolume = fmt.Sprintf("projects/%s/regions/%s/disks/%s",
+			config.ProjectId,
+			region,
+			disk.DiskName)
+	} else {
+		// Generate the source URI for attachment later
+		s.DiskConfiguration[i].SourceVolume = fmt.Sprintf("projects/%s/zones/%s/disks/%s",
+			config.ProjectId,
+			disk.Zone,
+			disk.DiskName)
+	}
+
+	return nil
+}
+
+// moveToZone deletes the disks created by the step and creates them again in
+// zone, so they can be attached to an instance created in that zone instead.
+// Regional disks are kept, as they can be attached in their replica zones.
+func (s *StepCreateDisks) moveToZone(ctx context.Context, state multistep.StateBag, zone string) error {
+	ui := state.Get("ui").(packersdk.Ui)
+	driver := state.Get("driver").(common.Driver)
+	config := state.Get("config").(*Config)
+
+	// Check the regional disks first, so that no disk is deleted in vain.
+	for _, i := range s.created {
+		disk := s.DiskConfiguration[i]
+		if len(disk.ReplicaZones) != 0 && !slices.Contains(disk.ReplicaZones, zone) {
+			return fmt.Errorf("regional disk %q has no replica in zone %s", disk.DiskName, zone)
+		}
+	}
+
+	created := s.created
+	s.created = nil
+	for _, i := range created {
+		disk := s.DiskConfiguration[i]
 
 		if len(disk.ReplicaZones) != 0 {
-			region, _ := common.GetRegionFromZone(config.Zone)
-			// Generate the source URI for attachment later
-			s.DiskConfiguration[i].SourceVolume = fmt.Sprintf("projects/%s/regions/%s/disks/%s",
-				config.ProjectId,
-				region,
-				disk.DiskName)
-		} else {
-			// Generate the source URI for attachment later
-			s.DiskConfiguration[i].SourceVolume = fmt.Sprintf("projects/%s/zones/%s/disks/%s",
-				config.ProjectId,
-				config.Zone,
-				disk.DiskName)
+			ui.Say(fmt.Sprintf("Keeping regional disk %q, which has a replica in %s", disk.DiskName, zone))
+			s.created = append(s.created, i)
+			continue
+		}
+
+		ui.Say(fmt.Sprintf("Deleting persistent disk %q from %s", disk.DiskName, disk.Zone))
+		deleteCtx, cancel := context.WithTimeout(ctx, config.operationTimeout(config.DiskDeleteTimeout))
+		err := waitForOperation(deleteCtx, driver.DeleteDisk(deleteCtx, disk.Zone, disk.DiskName),
+			"time out while waiting for disk to delete")
+		cancel()
+		if err != nil {
+			return fmt.Errorf("failed to delete disk %q: %s", disk.DiskName, err)
+		}
+
+		s.DiskConfiguration[i].Zone = zone
+		s.DiskConfiguration[i].SourceVolume = ""
+		if err := s.createDisk(ctx, state, i); err != nil {
+			return fmt.Errorf("failed to create disk %q: %s", disk.DiskName, err)
 		}
 	}
 
-	return multistep.ActionContinue
+	return nil
 }
 
 func (s *StepCreateDisks) needToCreateDisks() bool {
@@ -96,6 +149,8 @@ func (s *StepCreateDisks) Cleanup(state multistep.StateBag) {
 	config := state.Get("config").(*Config)
 	driver := state.Get("driver").(common.Driver)
 
+	// The disks are deleted even when the build was cancelled.
+	ctx := context.Background()
 	for _, gceDisk := range s.DiskConfiguration {
 		if gceDisk.KeepDevice {
 			ui.Say(fmt.Sprintf("Keeping disk %q", gceDisk.DiskName))
@@ -113,14 +168,15 @@ func (s *StepCreateDisks) Cleanup(state multistep.StateBag) {
 			zone, _ = common.GetRegionFromZone(zone)
 		}
 
-		_, err := driver.GetDisk(zone, gceDisk.DiskName)
+		_, err := driver.GetDisk(ctx, zone, gceDisk.DiskName)
 		if err != nil {
 			// If the disk isn't found, it's likely because it was auto-deleted
 			// when the instance was torn-down.
 			//
 			// In this case, we don't say anything to the user since the disk is already
 			// gone, and there's nothing they have to do in order to clean it up.
-			if strings.Contains(err.Error(), "googleapi: Error 404") {
+			var notFound *common.NotFoundError
+			if common.AsError(err, &notFound) {
 				continue
 			}
 
@@ -129,12 +185,10 @@ func (s *StepCreateDisks) Cleanup(state multistep.StateBag) {
 
 		ui.Say(fmt.Sprintf("Deleting persistent disk %q", gceDisk.DiskName))
 
-		errCh := driver.DeleteDisk(zone, gceDisk.DiskName)
-		select {
-		case err = <-errCh:
-		case <-time.After(config.StateTimeout):
-			err = errors.New("time out while waiting for disk to delete")
-		}
+		deleteCtx, cancel := context.WithTimeout(ctx, config.operationTimeout(config.DiskDeleteTimeout))
+		err = waitForOperation(deleteCtx, driver.DeleteDisk(deleteCtx, zone, gceDisk.DiskName),
+			"time out while waiting for disk to delete")
+		cancel()
 
 		if err != nil {
 			ui.Error(fmt.Sprintf(
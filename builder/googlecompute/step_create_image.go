@@ -5,9 +5,9 @@ package googlecompute
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"log"
+	"slices"
 	"time"
 
 	"github.com/hashicorp/packer-plugin-googlecompute/lib/common"
@@ -16,14 +16,58 @@ import (
 	"google.golang.org/api/compute/v1"
 )
 
-// StepCreateImage represents a Packer build step that creates GCE machine
-// images.
+// diskImages returns the disk attachments imaged separately from the image of
+// image_name, with an image_name of their own.
+func (c *Config) diskImages() []common.BlockDevice {
+	var disks []common.BlockDevice
+	for _, bd := range c.ExtraBlockDevices {
+		if bd.CreateImage && bd.ImageName != "" {
+			disks = append(disks, bd)
+		}
+	}
+	return disks
+}
+
+// prepareDiskImages checks that the images of the disk attachments apply to
+// the artifact and that their names are valid and do not clash.
+func (c *Config) prepareDiskImages() []error {
+	disks := c.diskImages()
+	if len(disks) == 0 {
+		return nil
+	}
+	if c.ArtifactType != ArtifactTypeImage {
+		return []error{fmt.Errorf("disk_attachment: image_name only applies to images, not to artifact_type %s", c.ArtifactType)}
+	}
+
+	var errs []error
+	names := map[string]bool{c.ImageName: true}
+	for _, bd := range disks {
+		if !validImageName.MatchString(bd.ImageName) {
+			errs = append(errs, fmt.Errorf("disk_attachment: invalid image_name %q for disk %s", bd.ImageName, bd.DiskName))
+		}
+		if bd.ImageFamily != "" && !validImageName.MatchString(bd.ImageFamily) {
+			errs = append(errs, fmt.Errorf("disk_attachment: invalid image_family %q for disk %s", bd.ImageFamily, bd.DiskName))
+		}
+		if names[bd.ImageName] {
+			errs = append(errs, fmt.Errorf("disk_attachment: image %s is already created by the build, "+
+				"set a different image_name for disk %s", bd.ImageName, bd.DiskName))
+		}
+		names[bd.ImageName] = true
+	}
+
+	return errs
+}
+
+// StepCreateImage represents a Packer build step that creates the GCE image,
+// machine image or snapshot the build produces.
 type StepCreateImage int
 
-// Run executes the Packer build step that creates a GCE machine image.
+// Run executes the Packer build step that creates a GCE image.
 //
-// The image is created from the persistent disk used by the instance. The
-// instance must be deleted and the disk retained before doing this step.
+// Images and snapshots are created from the persistent disk used by the
+// instance. The instance must be deleted and the disk retained before doing
+// this step. Machine images are created from the instance itself, which must
+// be stopped instead.
 func (s *StepCreateImage) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
 	config := state.Get("config").(*Config)
 	driver := state.Get("driver").(common.Driver)
@@ -35,22 +79,30 @@ func (s *StepCreateImage) Run(ctx context.Context, state multistep.StateBag) mul
 	}
 
 	if config.PackerForce && config.imageAlreadyExists {
-		ui.Say("Deleting previous image...")
+		kind := artifactTypeName(config.ArtifactType)
+		ui.Say(fmt.Sprintf("Deleting previous %s...", kind))
 
-		errCh := driver.DeleteImage(config.ImageProjectId, config.ImageName)
+		errCh := deleteArtifact(ctx, driver, config.ArtifactType, config.ImageProjectId, config.ImageName)
 		err := <-errCh
 		if err != nil {
-			err := fmt.Errorf("Error deleting image: %s", err)
+			err := fmt.Errorf("Error deleting %s: %s", kind, err)
 			state.Put("error", err)
 			ui.Error(err.Error())
 			return multistep.ActionHalt
 		}
 	}
 
-	ui.Say("Creating image...")
-
 	sourceDiskURI := fmt.Sprintf("/compute/v1/projects/%s/zones/%s/disks/%s", config.ProjectId, config.Zone, config.imageSourceDisk)
 
+	switch config.ArtifactType {
+	case ArtifactTypeMachineImage:
+		return s.createMachineImage(ctx, state)
+	case ArtifactTypeSnapshot:
+		return s.createSnapshot(ctx, state, sourceDiskURI)
+	}
+
+	ui.Say("Creating image...")
+
 	imageFeatures := make([]*compute.GuestOsFeature, 0, len(config.ImageGuestOsFeatures))
 	for _, v := range config.ImageGuestOsFeatures {
 		imageFeatures = append(imageFeatures, &compute.GuestOsFeature{
@@ -58,12 +110,25 @@ func (s *StepCreateImage) Run(ctx context.Context, state multistep.StateBag) mul
 		})
 	}
 
-	shieldedVMStateConfig, shieldErr := common.CreateShieldedVMStateConfig(config.ImagePlatformKey, config.ImageKeyExchangeKey, config.ImageSignaturesDB, config.ImageForbiddenSignaturesDB)
+	// Images built on confidential instances can boot on them in turn.
+	if feature := common.ConfidentialGuestOsFeature(config.ConfidentialInstanceType); feature != "" && !slices.Contains(config.ImageGuestOsFeatures, feature) {
+		imageFeatures = append(imageFeatures, &compute.GuestOsFeature{
+			Type: feature,
+		})
+	}
+
+	keyLoader := &common.SecureBootKeyLoader{Reader: driver, Strict: config.ImageSecureBootStrict}
+	shieldedVMStateConfig, shieldErr := keyLoader.CreateShieldedVMStateConfig(ctx, config.ImagePlatformKey, config.ImageKeyExchangeKey, config.ImageSignaturesDB, config.ImageForbiddenSignaturesDB)
 
 	if shieldErr != nil {
-		ui.Error(shieldErr.Error())
+		err := fmt.Errorf("Error loading the Secure Boot keys: %s", shieldErr)
+		state.Put("error", err)
+		ui.Error(err.Error())
 		return multistep.ActionHalt
 	}
+	for _, warning := range keyLoader.Warnings {
+		ui.Message(fmt.Sprintf("Warning: %s", warning))
+	}
 
 	imagePayload := &compute.Image{
 		Architecture:                 config.ImageArchitecture,
@@ -79,14 +144,10 @@ func (s *StepCreateImage) Run(ctx context.Context, state multistep.StateBag) mul
 		SourceType:                   "RAW",
 		StorageLocations:             config.ImageStorageLocations,
 	}
-	imageCh, errCh := driver.CreateImage(config.ImageProjectId, imagePayload)
-	var err error
-	select {
-	case err = <-errCh:
-	case <-time.After(config.StateTimeout):
-		err = errors.New("time out while waiting for image to register")
-	}
-
+	createCtx, cancel := context.WithTimeout(ctx, config.operationTimeout(config.ImageCreateTimeout))
+	defer cancel()
+	imageCh, errCh := driver.CreateImage(createCtx, config.ImageProjectId, imagePayload)
+	err := waitForOperation(createCtx, errCh, "time out while waiting for image to register")
 	if err != nil {
 		err := fmt.Errorf("Error waiting for image: %s", err)
 		state.Put("error", err)
@@ -104,7 +165,7 @@ func (s *StepCreateImage) Run(ctx context.Context, state multistep.StateBag) mul
 		return multistep.ActionHalt
 	}
 
-	err = driver.SetImageDeprecationStatus(config.ImageProjectId, config.ImageName, deprecationStatus)
+	err = driver.SetImageDeprecationStatus(ctx, config.ImageProjectId, config.ImageName, deprecationStatus)
 	if err != nil {
 		err := fmt.Errorf("Error setting image deprecation status: %s", err)
 		state.Put("error", err.Error())
@@ -115,6 +176,113 @@ func (s *StepCreateImage) Run(ctx context.Context, state multistep.StateBag) mul
 		ui.Say("Image deprecation status set")
 	}
 
+	if len(config.diskImages()) > 0 {
+		return s.createDiskImages(ctx, state)
+	}
+	return multistep.ActionContinue
+}
+
+// createDiskImages creates the images of the disk attachments that have an
+// image_name of their own. The images are all created at once, and the step
+// waits for all of them.
+func (s *StepCreateImage) createDiskImages(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	config := state.Get("config").(*Config)
+	driver := state.Get("driver").(common.Driver)
+	ui := state.Get("ui").(packersdk.Ui)
+
+	disks := config.diskImages()
+	ui.Say(fmt.Sprintf("Creating images of %d attached disk(s)...", len(disks)))
+	var images []extraImage
+	for _, bd := range disks {
+		images = append(images, extraImage{
+			desc:    fmt.Sprintf("image %s of disk %s", bd.ImageName, bd.DiskName),
+			project: config.ImageProjectId,
+			spec: &compute.Image{
+				Description:        config.ImageDescription,
+				Family:             bd.ImageFamily,
+				ImageEncryptionKey: bd.ImageEncryptionKey.ComputeType(),
+				Labels:             bd.ImageLabels,
+				Name:               bd.ImageName,
+				SourceDisk:         fmt.Sprintf("/compute/v1/projects/%s/zones/%s/disks/%s", config.ProjectId, config.Zone, bd.DiskName),
+				SourceType:         "RAW",
+				StorageLocations:   config.ImageStorageLocations,
+			},
+		})
+	}
+
+	diskImages, err := createExtraImages(ctx, state, images)
+	if err != nil {
+		// The image of image_name was created by this step as well.
+		deleteImages(ctx, driver, []*common.Image{state.Get("image").(*common.Image)})
+		state.Remove("image")
+
+		err := fmt.Errorf("Error creating disk images: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	state.Put("disk_images", diskImages)
+	return multistep.ActionContinue
+}
+
+// createMachineImage creates a machine image of the stopped instance.
+func (s *StepCreateImage) createMachineImage(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	config := state.Get("config").(*Config)
+	driver := state.Get("driver").(common.Driver)
+	ui := state.Get("ui").(packersdk.Ui)
+
+	ui.Say("Creating machine image...")
+
+	ctx, cancel := context.WithTimeout(ctx, config.operationTimeout(config.ImageCreateTimeout))
+	defer cancel()
+	machineImageCh, errCh := driver.CreateMachineImage(ctx, config.ImageProjectId, &compute.MachineImage{
+		Description:               config.ImageDescription,
+		Labels:                    config.ImageLabels,
+		MachineImageEncryptionKey: config.ImageEncryptionKey.ComputeType(),
+		Name:                      config.ImageName,
+		SourceInstance:            fmt.Sprintf("projects/%s/zones/%s/instances/%s", config.ProjectId, config.Zone, config.InstanceName),
+		StorageLocations:          config.ImageStorageLocations,
+	})
+	err := waitForOperation(ctx, errCh, "time out while waiting for machine image to register")
+	if err != nil {
+		err := fmt.Errorf("Error waiting for machine image: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	state.Put("machine_image", <-machineImageCh)
+	return multistep.ActionContinue
+}
+
+// createSnapshot creates a snapshot of the disk at sourceDiskURI.
+func (s *StepCreateImage) createSnapshot(ctx context.Context, state multistep.StateBag, sourceDiskURI string) multistep.StepAction {
+	config := state.Get("config").(*Config)
+	driver := state.Get("driver").(common.Driver)
+	ui := state.Get("ui").(packersdk.Ui)
+
+	ui.Say("Creating snapshot...")
+
+	ctx, cancel := context.WithTimeout(ctx, config.operationTimeout(config.ImageCreateTimeout))
+	defer cancel()
+	snapshotCh, errCh := driver.CreateSnapshot(ctx, config.ImageProjectId, &compute.Snapshot{
+		Description:           config.ImageDescription,
+		Labels:                config.ImageLabels,
+		Name:                  config.ImageName,
+		SnapshotEncryptionKey: config.ImageEncryptionKey.ComputeType(),
+		SourceDisk:            sourceDiskURI,
+		StorageLocations:      config.ImageStorageLocations,
+	})
+	err := waitForOperation(ctx, errCh, "time out while waiting for snapshot to register")
+	if err != nil {
+		err := fmt.Errorf("Error waiting for snapshot: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	state.Put("snapshot", <-snapshotCh)
 	return multistep.ActionContinue
 }
 
@@ -7,10 +7,12 @@ import (
 	"context"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/packer-plugin-googlecompute/lib/common"
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	"github.com/stretchr/testify/assert"
+	compute "google.golang.org/api/compute/v1"
 )
 
 func TestStepCreateImage_impl(t *testing.T) {
@@ -71,6 +73,26 @@ func TestStepCreateImage_errorOnChannel(t *testing.T) {
 	assert.False(t, ok, "State should not have a resulting image.")
 }
 
+func TestStepCreateImage_imageCreateTimeout(t *testing.T) {
+	state := testState(t)
+	step := new(StepCreateImage)
+	defer step.Cleanup(state)
+
+	// The image creation gets its own timeout, shorter than state_timeout.
+	config := state.Get("config").(*Config)
+	config.StateTimeout = time.Hour
+	config.ImageCreateTimeout = time.Millisecond
+
+	driver := state.Get("driver").(*common.DriverMock)
+	driver.CreateImageErrCh = make(chan error)
+
+	action := step.Run(context.Background(), state)
+	assert.Equal(t, multistep.ActionHalt, action, "Step should not have passed.")
+	err, ok := state.GetOk("error")
+	assert.True(t, ok, "State should have an error.")
+	assert.ErrorContains(t, err.(error), "time out while waiting for image to register")
+}
+
 func TestStepCreateImage_setsDeprecationFields(t *testing.T) {
 	state := testState(t)
 	step := new(StepCreateImage)
@@ -120,6 +142,24 @@ func TestStepCreateImage_noSignatureInputsLeavesInitialStateNil(t *testing.T) {
 	assert.Nil(t, d.CreateImageSpec.ShieldedInstanceInitialState, "shieldedInstanceInitialState must be nil so the source disk's initial state is inherited")
 }
 
+func TestStepCreateImage_secureBootStrict(t *testing.T) {
+	state := testState(t)
+	step := new(StepCreateImage)
+	defer step.Cleanup(state)
+
+	// The keys supplied by the default testConfig are not certificates.
+	c := state.Get("config").(*Config)
+	c.ImageSecureBootStrict = true
+	d := state.Get("driver").(*common.DriverMock)
+
+	action := step.Run(context.Background(), state)
+	assert.Equal(t, multistep.ActionHalt, action, "Step should not have passed.")
+	err, ok := state.GetOk("error")
+	assert.True(t, ok, "State should have an error.")
+	assert.ErrorContains(t, err.(error), "test-fixtures/fake-key")
+	assert.Nil(t, d.CreateImageSpec, "No image should be created.")
+}
+
 func TestStepCreateImageNonUEFI_image(t *testing.T) {
 	state := testState(t)
 	step := new(StepCreateImage)
@@ -163,3 +203,189 @@ func TestStepCreateImageUEFI_image(t *testing.T) {
 	assert.Len(t, image.ShieldedInstanceInitialState.Dbs, 1)
 	assert.Len(t, image.ShieldedInstanceInitialState.Dbxs, 1)
 }
+
+func TestStepCreateImage_confidentialFeature(t *testing.T) {
+	cases := []struct {
+		Features []string
+		Expected []string
+	}{
+		{[]string{"UEFI_COMPATIBLE"}, []string{"UEFI_COMPATIBLE", "SEV_SNP_CAPABLE"}},
+		{[]string{"SEV_SNP_CAPABLE"}, []string{"SEV_SNP_CAPABLE"}},
+	}
+
+	for _, tc := range cases {
+		state := testState(t)
+		step := new(StepCreateImage)
+		defer step.Cleanup(state)
+
+		c := state.Get("config").(*Config)
+		c.ConfidentialInstanceType = common.ConfidentialInstanceTypeSEVSNP
+		c.ImageGuestOsFeatures = tc.Features
+		d := state.Get("driver").(*common.DriverMock)
+
+		// run the step
+		action := step.Run(context.Background(), state)
+		assert.Equal(t, action, multistep.ActionContinue, "Step did not pass.")
+
+		var features []string
+		for _, feature := range d.CreateImageSpec.GuestOsFeatures {
+			features = append(features, feature.Type)
+		}
+		assert.Equal(t, tc.Expected, features, "Image should be confidential compatible.")
+	}
+}
+
+func TestStepCreateImage_machineImage(t *testing.T) {
+	state := testState(t)
+	step := new(StepCreateImage)
+	defer step.Cleanup(state)
+
+	c := state.Get("config").(*Config)
+	c.ArtifactType = ArtifactTypeMachineImage
+	c.PackerForce = true
+	c.imageAlreadyExists = true
+	d := state.Get("driver").(*common.DriverMock)
+
+	assert.Equal(t, multistep.ActionContinue, step.Run(context.Background(), state), "Step did not pass.")
+
+	_, ok := state.GetOk("image")
+	assert.False(t, ok, "No disk image should be created.")
+	machineImage, ok := state.GetOk("machine_image")
+	assert.True(t, ok, "State does not have resulting machine image.")
+	assert.Equal(t, c.ImageName, machineImage.(*compute.MachineImage).Name)
+
+	assert.Equal(t, c.ImageName, d.DeleteMachineImageName, "Previous machine image should be deleted.")
+	assert.Empty(t, d.DeleteImageName, "No disk image should be deleted.")
+	assert.Equal(t, c.ImageProjectId, d.CreateMachineImageProjectId)
+	assert.Equal(t, "projects/hashicorp/zones/us-east1-a/instances/"+c.InstanceName, d.CreateMachineImageSpec.SourceInstance)
+	assert.Equal(t, c.ImageStorageLocations, d.CreateMachineImageSpec.StorageLocations)
+}
+
+func TestStepCreateImage_snapshot(t *testing.T) {
+	state := testState(t)
+	step := new(StepCreateImage)
+	defer step.Cleanup(state)
+
+	c := state.Get("config").(*Config)
+	c.ArtifactType = ArtifactTypeSnapshot
+	d := state.Get("driver").(*common.DriverMock)
+
+	assert.Equal(t, multistep.ActionContinue, step.Run(context.Background(), state), "Step did not pass.")
+
+	snapshot, ok := state.GetOk("snapshot")
+	assert.True(t, ok, "State does not have resulting snapshot.")
+	assert.Equal(t, c.ImageName, snapshot.(*compute.Snapshot).Name)
+
+	assert.Nil(t, d.CreateImageSpec, "No disk image should be created.")
+	assert.Equal(t, "/compute/v1/projects/hashicorp/zones/us-east1-a/disks/"+c.DiskName, d.CreateSnapshotSpec.SourceDisk)
+	assert.Equal(t, c.ImageLabels, d.CreateSnapshotSpec.Labels)
+}
+
+func TestStepCreateImage_snapshotError(t *testing.T) {
+	state := testState(t)
+	step := new(StepCreateImage)
+	defer step.Cleanup(state)
+
+	c := state.Get("config").(*Config)
+	c.ArtifactType = ArtifactTypeSnapshot
+	errCh := make(chan error, 1)
+	errCh <- errors.New("error")
+	d := state.Get("driver").(*common.DriverMock)
+	d.CreateSnapshotErrCh = errCh
+
+	assert.Equal(t, multistep.ActionHalt, step.Run(context.Background(), state), "Step should have failed and halted.")
+
+	_, ok := state.GetOk("error")
+	assert.True(t, ok, "State should have an error.")
+	_, ok = state.GetOk("snapshot")
+	assert.False(t, ok, "State should not have a resulting snapshot.")
+}
+
+func TestStepCreateImage_diskImages(t *testing.T) {
+	state := testState(t)
+	step := new(StepCreateImage)
+	defer step.Cleanup(state)
+
+	c := state.Get("config").(*Config)
+	c.ExtraBlockDevices = []common.BlockDevice{
+		{DiskName: "os-data", CreateImage: true},
+		{DiskName: "db-disk", CreateImage: true, ImageName: "db-image", ImageFamily: "db", ImageLabels: map[string]string{"role": "db"},
+			ImageEncryptionKey: &common.CustomerEncryptionKey{KmsKeyName: "db-key"}},
+		{DiskName: "logs-disk", CreateImage: true, ImageName: "logs-image"},
+		{DiskName: "scratch-disk"},
+	}
+	d := state.Get("driver").(*common.DriverMock)
+
+	action := step.Run(context.Background(), state)
+	assert.Equal(t, multistep.ActionContinue, action, "Step did not pass.")
+
+	if assert.Len(t, d.CreateImageSpecs, 3) {
+		assert.Equal(t, c.ImageName, d.CreateImageSpecs[0].Name)
+
+		db := d.CreateImageSpecs[1]
+		assert.Equal(t, "db-image", db.Name)
+		assert.Equal(t, "db", db.Family)
+		assert.Equal(t, map[string]string{"role": "db"}, db.Labels)
+		assert.Equal(t, "db-key", db.ImageEncryptionKey.KmsKeyName)
+		assert.Equal(t, "/compute/v1/projects/"+c.ProjectId+"/zones/"+c.Zone+"/disks/db-disk", db.SourceDisk)
+		assert.Equal(t, "logs-image", d.CreateImageSpecs[2].Name)
+	}
+
+	diskImages, ok := state.GetOk("disk_images")
+	assert.True(t, ok, "State does not have the disk images.")
+	var names []string
+	for _, image := range diskImages.([]*common.Image) {
+		names = append(names, image.Name)
+	}
+	assert.Equal(t, []string{"db-image", "logs-image"}, names)
+}
+
+func TestStepCreateImage_diskImageError(t *testing.T) {
+	state := testState(t)
+	step := new(StepCreateImage)
+	defer step.Cleanup(state)
+
+	c := state.Get("config").(*Config)
+	c.ExtraBlockDevices = []common.BlockDevice{
+		{DiskName: "db-disk", CreateImage: true, ImageName: "db-image"},
+		{DiskName: "logs-disk", CreateImage: true, ImageName: "logs-image"},
+	}
+
+	// The image of image_name is created, the one of db-disk fails.
+	errCh := make(chan error, 2)
+	errCh <- nil
+	errCh <- errors.New("quota exceeded")
+	close(errCh)
+	d := state.Get("driver").(*common.DriverMock)
+	d.CreateImageErrCh = errCh
+
+	action := step.Run(context.Background(), state)
+	assert.Equal(t, multistep.ActionHalt, action, "Step should not have passed.")
+	err, ok := state.GetOk("error")
+	assert.True(t, ok, "State should have an error.")
+	assert.ErrorContains(t, err.(error), "image db-image of disk db-disk: quota exceeded")
+	_, ok = state.GetOk("disk_images")
+	assert.False(t, ok, "State should not have the disk images.")
+	assert.Equal(t, []string{"logs-image", c.ImageName}, d.DeleteImageNames,
+		"The disk images created and the image of image_name should be deleted.")
+	_, ok = state.GetOk("image")
+	assert.False(t, ok, "State should not have the deleted image.")
+}
+
+func TestStepCreateImage_diskImagesForce(t *testing.T) {
+	state := testState(t)
+	step := new(StepCreateImage)
+	defer step.Cleanup(state)
+
+	c := state.Get("config").(*Config)
+	c.PackerForce = true
+	c.ExtraBlockDevices = []common.BlockDevice{
+		{DiskName: "db-disk", CreateImage: true, ImageName: "db-image"},
+	}
+	d := state.Get("driver").(*common.DriverMock)
+	d.ImageExistsResult = true
+
+	action := step.Run(context.Background(), state)
+	assert.Equal(t, multistep.ActionContinue, action, "Step did not pass.")
+	assert.Equal(t, []string{"db-image"}, d.DeleteImageNames, "The previous disk image should be deleted.")
+}
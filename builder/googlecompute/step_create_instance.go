@@ -5,7 +5,6 @@ package googlecompute
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -22,6 +21,9 @@ import (
 type StepCreateInstance struct {
 	Debug         bool
 	GeneratedData *packerbuilderdata.GeneratedData
+	// Disks is the step that created the extra disks of the instance, if
+	// any, so they can follow the instance when it falls back to another zone.
+	Disks *StepCreateDisks
 }
 
 func (c *Config) createInstanceMetadata(sourceImage *common.Image, sshPublicKey string) (map[string]string, map[string]string, error) {
@@ -64,17 +66,42 @@ func (c *Config) createInstanceMetadata(sourceImage *common.Image, sshPublicKey
 	}
 	instanceMetadataNoSSHKeys[StartupScriptKey] = startupScript
 
-	// Wrap any found startup script with our own startup script wrapper.
-	if startupScript != "" && c.WrapStartupScriptFile.True() {
+	if sourceImage.IsWindows() {
+		c.addWindowsStartupScript(instanceMetadataNoSSHKeys, startupScript)
+	} else if (startupScript != "" || c.StartupScriptURL != "") && c.WrapStartupScriptFile.True() {
+		// Wrap any found startup script with our own startup script wrapper.
 		instanceMetadataNoSSHKeys[StartupScriptKey] = StartupScriptLinux
 		instanceMetadataNoSSHKeys[StartupWrappedScriptKey] = startupScript
+		if c.StartupScriptURL != "" {
+			// The wrapper downloads the script itself, it must not be run
+			// a second time by the guest agent.
+			instanceMetadataNoSSHKeys[StartupWrappedScriptURLKey] = c.StartupScriptURL
+			delete(instanceMetadataNoSSHKeys, StartupScriptURLKey)
+		}
 		instanceMetadataNoSSHKeys[StartupScriptStatusKey] = StartupScriptStatusNotDone
+		instanceMetadataNoSSHKeys[EnableGuestAttributesKey] = "TRUE"
+	} else if c.StartupScriptURL != "" {
+		instanceMetadataNoSSHKeys[StartupScriptURLKey] = c.StartupScriptURL
+	} else if startupScript == "" && c.hasWindowsStartupScript() {
+		// Linux instances ignore the Windows scripts, there is nothing to
+		// wait for.
+		instanceMetadataNoSSHKeys[StartupScriptStatusKey] = StartupScriptStatusDone
 	}
 
-	if sourceImage.IsWindows() {
-		// Windows startup script support is not yet implemented so clear any script data and set status to done
-		instanceMetadataNoSSHKeys[StartupScriptKey] = StartupScriptWindows
-		instanceMetadataNoSSHKeys[StartupScriptStatusKey] = StartupScriptStatusDone
+	if c.ShutdownScriptFile != "" {
+		var content []byte
+		content, err = os.ReadFile(c.ShutdownScriptFile)
+		if err != nil {
+			return nil, instanceMetadataNoSSHKeys, err
+		}
+		// The shutdown script is always wrapped, so that its completion can
+		// be checked once the instance is stopped.
+		key, wrapper := ShutdownScriptKey, ShutdownScriptLinux
+		if sourceImage.IsWindows() {
+			key, wrapper = WindowsShutdownScriptKey, ShutdownScriptWindows
+		}
+		instanceMetadataNoSSHKeys[key] = wrapper
+		instanceMetadataNoSSHKeys[ShutdownWrappedScriptKey] = string(content)
 	}
 
 	// If UseOSLogin is true, force `enable-oslogin` in metadata
@@ -87,6 +114,10 @@ func (c *Config) createInstanceMetadata(sourceImage *common.Image, sshPublicKey
 		instanceMetadataNoSSHKeys[EnableOSLoginKey] = "FALSE"
 	}
 
+	if c.userData != "" {
+		instanceMetadataNoSSHKeys[UserDataKey] = c.userData
+	}
+
 	for key, value := range c.MetadataFiles {
 		var content []byte
 		content, err = os.ReadFile(value)
@@ -102,7 +133,61 @@ func (c *Config) createInstanceMetadata(sourceImage *common.Image, sshPublicKey
 	return instanceMetadataNoSSHKeys, instanceMetadataSSHKeys, nil
 }
 
-func getImage(c *Config, d common.Driver) (*common.Image, error) {
+// addWindowsStartupScript sets the startup script of a Windows instance in
+// metadata. Windows instances run the PowerShell scripts of their own keys
+// rather than startup-script: the script of startup_script_file, if any, is
+// run as windows-startup-script-ps1, then the script of startup_script_url,
+// otherwise the script found under windows-startup-script-ps1 or
+// sysprep-specialize-script-ps1 is. The script is wrapped with the Windows
+// startup script wrapper, which tracks its completion.
+func (c *Config) addWindowsStartupScript(metadata map[string]string, startupScriptFile string) {
+	delete(metadata, StartupScriptKey)
+
+	if c.StartupScriptURL != "" {
+		if !c.WrapStartupScriptFile.True() {
+			metadata[WindowsStartupScriptURLKey] = c.StartupScriptURL
+			// Nothing tracks the script, there is nothing to wait for.
+			metadata[StartupScriptStatusKey] = StartupScriptStatusDone
+			return
+		}
+		delete(metadata, WindowsStartupScriptURLKey)
+		metadata[WindowsStartupScriptKey] = StartupScriptWindows
+		metadata[StartupWrappedScriptURLKey] = c.StartupScriptURL
+		metadata[StartupScriptStatusKey] = StartupScriptStatusNotDone
+		metadata[EnableGuestAttributesKey] = "TRUE"
+		return
+	}
+
+	key, script := WindowsStartupScriptKey, metadata[WindowsStartupScriptKey]
+	switch {
+	case c.StartupScriptFile != "":
+		script = startupScriptFile
+	case script == "" && metadata[WindowsSysprepScriptKey] != "":
+		key, script = WindowsSysprepScriptKey, metadata[WindowsSysprepScriptKey]
+	}
+
+	if script == "" || !c.WrapStartupScriptFile.True() {
+		if script != "" {
+			metadata[key] = script
+		}
+		// Nothing tracks the script, there is nothing to wait for.
+		metadata[StartupScriptStatusKey] = StartupScriptStatusDone
+		return
+	}
+
+	metadata[key] = StartupScriptWindows
+	metadata[StartupWrappedScriptKey] = script
+	metadata[StartupScriptStatusKey] = StartupScriptStatusNotDone
+	metadata[EnableGuestAttributesKey] = "TRUE"
+}
+
+// hasWindowsStartupScript returns true if the metadata has a startup script
+// for Windows instances.
+func (c *Config) hasWindowsStartupScript() bool {
+	return c.Metadata[WindowsStartupScriptKey] != "" || c.Metadata[WindowsSysprepScriptKey] != ""
+}
+
+func getImage(ctx context.Context, c *Config, d common.Driver) (*common.Image, error) {
 	name := c.SourceImageFamily
 	fromFamily := true
 	if c.SourceImage != "" {
@@ -110,12 +195,54 @@ func getImage(c *Config, d common.Driver) (*common.Image, error) {
 		fromFamily = false
 	}
 	if len(c.SourceImageProjectId) == 0 {
-		return d.GetImage(name, fromFamily)
+		return d.GetImage(ctx, name, fromFamily)
 	} else {
-		return d.GetImageFromProjects(c.SourceImageProjectId, name, fromFamily)
+		return d.GetImageFromProjects(ctx, c.SourceImageProjectId, name, fromFamily)
 	}
 }
 
+// getSource gets the image, snapshot, disk or machine image to create the
+// instance from, described as an image.
+func getSource(ctx context.Context, c *Config, d common.Driver) (*common.Image, error) {
+	switch c.sourceType() {
+	case sourceTypeSnapshot:
+		project, name := sourceProjectAndName(c.SourceSnapshot, c.ProjectId)
+		snapshot, err := d.GetSnapshot(ctx, project, name)
+		if err != nil {
+			return nil, err
+		}
+		return common.ImageFromSnapshot(snapshot, project), nil
+	case sourceTypeDisk:
+		disk, err := d.GetDisk(ctx, c.Zone, c.SourceDisk)
+		if err != nil {
+			return nil, err
+		}
+		return common.ImageFromDisk(disk, c.ProjectId), nil
+	case sourceTypeMachineImage:
+		project, name := sourceProjectAndName(c.SourceMachineImage, c.ProjectId)
+		machineImage, err := d.GetMachineImage(ctx, project, name)
+		if err != nil {
+			return nil, err
+		}
+		return common.ImageFromMachineImage(machineImage, project), nil
+	}
+	return getImage(ctx, c, d)
+}
+
+// sourceProjectAndName splits the partial or full URL of a global resource
+// into its project and name. A plain name is in the default project.
+func sourceProjectAndName(source, defaultProject string) (string, string) {
+	parts := strings.Split(source, "/")
+	project := defaultProject
+	for i := 0; i < len(parts)-1; i++ {
+		if parts[i] == "projects" {
+			project = parts[i+1]
+			break
+		}
+	}
+	return project, parts[len(parts)-1]
+}
+
 // Run executes the Packer build step that creates a GCE instance.
 func (s *StepCreateInstance) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
 	c := state.Get("config").(*Config)
@@ -123,9 +250,10 @@ func (s *StepCreateInstance) Run(ctx context.Context, state multistep.StateBag)
 
 	ui := state.Get("ui").(packersdk.Ui)
 
-	sourceImage, err := getImage(c, d)
+	sourceType := c.sourceType()
+	sourceImage, err := getSource(ctx, c, d)
 	if err != nil {
-		err := fmt.Errorf("Error getting source image for instance creation: %s", err)
+		err := fmt.Errorf("Error getting source %s for instance creation: %s", strings.ReplaceAll(sourceType, "_", " "), err)
 		state.Put("error", err)
 		ui.Error(err.Error())
 		return multistep.ActionHalt
@@ -136,6 +264,7 @@ func (s *StepCreateInstance) Run(ctx context.Context, state multistep.StateBag)
 	if s.GeneratedData != nil {
 		// Store source image name for use in PARtifact.
 		s.GeneratedData.Put("SourceImageName", sourceImage.Name)
+		s.GeneratedData.Put("SourceType", sourceType)
 	}
 
 	if c.EnableSecureBoot && !sourceImage.IsSecureBootCompatible() {
@@ -145,7 +274,16 @@ func (s *StepCreateInstance) Run(ctx context.Context, state multistep.StateBag)
 		return multistep.ActionHalt
 	}
 
-	ui.Say(fmt.Sprintf("Using image: %s", sourceImage.Name))
+	if c.ConfidentialInstanceType != "" && !sourceImage.IsConfidentialCompatible(c.ConfidentialInstanceType) {
+		err := fmt.Errorf("Image: %s is not compatible with %s confidential instances, it lacks the %s guest OS feature. "+
+			"Please choose another source image or unset 'confidential_instance_type'.",
+			sourceImage.Name, c.ConfidentialInstanceType, common.ConfidentialGuestOsFeature(c.ConfidentialInstanceType))
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	ui.Say(fmt.Sprintf("Using %s: %s", strings.ReplaceAll(sourceType, "_", " "), sourceImage.Name))
 
 	if sourceImage.IsWindows() && c.Comm.Type == "winrm" && c.Comm.WinRMPassword == "" {
 		state.Put("create_windows_password", true)
@@ -154,7 +292,6 @@ func (s *StepCreateInstance) Run(ctx context.Context, state multistep.StateBag)
 	ui.Say("Creating instance...")
 	name := c.InstanceName
 
-	var errCh <-chan error
 	var metadataNoSSHKeys map[string]string
 	var metadataSSHKeys map[string]string
 	metadataForInstance := make(map[string]string)
@@ -177,97 +314,195 @@ func (s *StepCreateInstance) Run(ctx context.Context, state multistep.StateBag)
 		addmap(metadataForInstance, metadataNoSSHKeys)
 	}
 
-	errCh, err = d.RunInstance(&common.InstanceConfig{
+	zones := append([]string{c.Zone}, c.FallbackZones...)
+	// Quotas are per region, the zones of a region whose quota is exceeded
+	// are not tried.
+	quotaExceededRegions := map[string]bool{}
+	for i, zone := range zones {
+		if region, _ := common.GetRegionFromZone(zone); quotaExceededRegions[region] {
+			ui.Say(fmt.Sprintf("Skipping zone %s, the quota of region %s is exceeded", zone, region))
+			continue
+		}
+		if zone != c.Zone {
+			ui.Say(fmt.Sprintf("Falling back to zone %s...", zone))
+			if err := s.moveToZone(ctx, state, zone); err != nil {
+				err := fmt.Errorf("Error moving build to zone %s: %s", zone, err)
+				state.Put("error", err)
+				ui.Error(err.Error())
+				return multistep.ActionHalt
+			}
+		}
+
+		err = s.createInstance(ctx, state, sourceImage, name, metadataForInstance)
+		if err == nil || i == len(zones)-1 || !common.IsCapacityError(err) {
+			break
+		}
+		var quotaErr *common.QuotaExceededError
+		if common.AsError(err, &quotaErr) && quotaErr.Region != "" {
+			quotaExceededRegions[quotaErr.Region] = true
+		}
+		ui.Error(fmt.Sprintf("Zone %s cannot create the instance: %s", zone, err))
+	}
+
+	if err != nil {
+		err := fmt.Errorf("Error creating instance: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	ui.Message("Instance has been created!")
+
+	if s.Debug {
+		if name != "" {
+			ui.Message(fmt.Sprintf("Instance: %s started in %s", name, c.Zone))
+		}
+	}
+
+	// Things succeeded, store the name so we can remove it later
+	state.Put("instance_name", name)
+	// instance_id is the generic term used so that users can have access to the
+	// instance id inside of the provisioners, used in step_provision.
+	state.Put("instance_id", name)
+
+	// The boot disk of an instance created from a machine image is named by
+	// Compute Engine, and deleted along with the instance by default.
+	if sourceType == sourceTypeMachineImage {
+		diskName, err := d.KeepBootDisk(ctx, c.Zone, name)
+		if err != nil {
+			err := fmt.Errorf("Error keeping the boot disk of the instance: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+		if c.imageSourceDisk == c.DiskName {
+			c.imageSourceDisk = diskName
+		}
+		c.DiskName = diskName
+	}
+
+	if c.WaitToAddSSHKeys > 0 {
+		ui.Message(fmt.Sprintf("Waiting %s before adding SSH keys...",
+			c.WaitToAddSSHKeys.String()))
+		cancelled := s.waitForBoot(ctx, c.WaitToAddSSHKeys)
+		if cancelled {
+			return multistep.ActionHalt
+		}
+
+		log.Printf("[DEBUG] %s wait is over. Adding SSH keys to existing instance...",
+			c.WaitToAddSSHKeys.String())
+		err = d.AddToInstanceMetadata(ctx, c.Zone, name, metadataSSHKeys)
+
+		if err != nil {
+			err := fmt.Errorf("Error adding SSH keys to existing instance: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
+	return multistep.ActionContinue
+}
+
+// createInstance creates the instance in the configured zone and waits for
+// the creation to complete.
+func (s *StepCreateInstance) createInstance(ctx context.Context, state multistep.StateBag, sourceImage *common.Image, name string, metadata map[string]string) error {
+	c := state.Get("config").(*Config)
+	d := state.Get("driver").(common.Driver)
+	ui := state.Get("ui").(packersdk.Ui)
+
+	instanceConfig := &common.InstanceConfig{
 		AcceleratorType:              c.AcceleratorType,
 		AcceleratorCount:             c.AcceleratorCount,
 		Address:                      c.Address,
+		ConfidentialInstanceType:     c.ConfidentialInstanceType,
 		Description:                  "New instance created by Packer",
 		DisableDefaultServiceAccount: c.DisableDefaultServiceAccount,
 		DiskName:                     c.DiskName,
 		DiskSizeGb:                   c.DiskSizeGb,
 		DiskType:                     c.DiskType,
 		DiskEncryptionKey:            c.DiskEncryptionKey,
+		DiskProvisionedIops:          c.DiskProvisionedIops,
+		DiskProvisionedThroughput:    c.DiskProvisionedThroughput,
+		DiskLabels:                   c.DiskLabels,
+		DiskStoragePool:              c.DiskStoragePool,
+		DiskResourcePolicies:         c.DiskResourcePolicies,
 		EnableNestedVirtualization:   c.EnableNestedVirtualization,
 		EnableSecureBoot:             c.EnableSecureBoot,
 		EnableVtpm:                   c.EnableVtpm,
 		EnableIntegrityMonitoring:    c.EnableIntegrityMonitoring,
+		ExternalIPv6:                 c.ExternalIPv6,
 		ExtraBlockDevices:            c.ExtraBlockDevices,
 		Image:                        sourceImage,
 		Labels:                       c.Labels,
 		MachineType:                  c.MachineType,
-		Metadata:                     metadataForInstance,
+		Metadata:                     metadata,
 		MinCpuPlatform:               c.MinCpuPlatform,
 		Name:                         name,
 		Network:                      c.Network,
 		NetworkProjectId:             c.NetworkProjectId,
+		NetworkInterfaces:            c.NetworkInterfaces,
 		OmitExternalIP:               c.OmitExternalIP,
 		OnHostMaintenance:            c.OnHostMaintenance,
 		MaxRunDurationInSeconds:      c.MaxRunDurationInSeconds,
 		InstanceTerminationAction:    c.InstanceTerminationAction,
 		Preemptible:                  c.Preemptible,
+		ProvisioningModel:            c.ProvisioningModel,
 		NodeAffinities:               c.NodeAffinities,
 		Region:                       c.Region,
 		ReservationAffinity:          c.ReservationAffinity,
 		ServiceAccountEmail:          c.ServiceAccountEmail,
 		Scopes:                       c.Scopes,
+		StackType:                    c.StackType,
 		Subnetwork:                   c.Subnetwork,
 		Tags:                         c.Tags,
 		ResourceManagerTags:          c.ResourceManagerTags,
 		Zone:                         c.Zone,
 		NetworkIP:                    c.NetworkIP,
-	})
-
-	if err == nil {
-		ui.Message("Waiting for creation operation to complete...")
-		select {
-		case err = <-errCh:
-		case <-time.After(c.StateTimeout):
-			err = errors.New("time out while waiting for instance to create")
-		}
 	}
 
-	if err != nil {
-		err := fmt.Errorf("Error creating instance: %s", err)
-		state.Put("error", err)
-		ui.Error(err.Error())
-		return multistep.ActionHalt
+	switch c.sourceType() {
+	case sourceTypeSnapshot:
+		instanceConfig.SourceSnapshot = sourceImage.SelfLink
+	case sourceTypeDisk:
+		instanceConfig.SourceDisk = sourceImage.SelfLink
+	case sourceTypeMachineImage:
+		instanceConfig.SourceMachineImage = sourceImage.SelfLink
 	}
 
-	ui.Message("Instance has been created!")
-
-	if s.Debug {
-		if name != "" {
-			ui.Message(fmt.Sprintf("Instance: %s started in %s", name, c.Zone))
-		}
+	ctx, cancel := context.WithTimeout(ctx, c.operationTimeout(c.InstanceCreateTimeout))
+	defer cancel()
+	errCh, err := d.RunInstance(ctx, instanceConfig)
+	if err != nil {
+		return err
 	}
 
-	// Things succeeded, store the name so we can remove it later
-	state.Put("instance_name", name)
-	// instance_id is the generic term used so that users can have access to the
-	// instance id inside of the provisioners, used in step_provision.
-	state.Put("instance_id", name)
+	ui.Message("Waiting for creation operation to complete...")
+	return waitForOperation(ctx, errCh, "time out while waiting for instance to create")
+}
 
-	if c.WaitToAddSSHKeys > 0 {
-		ui.Message(fmt.Sprintf("Waiting %s before adding SSH keys...",
-			c.WaitToAddSSHKeys.String()))
-		cancelled := s.waitForBoot(ctx, c.WaitToAddSSHKeys)
-		if cancelled {
-			return multistep.ActionHalt
-		}
+// moveToZone moves the build to zone, along with the region and the extra
+// disks that depend on it.
+func (s *StepCreateInstance) moveToZone(ctx context.Context, state multistep.StateBag, zone string) error {
+	c := state.Get("config").(*Config)
 
-		log.Printf("[DEBUG] %s wait is over. Adding SSH keys to existing instance...",
-			c.WaitToAddSSHKeys.String())
-		err = d.AddToInstanceMetadata(c.Zone, name, metadataSSHKeys)
+	region, err := common.GetRegionFromZone(zone)
+	if err != nil {
+		return err
+	}
 
-		if err != nil {
-			err := fmt.Errorf("Error adding SSH keys to existing instance: %s", err)
-			state.Put("error", err)
-			ui.Error(err.Error())
-			return multistep.ActionHalt
+	if s.Disks != nil {
+		if err := s.Disks.moveToZone(ctx, state, zone); err != nil {
+			return err
 		}
 	}
+	for i := range c.ExtraBlockDevices {
+		c.ExtraBlockDevices[i].Zone = zone
+	}
+	c.Zone = zone
+	c.Region = region
 
-	return multistep.ActionContinue
+	return nil
 }
 
 func (s *StepCreateInstance) waitForBoot(ctx context.Context, waitLen time.Duration) bool {
@@ -296,15 +531,14 @@ func (s *StepCreateInstance) Cleanup(state multistep.StateBag) {
 	driver := state.Get("driver").(common.Driver)
 	ui := state.Get("ui").(packersdk.Ui)
 
+	// The instance is deleted even when the build was cancelled.
 	ui.Say("Deleting instance...")
-	errCh, err := driver.DeleteInstance(config.Zone, name)
+	ctx, cancel := context.WithTimeout(context.Background(), config.StateTimeout)
+	errCh, err := driver.DeleteInstance(ctx, config.Zone, name)
 	if err == nil {
-		select {
-		case err = <-errCh:
-		case <-time.After(config.StateTimeout):
-			err = errors.New("time out while waiting for instance to delete")
-		}
+		err = waitForOperation(ctx, errCh, "time out while waiting for instance to delete")
 	}
+	cancel()
 
 	if err != nil {
 		ui.Error(fmt.Sprintf(
@@ -319,12 +553,10 @@ func (s *StepCreateInstance) Cleanup(state multistep.StateBag) {
 	// Deleting the instance does not remove the boot disk. This cleanup removes
 	// the disk.
 	ui.Say("Deleting disk...")
-	errCh = driver.DeleteDisk(config.Zone, config.DiskName)
-	select {
-	case err = <-errCh:
-	case <-time.After(config.StateTimeout):
-		err = errors.New("time out while waiting for disk to delete")
-	}
+	ctx, cancel = context.WithTimeout(context.Background(), config.operationTimeout(config.DiskDeleteTimeout))
+	err = waitForOperation(ctx, driver.DeleteDisk(ctx, config.Zone, config.DiskName),
+		"time out while waiting for disk to delete")
+	cancel()
 
 	if err != nil {
 		ui.Error(fmt.Sprintf(
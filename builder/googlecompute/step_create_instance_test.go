@@ -13,9 +13,11 @@ import (
 
 	"github.com/hashicorp/packer-plugin-googlecompute/lib/common"
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 	"github.com/hashicorp/packer-plugin-sdk/packerbuilderdata"
 	"github.com/hashicorp/packer-plugin-sdk/template/config"
 	"github.com/stretchr/testify/assert"
+	"google.golang.org/api/compute/v1"
 )
 
 func TestStepCreateInstance_impl(t *testing.T) {
@@ -52,6 +54,35 @@ func TestStepCreateInstance(t *testing.T) {
 	assert.Equal(t, d.DeleteDiskZone, c.Zone, "Incorrect disk zone passed to driver.")
 }
 
+func TestStepCreateInstance_bootDisk(t *testing.T) {
+	state := testState(t)
+	step := new(StepCreateInstance)
+	defer step.Cleanup(state)
+
+	state.Put("ssh_public_key", "key")
+	step.GeneratedData = &packerbuilderdata.GeneratedData{State: state}
+
+	c := state.Get("config").(*Config)
+	d := state.Get("driver").(*common.DriverMock)
+	d.GetImageResult = StubImage("test-image", "test-project", []string{}, 100)
+
+	c.DiskType = "hyperdisk-balanced"
+	c.DiskProvisionedIops = 5000
+	c.DiskProvisionedThroughput = 300
+	c.DiskLabels = map[string]string{"team": "images"}
+	c.DiskStoragePool = "my-pool"
+	c.DiskResourcePolicies = []string{"daily-snapshots"}
+
+	assert.Equal(t, multistep.ActionContinue, step.Run(context.Background(), state))
+
+	ic := d.RunInstanceConfig
+	assert.Equal(t, int64(5000), ic.DiskProvisionedIops)
+	assert.Equal(t, int64(300), ic.DiskProvisionedThroughput)
+	assert.Equal(t, map[string]string{"team": "images"}, ic.DiskLabels)
+	assert.Equal(t, "my-pool", ic.DiskStoragePool)
+	assert.Equal(t, []string{"daily-snapshots"}, ic.DiskResourcePolicies)
+}
+
 func TestStepCreateInstance_fromFamily(t *testing.T) {
 	cases := []struct {
 		Name   string
@@ -271,6 +302,271 @@ func TestStepCreateInstance_errorTimeout(t *testing.T) {
 	assert.False(t, ok, "State should not have an instance name.")
 }
 
+func stockoutError(zone string) error {
+	return &packersdk.MultiError{Errors: []error{&common.OperationError{
+		Code:    "ZONE_RESOURCE_POOL_EXHAUSTED",
+		Message: fmt.Sprintf("The zone 'projects/hashicorp/zones/%s' does not have enough resources available to fulfill the request.", zone),
+	}}}
+}
+
+func TestStepCreateInstance_fallbackZones(t *testing.T) {
+	state := testState(t)
+	step := new(StepCreateInstance)
+	defer step.Cleanup(state)
+
+	state.Put("ssh_public_key", "key")
+	generatedData := &packerbuilderdata.GeneratedData{State: state}
+	step.GeneratedData = generatedData
+
+	c := state.Get("config").(*Config)
+	c.FallbackZones = []string{"us-east1-b", "us-east4-a"}
+	c.Subnetwork = "my-subnet"
+	c.ExtraBlockDevices = []common.BlockDevice{
+		{VolumeType: common.ZonalSSD, VolumeSize: 10, DiskName: "extra", Zone: c.Zone},
+		{VolumeType: common.LocalScratch, VolumeSize: 375, Zone: c.Zone},
+	}
+	step.Disks = &StepCreateDisks{DiskConfiguration: c.ExtraBlockDevices}
+
+	d := state.Get("driver").(*common.DriverMock)
+	d.GetImageResult = StubImage("test-image", "test-project", []string{}, 100)
+	d.CreateDiskErrCh = make(chan error)
+	d.RunInstanceZoneErrs = map[string]error{
+		"us-east1-a": stockoutError("us-east1-a"),
+		"us-east1-b": stockoutError("us-east1-b"),
+	}
+
+	assert.Equal(t, multistep.ActionContinue, step.Disks.Run(context.Background(), state), "Disks should have been created.")
+	assert.Equal(t, multistep.ActionContinue, step.Run(context.Background(), state), "Step should have fallen back and continued.")
+
+	assert.Equal(t, []string{"us-east1-a", "us-east1-b", "us-east4-a"}, d.RunInstanceZones, "Zones should have been tried in order.")
+	assert.Equal(t, "us-east4-a", c.Zone, "Config should follow the zone of the instance.")
+	assert.Equal(t, "us-east4", c.Region, "Config should follow the region of the instance.")
+	assert.Equal(t, "us-east4", d.RunInstanceConfig.Region, "Instance should be created in the region of the zone.")
+	for _, bd := range c.ExtraBlockDevices {
+		assert.Equal(t, "us-east4-a", bd.Zone, "Extra disks should follow the zone of the instance.")
+	}
+	assert.Equal(t, "us-east1-b", d.DeleteDiskZone, "Extra disk should have been deleted from the previous zone.")
+	assert.Equal(t, "us-east4-a", d.CreateDiskConfig.Zone, "Extra disk should have been created again in the new zone.")
+	assert.Equal(t, "projects/hashicorp/zones/us-east4-a/disks/extra", c.ExtraBlockDevices[0].SourceVolume)
+}
+
+func TestStepCreateInstance_fallbackZonesRegionalDisk(t *testing.T) {
+	state := testState(t)
+	step := new(StepCreateInstance)
+	defer step.Cleanup(state)
+
+	state.Put("ssh_public_key", "key")
+	step.GeneratedData = &packerbuilderdata.GeneratedData{State: state}
+
+	c := state.Get("config").(*Config)
+	c.FallbackZones = []string{"us-east1-b"}
+	c.ExtraBlockDevices = []common.BlockDevice{
+		{VolumeType: common.ZonalSSD, VolumeSize: 10, DiskName: "regional", Zone: c.Zone, ReplicaZones: []string{"us-east1-b"}},
+	}
+	step.Disks = &StepCreateDisks{DiskConfiguration: c.ExtraBlockDevices}
+
+	d := state.Get("driver").(*common.DriverMock)
+	d.GetImageResult = StubImage("test-image", "test-project", []string{}, 100)
+	d.CreateDiskErrCh = make(chan error)
+	d.RunInstanceZoneErrs = map[string]error{
+		"us-east1-a": stockoutError("us-east1-a"),
+	}
+
+	assert.Equal(t, multistep.ActionContinue, step.Disks.Run(context.Background(), state), "Disks should have been created.")
+	assert.Equal(t, multistep.ActionContinue, step.Run(context.Background(), state), "Step should have fallen back and continued.")
+
+	assert.Equal(t, []string{"us-east1-a", "us-east1-b"}, d.RunInstanceZones)
+	assert.Empty(t, d.DeleteDiskName, "The regional disk should have been kept.")
+	assert.Equal(t, "projects/hashicorp/regions/us-east1/disks/regional", c.ExtraBlockDevices[0].SourceVolume)
+}
+
+func TestStepCreateInstance_fallbackZonesQuota(t *testing.T) {
+	state := testState(t)
+	step := new(StepCreateInstance)
+	defer step.Cleanup(state)
+
+	state.Put("ssh_public_key", "key")
+	step.GeneratedData = &packerbuilderdata.GeneratedData{State: state}
+
+	c := state.Get("config").(*Config)
+	c.FallbackZones = []string{"us-east1-b", "us-east4-a"}
+
+	d := state.Get("driver").(*common.DriverMock)
+	d.GetImageResult = StubImage("test-image", "test-project", []string{}, 100)
+	d.RunInstanceZoneErrs = map[string]error{
+		"us-east1-a": &packersdk.MultiError{Errors: []error{&common.QuotaExceededError{
+			Metric: "CPUS",
+			Region: "us-east1",
+			Err:    &common.OperationError{Code: "QUOTA_EXCEEDED", Message: "Quota 'CPUS' exceeded.  Limit: 24.0 in region us-east1."},
+		}}},
+	}
+
+	assert.Equal(t, multistep.ActionContinue, step.Run(context.Background(), state), "Step should have fallen back and continued.")
+	assert.Equal(t, []string{"us-east1-a", "us-east4-a"}, d.RunInstanceZones,
+		"Zones of the region whose quota is exceeded should have been skipped.")
+	assert.Equal(t, "us-east4-a", c.Zone)
+}
+
+func TestStepCreateInstance_fallbackZonesOtherError(t *testing.T) {
+	state := testState(t)
+	step := new(StepCreateInstance)
+	defer step.Cleanup(state)
+
+	state.Put("ssh_public_key", "key")
+	generatedData := &packerbuilderdata.GeneratedData{State: state}
+	step.GeneratedData = generatedData
+
+	c := state.Get("config").(*Config)
+	c.FallbackZones = []string{"us-east1-b"}
+
+	d := state.Get("driver").(*common.DriverMock)
+	d.GetImageResult = StubImage("test-image", "test-project", []string{}, 100)
+	d.RunInstanceZoneErrs = map[string]error{
+		"us-east1-a": errors.New("Invalid value for field 'resource.machineType'"),
+	}
+
+	assert.Equal(t, multistep.ActionHalt, step.Run(context.Background(), state), "Step should have failed and halted.")
+	assert.Equal(t, []string{"us-east1-a"}, d.RunInstanceZones, "Only capacity errors should fall back to another zone.")
+	assert.Equal(t, "us-east1-a", c.Zone)
+}
+
+func TestStepCreateInstance_confidentialIncompatibleImage(t *testing.T) {
+	state := testState(t)
+	step := new(StepCreateInstance)
+	defer step.Cleanup(state)
+
+	state.Put("ssh_public_key", "key")
+	generatedData := &packerbuilderdata.GeneratedData{State: state}
+	step.GeneratedData = generatedData
+
+	c := state.Get("config").(*Config)
+	c.ConfidentialInstanceType = common.ConfidentialInstanceTypeTDX
+	c.MachineType = "c3-standard-4"
+
+	d := state.Get("driver").(*common.DriverMock)
+	d.GetImageResult = StubImage("test-image", "test-project", []string{}, 100)
+	d.GetImageResult.GuestOsFeatures = []*compute.GuestOsFeature{{Type: "SEV_CAPABLE"}}
+
+	assert.Equal(t, multistep.ActionHalt, step.Run(context.Background(), state), "Step should have failed and halted.")
+	err, ok := state.GetOk("error")
+	assert.True(t, ok, "State should have an error.")
+	assert.Contains(t, err.(error).Error(), "TDX_CAPABLE")
+	assert.Nil(t, d.RunInstanceConfig, "Instance should not have been created.")
+
+	d.GetImageResult.GuestOsFeatures = append(d.GetImageResult.GuestOsFeatures, &compute.GuestOsFeature{Type: "TDX_CAPABLE"})
+	state.Remove("error")
+	assert.Equal(t, multistep.ActionContinue, step.Run(context.Background(), state), "Step should have passed and continued.")
+	assert.Equal(t, common.ConfidentialInstanceTypeTDX, d.RunInstanceConfig.ConfidentialInstanceType)
+}
+
+func TestStepCreateInstance_fromSnapshot(t *testing.T) {
+	state := testState(t)
+	step := new(StepCreateInstance)
+	defer step.Cleanup(state)
+
+	state.Put("ssh_public_key", "key")
+	step.GeneratedData = &packerbuilderdata.GeneratedData{State: state}
+
+	c := state.Get("config").(*Config)
+	c.SourceImage = ""
+	c.SourceSnapshot = "projects/golden/global/snapshots/web-snapshot"
+	d := state.Get("driver").(*common.DriverMock)
+	d.GetSnapshotResult = &compute.Snapshot{
+		Name:       "web-snapshot",
+		SelfLink:   "https://compute.googleapis.com/compute/v1/projects/golden/global/snapshots/web-snapshot",
+		DiskSizeGb: 20,
+	}
+
+	assert.Equal(t, multistep.ActionContinue, step.Run(context.Background(), state), "Step should have passed and continued.")
+
+	assert.Equal(t, "golden", d.GetSnapshotProjectId)
+	assert.Equal(t, "web-snapshot", d.GetSnapshotName)
+	assert.Equal(t, d.GetSnapshotResult.SelfLink, d.RunInstanceConfig.SourceSnapshot)
+	assert.Empty(t, d.RunInstanceConfig.SourceDisk)
+	assert.Empty(t, d.RunInstanceConfig.SourceMachineImage)
+
+	data := state.Get("generated_data").(map[string]interface{})
+	assert.Equal(t, "web-snapshot", data["SourceImageName"])
+	assert.Equal(t, sourceTypeSnapshot, data["SourceType"])
+}
+
+func TestStepCreateInstance_fromDisk(t *testing.T) {
+	state := testState(t)
+	step := new(StepCreateInstance)
+	defer step.Cleanup(state)
+
+	state.Put("ssh_public_key", "key")
+	step.GeneratedData = &packerbuilderdata.GeneratedData{State: state}
+
+	c := state.Get("config").(*Config)
+	c.SourceImage = ""
+	c.SourceDisk = "golden-disk"
+	d := state.Get("driver").(*common.DriverMock)
+	d.GetDiskResult = &compute.Disk{
+		Name:     "golden-disk",
+		SelfLink: "https://compute.googleapis.com/compute/v1/projects/hashicorp/zones/us-central1-a/disks/golden-disk",
+	}
+
+	assert.Equal(t, multistep.ActionContinue, step.Run(context.Background(), state), "Step should have passed and continued.")
+
+	assert.Equal(t, c.Zone, d.GetDiskZone)
+	assert.Equal(t, "golden-disk", d.GetDiskName)
+	assert.Equal(t, d.GetDiskResult.SelfLink, d.RunInstanceConfig.SourceDisk)
+
+	data := state.Get("generated_data").(map[string]interface{})
+	assert.Equal(t, sourceTypeDisk, data["SourceType"])
+}
+
+func TestStepCreateInstance_fromMachineImage(t *testing.T) {
+	state := testState(t)
+	step := new(StepCreateInstance)
+	defer step.Cleanup(state)
+
+	state.Put("ssh_public_key", "key")
+	step.GeneratedData = &packerbuilderdata.GeneratedData{State: state}
+
+	c := state.Get("config").(*Config)
+	c.SourceImage = ""
+	c.SourceMachineImage = "web-machine-image"
+	d := state.Get("driver").(*common.DriverMock)
+	d.GetMachineImageResult = &compute.MachineImage{
+		Name:     "web-machine-image",
+		SelfLink: "https://compute.googleapis.com/compute/v1/projects/hashicorp/global/machineImages/web-machine-image",
+	}
+	d.KeepBootDiskResult = "web-machine-image-boot"
+
+	assert.Equal(t, multistep.ActionContinue, step.Run(context.Background(), state), "Step should have passed and continued.")
+
+	assert.Equal(t, c.ProjectId, d.GetMachineImageProjectId)
+	assert.Equal(t, d.GetMachineImageResult.SelfLink, d.RunInstanceConfig.SourceMachineImage)
+	assert.Equal(t, c.InstanceName, d.KeepBootDiskName)
+	assert.Equal(t, c.Zone, d.KeepBootDiskZone)
+	assert.Equal(t, "web-machine-image-boot", c.DiskName, "The boot disk of the instance should be the one to image and delete.")
+	assert.Equal(t, "web-machine-image-boot", c.imageSourceDisk)
+
+	step.Cleanup(state)
+	assert.Equal(t, "web-machine-image-boot", d.DeleteDiskName)
+}
+
+func TestSourceProjectAndName(t *testing.T) {
+	cases := []struct {
+		source  string
+		project string
+		name    string
+	}{
+		{"web-snapshot", "default", "web-snapshot"},
+		{"projects/golden/global/snapshots/web-snapshot", "golden", "web-snapshot"},
+		{"https://compute.googleapis.com/compute/v1/projects/golden/global/machineImages/web", "golden", "web"},
+		{"global/snapshots/web-snapshot", "default", "web-snapshot"},
+	}
+
+	for _, tc := range cases {
+		project, name := sourceProjectAndName(tc.source, "default")
+		assert.Equal(t, tc.project, project, "Incorrect project for %s", tc.source)
+		assert.Equal(t, tc.name, name, "Incorrect name for %s", tc.source)
+	}
+}
+
 func TestStepCreateInstance_noServiceAccount(t *testing.T) {
 	state := testState(t)
 	step := new(StepCreateInstance)
@@ -408,6 +704,178 @@ func TestCreateInstanceMetadata_withWrapStartupScript(t *testing.T) {
 		assert.Equal(t, tc.StartupScriptContents, metadataNoSSHKeys[StartupScriptKey], fmt.Sprintf("Instance metadata for startup script should be %q.", tc.StartupScriptContents))
 		assert.Equal(t, tc.WrappedStartupScriptContents, metadataNoSSHKeys[StartupWrappedScriptKey], fmt.Sprintf("Instance metadata for wrapped startup script should be %q.", tc.WrappedStartupScriptContents))
 		assert.Equal(t, tc.WrappedStartupScriptStatus, metadataNoSSHKeys[StartupScriptStatusKey], fmt.Sprintf("Instance metadata startup script status should be %q.", tc.WrappedStartupScriptStatus))
+		if tc.WrappedStartupScriptContents != "" {
+			assert.Equal(t, "TRUE", metadataNoSSHKeys[EnableGuestAttributesKey], "Guest attributes should be enabled for the wrapper to report its status.")
+		}
+	}
+}
+
+func TestCreateInstanceMetadata_windowsStartupScript(t *testing.T) {
+	tt := []struct {
+		Name                string
+		WrapStartupScript   config.Trilean
+		StartupScriptFile   bool
+		Metadata            map[string]string
+		ScriptKey           string
+		ScriptContents      string
+		WrappedContents     string
+		StartupScriptStatus string
+	}{
+		{
+			Name:                "no script",
+			WrapStartupScript:   config.TriTrue,
+			StartupScriptStatus: StartupScriptStatusDone,
+		},
+		{
+			Name:                "startup script file",
+			WrapStartupScript:   config.TriTrue,
+			StartupScriptFile:   true,
+			Metadata:            map[string]string{WindowsStartupScriptKey: "Write-Output 'overridden'"},
+			ScriptKey:           WindowsStartupScriptKey,
+			ScriptContents:      StartupScriptWindows,
+			WrappedContents:     testMetadataFileContent,
+			StartupScriptStatus: StartupScriptStatusNotDone,
+		},
+		{
+			Name:                "sysprep specialize script",
+			WrapStartupScript:   config.TriTrue,
+			Metadata:            map[string]string{WindowsSysprepScriptKey: "Write-Output 'sysprep'"},
+			ScriptKey:           WindowsSysprepScriptKey,
+			ScriptContents:      StartupScriptWindows,
+			WrappedContents:     "Write-Output 'sysprep'",
+			StartupScriptStatus: StartupScriptStatusNotDone,
+		},
+		{
+			Name:                "not wrapped",
+			WrapStartupScript:   config.TriFalse,
+			StartupScriptFile:   true,
+			ScriptKey:           WindowsStartupScriptKey,
+			ScriptContents:      testMetadataFileContent,
+			StartupScriptStatus: StartupScriptStatusDone,
+		},
+	}
+
+	for _, tc := range tt {
+		state := testState(t)
+		image := StubImage("test-image", "test-project", []string{"windows"}, 100)
+		c := state.Get("config").(*Config)
+		c.WrapStartupScriptFile = tc.WrapStartupScript
+		if tc.StartupScriptFile {
+			c.StartupScriptFile = testMetadataFile(t)
+		}
+		c.Metadata = tc.Metadata
+
+		metadataNoSSHKeys, _, err := c.createInstanceMetadata(image, "")
+
+		assert.NoError(t, err, "%s: Metadata creation should have succeeded.", tc.Name)
+		_, ok := metadataNoSSHKeys[StartupScriptKey]
+		assert.False(t, ok, "%s: Windows instances do not run startup-script.", tc.Name)
+		if tc.ScriptKey != "" {
+			assert.Equal(t, tc.ScriptContents, metadataNoSSHKeys[tc.ScriptKey], tc.Name)
+		}
+		assert.Equal(t, tc.WrappedContents, metadataNoSSHKeys[StartupWrappedScriptKey], tc.Name)
+		assert.Equal(t, tc.StartupScriptStatus, metadataNoSSHKeys[StartupScriptStatusKey], tc.Name)
+	}
+}
+
+func TestCreateInstanceMetadata_windowsScriptOnLinux(t *testing.T) {
+	state := testState(t)
+	image := StubImage("test-image", "test-project", []string{}, 100)
+	c := state.Get("config").(*Config)
+	c.Metadata = map[string]string{WindowsStartupScriptKey: "Write-Output 'windows'"}
+	c.WrapStartupScriptFile = config.TriTrue
+
+	metadataNoSSHKeys, _, err := c.createInstanceMetadata(image, "")
+
+	assert.NoError(t, err, "Metadata creation should have succeeded.")
+	assert.Equal(t, "Write-Output 'windows'", metadataNoSSHKeys[WindowsStartupScriptKey])
+	assert.Equal(t, StartupScriptStatusDone, metadataNoSSHKeys[StartupScriptStatusKey],
+		"Linux instances ignore the Windows script, there should be nothing to wait for.")
+}
+
+func TestCreateInstanceMetadata_startupScriptURL(t *testing.T) {
+	tt := []struct {
+		Name              string
+		WrapStartupScript config.Trilean
+		Licenses          []string
+		Expected          map[string]string
+		Absent            []string
+	}{
+		{
+			Name:              "linux wrapped",
+			WrapStartupScript: config.TriTrue,
+			Expected: map[string]string{
+				StartupScriptKey:           StartupScriptLinux,
+				StartupWrappedScriptURLKey: "gs://bucket/setup.sh",
+				StartupScriptStatusKey:     StartupScriptStatusNotDone,
+			},
+			Absent: []string{StartupScriptURLKey},
+		},
+		{
+			Name:              "linux not wrapped",
+			WrapStartupScript: config.TriFalse,
+			Expected:          map[string]string{StartupScriptURLKey: "gs://bucket/setup.sh"},
+			Absent:            []string{StartupWrappedScriptURLKey},
+		},
+		{
+			Name:              "windows wrapped",
+			WrapStartupScript: config.TriTrue,
+			Licenses:          []string{"windows"},
+			Expected: map[string]string{
+				WindowsStartupScriptKey:    StartupScriptWindows,
+				StartupWrappedScriptURLKey: "gs://bucket/setup.sh",
+				StartupScriptStatusKey:     StartupScriptStatusNotDone,
+			},
+			Absent: []string{StartupScriptKey},
+		},
+		{
+			Name:              "windows not wrapped",
+			WrapStartupScript: config.TriFalse,
+			Licenses:          []string{"windows"},
+			Expected: map[string]string{
+				WindowsStartupScriptURLKey: "gs://bucket/setup.sh",
+				StartupScriptStatusKey:     StartupScriptStatusDone,
+			},
+			Absent: []string{StartupWrappedScriptURLKey},
+		},
+	}
+
+	for _, tc := range tt {
+		state := testState(t)
+		image := StubImage("test-image", "test-project", tc.Licenses, 100)
+		c := state.Get("config").(*Config)
+		c.WrapStartupScriptFile = tc.WrapStartupScript
+		c.StartupScriptURL = "gs://bucket/setup.sh"
+
+		metadataNoSSHKeys, _, err := c.createInstanceMetadata(image, "")
+
+		assert.NoError(t, err, "%s: Metadata creation should have succeeded.", tc.Name)
+		for key, value := range tc.Expected {
+			assert.Equal(t, value, metadataNoSSHKeys[key], "%s: bad %s", tc.Name, key)
+		}
+		for _, key := range tc.Absent {
+			_, ok := metadataNoSSHKeys[key]
+			assert.False(t, ok, "%s: %s should not be set", tc.Name, key)
+		}
+	}
+}
+
+func TestCreateInstanceMetadata_shutdownScript(t *testing.T) {
+	for _, licenses := range [][]string{{}, {"windows"}} {
+		state := testState(t)
+		image := StubImage("test-image", "test-project", licenses, 100)
+		c := state.Get("config").(*Config)
+		c.ShutdownScriptFile = testMetadataFile(t)
+
+		metadataNoSSHKeys, _, err := c.createInstanceMetadata(image, "")
+
+		assert.NoError(t, err, "Metadata creation should have succeeded.")
+		if image.IsWindows() {
+			assert.Equal(t, ShutdownScriptWindows, metadataNoSSHKeys[WindowsShutdownScriptKey])
+		} else {
+			assert.Equal(t, ShutdownScriptLinux, metadataNoSSHKeys[ShutdownScriptKey])
+		}
+		assert.Equal(t, testMetadataFileContent, metadataNoSSHKeys[ShutdownWrappedScriptKey])
 	}
 }
 
@@ -524,6 +992,14 @@ func StubImage(name, project string, licenses []string, sizeGb int64) *common.Im
 	}
 }
 
+func TestImage_IsConfidentialCompatible(t *testing.T) {
+	i := StubImage("foo", "foo-project", []string{}, 100)
+	i.GuestOsFeatures = []*compute.GuestOsFeature{{Type: "UEFI_COMPATIBLE"}, {Type: "SEV_CAPABLE"}}
+	assert.True(t, i.IsConfidentialCompatible(common.ConfidentialInstanceTypeSEV))
+	assert.False(t, i.IsConfidentialCompatible(common.ConfidentialInstanceTypeSEVSNP))
+	assert.False(t, i.IsConfidentialCompatible(common.ConfidentialInstanceTypeTDX))
+}
+
 func TestImage_IsWindows(t *testing.T) {
 	i := StubImage("foo", "foo-project", []string{"license-foo", "license-bar"}, 100)
 	assert.False(t, i.IsWindows())
@@ -531,3 +1007,15 @@ func TestImage_IsWindows(t *testing.T) {
 	i = StubImage("foo", "foo-project", []string{"license-foo", "windows-license"}, 100)
 	assert.True(t, i.IsWindows())
 }
+
+func TestCreateInstanceMetadata_userData(t *testing.T) {
+	state := testState(t)
+	c := state.Get("config").(*Config)
+	image := StubImage("test-image", "test-project", []string{}, 100)
+	c.userData = "#cloud-config\npackages:\n  - nginx\n"
+
+	metadataNoSSHKeys, _, err := c.createInstanceMetadata(image, "")
+
+	assert.NoError(t, err, "Metadata creation should have succeeded.")
+	assert.Equal(t, c.userData, metadataNoSSHKeys[UserDataKey], "The user data should be in the instance metadata.")
+}
@@ -11,7 +11,6 @@ import (
 	"encoding/base64"
 	"encoding/binary"
 	"encoding/pem"
-	"errors"
 	"fmt"
 	"os"
 	"time"
@@ -91,15 +90,13 @@ func (s *StepCreateWindowsPassword) Run(ctx context.Context, state multistep.Sta
 		}
 	}
 
-	errCh, err := d.CreateOrResetWindowsPassword(name, c.Zone, &data)
+	ctx, cancel := context.WithTimeout(ctx, c.WindowsPasswordTimeout)
+	defer cancel()
+	errCh, err := d.CreateOrResetWindowsPassword(ctx, name, c.Zone, &data)
 
 	if err == nil {
 		ui.Message("Waiting for windows password to complete...")
-		select {
-		case err = <-errCh:
-		case <-time.After(c.WindowsPasswordTimeout):
-			err = errors.New("time out while waiting for the password to be created")
-		}
+		err = waitForOperation(ctx, errCh, "time out while waiting for the password to be created")
 	}
 
 	if err != nil {
@@ -0,0 +1,242 @@
+// Copyright IBM Corp. 2013, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package googlecompute
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-googlecompute/lib/common"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// serialPortPrefix prefixes the lines of the serial port shown in the UI.
+const serialPortPrefix = "[serial] "
+
+// serialPortFollower tails the serial port output of an instance. It keeps
+// the whole output and its last lines, and can show the new lines in the UI
+// as they come.
+type serialPortFollower struct {
+	driver    common.Driver
+	zone      string
+	name      string
+	ui        packersdk.Ui
+	logFile   io.WriteCloser
+	tailLines int
+	// show returns true when the new lines are to be shown in the UI.
+	show func() bool
+
+	mu      sync.Mutex
+	next    int64
+	partial string
+	output  strings.Builder
+	tail    []string
+
+	stop context.CancelFunc
+	done chan struct{}
+}
+
+// poll reads the output written since the previous poll.
+func (f *serialPortFollower) poll(ctx context.Context) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	contents, next, err := f.driver.GetSerialPortOutputFrom(ctx, f.zone, f.name, f.next)
+	if err != nil {
+		return err
+	}
+	f.next = next
+	if contents == "" {
+		return nil
+	}
+
+	f.output.WriteString(contents)
+	if f.logFile != nil {
+		if _, err := io.WriteString(f.logFile, contents); err != nil {
+			log.Printf("[WARN] Failed to write the serial port output to file: %s", err)
+		}
+	}
+
+	// The last line is kept until it is complete.
+	lines := strings.Split(f.partial+contents, "\n")
+	f.partial = lines[len(lines)-1]
+	show := f.show != nil && f.show()
+	for _, line := range lines[:len(lines)-1] {
+		line = strings.TrimRight(line, "\r")
+		f.tail = append(f.tail, line)
+		if show {
+			f.ui.Message(serialPortPrefix + line)
+		}
+	}
+	if len(f.tail) > f.tailLines {
+		f.tail = f.tail[len(f.tail)-f.tailLines:]
+	}
+	return nil
+}
+
+// lastLines returns the last lines of the output read so far.
+func (f *serialPortFollower) lastLines() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	lines := append([]string{}, f.tail...)
+	if f.partial != "" {
+		lines = append(lines, f.partial)
+	}
+	if len(lines) > f.tailLines {
+		lines = lines[len(lines)-f.tailLines:]
+	}
+	return lines
+}
+
+// fullOutput returns the whole output read so far.
+func (f *serialPortFollower) fullOutput() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.output.String()
+}
+
+// start polls the output in the background every interval.
+func (f *serialPortFollower) start(interval time.Duration) {
+	ctx, stop := context.WithCancel(context.Background())
+	f.stop = stop
+	f.done = make(chan struct{})
+
+	go func() {
+		defer close(f.done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			if err := f.poll(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("[WARN] Failed to get the serial port output of instance %s: %s", f.name, err)
+			}
+		}
+	}()
+}
+
+// close stops polling after reading the output once more, and closes the
+// log file. It must be called before the instance is deleted.
+func (f *serialPortFollower) close() {
+	if f.stop == nil {
+		return
+	}
+	f.stop()
+	<-f.done
+	f.stop = nil
+
+	if err := f.poll(context.Background()); err != nil {
+		log.Printf("[WARN] Failed to get the serial port output of instance %s: %s", f.name, err)
+	}
+	if f.logFile != nil {
+		if err := f.logFile.Close(); err != nil {
+			log.Printf("[WARN] Failed to close the serial port log file: %s", err)
+		}
+	}
+}
+
+// showLastLines shows the last lines of the serial port output in the UI as
+// an error, to help understand a failure.
+func showLastLines(state multistep.StateBag) {
+	raw, ok := state.GetOk("serial_port_follower")
+	if !ok {
+		return
+	}
+	follower := raw.(*serialPortFollower)
+	ui := state.Get("ui").(packersdk.Ui)
+
+	if err := follower.poll(context.Background()); err != nil {
+		log.Printf("[WARN] Failed to get the serial port output of instance %s: %s", follower.name, err)
+	}
+	lines := follower.lastLines()
+	if len(lines) == 0 {
+		return
+	}
+	ui.Error(fmt.Sprintf("Last %d lines of the serial port output:\n%s", len(lines), strings.Join(lines, "\n")))
+}
+
+// StepFollowSerialPort represents a Packer build step that follows the serial
+// port output of the instance until it is deleted. The output is shown in the
+// UI while the instance boots and while waiting for the startup script, if
+// `stream_serial_port_output` is set, and written to `serial_port_log_file`.
+type StepFollowSerialPort struct {
+	// Interval between two reads of the output. Defaults to 5 seconds.
+	Interval time.Duration
+
+	follower *serialPortFollower
+}
+
+// Run starts following the serial port output of the instance.
+func (s *StepFollowSerialPort) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	config := state.Get("config").(*Config)
+	driver := state.Get("driver").(common.Driver)
+	ui := state.Get("ui").(packersdk.Ui)
+
+	s.follower = &serialPortFollower{
+		driver:    driver,
+		zone:      config.Zone,
+		name:      state.Get("instance_name").(string),
+		ui:        ui,
+		tailLines: config.SerialPortTailLines,
+		show: func() bool {
+			if !config.StreamSerialPortOutput {
+				return false
+			}
+			// The instance is done booting once connected.
+			_, connected := state.GetOk("communicator")
+			_, waiting := state.GetOk("waiting_startup_script")
+			return !connected || waiting
+		},
+	}
+
+	if config.SerialPortLogFile != "" {
+		// The output of the instances of later attempts, after a preemption,
+		// is appended to the one of the first attempt.
+		flag := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+		attempt, _ := state.Get("build_attempt").(int)
+		if attempt > 1 {
+			flag = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+		}
+		logFile, err := os.OpenFile(config.SerialPortLogFile, flag, 0666)
+		if err == nil && attempt > 1 {
+			_, err = fmt.Fprintf(logFile, "\n==> Build attempt %d, instance %s\n", attempt, s.follower.name)
+		}
+		if err != nil {
+			err := fmt.Errorf("Error creating the serial port log file: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+		s.follower.logFile = logFile
+	}
+
+	interval := s.Interval
+	if interval == 0 {
+		interval = 5 * time.Second
+	}
+	s.follower.start(interval)
+	state.Put("serial_port_follower", s.follower)
+
+	return multistep.ActionContinue
+}
+
+// Cleanup stops following the serial port output.
+func (s *StepFollowSerialPort) Cleanup(state multistep.StateBag) {
+	if s.follower != nil {
+		s.follower.close()
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright IBM Corp. 2013, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package googlecompute
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-googlecompute/lib/common"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStepFollowSerialPort_impl(t *testing.T) {
+	var _ multistep.Step = new(StepFollowSerialPort)
+}
+
+func TestSerialPortFollower_poll(t *testing.T) {
+	d := &common.DriverMock{}
+	out := new(bytes.Buffer)
+	show := true
+	f := &serialPortFollower{
+		driver:    d,
+		zone:      "us-central1-a",
+		name:      "packer-instance",
+		ui:        &packersdk.BasicUi{Reader: new(bytes.Buffer), Writer: out},
+		tailLines: 2,
+		show:      func() bool { return show },
+	}
+
+	d.GetSerialPortOutputFromContents = "booting\nstarting scr"
+	assert.NoError(t, f.poll(context.Background()))
+	assert.Equal(t, "[serial] booting\n", out.String(), "Only complete lines should be shown.")
+
+	d.GetSerialPortOutputFromContents += "ipt\nscript done\n"
+	assert.NoError(t, f.poll(context.Background()))
+	assert.Equal(t, "[serial] booting\n[serial] starting script\n[serial] script done\n", out.String())
+	assert.Equal(t, []string{"starting script", "script done"}, f.lastLines())
+
+	show = false
+	d.GetSerialPortOutputFromContents += "shutting down\nhalt"
+	assert.NoError(t, f.poll(context.Background()))
+	assert.NotContains(t, out.String(), "shutting down", "Lines should not be shown while hidden.")
+	assert.Equal(t, []string{"shutting down", "halt"}, f.lastLines())
+	assert.Equal(t, d.GetSerialPortOutputFromContents, f.fullOutput())
+	assert.Equal(t, "packer-instance", d.GetSerialPortOutputName)
+}
+
+func TestStepFollowSerialPort(t *testing.T) {
+	state := testState(t)
+	step := &StepFollowSerialPort{Interval: time.Hour}
+	c := state.Get("config").(*Config)
+	d := state.Get("driver").(*common.DriverMock)
+
+	c.SerialPortLogFile = filepath.Join(t.TempDir(), "serial.log")
+	state.Put("instance_name", "packer-instance")
+	d.GetSerialPortOutputFromContents = "booting\nready\n"
+
+	assert.Equal(t, multistep.ActionContinue, step.Run(context.Background(), state))
+	_, ok := state.GetOk("serial_port_follower")
+	assert.True(t, ok, "The follower should be in state.")
+
+	step.Cleanup(state)
+
+	log, err := os.ReadFile(c.SerialPortLogFile)
+	assert.NoError(t, err)
+	assert.Equal(t, "booting\nready\n", string(log))
+}
+
+func TestStepFollowSerialPort_attempts(t *testing.T) {
+	state := testState(t)
+	c := state.Get("config").(*Config)
+	d := state.Get("driver").(*common.DriverMock)
+
+	c.SerialPortLogFile = filepath.Join(t.TempDir(), "serial.log")
+	state.Put("instance_name", "packer-instance")
+
+	for attempt, output := range []string{"booting\npreempted\n", "booting\nready\n"} {
+		step := &StepFollowSerialPort{Interval: time.Hour}
+		state.Put("build_attempt", attempt+1)
+		d.GetSerialPortOutputFromContents = output
+
+		assert.Equal(t, multistep.ActionContinue, step.Run(context.Background(), state))
+		step.Cleanup(state)
+	}
+
+	log, err := os.ReadFile(c.SerialPortLogFile)
+	assert.NoError(t, err)
+	assert.Equal(t, "booting\npreempted\n\n==> Build attempt 2, instance packer-instance\nbooting\nready\n", string(log))
+}
+
+func TestStepFollowSerialPort_badLogFile(t *testing.T) {
+	state := testState(t)
+	step := &StepFollowSerialPort{Interval: time.Hour}
+	c := state.Get("config").(*Config)
+
+	c.SerialPortLogFile = filepath.Join(t.TempDir(), "missing", "serial.log")
+	state.Put("instance_name", "packer-instance")
+
+	assert.Equal(t, multistep.ActionHalt, step.Run(context.Background(), state))
+	_, ok := state.GetOk("error")
+	assert.True(t, ok, "An error should be in state.")
+	step.Cleanup(state)
+}
@@ -28,7 +28,7 @@ const (
 // StepImportOSLoginSSHKey imports a temporary SSH key pair into a GCE login profile.
 type StepImportOSLoginSSHKey struct {
 	Debug         bool
-	TokeninfoFunc func() (*oauth2.Tokeninfo, error)
+	TokeninfoFunc func(context.Context) (*oauth2.Tokeninfo, error)
 	accountEmail  string
 	GCEUserFunc   func() string
 }
@@ -40,7 +40,7 @@ func (s *StepImportOSLoginSSHKey) Run(ctx context.Context, state multistep.State
 	driver := state.Get("driver").(common.Driver)
 	ui := state.Get("ui").(packersdk.Ui)
 
-	osLoginEnabledAtProject, err := driver.GetProjectMetadata(config.Zone, EnableOSLoginKey)
+	osLoginEnabledAtProject, err := driver.GetProjectMetadata(ctx, config.Zone, EnableOSLoginKey)
 	if err != nil {
 		log.Printf("failed to get project metadata: %s", err)
 	}
@@ -77,7 +77,7 @@ func (s *StepImportOSLoginSSHKey) Run(ctx context.Context, state multistep.State
 	}
 
 	if s.accountEmail == "" {
-		info, err := s.TokeninfoFunc()
+		info, err := s.TokeninfoFunc(ctx)
 		if err != nil {
 			log.Printf("failed to derive account info from token: %s", err)
 		} else {
@@ -111,7 +111,7 @@ func (s *StepImportOSLoginSSHKey) Run(ctx context.Context, state multistep.State
 		expirationTimeUsec = &expirationTimeUsecVal
 	}
 
-	loginProfile, err := driver.ImportOSLoginSSHKey(s.accountEmail, string(config.Comm.SSHPublicKey), expirationTimeUsec)
+	loginProfile, err := driver.ImportOSLoginSSHKey(ctx, s.accountEmail, string(config.Comm.SSHPublicKey), expirationTimeUsec)
 	if err != nil {
 		err := fmt.Errorf("Error importing SSH public key for OSLogin: %s", err)
 		state.Put("error", err)
@@ -157,7 +157,7 @@ func (s *StepImportOSLoginSSHKey) Cleanup(state multistep.StateBag) {
 	}
 
 	ui.Say("Deleting SSH public key for OSLogin...")
-	err := driver.DeleteOSLoginSSHKey(s.accountEmail, fingerprint)
+	err := driver.DeleteOSLoginSSHKey(context.Background(), s.accountEmail, fingerprint)
 	if err != nil {
 		ui.Error(fmt.Sprintf("Error deleting SSH public key for OSLogin. Please delete it manually.\n\nError: %s", err))
 		return
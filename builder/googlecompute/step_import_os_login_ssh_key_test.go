@@ -47,7 +47,7 @@ func TestStepImportOSLoginSSHKey(t *testing.T) {
 		state := testState(t)
 		fakeAccountEmail := "raffi-compute@developer.gserviceaccount.com"
 		step := &StepImportOSLoginSSHKey{
-			TokeninfoFunc: func() (*oauth2.Tokeninfo, error) {
+			TokeninfoFunc: func(context.Context) (*oauth2.Tokeninfo, error) {
 				return &oauth2.Tokeninfo{Email: fakeAccountEmail}, nil
 			},
 		}
@@ -79,7 +79,7 @@ func TestStepImportOSLoginSSHKey_withAccountFile(t *testing.T) {
 	state := testState(t)
 	fakeAccountEmail := "raffi-compute@developer.gserviceaccount.com"
 	step := &StepImportOSLoginSSHKey{
-		TokeninfoFunc: func() (*oauth2.Tokeninfo, error) {
+		TokeninfoFunc: func(context.Context) (*oauth2.Tokeninfo, error) {
 			return &oauth2.Tokeninfo{Email: fakeAccountEmail}, nil
 		},
 	}
@@ -112,7 +112,7 @@ func TestStepImportOSLoginSSHKey_withNoAccountFile(t *testing.T) {
 	state := testState(t)
 	fakeAccountEmail := "testing@packer.io"
 	step := &StepImportOSLoginSSHKey{
-		TokeninfoFunc: func() (*oauth2.Tokeninfo, error) {
+		TokeninfoFunc: func(context.Context) (*oauth2.Tokeninfo, error) {
 			return &oauth2.Tokeninfo{Email: fakeAccountEmail}, nil
 		},
 	}
@@ -179,7 +179,7 @@ func TestStepImportOSLoginSSHKey_withGCEAndAccount(t *testing.T) {
 	fakeGCEEmail := "testing@packer.io"
 	fakeAccountEmail := "raffi-compute@developer.gserviceaccount.com"
 	step := &StepImportOSLoginSSHKey{
-		TokeninfoFunc: func() (*oauth2.Tokeninfo, error) {
+		TokeninfoFunc: func(context.Context) (*oauth2.Tokeninfo, error) {
 			return &oauth2.Tokeninfo{Email: fakeAccountEmail}, nil
 		},
 		GCEUserFunc: func() string {
@@ -317,7 +317,7 @@ func TestStepImportOSLoginSSHKey_withExpirationTime(t *testing.T) {
 	fakeAccountEmail := "raffi-compute@developer.gserviceaccount.com"
 	driver := state.Get("driver").(*common.DriverMock)
 	step := &StepImportOSLoginSSHKey{
-		TokeninfoFunc: func() (*oauth2.Tokeninfo, error) {
+		TokeninfoFunc: func(context.Context) (*oauth2.Tokeninfo, error) {
 			return &oauth2.Tokeninfo{Email: fakeAccountEmail}, nil
 		},
 	}
@@ -360,7 +360,7 @@ func TestStepImportOSLoginSSHKey_withoutExpirationTime(t *testing.T) {
 	fakeAccountEmail := "raffi-compute@developer.gserviceaccount.com"
 	driver := state.Get("driver").(*common.DriverMock)
 	step := &StepImportOSLoginSSHKey{
-		TokeninfoFunc: func() (*oauth2.Tokeninfo, error) {
+		TokeninfoFunc: func(context.Context) (*oauth2.Tokeninfo, error) {
 			return &oauth2.Tokeninfo{Email: fakeAccountEmail}, nil
 		},
 	}
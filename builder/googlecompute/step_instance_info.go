@@ -5,9 +5,7 @@ package googlecompute
 
 import (
 	"context"
-	"errors"
 	"fmt"
-	"time"
 
 	"github.com/hashicorp/packer-plugin-googlecompute/lib/common"
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
@@ -29,13 +27,10 @@ func (s *StepInstanceInfo) Run(ctx context.Context, state multistep.StateBag) mu
 	instanceName := state.Get("instance_name").(string)
 
 	ui.Say("Waiting for the instance to become running...")
-	errCh := driver.WaitForInstance("RUNNING", config.Zone, instanceName)
-	var err error
-	select {
-	case err = <-errCh:
-	case <-time.After(config.StateTimeout):
-		err = errors.New("time out while waiting for instance to become running")
-	}
+	waitCtx, cancel := context.WithTimeout(ctx, config.StateTimeout)
+	err := waitForOperation(waitCtx, driver.WaitForInstance(waitCtx, "RUNNING", config.Zone, instanceName),
+		"time out while waiting for instance to become running")
+	cancel()
 
 	if err != nil {
 		err := fmt.Errorf("Error waiting for instance: %s", err)
@@ -44,41 +39,40 @@ func (s *StepInstanceInfo) Run(ctx context.Context, state multistep.StateBag) mu
 		return multistep.ActionHalt
 	}
 
-	if config.UseInternalIP {
-		ip, err := driver.GetInternalIP(config.Zone, instanceName)
-		if err != nil {
-			err := fmt.Errorf("Error retrieving instance internal ip address: %s", err)
-			state.Put("error", err)
-			ui.Error(err.Error())
-			return multistep.ActionHalt
-		}
-
-		if s.Debug {
-			if ip != "" {
-				ui.Message(fmt.Sprintf("Internal IP: %s", ip))
-			}
-		}
-		ui.Message(fmt.Sprintf("IP: %s", ip))
-		state.Put("instance_ip", ip)
-		return multistep.ActionContinue
-	} else {
-		ip, err := driver.GetNatIP(config.Zone, instanceName)
-		if err != nil {
-			err := fmt.Errorf("Error retrieving instance nat ip address: %s", err)
-			state.Put("error", err)
-			ui.Error(err.Error())
-			return multistep.ActionHalt
-		}
+	nic := config.InstanceIPNetworkInterface
+	var ip, kind, label string
+	switch {
+	case config.UseIPv6 && config.UseInternalIP:
+		kind, label = "internal IPv6", "Internal IPv6"
+		ip, err = driver.GetInternalIPv6(ctx, config.Zone, instanceName, nic)
+	case config.UseIPv6:
+		kind, label = "external IPv6", "Public IPv6"
+		ip, err = driver.GetExternalIPv6(ctx, config.Zone, instanceName, nic)
+	case config.UseInternalIP:
+		kind, label = "internal ip", "Internal IP"
+		ip, err = driver.GetInternalIP(ctx, config.Zone, instanceName, nic)
+	default:
+		kind, label = "nat ip", "Public IP"
+		ip, err = driver.GetNatIP(ctx, config.Zone, instanceName, nic)
+	}
+	if err == nil && ip == "" && config.UseIPv6 {
+		err = fmt.Errorf("network interface nic%d has no %s address", nic, kind)
+	}
+	if err != nil {
+		err := fmt.Errorf("Error retrieving instance %s address: %s", kind, err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
 
-		if s.Debug {
-			if ip != "" {
-				ui.Message(fmt.Sprintf("Public IP: %s", ip))
-			}
+	if s.Debug {
+		if ip != "" {
+			ui.Message(fmt.Sprintf("%s: %s", label, ip))
 		}
-		ui.Message(fmt.Sprintf("IP: %s", ip))
-		state.Put("instance_ip", ip)
-		return multistep.ActionContinue
 	}
+	ui.Message(fmt.Sprintf("IP: %s", ip))
+	state.Put("instance_ip", ip)
+	return multistep.ActionContinue
 }
 
 // Cleanup.
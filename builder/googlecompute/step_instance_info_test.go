@@ -6,6 +6,7 @@ package googlecompute
 import (
 	"context"
 	"errors"
+	"strings"
 	"testing"
 	"time"
 
@@ -95,6 +96,94 @@ func TestStepInstanceInfo_InternalIP(t *testing.T) {
 	}
 }
 
+func TestStepInstanceInfo_networkInterface(t *testing.T) {
+	state := testState(t)
+	step := new(StepInstanceInfo)
+	defer step.Cleanup(state)
+
+	state.Put("instance_name", "foo")
+
+	config := state.Get("config").(*Config)
+	config.UseInternalIP = true
+	config.InstanceIPNetworkInterface = 1
+	driver := state.Get("driver").(*common.DriverMock)
+	driver.GetInternalIPResult = "5.6.7.8"
+
+	// run the step
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	if driver.GetInternalIPNic != 1 {
+		t.Fatalf("should get the IP of nic1, got nic%d", driver.GetInternalIPNic)
+	}
+	if ip := state.Get("instance_ip").(string); ip != "5.6.7.8" {
+		t.Fatalf("bad ip: %s", ip)
+	}
+}
+
+func TestStepInstanceInfo_ipv6(t *testing.T) {
+	cases := []struct {
+		name          string
+		useInternalIP bool
+	}{
+		{"external", false},
+		{"internal", true},
+	}
+
+	for _, tc := range cases {
+		state := testState(t)
+		step := new(StepInstanceInfo)
+
+		state.Put("instance_name", "foo")
+
+		config := state.Get("config").(*Config)
+		config.UseIPv6 = true
+		config.UseInternalIP = tc.useInternalIP
+		driver := state.Get("driver").(*common.DriverMock)
+		driver.GetNatIPResult = "1.2.3.4"
+		driver.GetInternalIPResult = "10.0.0.2"
+		driver.GetExternalIPv6Result = "2600:1900::1"
+		driver.GetInternalIPv6Result = "fd20::2"
+
+		if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+			t.Fatalf("%s: bad action: %#v", tc.name, action)
+		}
+
+		expected := driver.GetExternalIPv6Result
+		if tc.useInternalIP {
+			expected = driver.GetInternalIPv6Result
+		}
+		if ip := state.Get("instance_ip").(string); ip != expected {
+			t.Fatalf("%s: expected %s, got %s", tc.name, expected, ip)
+		}
+		step.Cleanup(state)
+	}
+}
+
+func TestStepInstanceInfo_ipv6Missing(t *testing.T) {
+	state := testState(t)
+	step := new(StepInstanceInfo)
+	defer step.Cleanup(state)
+
+	state.Put("instance_name", "foo")
+
+	config := state.Get("config").(*Config)
+	config.UseIPv6 = true
+
+	// run the step
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	if _, ok := state.GetOk("error"); !ok {
+		t.Fatal("should have error")
+	}
+	if _, ok := state.GetOk("instance_ip"); ok {
+		t.Fatal("should not have ip")
+	}
+}
+
 func TestStepInstanceInfo_getNatIPError(t *testing.T) {
 	state := testState(t)
 	step := new(StepInstanceInfo)
@@ -178,3 +267,29 @@ func TestStepInstanceInfo_errorTimeout(t *testing.T) {
 		t.Fatal("should NOT have instance IP")
 	}
 }
+
+func TestStepInstanceInfo_cancelled(t *testing.T) {
+	state := testState(t)
+	step := new(StepInstanceInfo)
+	defer step.Cleanup(state)
+
+	state.Put("instance_name", "foo")
+
+	// The instance never becomes running, the build is cancelled instead.
+	driver := state.Get("driver").(*common.DriverMock)
+	driver.WaitForInstanceErrCh = make(chan error)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if action := step.Run(ctx, state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	err, ok := state.GetOk("error")
+	if !ok {
+		t.Fatal("should have error")
+	}
+	if !strings.Contains(err.(error).Error(), context.Canceled.Error()) {
+		t.Fatalf("should have been cancelled, got: %s", err)
+	}
+}
@@ -0,0 +1,52 @@
+// Copyright IBM Corp. 2013, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package googlecompute
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/packer-plugin-googlecompute/lib/common"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// StepSetImageIAMPolicy represents a Packer build step that adds the
+// configured IAM policy bindings to the created image, to share it.
+type StepSetImageIAMPolicy int
+
+// Run executes the Packer build step that shares the image.
+func (s *StepSetImageIAMPolicy) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	config := state.Get("config").(*Config)
+	driver := state.Get("driver").(common.Driver)
+	ui := state.Get("ui").(packersdk.Ui)
+
+	if len(config.ImageIAMBindings) == 0 {
+		return multistep.ActionContinue
+	}
+
+	// No image is created with skip_create_image.
+	rawImage, ok := state.GetOk("image")
+	if !ok {
+		ui.Say("Skipping IAM policy bindings, as no image was created...")
+		return multistep.ActionContinue
+	}
+	image := rawImage.(*common.Image)
+
+	ui.Say("Adding IAM policy bindings to the image...")
+	for _, b := range config.ImageIAMBindings {
+		ui.Message(fmt.Sprintf("Granting %s to %d member(s)", b.Role, len(b.Members)))
+	}
+	if err := driver.AddImageIAMBindings(ctx, config.ImageProjectId, image.Name, config.ImageIAMBindings); err != nil {
+		err := fmt.Errorf("Error adding IAM policy bindings to image %s: %s", image.Name, err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	return multistep.ActionContinue
+}
+
+// Cleanup.
+func (s *StepSetImageIAMPolicy) Cleanup(state multistep.StateBag) {}
@@ -0,0 +1,84 @@
+// Copyright IBM Corp. 2013, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package googlecompute
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-googlecompute/lib/common"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStepSetImageIAMPolicy_impl(t *testing.T) {
+	var _ multistep.Step = new(StepSetImageIAMPolicy)
+}
+
+func TestStepSetImageIAMPolicy(t *testing.T) {
+	state := testState(t)
+	step := new(StepSetImageIAMPolicy)
+	defer step.Cleanup(state)
+
+	c := state.Get("config").(*Config)
+	c.ImageIAMBindings = []common.IAMBinding{
+		{Role: "roles/compute.imageUser", Members: []string{"group:builders@example.com"}},
+	}
+	state.Put("image", &common.Image{Name: "test-image", ProjectId: c.ImageProjectId})
+	d := state.Get("driver").(*common.DriverMock)
+
+	assert.Equal(t, multistep.ActionContinue, step.Run(context.Background(), state), "Step should have passed and continued.")
+
+	assert.Equal(t, c.ImageProjectId, d.AddImageIAMBindingsProjectId)
+	assert.Equal(t, "test-image", d.AddImageIAMBindingsName)
+	assert.Equal(t, c.ImageIAMBindings, d.AddImageIAMBindingsBindings)
+}
+
+func TestStepSetImageIAMPolicy_noBindings(t *testing.T) {
+	state := testState(t)
+	step := new(StepSetImageIAMPolicy)
+	defer step.Cleanup(state)
+
+	assert.Equal(t, multistep.ActionContinue, step.Run(context.Background(), state), "Step should have passed and continued.")
+
+	d := state.Get("driver").(*common.DriverMock)
+	assert.Empty(t, d.AddImageIAMBindingsName, "No policy should be set without bindings.")
+}
+
+func TestStepSetImageIAMPolicy_skipCreateImage(t *testing.T) {
+	state := testState(t)
+	step := new(StepSetImageIAMPolicy)
+	defer step.Cleanup(state)
+
+	c := state.Get("config").(*Config)
+	c.SkipCreateImage = true
+	c.ImageIAMBindings = []common.IAMBinding{
+		{Role: "roles/compute.imageUser", Members: []string{"group:builders@example.com"}},
+	}
+
+	assert.Equal(t, multistep.ActionContinue, new(StepCreateImage).Run(context.Background(), state))
+	assert.Equal(t, multistep.ActionContinue, step.Run(context.Background(), state), "Step should have passed and continued.")
+
+	d := state.Get("driver").(*common.DriverMock)
+	assert.Empty(t, d.AddImageIAMBindingsName, "No policy should be set without an image.")
+}
+
+func TestStepSetImageIAMPolicy_error(t *testing.T) {
+	state := testState(t)
+	step := new(StepSetImageIAMPolicy)
+	defer step.Cleanup(state)
+
+	c := state.Get("config").(*Config)
+	c.ImageIAMBindings = []common.IAMBinding{
+		{Role: "roles/compute.imageUser", Members: []string{"group:builders@example.com"}},
+	}
+	state.Put("image", &common.Image{Name: "test-image"})
+	d := state.Get("driver").(*common.DriverMock)
+	d.AddImageIAMBindingsErr = errors.New("error")
+
+	assert.Equal(t, multistep.ActionHalt, step.Run(context.Background(), state), "Step should have failed and halted.")
+	_, ok := state.GetOk("error")
+	assert.True(t, ok, "State should have an error.")
+}
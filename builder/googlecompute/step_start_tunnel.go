@@ -7,25 +7,17 @@
 package googlecompute
 
 import (
-	"bufio"
-	"bytes"
 	"context"
 	"fmt"
-	"io"
 	"log"
-	"os"
-	"os/exec"
-	"runtime"
-	"strconv"
-	"strings"
 	"time"
 
+	"github.com/hashicorp/packer-plugin-googlecompute/lib/common"
 	"github.com/hashicorp/packer-plugin-sdk/communicator"
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	"github.com/hashicorp/packer-plugin-sdk/net"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 	"github.com/hashicorp/packer-plugin-sdk/retry"
-	"github.com/hashicorp/packer-plugin-sdk/tmp"
 )
 
 // StepStartTunnel represents a Packer build step that launches an IAP tunnel
@@ -33,7 +25,8 @@ type IAPConfig struct {
 	// Whether to use an IAP proxy.
 	// Prerequisites and limitations for using IAP:
 	// - You must manually enable the IAP API in the Google Cloud console.
-	// - You must have the gcloud sdk installed on the computer running Packer.
+	// - The tunnel is opened by Packer itself with the credentials of the build,
+	//   the gcloud sdk is not required.
 	// - If you use a service account, you must add it to project level IAP permissions
 	//   in https://console.cloud.google.com/security/iap. To do so, click
 	//   "project" > "SSH and TCP resources" > "All Tunnel Resources" >
@@ -43,87 +36,16 @@ type IAPConfig struct {
 	// Which port to connect the local end of the IAM localhost proxy to. If
 	// left blank, Packer will choose a port for you from available ports.
 	IAPLocalhostPort int `mapstructure:"iap_localhost_port"`
-	// What "hashbang" to use to invoke script that sets up gcloud.
-	// Default: "/bin/sh"
+	// Deprecated: the IAP tunnel no longer uses gcloud, this is ignored.
 	IAPHashBang string `mapstructure:"iap_hashbang" required:"false"`
-	// What file extension to use for script that sets up gcloud.
-	// Default: ".sh"
+	// Deprecated: the IAP tunnel no longer uses gcloud, this is ignored.
 	IAPExt string `mapstructure:"iap_ext" required:"false"`
-	// How long to wait, in seconds, before assuming a tunnel launch was
-	// successful. Defaults to 30 seconds for SSH or 40 seconds for WinRM.
+	// How long to wait, in seconds, for IAP to connect to the instance
+	// before retrying to launch the tunnel. Defaults to 30 seconds for SSH or
+	// 40 seconds for WinRM.
 	IAPTunnelLaunchWait int `mapstructure:"iap_tunnel_launch_wait" required:"false"`
 }
 
-type TunnelDriver interface {
-	StartTunnel(context.Context, string, int) error
-	StopTunnel()
-}
-
-func RunTunnelCommand(cmd *exec.Cmd, timeout int) error {
-	// set stdout and stderr so we can read what's going on.
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-	err := cmd.Start()
-	if err != nil {
-		err := fmt.Errorf("Error calling gcloud sdk to launch IAP tunnel: %s",
-			err)
-		return err
-	}
-
-	// Give tunnel 30 seconds to either launch, or return an error.
-	// Unfortunately, the SDK doesn't provide any official acknowledgment that
-	// the tunnel is launched when it's not being run through a TTY so we
-	// are just trusting here that 30s is enough to know whether the tunnel
-	// launch was going to fail. Yep, feels icky to me too. But I spent an
-	// afternoon trying to figure out how to get the SDK to actually send
-	// the "Listening on port [n]" line I see when I run it manually, and I
-	// can't justify spending more time than that on aesthetics.
-
-	for i := 0; i < timeout; i++ {
-		time.Sleep(1 * time.Second)
-
-		lineStderr, err := stderr.ReadString('\n')
-		if err != nil && err != io.EOF {
-			log.Printf("Err from scanning stderr is %s", err)
-			return fmt.Errorf("Error reading stderr from tunnel launch: %s", err)
-		}
-		if lineStderr != "" {
-			log.Printf("stderr: %s", lineStderr)
-		}
-
-		lineStdout, err := stdout.ReadString('\n')
-		if err != nil && err != io.EOF {
-			log.Printf("Err from scanning stdout is %s", err)
-			return fmt.Errorf("Error reading stdout from tunnel launch: %s", err)
-		}
-		if lineStdout != "" {
-			log.Printf("stdout: %s", lineStdout)
-		}
-
-		if strings.Contains(lineStderr, "ERROR") {
-			// 4033: Either you don't have permission to access the instance,
-			// the instance doesn't exist, or the instance is stopped. The two
-			// sub-errors we may see while the permissions settle are "not
-			// authorized" and "failed to connect to backend," but after about a
-			// minute of retries this goes away and we're able to connect.
-			// 4003: "failed to connect to backend". Network blip.
-			// 4047: "Either the instance doesn't exist, or the instance is
-			// stopped. Ensure that the VM is powered on and has completed its
-			// startup" — Let's wait a little more in this case.
-			if strings.Contains(lineStderr, "4033") || strings.Contains(lineStderr, "4003") || strings.Contains(lineStderr, "4047") {
-				return RetryableTunnelError{lineStderr}
-			} else {
-				log.Printf("NOT RETRYABLE: %s", lineStderr)
-				return fmt.Errorf("Non-retryable tunnel error: %s", lineStderr)
-			}
-		}
-	}
-
-	log.Printf("No error detected after tunnel launch; continuing...")
-	return nil
-}
-
 type RetryableTunnelError struct {
 	s string
 }
@@ -133,11 +55,9 @@ func (e RetryableTunnelError) Error() string {
 }
 
 type StepStartTunnel struct {
-	IAPConf            *IAPConfig
-	CommConf           *communicator.Config
-	AccountFile        string
-	ImpersonateAccount string
-	ProjectId          string
+	IAPConf   *IAPConfig
+	CommConf  *communicator.Config
+	ProjectId string
 
 	tunnelDriver TunnelDriver
 }
@@ -173,80 +93,6 @@ func (s *StepStartTunnel) ConfigureLocalHostPort(ctx context.Context) error {
 	return nil
 }
 
-func (s *StepStartTunnel) createTempGcloudScript(args []string) (string, error) {
-	// Generate temp script that contains both gcloud auth and gcloud compute
-	// iap launch call.
-
-	// Create temp file.
-	tf, err := tmp.File("gcloud-setup")
-	if err != nil {
-		return "", fmt.Errorf("Error preparing gcloud setup script: %s", err)
-	}
-	defer tf.Close()
-	// Write our contents to it
-	writer := bufio.NewWriter(tf)
-
-	if s.IAPConf.IAPHashBang != "" {
-		s.IAPConf.IAPHashBang = fmt.Sprintf("#!%s\n", s.IAPConf.IAPHashBang)
-		log.Printf("[INFO] (google): Prepending inline gcloud setup script with %s",
-			s.IAPConf.IAPHashBang)
-		_, err = writer.WriteString(s.IAPConf.IAPHashBang)
-		if err != nil {
-			return "", fmt.Errorf("Error preparing inline hashbang: %s", err)
-		}
-	}
-
-	// call command
-	args = append([]string{"gcloud"}, args...)
-	argString := strings.Join(args, " ")
-
-	if s.AccountFile == "" {
-		s.AccountFile = os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
-	}
-
-	if s.AccountFile != "" {
-		if runtime.GOOS == "windows" {
-			_, err = fmt.Fprintf(writer, "set \"CLOUDSDK_AUTH_CREDENTIAL_FILE_OVERRIDE=%s\"\n", s.AccountFile)
-		} else {
-			_, err = fmt.Fprintf(writer, "CLOUDSDK_AUTH_CREDENTIAL_FILE_OVERRIDE='%s'\nexport CLOUDSDK_AUTH_CREDENTIAL_FILE_OVERRIDE\n", s.AccountFile)
-		}
-		if err != nil {
-			return "", fmt.Errorf("error preparing shell script: %s", err)
-		}
-	}
-
-	if runtime.GOOS == "windows" {
-		_, err = fmt.Fprintf(writer, "call %s\n", argString)
-	} else {
-		_, err = fmt.Fprintf(writer, "%s\n", argString)
-	}
-	if err != nil {
-		return "", fmt.Errorf("error preparing shell script: %s", err)
-	}
-
-	if err := writer.Flush(); err != nil {
-		return "", fmt.Errorf("Error preparing shell script: %s", err)
-	}
-	// Have to close temp file before renaming it or Windows will complain.
-	tf.Close()
-	err = os.Chmod(tf.Name(), 0700)
-	if err != nil {
-		log.Printf("[ERROR] (google): error modifying permissions of temp script file: %s", err.Error())
-	}
-
-	// figure out what extension the file should have, and rename it.
-	tempScriptFileName := tf.Name()
-	if s.IAPConf.IAPExt != "" {
-		err := os.Rename(tempScriptFileName, fmt.Sprintf("%s%s", tempScriptFileName, s.IAPConf.IAPExt))
-		if err != nil {
-			return "", fmt.Errorf("Error setting the correct temp file extension: %s", err)
-		}
-		tempScriptFileName = fmt.Sprintf("%s%s", tempScriptFileName, s.IAPConf.IAPExt)
-	}
-
-	return tempScriptFileName, nil
-}
-
 // Run executes the Packer build step that creates an IAP tunnel.
 func (s *StepStartTunnel) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
 	if !s.IAPConf.IAP {
@@ -254,8 +100,8 @@ func (s *StepStartTunnel) Run(ctx context.Context, state multistep.StateBag) mul
 		return multistep.ActionContinue
 	}
 
-	// shell out to create the tunnel.
 	ui := state.Get("ui").(packersdk.Ui)
+	driver := state.Get("driver").(common.Driver)
 	instanceName := state.Get("instance_name").(string)
 	c := state.Get("config").(*Config)
 
@@ -268,16 +114,9 @@ func (s *StepStartTunnel) Run(ctx context.Context, state multistep.StateBag) mul
 		return multistep.ActionHalt
 	}
 
-	// Generate list of args to use to call gcloud cli.
-	args := []string{"compute", "start-iap-tunnel", instanceName,
-		strconv.Itoa(s.CommConf.Port()),
-		fmt.Sprintf("--local-host-port=localhost:%d", s.IAPConf.IAPLocalhostPort),
-		"--zone", c.Zone, "--project", s.ProjectId,
-	}
-
-	if s.ImpersonateAccount != "" {
-		args = append(args, fmt.Sprintf("--impersonate-service-account='%s'", s.ImpersonateAccount))
-	}
+	// The port the communicator listens on, on the instance. Read it before
+	// the communicator is pointed at the local end of the tunnel.
+	remotePort := s.CommConf.Port()
 
 	// This is the port the IAP tunnel listens on, on localhost.
 	// TODO make setting LocalHostPort optional
@@ -290,18 +129,26 @@ func (s *StepStartTunnel) Run(ctx context.Context, state multistep.StateBag) mul
 		return multistep.ActionHalt
 	}
 
-	log.Printf("Creating tunnel launch script with args %#v", args)
-	// Create temp file that contains both gcloud authentication, and gcloud
-	// proxy setup call.
-	tempScriptFileName, err := s.createTempGcloudScript(args)
-	if err != nil {
-		state.Put("error", err)
-		ui.Error(err.Error())
-		return multistep.ActionHalt
-	}
-	defer os.Remove(tempScriptFileName)
+	if s.tunnelDriver == nil {
+		tokenSource, err := driver.GetTokenSource(ctx)
+		if err != nil {
+			err := fmt.Errorf("Error getting credentials for IAP tunnel: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
 
-	s.tunnelDriver = NewTunnelDriver()
+		s.tunnelDriver = NewTunnelDriver(&common.IAPTunnel{
+			ProjectId:   s.ProjectId,
+			Zone:        c.Zone,
+			Instance:    instanceName,
+			Interface:   fmt.Sprintf("nic%d", c.InstanceIPNetworkInterface),
+			Port:        remotePort,
+			LocalPort:   s.IAPConf.IAPLocalhostPort,
+			TokenSource: tokenSource,
+			Endpoint:    c.CustomEndpoints["iap"],
+		})
+	}
 
 	err = retry.Config{
 		Tries: 11,
@@ -315,8 +162,10 @@ func (s *StepStartTunnel) Run(ctx context.Context, state multistep.StateBag) mul
 		},
 		RetryDelay: (&retry.Backoff{InitialBackoff: 200 * time.Millisecond, MaxBackoff: 30 * time.Second, Multiplier: 2}).Linear,
 	}.Run(ctx, func(ctx context.Context) error {
-		// tunnel launcher/destroyer has to be different on windows vs. unix.
-		err := s.tunnelDriver.StartTunnel(ctx, tempScriptFileName, s.IAPConf.IAPTunnelLaunchWait)
+		err := s.tunnelDriver.StartTunnel(ctx, s.IAPConf.IAPTunnelLaunchWait)
+		if err != nil {
+			log.Printf("[DEBUG] IAP tunnel launch failed: %s", err)
+		}
 		return err
 	})
 	if err != nil {
@@ -325,6 +174,7 @@ func (s *StepStartTunnel) Run(ctx context.Context, state multistep.StateBag) mul
 		return multistep.ActionHalt
 	}
 
+	ui.Message(fmt.Sprintf("IAP tunnel listening on localhost:%d", s.IAPConf.IAPLocalhostPort))
 	return multistep.ActionContinue
 }
 
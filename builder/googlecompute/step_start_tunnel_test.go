@@ -5,79 +5,105 @@ package googlecompute
 
 import (
 	"context"
-	"os"
-	"runtime"
+	"errors"
 	"testing"
 
+	"github.com/hashicorp/packer-plugin-googlecompute/lib/common"
 	"github.com/hashicorp/packer-plugin-sdk/communicator"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
 )
 
 type MockTunnelDriver struct {
 	StopTunnelCalled   bool
 	StartTunnelCalled  bool
+	StartTunnelCount   int
 	StartTunnelTimeout int
+	StartTunnelErrs    []error
 }
 
 func (m *MockTunnelDriver) StopTunnel() {
 	m.StopTunnelCalled = true
 }
 
-func (m *MockTunnelDriver) StartTunnel(_ context.Context, _ string, timeout int) error {
+func (m *MockTunnelDriver) StartTunnel(_ context.Context, timeout int) error {
 	m.StartTunnelCalled = true
 	m.StartTunnelTimeout = timeout
+	m.StartTunnelCount++
+	if len(m.StartTunnelErrs) > 0 {
+		err := m.StartTunnelErrs[0]
+		m.StartTunnelErrs = m.StartTunnelErrs[1:]
+		return err
+	}
 	return nil
 }
 
 func getTestStepStartTunnel() *StepStartTunnel {
 	return &StepStartTunnel{
 		IAPConf: &IAPConfig{
-			IAP:              true,
-			IAPLocalhostPort: 0,
-			IAPHashBang:      "/bin/bash",
-			IAPExt:           "",
+			IAP:                 true,
+			IAPLocalhostPort:    0,
+			IAPTunnelLaunchWait: 30,
 		},
 		CommConf: &communicator.Config{
+			Type: "ssh",
 			SSH: communicator.SSH{
 				SSHPort: 1234,
 			},
 		},
-		AccountFile: "/path/to/account_file.json",
-		ProjectId:   "fake-project-123",
+		ProjectId: "fake-project-123",
 	}
 }
 
-func TestStepStartTunnel_CreateTempScript(t *testing.T) {
+func TestStepStartTunnel_Run(t *testing.T) {
 	s := getTestStepStartTunnel()
+	td := &MockTunnelDriver{
+		StartTunnelErrs: []error{
+			RetryableTunnelError{"IAP tunnel error 4047: instance is not running"},
+		},
+	}
+	s.tunnelDriver = td
 
-	args := []string{"compute", "start-iap-tunnel", "fakeinstance-12345",
-		"1234", "--local-host-port=localhost:8774", "--zone", "us-central-b",
-		"--project", "fake-project-123"}
+	state := testState(t)
+	state.Put("instance_name", "fakeinstance-12345")
 
-	scriptPath, err := s.createTempGcloudScript(args)
-	if err != nil {
-		t.Fatalf("Shouldn't have error building script file.")
+	if action := s.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v, error: %s", action, state.Get("error"))
+	}
+	if td.StartTunnelCount != 2 {
+		t.Fatalf("Should have retried starting the tunnel once, started it %d times", td.StartTunnelCount)
+	}
+	if td.StartTunnelTimeout != 30 {
+		t.Fatalf("Should have started the tunnel with the configured launch wait, got %d", td.StartTunnelTimeout)
+	}
+	if s.IAPConf.IAPLocalhostPort == 0 {
+		t.Fatalf("Should have picked a local port for the tunnel.")
+	}
+	if s.CommConf.SSHPort != s.IAPConf.IAPLocalhostPort {
+		t.Fatalf("Should have pointed the communicator at the tunnel, got port %d", s.CommConf.SSHPort)
 	}
-	defer os.Remove(scriptPath)
+}
 
-	f, err := os.ReadFile(scriptPath)
-	if err != nil {
-		t.Fatalf("couldn't read created inventoryfile: %s", err)
-	}
-
-	expected := `#!/bin/bash
-CLOUDSDK_AUTH_CREDENTIAL_FILE_OVERRIDE='/path/to/account_file.json'
-export CLOUDSDK_AUTH_CREDENTIAL_FILE_OVERRIDE
-gcloud compute start-iap-tunnel fakeinstance-12345 1234 --local-host-port=localhost:8774 --zone us-central-b --project fake-project-123
-`
-	if runtime.GOOS == "windows" {
-		// in real life you'd not be passing a HashBang here, but GIGO.
-		expected = `#!/bin/bash
-set "CLOUDSDK_AUTH_CREDENTIAL_FILE_OVERRIDE=/path/to/account_file.json"
-call gcloud compute start-iap-tunnel fakeinstance-12345 1234 --local-host-port=localhost:8774 --zone us-central-b --project fake-project-123
-`
-	}
-	if string(f) != expected {
-		t.Fatalf("script didn't match expected:\n\n expected: \n%s\n; recieved: \n%s\n", expected, f)
+func TestStepStartTunnel_Run_fatalError(t *testing.T) {
+	s := getTestStepStartTunnel()
+	td := &MockTunnelDriver{
+		StartTunnelErrs: []error{
+			&common.IAPTunnelError{Code: 4033, Reason: "not authorized"},
+			errors.New("permission denied"),
+		},
+	}
+	s.tunnelDriver = td
+
+	state := testState(t)
+	state.Put("instance_name", "fakeinstance-12345")
+
+	if action := s.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if td.StartTunnelCount != 1 {
+		t.Fatalf("Should not have retried an error the tunnel driver didn't mark as retryable.")
+	}
+	if _, ok := state.GetOk("error"); !ok {
+		t.Fatalf("should have error")
 	}
 }
 
@@ -0,0 +1,92 @@
+// Copyright IBM Corp. 2013, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package googlecompute
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-googlecompute/lib/common"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// StepStopInstance represents a Packer build step that stops the instance, so
+// that a machine image can capture it in a consistent state.
+type StepStopInstance int
+
+// Run executes the Packer build step that stops the instance.
+func (s *StepStopInstance) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	ui := state.Get("ui").(packersdk.Ui)
+
+	ui.Say("Stopping instance...")
+	if err := stopInstance(ctx, state); err != nil {
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	ui.Message("Instance has been stopped!")
+	return multistep.ActionContinue
+}
+
+// Cleanup.
+func (s *StepStopInstance) Cleanup(state multistep.StateBag) {}
+
+// stopInstance stops the instance and, if shutdown_script_file is set, checks
+// that the shutdown script, which runs as the instance stops, succeeded.
+func stopInstance(ctx context.Context, state multistep.StateBag) error {
+	config := state.Get("config").(*Config)
+	driver := state.Get("driver").(common.Driver)
+	ui := state.Get("ui").(packersdk.Ui)
+
+	name := config.InstanceName
+
+	// The instance stops on purpose, stop watching for its preemption.
+	if stop, ok := state.GetOk("stop_preemption_watch"); ok {
+		stop.(func())()
+	}
+
+	stopCtx, cancel := context.WithTimeout(ctx, config.StateTimeout)
+	defer cancel()
+	errCh, err := driver.StopInstance(stopCtx, config.Zone, name)
+	if err == nil {
+		err = waitForOperation(stopCtx, errCh, "time out while waiting for instance to stop")
+	}
+	if err != nil {
+		return fmt.Errorf("Error stopping instance: %s", err)
+	}
+	state.Put("instance_stopped", true)
+
+	if config.ShutdownScriptFile == "" {
+		return nil
+	}
+	if err := checkShutdownScript(ctx, driver, config.Zone, name); err != nil {
+		return fmt.Errorf("Error running the shutdown script: %s", err)
+	}
+	ui.Message("Shutdown script successfully finished.")
+	return nil
+}
+
+// checkShutdownScript looks for the result the shutdown script wrapper logged
+// to the serial port of the stopped instance.
+func checkShutdownScript(ctx context.Context, driver common.Driver, zone, name string) error {
+	output, err := driver.GetSerialPortOutput(ctx, zone, name)
+	if err != nil {
+		return fmt.Errorf("failed to get the serial port output of instance %s: %s", name, err)
+	}
+
+	done := strings.LastIndex(output, ShutdownScriptDoneMarker)
+	failed := strings.LastIndex(output, ShutdownScriptErrorMarker)
+	switch {
+	case failed > done:
+		code, _, _ := strings.Cut(output[failed+len(ShutdownScriptErrorMarker):], "\n")
+		return fmt.Errorf("the shutdown script exited with exit code %s", strings.TrimSpace(code))
+	case done >= 0:
+		return nil
+	}
+	return errors.New("the shutdown script did not finish before the instance stopped")
+}
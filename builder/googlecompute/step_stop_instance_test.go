@@ -0,0 +1,112 @@
+// Copyright IBM Corp. 2013, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package googlecompute
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-googlecompute/lib/common"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+func TestStepStopInstance_impl(t *testing.T) {
+	var _ multistep.Step = new(StepStopInstance)
+}
+
+func TestStepStopInstance(t *testing.T) {
+	state := testState(t)
+	step := new(StepStopInstance)
+	defer step.Cleanup(state)
+
+	config := state.Get("config").(*Config)
+	driver := state.Get("driver").(*common.DriverMock)
+
+	// run the step
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	if driver.StopInstanceName != config.InstanceName {
+		t.Fatal("should've stopped instance")
+	}
+	if driver.StopInstanceZone != config.Zone {
+		t.Fatalf("bad zone: %#v", driver.StopInstanceZone)
+	}
+	if driver.DeleteInstanceName != "" {
+		t.Fatal("should not have deleted instance")
+	}
+}
+
+func TestStepStopInstance_error(t *testing.T) {
+	state := testState(t)
+	step := new(StepStopInstance)
+	defer step.Cleanup(state)
+
+	driver := state.Get("driver").(*common.DriverMock)
+	driver.StopInstanceErr = errors.New("error")
+
+	// run the step
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	if _, ok := state.GetOk("error"); !ok {
+		t.Fatal("should have error")
+	}
+}
+
+func TestCheckShutdownScript(t *testing.T) {
+	cases := []struct {
+		Output string
+		Err    string
+	}{
+		{
+			Output: "shutdown-script: Packer shutdown script starting.\nshutdown-script: " + ShutdownScriptDoneMarker + "\n",
+		},
+		{
+			Output: "shutdown-script: " + ShutdownScriptErrorMarker + "3\r\n",
+			Err:    "the shutdown script exited with exit code 3",
+		},
+		{
+			// The script of a previous shutdown succeeded.
+			Output: ShutdownScriptDoneMarker + "\n" + ShutdownScriptErrorMarker + "1\n",
+			Err:    "the shutdown script exited with exit code 1",
+		},
+		{
+			Output: "shutdown-script: Packer shutdown script starting.\n",
+			Err:    "the shutdown script did not finish before the instance stopped",
+		},
+	}
+
+	for _, tc := range cases {
+		driver := &common.DriverMock{GetSerialPortOutputResult: tc.Output}
+		err := checkShutdownScript(context.Background(), driver, "us-central1-a", "packer-instance")
+		if tc.Err == "" && err != nil {
+			t.Fatalf("unexpected error for %q: %s", tc.Output, err)
+		}
+		if tc.Err != "" && (err == nil || err.Error() != tc.Err) {
+			t.Fatalf("bad error for %q: %v", tc.Output, err)
+		}
+	}
+}
+
+func TestStepStopInstance_shutdownScriptError(t *testing.T) {
+	state := testState(t)
+	step := new(StepStopInstance)
+	defer step.Cleanup(state)
+
+	config := state.Get("config").(*Config)
+	driver := state.Get("driver").(*common.DriverMock)
+	config.ShutdownScriptFile = "shutdown.sh"
+	driver.GetSerialPortOutputResult = ShutdownScriptErrorMarker + "1\n"
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); !ok {
+		t.Fatal("should have error")
+	}
+}
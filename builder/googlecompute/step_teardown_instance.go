@@ -5,9 +5,7 @@ package googlecompute
 
 import (
 	"context"
-	"errors"
 	"fmt"
-	"time"
 
 	"github.com/hashicorp/packer-plugin-googlecompute/lib/common"
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
@@ -31,16 +29,37 @@ func (s *StepTeardownInstance) Run(ctx context.Context, state multistep.StateBag
 		return multistep.ActionHalt
 	}
 
+	// The instance is going away on purpose, stop watching for its preemption.
+	if stop, ok := state.GetOk("stop_preemption_watch"); ok {
+		stop.(func())()
+	}
+
+	// The shutdown script runs as the instance stops, which must be done
+	// before deleting it to wait for the script.
+	if _, stopped := state.GetOk("instance_stopped"); config.ShutdownScriptFile != "" && !stopped {
+		ui.Say("Stopping instance to run the shutdown script...")
+		if err := stopInstance(ctx, state); err != nil {
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
 	ui.Say("Deleting instance...")
-	instanceLog, _ := driver.GetSerialPortOutput(config.Zone, name)
+	var instanceLog string
+	if follower, ok := state.GetOk("serial_port_follower"); ok {
+		// The output cannot be read anymore once the instance is deleted.
+		follower.(*serialPortFollower).close()
+		instanceLog = follower.(*serialPortFollower).fullOutput()
+	} else {
+		instanceLog, _ = driver.GetSerialPortOutput(ctx, config.Zone, name)
+	}
 	state.Put("instance_log", instanceLog)
-	errCh, err := driver.DeleteInstance(config.Zone, name)
+	deleteCtx, cancel := context.WithTimeout(ctx, config.StateTimeout)
+	defer cancel()
+	errCh, err := driver.DeleteInstance(deleteCtx, config.Zone, name)
 	if err == nil {
-		select {
-		case err = <-errCh:
-		case <-time.After(config.StateTimeout):
-			err = errors.New("time out while waiting for instance to delete")
-		}
+		err = waitForOperation(deleteCtx, errCh, "time out while waiting for instance to delete")
 	}
 
 	if err != nil {
@@ -63,15 +82,12 @@ func (s *StepTeardownInstance) Cleanup(state multistep.StateBag) {
 	driver := state.Get("driver").(common.Driver)
 	ui := state.Get("ui").(packersdk.Ui)
 
-	var err error
-
+	// The disk is deleted even when the build was cancelled.
 	ui.Say("Deleting disk...")
-	errCh := driver.DeleteDisk(config.Zone, config.DiskName)
-	select {
-	case err = <-errCh:
-	case <-time.After(config.StateTimeout):
-		err = errors.New("time out while waiting for disk to delete")
-	}
+	ctx, cancel := context.WithTimeout(context.Background(), config.operationTimeout(config.DiskDeleteTimeout))
+	defer cancel()
+	err := waitForOperation(ctx, driver.DeleteDisk(ctx, config.Zone, config.DiskName),
+		"time out while waiting for disk to delete")
 
 	if err != nil {
 		ui.Error(fmt.Sprintf(
@@ -6,6 +6,7 @@ package googlecompute
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/packer-plugin-googlecompute/lib/common"
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
@@ -45,3 +46,73 @@ func TestStepTeardownInstance(t *testing.T) {
 		t.Fatalf("bad zone: %#v", driver.DeleteDiskZone)
 	}
 }
+
+func TestStepTeardownInstance_withSerialPortFollower(t *testing.T) {
+	state := testState(t)
+	step := new(StepTeardownInstance)
+	defer step.Cleanup(state)
+
+	config := state.Get("config").(*Config)
+	driver := state.Get("driver").(*common.DriverMock)
+
+	config.InstanceName = "packer-instance"
+	state.Put("instance_name", config.InstanceName)
+	follow := &StepFollowSerialPort{Interval: time.Hour}
+	if action := follow.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	defer follow.Cleanup(state)
+	driver.GetSerialPortOutputFromContents = "startup script done\n"
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	if log := state.Get("instance_log").(string); log != "startup script done\n" {
+		t.Fatalf("bad instance log: %q", log)
+	}
+}
+
+func TestStepTeardownInstance_shutdownScript(t *testing.T) {
+	state := testState(t)
+	step := new(StepTeardownInstance)
+	defer step.Cleanup(state)
+
+	config := state.Get("config").(*Config)
+	driver := state.Get("driver").(*common.DriverMock)
+	config.ShutdownScriptFile = "shutdown.sh"
+	driver.GetSerialPortOutputResult = ShutdownScriptDoneMarker + "\n"
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	if driver.StopInstanceName != config.InstanceName {
+		t.Fatal("should've stopped instance to run the shutdown script")
+	}
+	if driver.DeleteInstanceName != config.InstanceName {
+		t.Fatal("should've deleted instance")
+	}
+}
+
+func TestStepTeardownInstance_shutdownScriptError(t *testing.T) {
+	state := testState(t)
+	step := new(StepTeardownInstance)
+	defer step.Cleanup(state)
+
+	config := state.Get("config").(*Config)
+	driver := state.Get("driver").(*common.DriverMock)
+	config.ShutdownScriptFile = "shutdown.sh"
+	driver.GetSerialPortOutputResult = "shutdown-script: Packer shutdown script starting.\n"
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	if driver.DeleteInstanceName != "" {
+		t.Fatal("should not have deleted instance")
+	}
+	if _, ok := state.GetOk("error"); !ok {
+		t.Fatal("should have error")
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright IBM Corp. 2013, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package googlecompute
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+	"gopkg.in/yaml.v3"
+)
+
+// UserDataKey is the metadata key cloud-init reads the user data from.
+const UserDataKey string = "user-data"
+
+// cloudConfigHeader starts the user data cloud-init reads as YAML.
+const cloudConfigHeader = "#cloud-config"
+
+// The exit codes of `cloud-init status --wait`.
+const (
+	cloudInitStatusDone        = 0
+	cloudInitStatusRecoverable = 2
+	cloudInitStatusNotFound    = 127
+)
+
+// prepareUserData reads the user data of user_data_file, renders its
+// template, and checks that a cloud-config is valid YAML.
+func (c *Config) prepareUserData() []error {
+	if c.UserData != "" && c.UserDataFile != "" {
+		return []error{errors.New("only one of user_data or user_data_file can be specified")}
+	}
+	if _, ok := c.Metadata[UserDataKey]; ok && (c.UserData != "" || c.UserDataFile != "") {
+		return []error{fmt.Errorf("the %s metadata cannot be set along with user_data or user_data_file", UserDataKey)}
+	}
+	if _, ok := c.MetadataFiles[UserDataKey]; ok && (c.UserData != "" || c.UserDataFile != "") {
+		return []error{fmt.Errorf("the %s metadata_files cannot be set along with user_data or user_data_file", UserDataKey)}
+	}
+
+	c.userData = c.UserData
+	if c.UserDataFile != "" {
+		content, err := os.ReadFile(c.UserDataFile)
+		if err != nil {
+			return []error{fmt.Errorf("user_data_file: %s", err)}
+		}
+		c.userData, err = interpolate.Render(string(content), &c.ctx)
+		if err != nil {
+			return []error{fmt.Errorf("user_data_file: error rendering template: %s", err)}
+		}
+	}
+
+	if err := validateCloudConfig(c.userData); err != nil {
+		return []error{err}
+	}
+	return nil
+}
+
+// validateCloudConfig checks that user data with the cloud-config header is a
+// YAML mapping. Other kinds of user data, like shell scripts or MIME
+// multi-part archives, are left to cloud-init.
+func validateCloudConfig(userData string) error {
+	if !strings.HasPrefix(userData, cloudConfigHeader) {
+		return nil
+	}
+
+	var cloudConfig map[string]interface{}
+	if err := yaml.Unmarshal([]byte(userData), &cloudConfig); err != nil {
+		return fmt.Errorf("user data is not a valid cloud-config: %s", err)
+	}
+	return nil
+}
+
+// StepWaitCloudInit represents a Packer build step that waits for cloud-init
+// to finish applying the user data, before the provisioners run.
+type StepWaitCloudInit int
+
+// Run runs `cloud-init status --wait` through the communicator, which returns
+// once cloud-init is done.
+func (s *StepWaitCloudInit) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	config := state.Get("config").(*Config)
+	ui := state.Get("ui").(packersdk.Ui)
+
+	if config.userData == "" {
+		return multistep.ActionContinue
+	}
+
+	raw, ok := state.GetOk("communicator")
+	if !ok {
+		ui.Say("No communicator to the instance, not waiting for cloud-init.")
+		return multistep.ActionContinue
+	}
+	comm := raw.(packersdk.Communicator)
+
+	ui.Say("Waiting for cloud-init to finish...")
+	cmd := &packersdk.RemoteCmd{Command: "cloud-init status --wait"}
+	if err := cmd.RunWithUi(ctx, comm, ui); err != nil {
+		err := fmt.Errorf("Error waiting for cloud-init to finish: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	switch cmd.ExitStatus() {
+	case cloudInitStatusDone:
+		ui.Message("cloud-init finished.")
+	case cloudInitStatusRecoverable:
+		ui.Message("cloud-init finished with recoverable errors, see /var/log/cloud-init.log on the instance.")
+	case cloudInitStatusNotFound:
+		ui.Message("cloud-init is not installed on the instance, the user data is ignored.")
+	default:
+		err := fmt.Errorf("Error waiting for cloud-init to finish: cloud-init failed with exit status %d", cmd.ExitStatus())
+		state.Put("error", err)
+		ui.Error(err.Error())
+		showLastLines(state)
+		return multistep.ActionHalt
+	}
+
+	return multistep.ActionContinue
+}
+
+// Cleanup.
+func (s *StepWaitCloudInit) Cleanup(state multistep.StateBag) {}
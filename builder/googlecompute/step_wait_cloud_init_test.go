@@ -0,0 +1,63 @@
+// Copyright IBM Corp. 2013, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package googlecompute
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStepWaitCloudInit_impl(t *testing.T) {
+	var _ multistep.Step = new(StepWaitCloudInit)
+}
+
+func TestStepWaitCloudInit(t *testing.T) {
+	tt := []struct {
+		Name       string
+		UserData   string
+		ExitStatus int
+		StepResult multistep.StepAction
+		Called     bool
+	}{
+		{Name: "no user data"},
+		{Name: "done", UserData: "#cloud-config\n", Called: true},
+		{Name: "recoverable errors", UserData: "#cloud-config\n", ExitStatus: 2, Called: true},
+		{Name: "not installed", UserData: "#cloud-config\n", ExitStatus: 127, Called: true},
+		{Name: "failed", UserData: "#cloud-config\n", ExitStatus: 1, StepResult: multistep.ActionHalt, Called: true},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			state := testState(t)
+			step := new(StepWaitCloudInit)
+			c := state.Get("config").(*Config)
+			comm := &packersdk.MockCommunicator{StartExitStatus: tc.ExitStatus}
+
+			c.userData = tc.UserData
+			state.Put("communicator", comm)
+
+			assert.Equal(t, tc.StepResult, step.Run(context.Background(), state))
+			assert.Equal(t, tc.Called, comm.StartCalled)
+			if tc.Called {
+				assert.Equal(t, "cloud-init status --wait", comm.StartCmd.Command)
+			}
+			_, halted := state.GetOk("error")
+			assert.Equal(t, tc.StepResult == multistep.ActionHalt, halted)
+		})
+	}
+}
+
+func TestStepWaitCloudInit_noCommunicator(t *testing.T) {
+	state := testState(t)
+	step := new(StepWaitCloudInit)
+	c := state.Get("config").(*Config)
+
+	c.userData = "#cloud-config\n"
+
+	assert.Equal(t, multistep.ActionContinue, step.Run(context.Background(), state))
+}
@@ -7,6 +7,9 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/hashicorp/packer-plugin-googlecompute/lib/common"
@@ -16,9 +19,57 @@ import (
 )
 
 // ErrStartupScriptMetadata means that the user provided startup script resulted in
-// setting the set-startup-script metadata status to error.
+// setting the set-startup-script metadata status to error. A *StartupScriptError
+// matches it with errors.Is.
 var ErrStartupScriptMetadata = errors.New("Startup script exited with error.")
 
+// StartupScriptError means that the user provided startup script reported an
+// error. The details of the result are only known when the startup script
+// wrapper could publish them in guest attributes.
+type StartupScriptError struct {
+	// The exit code of the script, or -1 if unknown.
+	ExitCode int
+	// How long the script ran, or 0 if unknown.
+	Duration time.Duration
+	// The last lines of the output of the script.
+	LogTail []string
+}
+
+func (e *StartupScriptError) Error() string {
+	msg := "Startup script exited with error"
+	if e.ExitCode >= 0 {
+		msg += fmt.Sprintf(" code %d", e.ExitCode)
+	}
+	if e.Duration > 0 {
+		msg += fmt.Sprintf(" after %s", e.Duration)
+	}
+	if len(e.LogTail) > 0 {
+		msg += fmt.Sprintf(", last %d lines of its output:\n%s", len(e.LogTail), strings.Join(e.LogTail, "\n"))
+	}
+	return msg
+}
+
+// Is reports whether target is ErrStartupScriptMetadata.
+func (e *StartupScriptError) Is(target error) bool {
+	return target == ErrStartupScriptMetadata
+}
+
+// newStartupScriptError returns the error of the startup script from the
+// result the wrapper published in the guest attributes.
+func newStartupScriptError(attributes map[string]string) *StartupScriptError {
+	e := &StartupScriptError{ExitCode: -1}
+	if code, err := strconv.Atoi(attributes[StartupScriptExitCodeKey]); err == nil {
+		e.ExitCode = code
+	}
+	if seconds, err := strconv.Atoi(attributes[StartupScriptDurationKey]); err == nil {
+		e.Duration = time.Duration(seconds) * time.Second
+	}
+	if tail := strings.TrimRight(attributes[StartupScriptLogTailKey], "\n"); tail != "" {
+		e.LogTail = strings.Split(tail, "\n")
+	}
+	return e
+}
+
 // StepWaitStartupScript is a trivial implementation of a Packer multistep
 // It can be used for tracking the set-startup-script metadata status.
 type StepWaitStartupScript int
@@ -35,30 +86,56 @@ func (s *StepWaitStartupScript) Run(ctx context.Context, state multistep.StateBa
 		return multistep.ActionContinue
 	}
 
-	ui.Say("Waiting for any running startup script to finish...")
+	waitCtx := ctx
+	if config.StartupScriptTimeout > 0 {
+		ui.Say(fmt.Sprintf("Waiting up to %s for any running startup script to finish...", config.StartupScriptTimeout))
+		var cancel context.CancelFunc
+		waitCtx, cancel = context.WithTimeout(ctx, config.StartupScriptTimeout)
+		defer cancel()
+	} else {
+		ui.Say("Waiting for any running startup script to finish...")
+	}
+	// Show the serial port output, if streamed, while waiting.
+	state.Put("waiting_startup_script", true)
+	defer state.Remove("waiting_startup_script")
+
+	// The retries sleep without watching the context, so the delay must not
+	// go past the timeout.
+	backoff := &retry.Backoff{InitialBackoff: 10 * time.Second, MaxBackoff: 60 * time.Second, Multiplier: 2}
+	retryDelay := func() time.Duration {
+		delay := backoff.Linear()
+		if deadline, ok := waitCtx.Deadline(); ok {
+			delay = max(min(delay, time.Until(deadline)), 0)
+		}
+		return delay
+	}
+
 	// Keep checking the serial port output to see if the startup script is done.
 	err := retry.Config{
 		ShouldRetry: func(err error) bool {
-			if errors.Is(err, ErrStartupScriptMetadata) {
+			var scriptErr *StartupScriptError
+			if errors.As(err, &scriptErr) || errors.Is(err, context.DeadlineExceeded) {
 				return false
 			}
 			return true
 		},
-		RetryDelay: (&retry.Backoff{InitialBackoff: 10 * time.Second, MaxBackoff: 60 * time.Second, Multiplier: 2}).Linear,
-	}.Run(ctx, func(ctx context.Context) error {
-		status, err := driver.GetInstanceMetadata(config.Zone,
-			instanceName, StartupScriptStatusKey)
+		RetryDelay: retryDelay,
+	}.Run(waitCtx, func(ctx context.Context) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		status, attributes, err := getStartupScriptStatus(ctx, driver, config.Zone, instanceName)
 
 		if err != nil {
 			ui.Message(fmt.Sprintf("Metadata %s on instance %s not available. Waiting...", StartupScriptStatusKey, instanceName))
-			err := fmt.Errorf("Error getting startup script status: %s", err)
+			err := fmt.Errorf("Error getting startup script status: %w", err)
 			return err
 		}
 
 		switch status {
 		case StartupScriptStatusError:
 			ui.Message("Startup script in error. Exiting...")
-			return ErrStartupScriptMetadata
+			return newStartupScriptError(attributes)
 
 		case StartupScriptStatusDone:
 			ui.Message("Startup script successfully finished.")
@@ -70,15 +147,57 @@ func (s *StepWaitStartupScript) Run(ctx context.Context, state multistep.StateBa
 		}
 	})
 
-	if err != nil {
-		err := fmt.Errorf("Error waiting for startup script to finish: %s", err)
+	var scriptErr *StartupScriptError
+	switch {
+	case errors.As(err, &scriptErr):
+		err := fmt.Errorf("Error waiting for startup script to finish: %w", scriptErr)
+		ui.Error(err.Error())
+		// The output of the script is in the serial port output if the
+		// wrapper could not publish it.
+		if len(scriptErr.LogTail) == 0 {
+			showLastLines(state)
+		}
+		if config.OnStartupScriptFailure == StartupScriptFailureContinue {
+			ui.Say(fmt.Sprintf("Continuing the build, as on_startup_script_failure is %q.", StartupScriptFailureContinue))
+			return multistep.ActionContinue
+		}
+		state.Put("error", err)
+		return multistep.ActionHalt
+	case err != nil && waitCtx.Err() != nil && ctx.Err() == nil:
+		// The last error of the retries is returned, which may not be the
+		// one of the timeout.
+		err := fmt.Errorf("Error waiting for startup script to finish: it did not finish within "+
+			"startup_script_timeout (%s)", config.StartupScriptTimeout)
 		state.Put("error", err)
 		ui.Error(err.Error())
+		showLastLines(state)
+		return multistep.ActionHalt
+	case err != nil:
+		err := fmt.Errorf("Error waiting for startup script to finish: %w", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		showLastLines(state)
 		return multistep.ActionHalt
 	}
 	ui.Say("Startup script, if any, has finished running.")
 	return multistep.ActionContinue
 }
 
+// getStartupScriptStatus gets the status the startup script wrapper reported
+// in the guest attributes of the instance, or in its metadata if the wrapper
+// could not set guest attributes. It also returns the guest attributes, with
+// the result of the script if published.
+func getStartupScriptStatus(ctx context.Context, driver common.Driver, zone, name string) (string, map[string]string, error) {
+	attributes, err := driver.GetGuestAttributes(ctx, zone, name, StartupScriptGuestAttributesNamespace)
+	if err != nil {
+		log.Printf("[DEBUG] Failed to get guest attributes of instance %s, checking metadata instead: %s", name, err)
+	} else if status, ok := attributes[StartupScriptGuestAttributesKey]; ok {
+		return status, attributes, nil
+	}
+
+	status, err := driver.GetInstanceMetadata(ctx, zone, name, StartupScriptStatusKey)
+	return status, attributes, err
+}
+
 // Cleanup.
 func (s *StepWaitStartupScript) Cleanup(state multistep.StateBag) {}
@@ -4,11 +4,15 @@
 package googlecompute
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/packer-plugin-googlecompute/lib/common"
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 	"github.com/hashicorp/packer-plugin-sdk/template/config"
 	"github.com/stretchr/testify/assert"
 )
@@ -80,3 +84,169 @@ func TestStepWaitStartupScript_withWrapStartupScript(t *testing.T) {
 		})
 	}
 }
+
+func TestStepWaitStartupScript_guestAttributes(t *testing.T) {
+	tt := []struct {
+		Name       string
+		Attributes map[string]string
+		StepResult multistep.StepAction
+	}{
+		{Name: "done", Attributes: map[string]string{StartupScriptGuestAttributesKey: StartupScriptStatusDone}},
+		{
+			Name:       "error",
+			Attributes: map[string]string{StartupScriptGuestAttributesKey: StartupScriptStatusError},
+			StepResult: multistep.ActionHalt,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			state := testState(t)
+			step := new(StepWaitStartupScript)
+			c := state.Get("config").(*Config)
+			d := state.Get("driver").(*common.DriverMock)
+
+			c.Zone = "test-zone"
+			state.Put("instance_name", "test-instance-name")
+
+			// The metadata keeps the status set at creation, the wrapper
+			// reported the status in guest attributes.
+			d.GetGuestAttributesResult = tc.Attributes
+			d.GetInstanceMetadataResult = StartupScriptStatusNotDone
+
+			assert.Equal(t, tc.StepResult, step.Run(context.Background(), state))
+
+			assert.Equal(t, "test-zone", d.GetGuestAttributesZone)
+			assert.Equal(t, "test-instance-name", d.GetGuestAttributesName)
+			assert.Equal(t, StartupScriptGuestAttributesNamespace, d.GetGuestAttributesNamespace)
+			assert.Empty(t, d.GetInstanceMetadataName, "Metadata should not be checked once the guest attribute is set.")
+		})
+	}
+}
+
+func TestStepWaitStartupScript_guestAttributesUnavailable(t *testing.T) {
+	state := testState(t)
+	step := new(StepWaitStartupScript)
+	c := state.Get("config").(*Config)
+	d := state.Get("driver").(*common.DriverMock)
+
+	c.Zone = "test-zone"
+	state.Put("instance_name", "test-instance-name")
+
+	d.GetGuestAttributesErr = errors.New("guest attributes disabled")
+	d.GetInstanceMetadataResult = StartupScriptStatusDone
+
+	assert.Equal(t, multistep.ActionContinue, step.Run(context.Background(), state),
+		"Step should fall back to the metadata and continue.")
+	assert.Equal(t, "test-instance-name", d.GetInstanceMetadataName)
+}
+
+func TestStepWaitStartupScript_errorShowsSerialPortTail(t *testing.T) {
+	state := testState(t)
+	step := new(StepWaitStartupScript)
+	d := state.Get("driver").(*common.DriverMock)
+	out := new(bytes.Buffer)
+	ui := &packersdk.BasicUi{Reader: new(bytes.Buffer), Writer: new(bytes.Buffer), ErrorWriter: out}
+
+	state.Put("ui", ui)
+	state.Put("instance_name", "test-instance-name")
+	state.Put("serial_port_follower", &serialPortFollower{
+		driver:    d,
+		name:      "test-instance-name",
+		ui:        ui,
+		tailLines: 1,
+	})
+
+	d.GetInstanceMetadataResult = StartupScriptStatusError
+	d.GetSerialPortOutputFromContents = "apt-get install foo\nE: Unable to locate package foo\n"
+
+	assert.Equal(t, multistep.ActionHalt, step.Run(context.Background(), state))
+	assert.Contains(t, out.String(), "Last 1 lines of the serial port output:\nE: Unable to locate package foo")
+	assert.NotContains(t, out.String(), "apt-get install foo")
+}
+
+func TestStepWaitStartupScript_result(t *testing.T) {
+	tt := []struct {
+		Name       string
+		OnFailure  string
+		StepResult multistep.StepAction
+	}{
+		{Name: "abort", OnFailure: StartupScriptFailureAbort, StepResult: multistep.ActionHalt},
+		{Name: "continue", OnFailure: StartupScriptFailureContinue, StepResult: multistep.ActionContinue},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			state := testState(t)
+			step := new(StepWaitStartupScript)
+			c := state.Get("config").(*Config)
+			d := state.Get("driver").(*common.DriverMock)
+
+			c.OnStartupScriptFailure = tc.OnFailure
+			state.Put("instance_name", "test-instance-name")
+
+			d.GetGuestAttributesResult = map[string]string{
+				StartupScriptGuestAttributesKey: StartupScriptStatusError,
+				StartupScriptExitCodeKey:        "100",
+				StartupScriptDurationKey:        "62",
+				StartupScriptLogTailKey:         "Reading package lists...\nE: Unable to locate package foo\n",
+			}
+
+			assert.Equal(t, tc.StepResult, step.Run(context.Background(), state))
+
+			err, halted := state.GetOk("error")
+			assert.Equal(t, tc.StepResult == multistep.ActionHalt, halted)
+			if !halted {
+				return
+			}
+			var scriptErr *StartupScriptError
+			assert.ErrorAs(t, err.(error), &scriptErr)
+			assert.Equal(t, &StartupScriptError{
+				ExitCode: 100,
+				Duration: 62 * time.Second,
+				LogTail:  []string{"Reading package lists...", "E: Unable to locate package foo"},
+			}, scriptErr)
+			assert.Contains(t, err.(error).Error(), "exited with error code 100 after 1m2s")
+			assert.ErrorIs(t, err.(error), ErrStartupScriptMetadata)
+		})
+	}
+}
+
+func TestStepWaitStartupScript_timeout(t *testing.T) {
+	state := testState(t)
+	step := new(StepWaitStartupScript)
+	c := state.Get("config").(*Config)
+	d := state.Get("driver").(*common.DriverMock)
+
+	c.StartupScriptTimeout = time.Nanosecond
+	state.Put("instance_name", "test-instance-name")
+	d.GetInstanceMetadataResult = StartupScriptStatusNotDone
+
+	assert.Equal(t, multistep.ActionHalt, step.Run(context.Background(), state))
+	err := state.Get("error").(error)
+	assert.Contains(t, err.Error(), "did not finish within startup_script_timeout (1ns)")
+}
+
+func TestStepWaitStartupScript_timeoutWhileStatusUnavailable(t *testing.T) {
+	state := testState(t)
+	step := new(StepWaitStartupScript)
+	c := state.Get("config").(*Config)
+	d := state.Get("driver").(*common.DriverMock)
+
+	c.StartupScriptTimeout = 100 * time.Millisecond
+	state.Put("instance_name", "test-instance-name")
+	d.GetGuestAttributesErr = errors.New("guest attributes disabled")
+	d.GetInstanceMetadataErr = errors.New("metadata unavailable")
+
+	start := time.Now()
+	assert.Equal(t, multistep.ActionHalt, step.Run(context.Background(), state))
+	assert.Less(t, time.Since(start), 5*time.Second, "the retries should not sleep past the timeout")
+	err := state.Get("error").(error)
+	assert.Contains(t, err.Error(), "did not finish within startup_script_timeout (100ms)")
+}
+
+func TestStartupScriptError(t *testing.T) {
+	err := newStartupScriptError(map[string]string{StartupScriptGuestAttributesKey: StartupScriptStatusError})
+	assert.Equal(t, &StartupScriptError{ExitCode: -1}, err, "The result should be unknown without guest attributes.")
+	assert.Equal(t, "Startup script exited with error", err.Error())
+}
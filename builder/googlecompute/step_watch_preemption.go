@@ -0,0 +1,98 @@
+// Copyright IBM Corp. 2013, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package googlecompute
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-googlecompute/lib/common"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// StepWatchPreemption represents a Packer build step that watches a
+// preemptible or Spot instance in the background, and cancels the build as
+// soon as Compute Engine preempts it, rather than letting the communicator or
+// the provisioners time out.
+type StepWatchPreemption struct {
+	// Cancel cancels the build.
+	Cancel context.CancelFunc
+	// Interval between two checks of the instance. Defaults to 15 seconds.
+	Interval time.Duration
+
+	stop context.CancelFunc
+	done chan struct{}
+}
+
+// Run starts watching the instance for preemption.
+func (s *StepWatchPreemption) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	config := state.Get("config").(*Config)
+	driver := state.Get("driver").(common.Driver)
+	ui := state.Get("ui").(packersdk.Ui)
+
+	if !config.isPreemptible() {
+		return multistep.ActionContinue
+	}
+
+	name := state.Get("instance_name").(string)
+	zone := config.Zone
+	interval := s.Interval
+	if interval == 0 {
+		interval = 15 * time.Second
+	}
+
+	watchCtx, stop := context.WithCancel(ctx)
+	s.stop = stop
+	s.done = make(chan struct{})
+	state.Put("stop_preemption_watch", s.stopWatching)
+
+	go func() {
+		defer close(s.done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-watchCtx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			preempted, err := driver.InstancePreempted(watchCtx, zone, name)
+			if watchCtx.Err() != nil {
+				return
+			}
+			if err != nil {
+				log.Printf("[WARN] Failed to check whether instance %s was preempted: %s", name, err)
+				continue
+			}
+			if preempted {
+				ui.Error(fmt.Sprintf("Instance %s was preempted by Compute Engine.", name))
+				state.Put("instance_preempted", true)
+				s.Cancel()
+				return
+			}
+		}
+	}()
+
+	return multistep.ActionContinue
+}
+
+// stopWatching stops watching the instance, and waits for the watch to end.
+func (s *StepWatchPreemption) stopWatching() {
+	if s.stop == nil {
+		return
+	}
+	s.stop()
+	<-s.done
+	s.stop = nil
+}
+
+// Cleanup stops watching the instance.
+func (s *StepWatchPreemption) Cleanup(state multistep.StateBag) {
+	s.stopWatching()
+}
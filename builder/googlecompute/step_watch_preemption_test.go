@@ -0,0 +1,85 @@
+// Copyright IBM Corp. 2013, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package googlecompute
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-googlecompute/lib/common"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStepWatchPreemption_impl(t *testing.T) {
+	var _ multistep.Step = new(StepWatchPreemption)
+}
+
+func TestStepWatchPreemption_preempted(t *testing.T) {
+	state := testState(t)
+	state.Put("instance_name", "test-instance")
+
+	c := state.Get("config").(*Config)
+	c.ProvisioningModel = common.ProvisioningModelSpot
+
+	d := state.Get("driver").(*common.DriverMock)
+	d.InstancePreemptedResult = true
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	step := &StepWatchPreemption{Cancel: cancel, Interval: time.Millisecond}
+	defer step.Cleanup(state)
+
+	assert.Equal(t, multistep.ActionContinue, step.Run(ctx, state), "Step should have passed and continued.")
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("build should have been cancelled once the instance was preempted")
+	}
+	_, ok := state.GetOk("instance_preempted")
+	assert.True(t, ok, "State should record the preemption.")
+	assert.Equal(t, "test-instance", d.InstancePreemptedName)
+	assert.Equal(t, c.Zone, d.InstancePreemptedZone)
+}
+
+func TestStepWatchPreemption_stopped(t *testing.T) {
+	state := testState(t)
+	state.Put("instance_name", "test-instance")
+
+	c := state.Get("config").(*Config)
+	c.Preemptible = true
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	step := &StepWatchPreemption{Cancel: cancel, Interval: time.Millisecond}
+
+	assert.Equal(t, multistep.ActionContinue, step.Run(ctx, state), "Step should have passed and continued.")
+
+	// The teardown stops the watch before deleting the instance.
+	stop, ok := state.GetOk("stop_preemption_watch")
+	assert.True(t, ok, "State should allow to stop the watch.")
+	stop.(func())()
+
+	d := state.Get("driver").(*common.DriverMock)
+	d.InstancePreemptedResult = true
+	time.Sleep(10 * time.Millisecond)
+
+	assert.NoError(t, ctx.Err(), "Build should not be cancelled once the watch stopped.")
+	step.Cleanup(state)
+}
+
+func TestStepWatchPreemption_notPreemptible(t *testing.T) {
+	state := testState(t)
+	state.Put("instance_name", "test-instance")
+
+	step := &StepWatchPreemption{Interval: time.Millisecond}
+	defer step.Cleanup(state)
+
+	assert.Equal(t, multistep.ActionContinue, step.Run(context.Background(), state), "Step should have passed and continued.")
+
+	_, ok := state.GetOk("stop_preemption_watch")
+	assert.False(t, ok, "Standard instances should not be watched.")
+}
@@ -1,55 +1,52 @@
 // Copyright IBM Corp. 2013, 2025
 // SPDX-License-Identifier: MPL-2.0
 
-//go:build !windows
-// +build !windows
-
 package googlecompute
 
 import (
 	"context"
+	"errors"
 	"log"
-	"os/exec"
-	"syscall"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-googlecompute/lib/common"
 )
 
-func NewTunnelDriver() TunnelDriver {
-	return &TunnelDriverLinux{}
+type TunnelDriver interface {
+	// StartTunnel launches the tunnel, waiting up to timeout seconds for it
+	// to be able to reach the instance.
+	StartTunnel(ctx context.Context, timeout int) error
+	StopTunnel()
 }
 
-type TunnelDriverLinux struct {
-	cmd *exec.Cmd
+func NewTunnelDriver(tunnel *common.IAPTunnel) TunnelDriver {
+	return &TunnelDriverIAP{tunnel: tunnel}
 }
 
-func (t *TunnelDriverLinux) StartTunnel(cancelCtx context.Context, tempScriptFileName string, timeout int) error {
-	cmd := exec.CommandContext(cancelCtx, tempScriptFileName)
-	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+// TunnelDriverIAP runs an IAP TCP forwarding tunnel in-process.
+type TunnelDriverIAP struct {
+	tunnel *common.IAPTunnel
+}
 
-	err := RunTunnelCommand(cmd, timeout)
-	if err != nil {
-		return err
+func (t *TunnelDriverIAP) StartTunnel(cancelCtx context.Context, timeout int) error {
+	err := t.tunnel.Start(cancelCtx, time.Duration(timeout)*time.Second)
+	if err == nil {
+		return nil
 	}
 
-	// Store successful command on step so we can access it to cancel it
-	// later.
-	t.cmd = cmd
-	return nil
+	// While the instance boots IAP may refuse the connection, or not get
+	// an answer from the instance in time; both are worth retrying.
+	var tunnelErr *common.IAPTunnelError
+	if errors.As(err, &tunnelErr) && tunnelErr.Retryable() {
+		return RetryableTunnelError{err.Error()}
+	}
+	if errors.Is(err, context.DeadlineExceeded) && cancelCtx.Err() == nil {
+		return RetryableTunnelError{err.Error()}
+	}
+	return err
 }
 
-func (t *TunnelDriverLinux) StopTunnel() {
-	if t.cmd != nil && t.cmd.Process != nil {
-		log.Printf("Cleaning up the IAP tunnel...")
-		// Why not just cmd.Process.Kill()?  I'm glad you asked. The gcloud
-		// call spawns a python subprocess that listens on the port, and you
-		// need to use the process _group_ id to halt this process and its
-		// daemon child. We create the group ID with the syscall.SysProcAttr
-		// call inside the retry loop above, and then store that ID on the
-		// command so we can halt it here.
-		err := syscall.Kill(-t.cmd.Process.Pid, syscall.SIGINT)
-		if err != nil {
-			log.Printf("Issue stopping IAP tunnel: %s", err)
-		}
-	} else {
-		log.Printf("Couldn't find IAP tunnel process to kill. Continuing.")
-	}
+func (t *TunnelDriverIAP) StopTunnel() {
+	log.Printf("Cleaning up the IAP tunnel...")
+	t.tunnel.Stop()
 }
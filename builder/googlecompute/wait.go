@@ -0,0 +1,36 @@
+// Copyright IBM Corp. 2013, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package googlecompute
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// waitForOperation waits for the result of an operation started by the
+// driver with ctx. Steps bound ctx with the timeout of the operation, once
+// its deadline passes timeoutMsg is returned; if the build is cancelled
+// instead, the error of the context is.
+func waitForOperation(ctx context.Context, errCh <-chan error, timeoutMsg string) error {
+	var err error
+	select {
+	case err = <-errCh:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return errors.New(timeoutMsg)
+	}
+	return err
+}
+
+// operationTimeout returns the timeout of a kind of operation, or
+// state_timeout if it is not set.
+func (c *Config) operationTimeout(timeout time.Duration) time.Duration {
+	if timeout > 0 {
+		return timeout
+	}
+	return c.StateTimeout
+}
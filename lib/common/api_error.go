@@ -0,0 +1,268 @@
+// Copyright IBM Corp. 2013, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+
+	compute "google.golang.org/api/compute/v1"
+	"google.golang.org/api/googleapi"
+)
+
+// The errors below are decoded from the errors of Compute Engine operations
+// and API responses, so that steps can tell them apart with errors.As. They
+// wrap the *OperationError or *googleapi.Error they were decoded from, and
+// render with a hint on how to fix them.
+
+// QuotaExceededError reports that a request exceeds a quota of the project.
+type QuotaExceededError struct {
+	// Metric is the quota metric, e.g. CPUS or compute.googleapis.com/cpus.
+	Metric string
+	// Region the quota applies to, empty for global quotas.
+	Region string
+	// Limit is the value of the quota, if known.
+	Limit float64
+	Err   error
+}
+
+func (e *QuotaExceededError) Error() string { return withHint(e.Err, e.Hint()) }
+func (e *QuotaExceededError) Unwrap() error { return e.Err }
+
+// Hint tells how to fix the error.
+func (e *QuotaExceededError) Hint() string {
+	quota := "the quota"
+	if e.Metric != "" {
+		quota = fmt.Sprintf("the %s quota", e.Metric)
+	}
+	if e.Region != "" {
+		quota += " in " + e.Region
+	}
+	return fmt.Sprintf("Request an increase of %s in the Cloud console, have the build use less "+
+		"resources, or set fallback_zones to zones of other regions.", quota)
+}
+
+// ResourceExhaustedError reports that a zone does not have the resources to
+// fulfill a request for now.
+type ResourceExhaustedError struct {
+	// Zone that is out of resources, if known.
+	Zone string
+	Err  error
+}
+
+func (e *ResourceExhaustedError) Error() string { return withHint(e.Err, e.Hint()) }
+func (e *ResourceExhaustedError) Unwrap() error { return e.Err }
+
+// Hint tells how to fix the error.
+func (e *ResourceExhaustedError) Hint() string {
+	return "Try again later, use another machine_type, or set fallback_zones to try other zones."
+}
+
+// PermissionDeniedError reports that the account Packer authenticates with
+// lacks a permission.
+type PermissionDeniedError struct {
+	// Permission that is missing, e.g. compute.instances.create, if known.
+	Permission string
+	// Resource the permission is required on, if known.
+	Resource string
+	Err      error
+}
+
+func (e *PermissionDeniedError) Error() string { return withHint(e.Err, e.Hint()) }
+func (e *PermissionDeniedError) Unwrap() error { return e.Err }
+
+// Hint tells how to fix the error.
+func (e *PermissionDeniedError) Hint() string {
+	if e.Permission == "" {
+		return "Check the roles granted to the account Packer authenticates with, and that the API is enabled in the project."
+	}
+	return fmt.Sprintf("Grant a role that includes the %s permission to the account Packer authenticates with.", e.Permission)
+}
+
+// NotFoundError reports that a resource does not exist.
+type NotFoundError struct {
+	// Resource that was not found, if known.
+	Resource string
+	Err      error
+}
+
+func (e *NotFoundError) Error() string { return withHint(e.Err, e.Hint()) }
+func (e *NotFoundError) Unwrap() error { return e.Err }
+
+// Hint tells how to fix the error.
+func (e *NotFoundError) Hint() string {
+	return "Check the name, the project and the zone or region of the resource, " +
+		"and that the account Packer authenticates with can see it."
+}
+
+// AlreadyExistsError reports that a resource to create already exists.
+type AlreadyExistsError struct {
+	// Resource that already exists, if known.
+	Resource string
+	Err      error
+}
+
+func (e *AlreadyExistsError) Error() string { return withHint(e.Err, e.Hint()) }
+func (e *AlreadyExistsError) Unwrap() error { return e.Err }
+
+// Hint tells how to fix the error.
+func (e *AlreadyExistsError) Hint() string {
+	return "Choose another name, or delete the existing resource. " +
+		"Images built before can be replaced with the -force flag."
+}
+
+// OrgPolicyViolationError reports that a request violates a constraint of
+// the organization policy.
+type OrgPolicyViolationError struct {
+	// Constraint that is violated, e.g. constraints/compute.vmExternalIpAccess.
+	Constraint string
+	Err        error
+}
+
+func (e *OrgPolicyViolationError) Error() string { return withHint(e.Err, e.Hint()) }
+func (e *OrgPolicyViolationError) Unwrap() error { return e.Err }
+
+// Hint tells how to fix the error.
+func (e *OrgPolicyViolationError) Hint() string {
+	return fmt.Sprintf("Change the configuration to comply with %s, "+
+		"or ask an administrator of the organization policy for an exception.", e.Constraint)
+}
+
+func withHint(err error, hint string) string {
+	return fmt.Sprintf("%s\nHint: %s", err, hint)
+}
+
+var (
+	quotaMessage       = regexp.MustCompile(`Quota '([^']+)' exceeded\.\s*Limit: ([0-9.]+)(?: in region ([a-z0-9-]+))?`)
+	permissionMessage  = regexp.MustCompile(`Required '([^']+)' permission for '([^']+)'`)
+	iamMessage         = regexp.MustCompile(`Permission '([^']+)' denied on resource '?([^' ]+)'?`)
+	resourceMessage    = regexp.MustCompile(`resource '([^']+)'`)
+	constraintMessage  = regexp.MustCompile(`[Cc]onstraint (constraints/[A-Za-z0-9_.]+) violated`)
+	zoneMessage        = regexp.MustCompile(`zones/([a-z0-9-]+)`)
+	quotaRegionMessage = regexp.MustCompile(`in region ([a-z0-9-]+)`)
+)
+
+// decodeOperationError returns the typed error matching an error reported
+// by an operation, or the error itself if there is none.
+func decodeOperationError(e *compute.OperationErrorErrors) error {
+	opErr := &OperationError{
+		Code:     e.Code,
+		Location: e.Location,
+		Message:  e.Message,
+	}
+
+	if m := constraintMessage.FindStringSubmatch(e.Message); m != nil {
+		return &OrgPolicyViolationError{Constraint: m[1], Err: opErr}
+	}
+
+	switch e.Code {
+	case "QUOTA_EXCEEDED":
+		quotaErr := quotaExceeded(e.Message, opErr)
+		for _, details := range e.ErrorDetails {
+			if info := details.QuotaInfo; info != nil {
+				quotaErr.Metric = info.MetricName
+				quotaErr.Limit = info.Limit
+				if region, ok := info.Dimensions["region"]; ok {
+					quotaErr.Region = region
+				}
+			}
+		}
+		return quotaErr
+	case "ZONE_RESOURCE_POOL_EXHAUSTED", "ZONE_RESOURCE_POOL_EXHAUSTED_WITH_DETAILS":
+		exhaustedErr := &ResourceExhaustedError{Err: opErr}
+		if m := zoneMessage.FindStringSubmatch(e.Message); m != nil {
+			exhaustedErr.Zone = m[1]
+		}
+		return exhaustedErr
+	case "RESOURCE_NOT_FOUND":
+		return &NotFoundError{Resource: quotedResource(e.Message), Err: opErr}
+	case "RESOURCE_ALREADY_EXISTS", "ALREADY_EXISTS":
+		return &AlreadyExistsError{Resource: quotedResource(e.Message), Err: opErr}
+	case "PERMISSIONS_ERROR", "FORBIDDEN", "IAM_PERMISSION_DENIED":
+		return permissionDenied(e.Message, opErr)
+	}
+	return opErr
+}
+
+// decodeAPIError returns the typed error matching an error response of the
+// API. Other errors, and responses that match none, are returned as is.
+func decodeAPIError(err error) error {
+	var apiErr *googleapi.Error
+	if !errors.As(err, &apiErr) {
+		return err
+	}
+
+	reasons := map[string]bool{}
+	for _, item := range apiErr.Errors {
+		reasons[item.Reason] = true
+	}
+
+	if m := constraintMessage.FindStringSubmatch(apiErr.Message); m != nil {
+		return &OrgPolicyViolationError{Constraint: m[1], Err: err}
+	}
+	switch {
+	case reasons["quotaExceeded"]:
+		return quotaExceeded(apiErr.Message, err)
+	case reasons["ZONE_RESOURCE_POOL_EXHAUSTED"] || reasons["ZONE_RESOURCE_POOL_EXHAUSTED_WITH_DETAILS"]:
+		exhaustedErr := &ResourceExhaustedError{Err: err}
+		if m := zoneMessage.FindStringSubmatch(apiErr.Message); m != nil {
+			exhaustedErr.Zone = m[1]
+		}
+		return exhaustedErr
+	}
+
+	switch apiErr.Code {
+	case http.StatusForbidden:
+		// 403 is also returned for rate limits, which are not denials.
+		if reasons["forbidden"] || reasons["insufficientPermissions"] || reasons["accessNotConfigured"] ||
+			permissionMessage.MatchString(apiErr.Message) || iamMessage.MatchString(apiErr.Message) {
+			return permissionDenied(apiErr.Message, err)
+		}
+	case http.StatusNotFound:
+		return &NotFoundError{Resource: quotedResource(apiErr.Message), Err: err}
+	case http.StatusConflict:
+		if reasons["alreadyExists"] {
+			return &AlreadyExistsError{Resource: quotedResource(apiErr.Message), Err: err}
+		}
+	}
+	return err
+}
+
+// decodeResult decodes the error of an API call, for use around the calls
+// that return a value along with the error.
+func decodeResult[T any](v T, err error) (T, error) {
+	return v, decodeAPIError(err)
+}
+
+func quotaExceeded(message string, err error) *QuotaExceededError {
+	quotaErr := &QuotaExceededError{Err: err}
+	if m := quotaMessage.FindStringSubmatch(message); m != nil {
+		quotaErr.Metric = m[1]
+		quotaErr.Limit, _ = strconv.ParseFloat(m[2], 64)
+		quotaErr.Region = m[3]
+	} else if m := quotaRegionMessage.FindStringSubmatch(message); m != nil {
+		quotaErr.Region = m[1]
+	}
+	return quotaErr
+}
+
+func permissionDenied(message string, err error) *PermissionDeniedError {
+	deniedErr := &PermissionDeniedError{Err: err}
+	if m := permissionMessage.FindStringSubmatch(message); m != nil {
+		deniedErr.Permission, deniedErr.Resource = m[1], m[2]
+	} else if m := iamMessage.FindStringSubmatch(message); m != nil {
+		deniedErr.Permission, deniedErr.Resource = m[1], m[2]
+	}
+	return deniedErr
+}
+
+func quotedResource(message string) string {
+	if m := resourceMessage.FindStringSubmatch(message); m != nil {
+		return m[1]
+	}
+	return ""
+}
@@ -0,0 +1,225 @@
+// Copyright IBM Corp. 2013, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	compute "google.golang.org/api/compute/v1"
+	"google.golang.org/api/googleapi"
+)
+
+func TestDecodeOperationError(t *testing.T) {
+	cases := []struct {
+		name     string
+		err      *compute.OperationErrorErrors
+		expected error
+	}{
+		{
+			"quota with details",
+			&compute.OperationErrorErrors{
+				Code:    "QUOTA_EXCEEDED",
+				Message: "Quota 'CPUS' exceeded.  Limit: 24.0 in region us-central1.",
+				ErrorDetails: []*compute.OperationErrorErrorsErrorDetails{{
+					QuotaInfo: &compute.QuotaExceededInfo{
+						MetricName: "compute.googleapis.com/cpus",
+						Limit:      24,
+						Dimensions: map[string]string{"region": "us-central1"},
+					},
+				}},
+			},
+			&QuotaExceededError{Metric: "compute.googleapis.com/cpus", Region: "us-central1", Limit: 24},
+		},
+		{
+			"quota from message",
+			&compute.OperationErrorErrors{
+				Code:    "QUOTA_EXCEEDED",
+				Message: "Quota 'SSD_TOTAL_GB' exceeded.  Limit: 500.0 in region europe-west1.",
+			},
+			&QuotaExceededError{Metric: "SSD_TOTAL_GB", Region: "europe-west1", Limit: 500},
+		},
+		{
+			"stockout",
+			&compute.OperationErrorErrors{
+				Code:    "ZONE_RESOURCE_POOL_EXHAUSTED",
+				Message: "The zone 'projects/p/zones/us-east1-b' does not have enough resources available to fulfill the request.",
+			},
+			&ResourceExhaustedError{Zone: "us-east1-b"},
+		},
+		{
+			"permission",
+			&compute.OperationErrorErrors{
+				Code:    "PERMISSIONS_ERROR",
+				Message: "Required 'compute.subnetworks.use' permission for 'projects/p/regions/us-east1/subnetworks/s'",
+			},
+			&PermissionDeniedError{Permission: "compute.subnetworks.use", Resource: "projects/p/regions/us-east1/subnetworks/s"},
+		},
+		{
+			"not found",
+			&compute.OperationErrorErrors{
+				Code:    "RESOURCE_NOT_FOUND",
+				Message: "The resource 'projects/p/global/images/i' was not found",
+			},
+			&NotFoundError{Resource: "projects/p/global/images/i"},
+		},
+		{
+			"already exists",
+			&compute.OperationErrorErrors{
+				Code:    "RESOURCE_ALREADY_EXISTS",
+				Message: "The resource 'projects/p/global/images/i' already exists",
+			},
+			&AlreadyExistsError{Resource: "projects/p/global/images/i"},
+		},
+		{
+			"org policy",
+			&compute.OperationErrorErrors{
+				Code:    "CONDITION_NOT_MET",
+				Message: "Constraint constraints/compute.vmExternalIpAccess violated for project 123.",
+			},
+			&OrgPolicyViolationError{Constraint: "constraints/compute.vmExternalIpAccess"},
+		},
+		{
+			"other",
+			&compute.OperationErrorErrors{Code: "INVALID_USAGE", Message: "invalid"},
+			&OperationError{Code: "INVALID_USAGE", Message: "invalid"},
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			err := decodeOperationError(tt.err)
+			assert.Equal(t, tt.expected, withoutCause(err))
+
+			var opErr *OperationError
+			assert.True(t, errors.As(err, &opErr), "the error of the operation should be wrapped")
+			assert.Equal(t, tt.err.Code, opErr.Code)
+		})
+	}
+}
+
+func TestDecodeAPIError(t *testing.T) {
+	apiErr := func(code int, reason, message string) *googleapi.Error {
+		return &googleapi.Error{
+			Code:    code,
+			Message: message,
+			Errors:  []googleapi.ErrorItem{{Reason: reason, Message: message}},
+		}
+	}
+
+	cases := []struct {
+		name     string
+		err      error
+		expected error
+	}{
+		{
+			"quota",
+			apiErr(403, "quotaExceeded", "Quota 'CPUS' exceeded.  Limit: 8.0 in region us-west1."),
+			&QuotaExceededError{Metric: "CPUS", Region: "us-west1", Limit: 8},
+		},
+		{
+			"permission",
+			apiErr(403, "forbidden", "Required 'compute.instances.create' permission for 'projects/p/zones/us-west1-a/instances/i'"),
+			&PermissionDeniedError{Permission: "compute.instances.create", Resource: "projects/p/zones/us-west1-a/instances/i"},
+		},
+		{
+			"iam permission",
+			apiErr(403, "forbidden", "Permission 'iam.serviceAccounts.actAs' denied on resource 'sa@p.iam.gserviceaccount.com'"),
+			&PermissionDeniedError{Permission: "iam.serviceAccounts.actAs", Resource: "sa@p.iam.gserviceaccount.com"},
+		},
+		{
+			"not found",
+			apiErr(404, "notFound", "The resource 'projects/p/zones/us-west1-a/machineTypes/n9' was not found"),
+			&NotFoundError{Resource: "projects/p/zones/us-west1-a/machineTypes/n9"},
+		},
+		{
+			"already exists",
+			apiErr(409, "alreadyExists", "The resource 'projects/p/global/images/i' already exists"),
+			&AlreadyExistsError{Resource: "projects/p/global/images/i"},
+		},
+		{
+			"conflict",
+			apiErr(409, "resourceInUseByAnotherResource", "in use"),
+			nil,
+		},
+		{
+			"org policy",
+			apiErr(412, "conditionNotMet", "Constraint constraints/compute.requireShieldedVm violated for project p."),
+			&OrgPolicyViolationError{Constraint: "constraints/compute.requireShieldedVm"},
+		},
+		{
+			"rate limit",
+			apiErr(403, "rateLimitExceeded", "Rate Limit Exceeded"),
+			nil,
+		},
+		{"wrapped", fmt.Errorf("getting image: %w", apiErr(404, "notFound", "not found")), &NotFoundError{}},
+		{"plain error", errors.New("not found"), nil},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			err := decodeAPIError(tt.err)
+			if tt.expected == nil {
+				assert.Equal(t, tt.err, err, "the error should be returned as is")
+				return
+			}
+			assert.Equal(t, tt.expected, withoutCause(err))
+			assert.True(t, errors.Is(err, tt.err), "the error of the API should be wrapped")
+		})
+	}
+}
+
+func TestAPIError_hint(t *testing.T) {
+	err := operationErrors(&compute.Operation{Error: &compute.OperationError{
+		Errors: []*compute.OperationErrorErrors{{
+			Code:    "QUOTA_EXCEEDED",
+			Message: "Quota 'CPUS' exceeded.  Limit: 24.0 in region us-central1.",
+		}},
+	}})
+
+	assert.ErrorContains(t, err, "Quota 'CPUS' exceeded.  Limit: 24.0 in region us-central1.\n"+
+		"Hint: Request an increase of the CPUS quota in us-central1")
+	assert.True(t, IsCapacityError(err))
+
+	var quotaErr *QuotaExceededError
+	assert.True(t, AsError(err, &quotaErr), "the typed error should be found in the operation errors")
+	assert.Equal(t, "us-central1", quotaErr.Region)
+
+	var notFound *NotFoundError
+	assert.False(t, AsError(err, &notFound))
+}
+
+// withoutCause returns a copy of a typed error without the error it was
+// decoded from, for comparisons.
+func withoutCause(err error) error {
+	switch e := err.(type) {
+	case *QuotaExceededError:
+		c := *e
+		c.Err = nil
+		return &c
+	case *ResourceExhaustedError:
+		c := *e
+		c.Err = nil
+		return &c
+	case *PermissionDeniedError:
+		c := *e
+		c.Err = nil
+		return &c
+	case *NotFoundError:
+		c := *e
+		c.Err = nil
+		return &c
+	case *AlreadyExistsError:
+		c := *e
+		c.Err = nil
+		return &c
+	case *OrgPolicyViolationError:
+		c := *e
+		c.Err = nil
+		return &c
+	}
+	return err
+}
@@ -10,6 +10,8 @@ import (
 	"fmt"
 	"log"
 	"regexp"
+	"slices"
+	"strings"
 
 	"github.com/gofrs/uuid"
 	compute "google.golang.org/api/compute/v1"
@@ -31,6 +33,14 @@ const (
 
 var diskNameRegex = regexp.MustCompile("^[a-z]([-a-z0-9]*[a-z0-9])?$")
 
+// The disk types that support provisioned IOPS, provisioned throughput, and
+// creation in a storage pool.
+var (
+	iopsDiskTypes        = []string{ZonalExtreme, HyperDiskBalanced, HyperDiskExtreme}
+	throughputDiskTypes  = []string{HyperDiskBalanced, HyperDiskML, HyperDiskThroughput}
+	storagePoolDiskTypes = []string{HyperDiskBalanced, HyperDiskThroughput}
+)
+
 // BlockDevice is a block device attachement/creation to an instance when building an image.
 type BlockDevice struct {
 	// How to attach the volume to the instance
@@ -40,8 +50,22 @@ type BlockDevice struct {
 	// If true, an image will be created for this disk, instead of the boot disk.
 	//
 	// This only applies to non-scratch disks, and can only be specified on one disk at a
-	// time.
+	// time, unless the disks set their own `image_name`.
 	CreateImage bool `mapstructure:"create_image"`
+	// The name of the image to create for this disk, with `create_image`. The
+	// image is then created in addition to the image of `image_name`, which is
+	// still created from the boot disk, or from the one disk with
+	// `create_image` and no `image_name`. This lets a build create images of
+	// several disks, e.g. data disk images along with the OS image.
+	ImageName string `mapstructure:"image_name"`
+	// The image family of the image of this disk. Requires `image_name`.
+	ImageFamily string `mapstructure:"image_family"`
+	// Key/value pair labels to apply to the image of this disk. Requires
+	// `image_name`.
+	ImageLabels map[string]string `mapstructure:"image_labels"`
+	// The customer-supplied encryption key to encrypt the image of this disk
+	// with. Requires `image_name`.
+	ImageEncryptionKey *CustomerEncryptionKey `mapstructure:"image_encryption_key"`
 	// The device name as exposed to the OS in the /dev/disk/by-id/google-* directory
 	//
 	// If unspecified, the disk will have a default name in the form
@@ -57,6 +81,10 @@ type BlockDevice struct {
 	// * RawKey: - A 256-bit customer-supplied encryption key, encodes in RFC 4648 base64.
 	//
 	// Refer to the [Customer Encryption Key](#customer-encryption-key) section for more information on the contents of this block.
+	//
+	// Cloud KMS Autokey key handles are not supported: to encrypt the disk
+	// with a key provisioned by Autokey, set kmsKeyName to the `kmsKey` of the
+	// key handle created for it.
 	DiskEncryptionKey CustomerEncryptionKey `mapstructure:"disk_encryption_key"`
 	// Name of the disk to create.
 	// This only applies to non-scratch disks. If the disk is persistent, and
@@ -72,8 +100,30 @@ type BlockDevice struct {
 	InterfaceType string `mapstructure:"interface_type"`
 	// The requested IOPS for the disk.
 	//
-	// This is only available for pd_extreme disks.
+	// This is only available for pd-extreme, hyperdisk-balanced and
+	// hyperdisk-extreme disks.
 	IOPS int `mapstructure:"iops"`
+	// The requested throughput for the disk, in MiB per second.
+	//
+	// This is only available for hyperdisk-balanced, hyperdisk-ml and
+	// hyperdisk-throughput disks.
+	ProvisionedThroughput int `mapstructure:"provisioned_throughput"`
+	// The storage pool to create the disk in, either its name in the zone of
+	// the instance or its URL.
+	//
+	// This is only available for hyperdisk-balanced and hyperdisk-throughput
+	// disks.
+	StoragePool string `mapstructure:"storage_pool"`
+	// Key/value pair labels to apply to the created disk.
+	Labels map[string]string `mapstructure:"labels"`
+	// The resource policies to apply to the created disk, e.g. a snapshot
+	// schedule. Either their names in the region of the instance or their
+	// URLs.
+	ResourcePolicies []string `mapstructure:"resource_policies"`
+	// The architecture of the created disk, either X86_64 or ARM64.
+	//
+	// Defaults to the architecture of `source_image` or `source_snapshot`.
+	Architecture string `mapstructure:"architecture"`
 	// Keep the device in the created disks after the instance is terminated.
 	// By default, the builder will remove the disks at the end of the build.
 	//
@@ -112,6 +162,15 @@ type BlockDevice struct {
 	//
 	// This cannot be used with SourceVolume.
 	SourceImage string `mapstructure:"source_image" required:"false"`
+	// The snapshot to create the disk from. Either the name of a snapshot of
+	// the project, or its partial or full URL, e.g.
+	// `projects/golden/global/snapshots/data-20240101`.
+	//
+	// This cannot be used with SourceVolume or SourceImage.
+	SourceSnapshot string `mapstructure:"source_snapshot" required:"false"`
+	// The customer-supplied encryption key of `source_snapshot`, if it is
+	// encrypted with one.
+	SourceSnapshotEncryptionKey *CustomerEncryptionKey `mapstructure:"source_snapshot_encryption_key" required:"false"`
 	// Zone is the zone in which to create the disk in.
 	//
 	// It is not exposed since the parent config already specifies it
@@ -166,6 +225,14 @@ func (bd *BlockDevice) Prepare() []error {
 		errs = append(errs, fmt.Errorf("Scratch volumes may not have create_image enabled"))
 	}
 
+	hasImageArgs := bd.ImageFamily != "" || len(bd.ImageLabels) > 0 || bd.ImageEncryptionKey != nil
+	if !bd.CreateImage && (bd.ImageName != "" || hasImageArgs) {
+		errs = append(errs, fmt.Errorf("image_name, image_family, image_labels and image_encryption_key require create_image"))
+	} else if bd.ImageName == "" && hasImageArgs {
+		errs = append(errs, fmt.Errorf("image_family, image_labels and image_encryption_key require image_name, "+
+			"the image of a disk without image_name uses the image settings of the build"))
+	}
+
 	if bd.SourceVolume != "" {
 		bd.KeepDevice = true
 	}
@@ -178,6 +245,11 @@ func (bd BlockDevice) hasDiskCreationArgs() bool {
 		bd.VolumeType != "" ||
 		bd.DiskName != "" ||
 		bd.IOPS != 0 ||
+		bd.ProvisionedThroughput != 0 ||
+		bd.StoragePool != "" ||
+		len(bd.Labels) > 0 ||
+		len(bd.ResourcePolicies) > 0 ||
+		bd.Architecture != "" ||
 		bd.KeepDevice
 }
 
@@ -189,11 +261,29 @@ func (bd *BlockDevice) prepareDiskCreate() []error {
 * volume_type
 * volume_size
 * iops
+* provisioned_throughput
+* storage_pool
+* labels
+* resource_policies
+* architecture
 * keep_device`),
 		}
-	} else if bd.SourceVolume != "" && bd.SourceImage != "" {
+	}
+
+	var sources []string
+	for source, set := range map[string]bool{
+		"source_image":    bd.SourceImage != "",
+		"source_snapshot": bd.SourceSnapshot != "",
+		"source_volume":   bd.SourceVolume != "",
+	} {
+		if set {
+			sources = append(sources, source)
+		}
+	}
+	if len(sources) > 1 {
+		slices.Sort(sources)
 		return []error{
-			fmt.Errorf("source_volume and source_image are mutually exclusive"),
+			fmt.Errorf("%s are mutually exclusive", strings.Join(sources, " and ")),
 		}
 	}
 
@@ -203,6 +293,17 @@ func (bd *BlockDevice) prepareDiskCreate() []error {
 
 	var errs []error
 
+	if bd.SourceSnapshotEncryptionKey != nil && bd.SourceSnapshot == "" {
+		errs = append(errs, fmt.Errorf("source_snapshot_encryption_key requires source_snapshot"))
+	}
+
+	bd.Architecture = strings.ToUpper(bd.Architecture)
+	switch bd.Architecture {
+	case "X86_64", "ARM64", "":
+	default:
+		errs = append(errs, fmt.Errorf("Invalid architecture %q: Must be either X86_64 or ARM64", bd.Architecture))
+	}
+
 	switch bd.VolumeType {
 	case LocalScratch,
 		ZonalStandard, ZonalBalanced, ZonalSSD, ZonalExtreme,
@@ -213,12 +314,11 @@ func (bd *BlockDevice) prepareDiskCreate() []error {
 		return errs
 	}
 
-	if (bd.IOPS != 0) && (bd.VolumeType != ZonalExtreme) {
-		errs = append(errs, fmt.Errorf("IOPS may only be specified for %q volumes", ZonalExtreme))
-	}
+	errs = append(errs, ValidateDiskPerformance(string(bd.VolumeType), int64(bd.IOPS),
+		int64(bd.ProvisionedThroughput), bd.StoragePool)...)
 
-	if bd.IOPS != 0 && (bd.IOPS < 10000 || bd.IOPS > 120000) {
-		errs = append(errs, fmt.Errorf("Requested IOPS must be >= 10000 and <= 120000"))
+	if bd.StoragePool != "" && len(bd.ReplicaZones) > 0 {
+		errs = append(errs, fmt.Errorf("storage_pool cannot be used along with replica_zones, storage pools are zonal"))
 	}
 
 	if bd.VolumeType == LocalScratch && bd.KeepDevice {
@@ -229,7 +329,11 @@ func (bd *BlockDevice) prepareDiskCreate() []error {
 		errs = append(errs, fmt.Errorf("Scratch volumes cannot have a name specified."))
 	}
 
-	if bd.VolumeSize == 0 && bd.SourceImage == "" {
+	if bd.VolumeType == LocalScratch && (bd.SourceSnapshot != "" || len(bd.ResourcePolicies) > 0) {
+		errs = append(errs, fmt.Errorf("Scratch volumes cannot be created from a snapshot or have resource policies."))
+	}
+
+	if bd.VolumeSize == 0 && bd.SourceImage == "" && bd.SourceSnapshot == "" {
 		errs = append(errs, fmt.Errorf("volume_size must be specified"))
 	}
 
@@ -257,6 +361,72 @@ func (bd *BlockDevice) prepareDiskCreate() []error {
 	return errs
 }
 
+// ValidateDiskPerformance checks that the disk type supports the provisioned
+// IOPS and throughput requested, and creation in a storage pool.
+func ValidateDiskPerformance(diskType string, iops, throughput int64, storagePool string) []error {
+	var errs []error
+
+	if iops < 0 {
+		errs = append(errs, fmt.Errorf("Requested IOPS must be positive"))
+	} else if iops != 0 && !slices.Contains(iopsDiskTypes, diskType) {
+		errs = append(errs, fmt.Errorf("IOPS may only be specified for %s volumes", strings.Join(iopsDiskTypes, ", ")))
+	}
+
+	if iops != 0 && diskType == ZonalExtreme && (iops < 10000 || iops > 120000) {
+		errs = append(errs, fmt.Errorf("Requested IOPS must be >= 10000 and <= 120000"))
+	}
+
+	if throughput < 0 {
+		errs = append(errs, fmt.Errorf("Requested throughput must be positive"))
+	} else if throughput != 0 && !slices.Contains(throughputDiskTypes, diskType) {
+		errs = append(errs, fmt.Errorf("Throughput may only be specified for %s volumes",
+			strings.Join(throughputDiskTypes, ", ")))
+	}
+
+	if storagePool != "" && !slices.Contains(storagePoolDiskTypes, diskType) {
+		errs = append(errs, fmt.Errorf("Storage pools may only be used for %s volumes",
+			strings.Join(storagePoolDiskTypes, ", ")))
+	}
+
+	return errs
+}
+
+// StoragePoolURL returns the partial URL of the storage pool, which may be
+// given as a plain name in the zone.
+func StoragePoolURL(storagePool, zone string) string {
+	if storagePool == "" || strings.Contains(storagePool, "/") {
+		return storagePool
+	}
+	return fmt.Sprintf("zones/%s/storagePools/%s", zone, storagePool)
+}
+
+// SnapshotURL returns the partial URL of the snapshot, which may be given as a
+// plain name in the project.
+func SnapshotURL(snapshot string) string {
+	if snapshot == "" || strings.Contains(snapshot, "/") {
+		return snapshot
+	}
+	return fmt.Sprintf("global/snapshots/%s", snapshot)
+}
+
+// ResourcePolicyURLs returns the partial URLs of the resource policies, which
+// may be given as plain names in the region of the zone.
+func ResourcePolicyURLs(policies []string, zone string) ([]string, error) {
+	var urls []string
+	for _, policy := range policies {
+		if strings.Contains(policy, "/") {
+			urls = append(urls, policy)
+			continue
+		}
+		region, err := GetRegionFromZone(zone)
+		if err != nil {
+			return nil, err
+		}
+		urls = append(urls, fmt.Sprintf("regions/%s/resourcePolicies/%s", region, policy))
+	}
+	return urls, nil
+}
+
 var regionRegexp = regexp.MustCompile("^(.+)-[^-]$")
 
 func GetRegionFromZone(zone string) (string, error) {
@@ -290,10 +460,29 @@ func (bd BlockDevice) GenerateComputeDiskPayload() (*compute.Disk, error) {
 		payload.SourceImage = bd.SourceImage
 	}
 
+	if bd.SourceSnapshot != "" {
+		payload.SourceSnapshot = SnapshotURL(bd.SourceSnapshot)
+		payload.SourceSnapshotEncryptionKey = bd.SourceSnapshotEncryptionKey.ComputeType()
+	}
+
 	if bd.IOPS != 0 {
 		payload.ProvisionedIops = int64(bd.IOPS)
 	}
 
+	if bd.ProvisionedThroughput != 0 {
+		payload.ProvisionedThroughput = int64(bd.ProvisionedThroughput)
+	}
+
+	payload.StoragePool = StoragePoolURL(bd.StoragePool, bd.Zone)
+	payload.Labels = bd.Labels
+	payload.Architecture = bd.Architecture
+
+	resourcePolicies, err := ResourcePolicyURLs(bd.ResourcePolicies, bd.Zone)
+	if err != nil {
+		return nil, err
+	}
+	payload.ResourcePolicies = resourcePolicies
+
 	if len(bd.ReplicaZones) == 0 {
 		payload.Type = fmt.Sprintf("zones/%s/diskTypes/%s", bd.Zone, bd.VolumeType)
 	} else {
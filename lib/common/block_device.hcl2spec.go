@@ -10,20 +10,31 @@ import (
 // FlatBlockDevice is an auto-generated flat version of BlockDevice.
 // Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
 type FlatBlockDevice struct {
-	AttachmentMode    *string                    `mapstructure:"attachment_mode" cty:"attachment_mode" hcl:"attachment_mode"`
-	CreateImage       *bool                      `mapstructure:"create_image" cty:"create_image" hcl:"create_image"`
-	DeviceName        *string                    `mapstructure:"device_name" cty:"device_name" hcl:"device_name"`
-	DiskEncryptionKey *FlatCustomerEncryptionKey `mapstructure:"disk_encryption_key" cty:"disk_encryption_key" hcl:"disk_encryption_key"`
-	DiskName          *string                    `mapstructure:"disk_name" cty:"disk_name" hcl:"disk_name"`
-	InterfaceType     *string                    `mapstructure:"interface_type" cty:"interface_type" hcl:"interface_type"`
-	IOPS              *int                       `mapstructure:"iops" cty:"iops" hcl:"iops"`
-	KeepDevice        *bool                      `mapstructure:"keep_device" cty:"keep_device" hcl:"keep_device"`
-	ReplicaZones      []string                   `mapstructure:"replica_zones" required:"false" cty:"replica_zones" hcl:"replica_zones"`
-	SourceVolume      *string                    `mapstructure:"source_volume" cty:"source_volume" hcl:"source_volume"`
-	VolumeSize        *int                       `mapstructure:"volume_size" required:"true" cty:"volume_size" hcl:"volume_size"`
-	VolumeType        *BlockDeviceType           `mapstructure:"volume_type" required:"true" cty:"volume_type" hcl:"volume_type"`
-	SourceImage       *string                    `mapstructure:"source_image" required:"false" cty:"source_image" hcl:"source_image"`
-	Zone              *string                    `mapstructure:"_" cty:"_" hcl:"_"`
+	AttachmentMode              *string                    `mapstructure:"attachment_mode" cty:"attachment_mode" hcl:"attachment_mode"`
+	CreateImage                 *bool                      `mapstructure:"create_image" cty:"create_image" hcl:"create_image"`
+	ImageName                   *string                    `mapstructure:"image_name" cty:"image_name" hcl:"image_name"`
+	ImageFamily                 *string                    `mapstructure:"image_family" cty:"image_family" hcl:"image_family"`
+	ImageLabels                 map[string]string          `mapstructure:"image_labels" cty:"image_labels" hcl:"image_labels"`
+	ImageEncryptionKey          *FlatCustomerEncryptionKey `mapstructure:"image_encryption_key" cty:"image_encryption_key" hcl:"image_encryption_key"`
+	DeviceName                  *string                    `mapstructure:"device_name" cty:"device_name" hcl:"device_name"`
+	DiskEncryptionKey           *FlatCustomerEncryptionKey `mapstructure:"disk_encryption_key" cty:"disk_encryption_key" hcl:"disk_encryption_key"`
+	DiskName                    *string                    `mapstructure:"disk_name" cty:"disk_name" hcl:"disk_name"`
+	InterfaceType               *string                    `mapstructure:"interface_type" cty:"interface_type" hcl:"interface_type"`
+	IOPS                        *int                       `mapstructure:"iops" cty:"iops" hcl:"iops"`
+	ProvisionedThroughput       *int                       `mapstructure:"provisioned_throughput" cty:"provisioned_throughput" hcl:"provisioned_throughput"`
+	StoragePool                 *string                    `mapstructure:"storage_pool" cty:"storage_pool" hcl:"storage_pool"`
+	Labels                      map[string]string          `mapstructure:"labels" cty:"labels" hcl:"labels"`
+	ResourcePolicies            []string                   `mapstructure:"resource_policies" cty:"resource_policies" hcl:"resource_policies"`
+	Architecture                *string                    `mapstructure:"architecture" cty:"architecture" hcl:"architecture"`
+	KeepDevice                  *bool                      `mapstructure:"keep_device" cty:"keep_device" hcl:"keep_device"`
+	ReplicaZones                []string                   `mapstructure:"replica_zones" required:"false" cty:"replica_zones" hcl:"replica_zones"`
+	SourceVolume                *string                    `mapstructure:"source_volume" cty:"source_volume" hcl:"source_volume"`
+	VolumeSize                  *int                       `mapstructure:"volume_size" required:"true" cty:"volume_size" hcl:"volume_size"`
+	VolumeType                  *BlockDeviceType           `mapstructure:"volume_type" required:"true" cty:"volume_type" hcl:"volume_type"`
+	SourceImage                 *string                    `mapstructure:"source_image" required:"false" cty:"source_image" hcl:"source_image"`
+	SourceSnapshot              *string                    `mapstructure:"source_snapshot" required:"false" cty:"source_snapshot" hcl:"source_snapshot"`
+	SourceSnapshotEncryptionKey *FlatCustomerEncryptionKey `mapstructure:"source_snapshot_encryption_key" required:"false" cty:"source_snapshot_encryption_key" hcl:"source_snapshot_encryption_key"`
+	Zone                        *string                    `mapstructure:"_" cty:"_" hcl:"_"`
 }
 
 // FlatMapstructure returns a new FlatBlockDevice.
@@ -38,20 +49,31 @@ func (*BlockDevice) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.S
 // The decoded values from this spec will then be applied to a FlatBlockDevice.
 func (*FlatBlockDevice) HCL2Spec() map[string]hcldec.Spec {
 	s := map[string]hcldec.Spec{
-		"attachment_mode":     &hcldec.AttrSpec{Name: "attachment_mode", Type: cty.String, Required: false},
-		"create_image":        &hcldec.AttrSpec{Name: "create_image", Type: cty.Bool, Required: false},
-		"device_name":         &hcldec.AttrSpec{Name: "device_name", Type: cty.String, Required: false},
-		"disk_encryption_key": &hcldec.BlockSpec{TypeName: "disk_encryption_key", Nested: hcldec.ObjectSpec((*FlatCustomerEncryptionKey)(nil).HCL2Spec())},
-		"disk_name":           &hcldec.AttrSpec{Name: "disk_name", Type: cty.String, Required: false},
-		"interface_type":      &hcldec.AttrSpec{Name: "interface_type", Type: cty.String, Required: false},
-		"iops":                &hcldec.AttrSpec{Name: "iops", Type: cty.Number, Required: false},
-		"keep_device":         &hcldec.AttrSpec{Name: "keep_device", Type: cty.Bool, Required: false},
-		"replica_zones":       &hcldec.AttrSpec{Name: "replica_zones", Type: cty.List(cty.String), Required: false},
-		"source_volume":       &hcldec.AttrSpec{Name: "source_volume", Type: cty.String, Required: false},
-		"volume_size":         &hcldec.AttrSpec{Name: "volume_size", Type: cty.Number, Required: false},
-		"volume_type":         &hcldec.AttrSpec{Name: "volume_type", Type: cty.String, Required: false},
-		"source_image":        &hcldec.AttrSpec{Name: "source_image", Type: cty.String, Required: false},
-		"_":                   &hcldec.AttrSpec{Name: "_", Type: cty.String, Required: false},
+		"attachment_mode":                &hcldec.AttrSpec{Name: "attachment_mode", Type: cty.String, Required: false},
+		"create_image":                   &hcldec.AttrSpec{Name: "create_image", Type: cty.Bool, Required: false},
+		"image_name":                     &hcldec.AttrSpec{Name: "image_name", Type: cty.String, Required: false},
+		"image_family":                   &hcldec.AttrSpec{Name: "image_family", Type: cty.String, Required: false},
+		"image_labels":                   &hcldec.AttrSpec{Name: "image_labels", Type: cty.Map(cty.String), Required: false},
+		"image_encryption_key":           &hcldec.BlockSpec{TypeName: "image_encryption_key", Nested: hcldec.ObjectSpec((*FlatCustomerEncryptionKey)(nil).HCL2Spec())},
+		"device_name":                    &hcldec.AttrSpec{Name: "device_name", Type: cty.String, Required: false},
+		"disk_encryption_key":            &hcldec.BlockSpec{TypeName: "disk_encryption_key", Nested: hcldec.ObjectSpec((*FlatCustomerEncryptionKey)(nil).HCL2Spec())},
+		"disk_name":                      &hcldec.AttrSpec{Name: "disk_name", Type: cty.String, Required: false},
+		"interface_type":                 &hcldec.AttrSpec{Name: "interface_type", Type: cty.String, Required: false},
+		"iops":                           &hcldec.AttrSpec{Name: "iops", Type: cty.Number, Required: false},
+		"provisioned_throughput":         &hcldec.AttrSpec{Name: "provisioned_throughput", Type: cty.Number, Required: false},
+		"storage_pool":                   &hcldec.AttrSpec{Name: "storage_pool", Type: cty.String, Required: false},
+		"labels":                         &hcldec.AttrSpec{Name: "labels", Type: cty.Map(cty.String), Required: false},
+		"resource_policies":              &hcldec.AttrSpec{Name: "resource_policies", Type: cty.List(cty.String), Required: false},
+		"architecture":                   &hcldec.AttrSpec{Name: "architecture", Type: cty.String, Required: false},
+		"keep_device":                    &hcldec.AttrSpec{Name: "keep_device", Type: cty.Bool, Required: false},
+		"replica_zones":                  &hcldec.AttrSpec{Name: "replica_zones", Type: cty.List(cty.String), Required: false},
+		"source_volume":                  &hcldec.AttrSpec{Name: "source_volume", Type: cty.String, Required: false},
+		"volume_size":                    &hcldec.AttrSpec{Name: "volume_size", Type: cty.Number, Required: false},
+		"volume_type":                    &hcldec.AttrSpec{Name: "volume_type", Type: cty.String, Required: false},
+		"source_image":                   &hcldec.AttrSpec{Name: "source_image", Type: cty.String, Required: false},
+		"source_snapshot":                &hcldec.AttrSpec{Name: "source_snapshot", Type: cty.String, Required: false},
+		"source_snapshot_encryption_key": &hcldec.BlockSpec{TypeName: "source_snapshot_encryption_key", Nested: hcldec.ObjectSpec((*FlatCustomerEncryptionKey)(nil).HCL2Spec())},
+		"_":                              &hcldec.AttrSpec{Name: "_", Type: cty.String, Required: false},
 	}
 	return s
 }
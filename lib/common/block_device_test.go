@@ -287,6 +287,147 @@ func TestBlockDevice_Prepare(t *testing.T) {
 			},
 			expectErr: true,
 		},
+		{
+			name: "OK - IOPS and throughput on hyperdisk-balanced",
+			config: &BlockDevice{
+				VolumeType:            "hyperdisk-balanced",
+				VolumeSize:            100,
+				IOPS:                  5000,
+				ProvisionedThroughput: 300,
+			},
+			expectErr: false,
+		},
+		{
+			name: "Fail - throughput set on non-compatible volume type",
+			config: &BlockDevice{
+				VolumeType:            "hyperdisk-extreme",
+				VolumeSize:            100,
+				ProvisionedThroughput: 300,
+			},
+			expectErr: true,
+		},
+		{
+			name: "OK - storage pool on hyperdisk-throughput",
+			config: &BlockDevice{
+				VolumeType:  "hyperdisk-throughput",
+				VolumeSize:  2048,
+				StoragePool: "my-pool",
+			},
+			expectErr: false,
+		},
+		{
+			name: "Fail - storage pool set on non-compatible volume type",
+			config: &BlockDevice{
+				VolumeType:  "pd-ssd",
+				VolumeSize:  100,
+				StoragePool: "my-pool",
+			},
+			expectErr: true,
+		},
+		{
+			name: "Fail - storage pool set along with replica zones",
+			config: &BlockDevice{
+				VolumeType:   "hyperdisk-balanced",
+				VolumeSize:   100,
+				StoragePool:  "my-pool",
+				ReplicaZones: []string{"us-central1-b"},
+			},
+			expectErr: true,
+		},
+		{
+			name: "fail - source volume set along with labels",
+			config: &BlockDevice{
+				SourceVolume: "zones/us-central1-a/disks/source-disk",
+				Labels:       map[string]string{"team": "images"},
+			},
+			expectErr: true,
+		},
+		{
+			name: "OK - image of its own",
+			config: &BlockDevice{
+				VolumeType:  "pd-standard",
+				VolumeSize:  25,
+				CreateImage: true,
+				ImageName:   "data-image",
+				ImageFamily: "data",
+				ImageLabels: map[string]string{"role": "data"},
+			},
+			expectErr: false,
+		},
+		{
+			name: "fail - image_name without create_image",
+			config: &BlockDevice{
+				VolumeType: "pd-standard",
+				VolumeSize: 25,
+				ImageName:  "data-image",
+			},
+			expectErr: true,
+		},
+		{
+			name: "fail - image_family without image_name",
+			config: &BlockDevice{
+				VolumeType:  "pd-standard",
+				VolumeSize:  25,
+				CreateImage: true,
+				ImageFamily: "data",
+			},
+			expectErr: true,
+		},
+		{
+			name: "OK - from a snapshot without volume size",
+			config: &BlockDevice{
+				VolumeType:                  "pd-ssd",
+				SourceSnapshot:              "data-nightly",
+				SourceSnapshotEncryptionKey: &CustomerEncryptionKey{KmsKeyName: "snapshot-key"},
+				ResourcePolicies:            []string{"daily"},
+				Architecture:                "arm64",
+			},
+			expectErr: false,
+		},
+		{
+			name: "fail - source snapshot set along with source image",
+			config: &BlockDevice{
+				VolumeType:     "pd-ssd",
+				SourceImage:    "projects/p/global/images/family/f",
+				SourceSnapshot: "data-nightly",
+			},
+			expectErr: true,
+		},
+		{
+			name: "fail - source snapshot encryption key without source snapshot",
+			config: &BlockDevice{
+				VolumeType:                  "pd-ssd",
+				VolumeSize:                  25,
+				SourceSnapshotEncryptionKey: &CustomerEncryptionKey{KmsKeyName: "snapshot-key"},
+			},
+			expectErr: true,
+		},
+		{
+			name: "fail - invalid architecture",
+			config: &BlockDevice{
+				VolumeType:   "pd-ssd",
+				VolumeSize:   25,
+				Architecture: "riscv",
+			},
+			expectErr: true,
+		},
+		{
+			name: "fail - scratch volume from a snapshot",
+			config: &BlockDevice{
+				VolumeType:     "scratch",
+				VolumeSize:     375,
+				SourceSnapshot: "data-nightly",
+			},
+			expectErr: true,
+		},
+		{
+			name: "fail - source volume set along with resource policies",
+			config: &BlockDevice{
+				SourceVolume:     "zones/us-central1-a/disks/source-disk",
+				ResourcePolicies: []string{"daily"},
+			},
+			expectErr: true,
+		},
 		{
 			name: "fail - source volume set along with source image",
 			config: &BlockDevice{
@@ -502,6 +643,55 @@ func TestGenerateComputeDisk(t *testing.T) {
 				Type:              "zones/us-central1-a/diskTypes/pd-extreme",
 			},
 		},
+		{
+			name: "with hyperdisk performance, storage pool and labels set",
+			config: BlockDevice{
+				VolumeType:            "hyperdisk-balanced",
+				VolumeSize:            250,
+				DiskName:              "packer-test",
+				IOPS:                  5000,
+				ProvisionedThroughput: 300,
+				StoragePool:           "my-pool",
+				Labels:                map[string]string{"team": "images"},
+				Zone:                  "us-central1-a",
+			},
+			expectval: &compute.Disk{
+				Description:           "created by Packer",
+				SizeGb:                250,
+				Name:                  "packer-test",
+				DiskEncryptionKey:     &compute.CustomerEncryptionKey{},
+				ProvisionedIops:       5000,
+				ProvisionedThroughput: 300,
+				StoragePool:           "zones/us-central1-a/storagePools/my-pool",
+				Labels:                map[string]string{"team": "images"},
+				Type:                  "zones/us-central1-a/diskTypes/hyperdisk-balanced",
+			},
+		},
+		{
+			name: "from a snapshot, with resource policies and architecture set",
+			config: BlockDevice{
+				VolumeType:                  "pd-ssd",
+				DiskName:                    "packer-test",
+				SourceSnapshot:              "data-nightly",
+				SourceSnapshotEncryptionKey: &CustomerEncryptionKey{KmsKeyName: "snapshot-key"},
+				ResourcePolicies:            []string{"daily", "projects/p/regions/us-east1/resourcePolicies/weekly"},
+				Architecture:                "ARM64",
+				Zone:                        "us-central1-a",
+			},
+			expectval: &compute.Disk{
+				Description:                 "created by Packer",
+				Name:                        "packer-test",
+				DiskEncryptionKey:           &compute.CustomerEncryptionKey{},
+				SourceSnapshot:              "global/snapshots/data-nightly",
+				SourceSnapshotEncryptionKey: &compute.CustomerEncryptionKey{KmsKeyName: "snapshot-key"},
+				ResourcePolicies: []string{
+					"regions/us-central1/resourcePolicies/daily",
+					"projects/p/regions/us-east1/resourcePolicies/weekly",
+				},
+				Architecture: "ARM64",
+				Type:         "zones/us-central1-a/diskTypes/pd-ssd",
+			},
+		},
 		{
 			name: "with extra zones set",
 			config: BlockDevice{
@@ -547,6 +737,17 @@ func TestGenerateComputeDisk(t *testing.T) {
 	}
 }
 
+func TestResourcePolicyURLs(t *testing.T) {
+	urls, err := ResourcePolicyURLs([]string{"daily", "projects/p/regions/us-east1/resourcePolicies/weekly"}, "us-central1-a")
+	if err != nil {
+		t.Fatalf("resource policy URLs failed: %s", err)
+	}
+	assert.Equal(t, []string{
+		"regions/us-central1/resourcePolicies/daily",
+		"projects/p/regions/us-east1/resourcePolicies/weekly",
+	}, urls)
+}
+
 func TestGetRegionFromZone(t *testing.T) {
 	zone := "us-central1-a"
 	region, err := GetRegionFromZone(zone)
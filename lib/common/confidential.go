@@ -0,0 +1,73 @@
+// Copyright IBM Corp. 2013, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	ConfidentialInstanceTypeSEV    = "SEV"
+	ConfidentialInstanceTypeSEVSNP = "SEV_SNP"
+	ConfidentialInstanceTypeTDX    = "TDX"
+)
+
+// confidentialGuestOsFeatures are the guest OS features an image must
+// advertise to boot on each type of confidential instance.
+var confidentialGuestOsFeatures = map[string]string{
+	ConfidentialInstanceTypeSEV:    "SEV_CAPABLE",
+	ConfidentialInstanceTypeSEVSNP: "SEV_SNP_CAPABLE",
+	ConfidentialInstanceTypeTDX:    "TDX_CAPABLE",
+}
+
+// confidentialMachineFamilies are the machine families supporting each type
+// of confidential instance.
+//
+// See https://cloud.google.com/confidential-computing/confidential-vm/docs/supported-configurations
+var confidentialMachineFamilies = map[string][]string{
+	ConfidentialInstanceTypeSEV:    {"n2d", "c2d", "c3d"},
+	ConfidentialInstanceTypeSEVSNP: {"n2d"},
+	ConfidentialInstanceTypeTDX:    {"c3"},
+}
+
+// ConfidentialGuestOsFeature returns the guest OS feature an image must
+// advertise to boot on the given type of confidential instance.
+func ConfidentialGuestOsFeature(instanceType string) string {
+	return confidentialGuestOsFeatures[instanceType]
+}
+
+// ValidateConfidentialInstance returns an error if instances of machineType
+// cannot be confidential instances of the given type.
+func ValidateConfidentialInstance(instanceType, machineType string) error {
+	families, ok := confidentialMachineFamilies[instanceType]
+	if !ok {
+		return fmt.Errorf("confidential_instance_type must be one of %s, %s or %s",
+			ConfidentialInstanceTypeSEV,
+			ConfidentialInstanceTypeSEVSNP,
+			ConfidentialInstanceTypeTDX)
+	}
+
+	family := machineFamily(machineType)
+	for _, f := range families {
+		if f == family {
+			return nil
+		}
+	}
+	return fmt.Errorf("machine type %q does not support %s confidential instances, "+
+		"supported machine families are: %s", machineType, instanceType, strings.Join(families, ", "))
+}
+
+// ConfidentialInstanceCanMigrate returns true if confidential instances of the
+// given type and machineType support live migration during host maintenance.
+func ConfidentialInstanceCanMigrate(instanceType, machineType string) bool {
+	return instanceType == ConfidentialInstanceTypeSEV && machineFamily(machineType) == "n2d"
+}
+
+// machineFamily returns the family of a machine type, e.g. n2d for
+// n2d-standard-2.
+func machineFamily(machineType string) string {
+	family, _, _ := strings.Cut(machineType, "-")
+	return strings.ToLower(family)
+}
@@ -4,10 +4,12 @@
 package common
 
 import (
+	"context"
 	"crypto/rsa"
 	"io"
 	"time"
 
+	"golang.org/x/oauth2"
 	compute "google.golang.org/api/compute/v1"
 	oauth2_svc "google.golang.org/api/oauth2/v2"
 	oslogin "google.golang.org/api/oslogin/v1"
@@ -16,91 +18,168 @@ import (
 // Driver is the interface that has to be implemented to communicate
 // with GCE. The Driver interface exists mostly to allow a mock implementation
 // to be used to test the steps.
+//
+// Every method takes the context of the build, cancelling it aborts the
+// requests in flight and stops waiting for the operations they started.
 type Driver interface {
 	// CreateDisk creates a persistent disk from the specified config.
-	CreateDisk(diskConfig BlockDevice) (<-chan *compute.Disk, <-chan error)
+	CreateDisk(ctx context.Context, diskConfig BlockDevice) (<-chan *compute.Disk, <-chan error)
 
 	// CreateImage creates an image from the given disk in Google Compute
 	// Engine.
-	CreateImage(project string, imageSpec *compute.Image) (<-chan *Image, <-chan error)
+	CreateImage(ctx context.Context, project string, imageSpec *compute.Image) (<-chan *Image, <-chan error)
 
 	// SetImageDeprecationStatus sets the deprecation, obsolete and deletion date
 	// for the image with the given name.
-	SetImageDeprecationStatus(project, name string, deprecationStatus *compute.DeprecationStatus) error
+	SetImageDeprecationStatus(ctx context.Context, project, name string, deprecationStatus *compute.DeprecationStatus) error
 
 	// DeleteImage deletes the image with the given name.
-	DeleteImage(project, name string) <-chan error
+	DeleteImage(ctx context.Context, project, name string) <-chan error
+
+	// ListImagesInFamily lists the images of the given family, including the
+	// deprecated ones.
+	ListImagesInFamily(ctx context.Context, project, family string) ([]*compute.Image, error)
+
+	// AddImageIAMBindings grants the roles of the bindings to their members on
+	// the image with the given name.
+	AddImageIAMBindings(ctx context.Context, project, name string, bindings []IAMBinding) error
+
+	// RemoveImageIAMBindings revokes the roles of the bindings from their
+	// members on the image with the given name.
+	RemoveImageIAMBindings(ctx context.Context, project, name string, bindings []IAMBinding) error
+
+	// CreateMachineImage creates a machine image from the instance given as
+	// source in the spec, including all its disks and properties.
+	CreateMachineImage(ctx context.Context, project string, machineImageSpec *compute.MachineImage) (<-chan *compute.MachineImage, <-chan error)
+
+	// DeleteMachineImage deletes the machine image with the given name.
+	DeleteMachineImage(ctx context.Context, project, name string) <-chan error
+
+	// GetMachineImage gets the machine image with the given name.
+	GetMachineImage(ctx context.Context, project, name string) (*compute.MachineImage, error)
+
+	// CreateSnapshot creates a snapshot of the disk given as source in the
+	// spec.
+	CreateSnapshot(ctx context.Context, project string, snapshotSpec *compute.Snapshot) (<-chan *compute.Snapshot, <-chan error)
+
+	// DeleteSnapshot deletes the snapshot with the given name.
+	DeleteSnapshot(ctx context.Context, project, name string) <-chan error
+
+	// GetSnapshot gets the snapshot with the given name.
+	GetSnapshot(ctx context.Context, project, name string) (*compute.Snapshot, error)
+
+	// StopInstance stops the given instance.
+	StopInstance(ctx context.Context, zone, name string) (<-chan error, error)
 
 	// DeleteInstance deletes the given instance, keeping the boot disk.
-	DeleteInstance(zone, name string) (<-chan error, error)
+	DeleteInstance(ctx context.Context, zone, name string) (<-chan error, error)
 
 	// DeleteDisk deletes the disk with the given name.
-	DeleteDisk(zone, name string) <-chan error
+	DeleteDisk(ctx context.Context, zone, name string) <-chan error
 
 	// GetDisk gets the disk with the given name in a zone/region.
-	GetDisk(zone, name string) (*compute.Disk, error)
+	GetDisk(ctx context.Context, zone, name string) (*compute.Disk, error)
 
 	// GetImage gets an image; tries the default and public projects. If
 	// fromFamily is true, name designates an image family instead of a
 	// particular image.
-	GetImage(name string, fromFamily bool) (*Image, error)
+	GetImage(ctx context.Context, name string, fromFamily bool) (*Image, error)
 
 	// GetImageFromProject gets an image from a specific projects.
 	// Returns the image from the first project in slice it can find one
 	// If fromFamily is true, name designates an image family instead of a particular image.
-	GetImageFromProjects(project []string, name string, fromFamily bool) (*Image, error)
+	GetImageFromProjects(ctx context.Context, project []string, name string, fromFamily bool) (*Image, error)
 
 	// GetImageFromProject gets an image from a specific project. If fromFamily
 	// is true, name designates an image family instead of a particular image.
-	GetImageFromProject(project, name string, fromFamily bool) (*Image, error)
+	GetImageFromProject(ctx context.Context, project, name string, fromFamily bool) (*Image, error)
 
 	// GetProjectMetadata gets a metadata variable for the project.
-	GetProjectMetadata(zone, key string) (string, error)
+	GetProjectMetadata(ctx context.Context, zone, key string) (string, error)
 
 	// GetInstanceMetadata gets a metadata variable for the instance, name.
-	GetInstanceMetadata(zone, name, key string) (string, error)
+	GetInstanceMetadata(ctx context.Context, zone, name, key string) (string, error)
 
-	// GetInternalIP gets the GCE-internal IP address for the instance.
-	GetInternalIP(zone, name string) (string, error)
+	// GetGuestAttributes gets the guest attributes the instance, name, set
+	// in the given namespace, keyed by name. It returns no attributes if the
+	// instance did not set any yet.
+	GetGuestAttributes(ctx context.Context, zone, name, namespace string) (map[string]string, error)
 
-	// GetNatIP gets the NAT IP address for the instance.
-	GetNatIP(zone, name string) (string, error)
+	// GetInternalIP gets the GCE-internal IP address of the network
+	// interface, nic, of the instance.
+	GetInternalIP(ctx context.Context, zone, name string, nic int) (string, error)
+
+	// GetNatIP gets the NAT IP address of the network interface, nic, of the
+	// instance.
+	GetNatIP(ctx context.Context, zone, name string, nic int) (string, error)
+
+	// GetInternalIPv6 gets the GCE-internal IPv6 address of the network
+	// interface, nic, of the instance.
+	GetInternalIPv6(ctx context.Context, zone, name string, nic int) (string, error)
+
+	// GetExternalIPv6 gets the external IPv6 address of the network
+	// interface, nic, of the instance.
+	GetExternalIPv6(ctx context.Context, zone, name string, nic int) (string, error)
 
 	// GetSerialPortOutput gets the Serial Port contents for the instance.
-	GetSerialPortOutput(zone, name string) (string, error)
+	GetSerialPortOutput(ctx context.Context, zone, name string) (string, error)
+
+	// GetSerialPortOutputFrom gets the Serial Port contents for the instance
+	// from the byte offset start, along with the offset to continue from.
+	// The contents start later if the output before start was discarded.
+	GetSerialPortOutputFrom(ctx context.Context, zone, name string, start int64) (string, int64, error)
 
 	// GetTokenInfo gets the information about the token used for authentication
-	GetTokenInfo() (*oauth2_svc.Tokeninfo, error)
+	GetTokenInfo(ctx context.Context) (*oauth2_svc.Tokeninfo, error)
+
+	// GetTokenSource gets the token source the driver authenticates with, for
+	// use by clients that don't go through the Google API libraries.
+	GetTokenSource(ctx context.Context) (oauth2.TokenSource, error)
+
+	// InstancePreempted returns true if Compute Engine preempted the
+	// preemptible or Spot instance.
+	InstancePreempted(ctx context.Context, zone, name string) (bool, error)
 
 	// ImageExists returns true if the specified image exists. If an error
 	// occurs calling the API, this method returns false.
-	ImageExists(project, name string) bool
+	ImageExists(ctx context.Context, project, name string) bool
+
+	// KeepBootDisk makes sure the boot disk of the instance outlives it, and
+	// returns the name of the disk.
+	KeepBootDisk(ctx context.Context, zone, name string) (string, error)
 
 	// RunInstance takes the given config and launches an instance.
-	RunInstance(*InstanceConfig) (<-chan error, error)
+	RunInstance(ctx context.Context, c *InstanceConfig) (<-chan error, error)
 
 	// WaitForInstance waits for an instance to reach the given state.
-	WaitForInstance(state, zone, name string) <-chan error
+	WaitForInstance(ctx context.Context, state, zone, name string) <-chan error
 
 	// CreateOrResetWindowsPassword creates or resets the password for a user on an Windows instance.
-	CreateOrResetWindowsPassword(zone, name string, config *WindowsPasswordConfig) (<-chan error, error)
+	CreateOrResetWindowsPassword(ctx context.Context, zone, name string, config *WindowsPasswordConfig) (<-chan error, error)
 
 	// ImportOSLoginSSHKey imports SSH public key for OSLogin.
 	// expirationTimeUsec is an optional expiration time in microseconds since Unix epoch.
 	// If nil, no expiration time will be set.
-	ImportOSLoginSSHKey(user, sshPublicKey string, expirationTimeUsec *int64) (*oslogin.LoginProfile, error)
+	ImportOSLoginSSHKey(ctx context.Context, user, sshPublicKey string, expirationTimeUsec *int64) (*oslogin.LoginProfile, error)
 
 	// DeleteOSLoginSSHKey deletes the SSH public key for OSLogin with the given key.
-	DeleteOSLoginSSHKey(user, fingerprint string) error
+	DeleteOSLoginSSHKey(ctx context.Context, user, fingerprint string) error
 
 	// Add to the instance metadata for the existing instance
-	AddToInstanceMetadata(zone string, name string, metadata map[string]string) error
+	AddToInstanceMetadata(ctx context.Context, zone string, name string, metadata map[string]string) error
 
 	// UploadToBucket uploads an artifact to a bucket on GCS.
-	UploadToBucket(bucket, objectName string, data io.Reader) (string, error)
+	UploadToBucket(ctx context.Context, bucket, objectName string, data io.Reader) (string, error)
 
 	// DeleteFromBucket deletes an object from a bucket on GCS.
-	DeleteFromBucket(bucket, objectName string) error
+	DeleteFromBucket(ctx context.Context, bucket, objectName string) error
+
+	// DownloadFromBucket returns the content of an object of a bucket on GCS.
+	DownloadFromBucket(ctx context.Context, bucket, objectName string) ([]byte, error)
+
+	// AccessSecretVersion returns the payload of a version of a secret of
+	// Secret Manager, given its full name.
+	AccessSecretVersion(ctx context.Context, name string) ([]byte, error)
 }
 
 // WindowsPasswordConfig is the data structure that GCE needs to encrypt the created
@@ -18,6 +18,8 @@ import (
 	"strings"
 	"time"
 
+	secretmanager "cloud.google.com/go/secretmanager/apiv1"
+	secretmanagerpb "cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
 	compute "google.golang.org/api/compute/v1"
 	"google.golang.org/api/googleapi"
 	impersonate "google.golang.org/api/impersonate"
@@ -25,10 +27,11 @@ import (
 	"google.golang.org/api/option"
 	oslogin "google.golang.org/api/oslogin/v1"
 	"google.golang.org/api/storage/v1"
+	"google.golang.org/api/transport"
+	htransport "google.golang.org/api/transport/http"
 
 	"github.com/hashicorp/packer-plugin-googlecompute/version"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
-	"github.com/hashicorp/packer-plugin-sdk/retry"
 	"github.com/hashicorp/packer-plugin-sdk/useragent"
 	vaultapi "github.com/hashicorp/vault/api"
 
@@ -41,6 +44,11 @@ const (
 	InstanceTerminationActionDelete = "DELETE"
 )
 
+const (
+	ProvisioningModelStandard = "STANDARD"
+	ProvisioningModelSpot     = "SPOT"
+)
+
 // driverGCE is a Driver implementation that actually talks to GCE.
 // Create an instance using NewDriverGCE.
 type driverGCE struct {
@@ -49,7 +57,12 @@ type driverGCE struct {
 	osLoginService *oslogin.Service
 	oauth2Service  *oauth2_svc.Service
 	storageService *storage.Service
-	ui             packersdk.Ui
+	clientOptions  []option.ClientOption
+	// customEndpoints holds the endpoints of the clients created on demand.
+	customEndpoints map[string]string
+	ui              packersdk.Ui
+	// pollBackoff spaces the polls of the operations and states waited for.
+	pollBackoff pollBackoff
 }
 
 type GCEDriverConfig struct {
@@ -62,6 +75,8 @@ type GCEDriverConfig struct {
 	Credentials                   *google.Credentials
 	UniverseDomain                string
 	CustomEndpoints               map[string]string
+	// Retry configures the retries of the API requests failing transiently.
+	Retry RetryConfig
 }
 
 var DriverScopes = []string{
@@ -214,60 +229,72 @@ func NewDriverGCE(config GCEDriverConfig) (Driver, error) {
 		opts = append(opts, option.WithUniverseDomain(config.UniverseDomain))
 	}
 
+	// The clients share an HTTP client whose requests are retried when they
+	// fail transiently.
+	httpClient, _, err := htransport.NewClient(context.TODO(), opts...)
+	if err != nil {
+		return nil, err
+	}
+	httpClient.Transport = newRetryTransport(httpClient.Transport, config.Retry)
+	httpOpts := append(opts[:len(opts):len(opts)], option.WithHTTPClient(httpClient))
+
 	log.Printf("[INFO] Instantiating GCE client...")
-	serviceOpts := buildServiceSpecificOptions(opts, config.CustomEndpoints, "compute")
+	serviceOpts := buildServiceSpecificOptions(httpOpts, config.CustomEndpoints, "compute")
 	service, err := compute.NewService(context.TODO(), serviceOpts...)
 	if err != nil {
 		return nil, err
 	}
 
 	log.Printf("[INFO] Instantiating OS Login client...")
-	serviceOpts = buildServiceSpecificOptions(opts, config.CustomEndpoints, "oslogin")
+	serviceOpts = buildServiceSpecificOptions(httpOpts, config.CustomEndpoints, "oslogin")
 	osLoginService, err := oslogin.NewService(context.TODO(), serviceOpts...)
 	if err != nil {
 		return nil, err
 	}
 
 	log.Printf("[INFO] Instantiating Oauth2 client...")
-	serviceOpts = buildServiceSpecificOptions(opts, config.CustomEndpoints, "oauth2")
+	serviceOpts = buildServiceSpecificOptions(httpOpts, config.CustomEndpoints, "oauth2")
 	oauth2Service, err := oauth2_svc.NewService(context.TODO(), serviceOpts...)
 	if err != nil {
 		return nil, err
 	}
 
 	log.Printf("[INFO] Instantiating storage client...")
-	serviceOpts = buildServiceSpecificOptions(opts, config.CustomEndpoints, "storage")
+	serviceOpts = buildServiceSpecificOptions(httpOpts, config.CustomEndpoints, "storage")
 	storageService, err := storage.NewService(context.TODO(), serviceOpts...)
 	if err != nil {
 		return nil, err
 	}
 
 	return &driverGCE{
-		projectId:      config.ProjectId,
-		service:        service,
-		osLoginService: osLoginService,
-		oauth2Service:  oauth2Service,
-		storageService: storageService,
-		ui:             config.Ui,
+		projectId:       config.ProjectId,
+		service:         service,
+		osLoginService:  osLoginService,
+		oauth2Service:   oauth2Service,
+		storageService:  storageService,
+		clientOptions:   opts,
+		customEndpoints: config.CustomEndpoints,
+		ui:              config.Ui,
+		pollBackoff:     defaultPollBackoff,
 	}, nil
 }
 
-func (d *driverGCE) CreateImage(project string, imageSpec *compute.Image) (<-chan *Image, <-chan error) {
+func (d *driverGCE) CreateImage(ctx context.Context, project string, imageSpec *compute.Image) (<-chan *Image, <-chan error) {
 	imageCh := make(chan *Image, 1)
 	errCh := make(chan error, 1)
-	op, err := d.service.Images.Insert(project, imageSpec).Do()
+	op, err := decodeResult(d.service.Images.Insert(project, imageSpec).RequestId(newRequestID()).Context(ctx).Do())
 	if err != nil {
 		errCh <- err
 	} else {
 		go func() {
-			err = waitForState(errCh, "DONE", d.refreshGlobalOp(project, op))
+			err = d.waitForOperation(ctx, project, op)
+			errCh <- err
 			if err != nil {
 				close(imageCh)
-				errCh <- err
 				return
 			}
 			var image *Image
-			image, err = d.GetImageFromProject(project, imageSpec.Name, false)
+			image, err = d.GetImageFromProject(ctx, project, imageSpec.Name, false)
 			if err != nil {
 				close(imageCh)
 				errCh <- err
@@ -281,51 +308,218 @@ func (d *driverGCE) CreateImage(project string, imageSpec *compute.Image) (<-cha
 	return imageCh, errCh
 }
 
-func (d *driverGCE) SetImageDeprecationStatus(project, name string, deprecationStatus *compute.DeprecationStatus) error {
+func (d *driverGCE) SetImageDeprecationStatus(ctx context.Context, project, name string, deprecationStatus *compute.DeprecationStatus) error {
 	if deprecationStatus == nil {
 		return errors.New("deprecationStatus cannot be nil")
 	}
-	_, err := d.service.Images.Deprecate(project, name, deprecationStatus).Do()
+	_, err := decodeResult(d.service.Images.Deprecate(project, name, deprecationStatus).RequestId(newRequestID()).Context(ctx).Do())
 	return err
 }
 
-func (d *driverGCE) DeleteImage(project, name string) <-chan error {
+func (d *driverGCE) DeleteImage(ctx context.Context, project, name string) <-chan error {
 	errCh := make(chan error, 1)
-	op, err := d.service.Images.Delete(project, name).Do()
+	op, err := decodeResult(d.service.Images.Delete(project, name).RequestId(newRequestID()).Context(ctx).Do())
 	if err != nil {
 		errCh <- err
 	} else {
 		go func() {
-			_ = waitForState(errCh, "DONE", d.refreshGlobalOp(project, op))
+			errCh <- d.waitForOperation(ctx, project, op)
+		}()
+
+	}
+
+	return errCh
+}
+
+func (d *driverGCE) ListImagesInFamily(ctx context.Context, project, family string) ([]*compute.Image, error) {
+	var images []*compute.Image
+	err := d.service.Images.List(project).
+		Filter(fmt.Sprintf("family = %q", family)).
+		Pages(ctx, func(list *compute.ImageList) error {
+			images = append(images, list.Items...)
+			return nil
+		})
+	return images, decodeAPIError(err)
+}
+
+func (d *driverGCE) AddImageIAMBindings(ctx context.Context, project, name string, bindings []IAMBinding) error {
+	return d.updateImageIAMPolicy(ctx, project, name, func(policy *compute.Policy) bool {
+		return AddIAMBindings(policy, bindings)
+	})
+}
+
+func (d *driverGCE) RemoveImageIAMBindings(ctx context.Context, project, name string, bindings []IAMBinding) error {
+	return d.updateImageIAMPolicy(ctx, project, name, func(policy *compute.Policy) bool {
+		return RemoveIAMBindings(policy, bindings)
+	})
+}
+
+// updateImageIAMPolicy reads the IAM policy of the image, changes it with
+// update, and writes it back if it changed. The write is conditioned on the
+// etag of the policy read, so that it is made again on a concurrent change
+// rather than overwriting it.
+func (d *driverGCE) updateImageIAMPolicy(ctx context.Context, project, name string, update func(*compute.Policy) bool) error {
+	const maxRetries = 5
+	var err error
+	for i := 0; i < maxRetries; i++ {
+		var policy *compute.Policy
+		policy, err = decodeResult(d.service.Images.GetIamPolicy(project, name).OptionsRequestedPolicyVersion(3).Context(ctx).Do())
+		if err != nil {
+			return err
+		}
+		if !update(policy) {
+			return nil
+		}
+
+		_, err = d.service.Images.SetIamPolicy(project, name, &compute.GlobalSetPolicyRequest{
+			Policy: policy,
+		}).Context(ctx).Do()
+		if err == nil {
+			return nil
+		}
+		// Retry on concurrent changes of the policy
+		var gErr *googleapi.Error
+		if errors.As(err, &gErr) && (gErr.Code == 409 || gErr.Code == 412) && (i+1 < maxRetries) {
+			log.Printf("SetIamPolicy conflict on image %s (try %d/%d): %v", name, i+1, maxRetries, err)
+			if err := sleepContext(ctx, time.Duration(retrySleepSeconds())*time.Second); err != nil {
+				return err
+			}
+		} else {
+			break
+		}
+	}
+	return decodeAPIError(err)
+}
+
+func (d *driverGCE) CreateMachineImage(ctx context.Context, project string, machineImageSpec *compute.MachineImage) (<-chan *compute.MachineImage, <-chan error) {
+	machineImageCh := make(chan *compute.MachineImage, 1)
+	errCh := make(chan error, 1)
+	op, err := decodeResult(d.service.MachineImages.Insert(project, machineImageSpec).RequestId(newRequestID()).Context(ctx).Do())
+	if err != nil {
+		errCh <- err
+	} else {
+		go func() {
+			err = d.waitForOperation(ctx, project, op)
+			errCh <- err
+			if err != nil {
+				close(machineImageCh)
+				return
+			}
+			var machineImage *compute.MachineImage
+			machineImage, err = d.GetMachineImage(ctx, project, machineImageSpec.Name)
+			if err != nil {
+				close(machineImageCh)
+				errCh <- err
+				return
+			}
+			machineImageCh <- machineImage
+			close(machineImageCh)
+		}()
+	}
+
+	return machineImageCh, errCh
+}
+
+func (d *driverGCE) DeleteMachineImage(ctx context.Context, project, name string) <-chan error {
+	errCh := make(chan error, 1)
+	op, err := decodeResult(d.service.MachineImages.Delete(project, name).RequestId(newRequestID()).Context(ctx).Do())
+	if err != nil {
+		errCh <- err
+	} else {
+		go func() {
+			errCh <- d.waitForOperation(ctx, project, op)
+		}()
+	}
+
+	return errCh
+}
+
+func (d *driverGCE) GetMachineImage(ctx context.Context, project, name string) (*compute.MachineImage, error) {
+	return decodeResult(d.service.MachineImages.Get(project, name).Context(ctx).Do())
+}
+
+func (d *driverGCE) CreateSnapshot(ctx context.Context, project string, snapshotSpec *compute.Snapshot) (<-chan *compute.Snapshot, <-chan error) {
+	snapshotCh := make(chan *compute.Snapshot, 1)
+	errCh := make(chan error, 1)
+	op, err := decodeResult(d.service.Snapshots.Insert(project, snapshotSpec).RequestId(newRequestID()).Context(ctx).Do())
+	if err != nil {
+		errCh <- err
+	} else {
+		go func() {
+			err = d.waitForOperation(ctx, project, op)
+			errCh <- err
+			if err != nil {
+				close(snapshotCh)
+				return
+			}
+			var snapshot *compute.Snapshot
+			snapshot, err = d.GetSnapshot(ctx, project, snapshotSpec.Name)
+			if err != nil {
+				close(snapshotCh)
+				errCh <- err
+				return
+			}
+			snapshotCh <- snapshot
+			close(snapshotCh)
 		}()
+	}
 
+	return snapshotCh, errCh
+}
+
+func (d *driverGCE) DeleteSnapshot(ctx context.Context, project, name string) <-chan error {
+	errCh := make(chan error, 1)
+	op, err := decodeResult(d.service.Snapshots.Delete(project, name).RequestId(newRequestID()).Context(ctx).Do())
+	if err != nil {
+		errCh <- err
+	} else {
+		go func() {
+			errCh <- d.waitForOperation(ctx, project, op)
+		}()
 	}
 
 	return errCh
 }
 
-func (d *driverGCE) DeleteInstance(zone, name string) (<-chan error, error) {
-	op, err := d.service.Instances.Delete(d.projectId, zone, name).Do()
+func (d *driverGCE) GetSnapshot(ctx context.Context, project, name string) (*compute.Snapshot, error) {
+	return decodeResult(d.service.Snapshots.Get(project, name).Context(ctx).Do())
+}
+
+func (d *driverGCE) StopInstance(ctx context.Context, zone, name string) (<-chan error, error) {
+	op, err := decodeResult(d.service.Instances.Stop(d.projectId, zone, name).RequestId(newRequestID()).Context(ctx).Do())
+	if err != nil {
+		return nil, err
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- d.waitForOperation(ctx, d.projectId, op)
+	}()
+	return errCh, nil
+}
+
+func (d *driverGCE) DeleteInstance(ctx context.Context, zone, name string) (<-chan error, error) {
+	op, err := decodeResult(d.service.Instances.Delete(d.projectId, zone, name).RequestId(newRequestID()).Context(ctx).Do())
 	if err != nil {
 		return nil, err
 	}
 
 	errCh := make(chan error, 1)
 	go func() {
-		_ = waitForState(errCh, "DONE", d.refreshZoneOp(zone, op))
+		errCh <- d.waitForOperation(ctx, d.projectId, op)
 	}()
 	return errCh, nil
 }
 
-func (d *driverGCE) CreateDisk(diskConfig BlockDevice) (<-chan *compute.Disk, <-chan error) {
+func (d *driverGCE) CreateDisk(ctx context.Context, diskConfig BlockDevice) (<-chan *compute.Disk, <-chan error) {
 	if len(diskConfig.ReplicaZones) != 0 {
-		return d.createRegionalDisk(diskConfig)
+		return d.createRegionalDisk(ctx, diskConfig)
 	}
 
-	return d.createZonalDisk(diskConfig)
+	return d.createZonalDisk(ctx, diskConfig)
 }
 
-func (d *driverGCE) createRegionalDisk(diskConfig BlockDevice) (<-chan *compute.Disk, <-chan error) {
+func (d *driverGCE) createRegionalDisk(ctx context.Context, diskConfig BlockDevice) (<-chan *compute.Disk, <-chan error) {
 	diskChan := make(chan *compute.Disk, 1)
 	errChan := make(chan error, 1)
 
@@ -338,7 +532,7 @@ func (d *driverGCE) createRegionalDisk(diskConfig BlockDevice) (<-chan *compute.
 	}
 
 	region, _ := GetRegionFromZone(diskConfig.Zone)
-	op, err := d.service.RegionDisks.Insert(d.projectId, region, computePayload).Do()
+	op, err := decodeResult(d.service.RegionDisks.Insert(d.projectId, region, computePayload).RequestId(newRequestID()).Context(ctx).Do())
 	if err != nil {
 		errChan <- err
 		close(diskChan)
@@ -352,12 +546,12 @@ func (d *driverGCE) createRegionalDisk(diskConfig BlockDevice) (<-chan *compute.
 			close(diskChan)
 		}()
 
-		err := waitForState(errChan, "DONE", d.refreshRegionOp(region, op))
+		err := d.waitForOperation(ctx, d.projectId, op)
+		errChan <- err
 		if err != nil {
-			errChan <- err
 			return
 		}
-		disk, err := d.service.Disks.Get(d.projectId, region, diskConfig.DiskName).Do()
+		disk, err := decodeResult(d.service.Disks.Get(d.projectId, region, diskConfig.DiskName).Context(ctx).Do())
 		if err != nil {
 			errChan <- err
 			return
@@ -368,7 +562,7 @@ func (d *driverGCE) createRegionalDisk(diskConfig BlockDevice) (<-chan *compute.
 	return diskChan, errChan
 }
 
-func (d *driverGCE) createZonalDisk(diskConfig BlockDevice) (<-chan *compute.Disk, <-chan error) {
+func (d *driverGCE) createZonalDisk(ctx context.Context, diskConfig BlockDevice) (<-chan *compute.Disk, <-chan error) {
 	diskChan := make(chan *compute.Disk, 1)
 	errChan := make(chan error, 1)
 
@@ -385,7 +579,7 @@ func (d *driverGCE) createZonalDisk(diskConfig BlockDevice) (<-chan *compute.Dis
 		return diskChan, errChan
 	}
 
-	op, err = d.service.Disks.Insert(d.projectId, zone, computePayload).Do()
+	op, err = decodeResult(d.service.Disks.Insert(d.projectId, zone, computePayload).RequestId(newRequestID()).Context(ctx).Do())
 	if err != nil {
 		errChan <- err
 		close(diskChan)
@@ -399,12 +593,12 @@ func (d *driverGCE) createZonalDisk(diskConfig BlockDevice) (<-chan *compute.Dis
 			close(diskChan)
 		}()
 
-		err := waitForState(errChan, "DONE", d.refreshZoneOp(zone, op))
+		err := d.waitForOperation(ctx, d.projectId, op)
+		errChan <- err
 		if err != nil {
-			errChan <- err
 			return
 		}
-		disk, err := d.service.Disks.Get(d.projectId, zone, diskConfig.DiskName).Do()
+		disk, err := decodeResult(d.service.Disks.Get(d.projectId, zone, diskConfig.DiskName).Context(ctx).Do())
 		if err != nil {
 			errChan <- err
 			return
@@ -415,18 +609,18 @@ func (d *driverGCE) createZonalDisk(diskConfig BlockDevice) (<-chan *compute.Dis
 	return diskChan, errChan
 }
 
-func (d *driverGCE) DeleteDisk(zoneOrRegion, name string) <-chan error {
+func (d *driverGCE) DeleteDisk(ctx context.Context, zoneOrRegion, name string) <-chan error {
 	if IsZoneARegion(zoneOrRegion) {
-		return d.deleteRegionalDisk(zoneOrRegion, name)
+		return d.deleteRegionalDisk(ctx, zoneOrRegion, name)
 	}
 
-	return d.deleteZonalDisk(zoneOrRegion, name)
+	return d.deleteZonalDisk(ctx, zoneOrRegion, name)
 }
 
-func (d *driverGCE) deleteZonalDisk(zone, name string) <-chan error {
+func (d *driverGCE) deleteZonalDisk(ctx context.Context, zone, name string) <-chan error {
 	errCh := make(chan error, 1)
 
-	op, err := d.service.Disks.Delete(d.projectId, zone, name).Do()
+	op, err := decodeResult(d.service.Disks.Delete(d.projectId, zone, name).RequestId(newRequestID()).Context(ctx).Do())
 	if err != nil {
 		errCh <- err
 		close(errCh)
@@ -434,16 +628,16 @@ func (d *driverGCE) deleteZonalDisk(zone, name string) <-chan error {
 	}
 
 	go func() {
-		_ = waitForState(errCh, "DONE", d.refreshZoneOp(zone, op))
+		errCh <- d.waitForOperation(ctx, d.projectId, op)
 		close(errCh)
 	}()
 	return errCh
 }
 
-func (d *driverGCE) deleteRegionalDisk(region, name string) <-chan error {
+func (d *driverGCE) deleteRegionalDisk(ctx context.Context, region, name string) <-chan error {
 	errCh := make(chan error, 1)
 
-	op, err := d.service.RegionDisks.Delete(d.projectId, region, name).Do()
+	op, err := decodeResult(d.service.RegionDisks.Delete(d.projectId, region, name).RequestId(newRequestID()).Context(ctx).Do())
 	if err != nil {
 		errCh <- err
 		close(errCh)
@@ -451,21 +645,21 @@ func (d *driverGCE) deleteRegionalDisk(region, name string) <-chan error {
 	}
 
 	go func() {
-		_ = waitForState(errCh, "DONE", d.refreshRegionOp(region, op))
+		errCh <- d.waitForOperation(ctx, d.projectId, op)
 		close(errCh)
 	}()
 	return errCh
 }
 
-func (d *driverGCE) GetDisk(zoneOrRegion, name string) (*compute.Disk, error) {
+func (d *driverGCE) GetDisk(ctx context.Context, zoneOrRegion, name string) (*compute.Disk, error) {
 	if IsZoneARegion(zoneOrRegion) {
-		return d.service.RegionDisks.Get(d.projectId, zoneOrRegion, name).Do()
+		return decodeResult(d.service.RegionDisks.Get(d.projectId, zoneOrRegion, name).Context(ctx).Do())
 	}
 
-	return d.service.Disks.Get(d.projectId, zoneOrRegion, name).Do()
+	return decodeResult(d.service.Disks.Get(d.projectId, zoneOrRegion, name).Context(ctx).Do())
 }
 
-func (d *driverGCE) GetImage(name string, fromFamily bool) (*Image, error) {
+func (d *driverGCE) GetImage(ctx context.Context, name string, fromFamily bool) (*Image, error) {
 
 	projects := []string{
 		d.projectId,
@@ -496,12 +690,12 @@ func (d *driverGCE) GetImage(name string, fromFamily bool) (*Image, error) {
 		"ubuntu-os-pro-cloud",
 		"ml-images",
 	}
-	return d.GetImageFromProjects(projects, name, fromFamily)
+	return d.GetImageFromProjects(ctx, projects, name, fromFamily)
 }
-func (d *driverGCE) GetImageFromProjects(projects []string, name string, fromFamily bool) (*Image, error) {
+func (d *driverGCE) GetImageFromProjects(ctx context.Context, projects []string, name string, fromFamily bool) (*Image, error) {
 	var errs error
 	for _, project := range projects {
-		image, err := d.GetImageFromProject(project, name, fromFamily)
+		image, err := d.GetImageFromProject(ctx, project, name, fromFamily)
 		if err != nil {
 			errs = packersdk.MultiErrorAppend(errs, err)
 		}
@@ -515,16 +709,16 @@ func (d *driverGCE) GetImageFromProjects(projects []string, name string, fromFam
 		projects, errs)
 }
 
-func (d *driverGCE) GetImageFromProject(project, name string, fromFamily bool) (*Image, error) {
+func (d *driverGCE) GetImageFromProject(ctx context.Context, project, name string, fromFamily bool) (*Image, error) {
 	var (
 		image *compute.Image
 		err   error
 	)
 
 	if fromFamily {
-		image, err = d.service.Images.GetFromFamily(project, name).Do()
+		image, err = decodeResult(d.service.Images.GetFromFamily(project, name).Context(ctx).Do())
 	} else {
-		image, err = d.service.Images.Get(project, name).Do()
+		image, err = decodeResult(d.service.Images.Get(project, name).Context(ctx).Do())
 	}
 
 	if err != nil {
@@ -544,8 +738,8 @@ func (d *driverGCE) GetImageFromProject(project, name string, fromFamily bool) (
 	}
 }
 
-func (d *driverGCE) GetProjectMetadata(zone, key string) (string, error) {
-	project, err := d.service.Projects.Get(d.projectId).Do()
+func (d *driverGCE) GetProjectMetadata(ctx context.Context, zone, key string) (string, error) {
+	project, err := decodeResult(d.service.Projects.Get(d.projectId).Context(ctx).Do())
 	if err != nil {
 		return "", err
 	}
@@ -559,8 +753,8 @@ func (d *driverGCE) GetProjectMetadata(zone, key string) (string, error) {
 	return "", fmt.Errorf("Project metadata key, %s, not found.", key)
 }
 
-func (d *driverGCE) GetInstanceMetadata(zone, name, key string) (string, error) {
-	instance, err := d.service.Instances.Get(d.projectId, zone, name).Do()
+func (d *driverGCE) GetInstanceMetadata(ctx context.Context, zone, name, key string) (string, error) {
+	instance, err := decodeResult(d.service.Instances.Get(d.projectId, zone, name).Context(ctx).Do())
 	if err != nil {
 		return "", err
 	}
@@ -574,44 +768,94 @@ func (d *driverGCE) GetInstanceMetadata(zone, name, key string) (string, error)
 	return "", fmt.Errorf("Instance metadata key, %s, not found.", key)
 }
 
-func (d *driverGCE) GetNatIP(zone, name string) (string, error) {
-	instance, err := d.service.Instances.Get(d.projectId, zone, name).Do()
+func (d *driverGCE) GetGuestAttributes(ctx context.Context, zone, name, namespace string) (map[string]string, error) {
+	attributes, err := decodeResult(d.service.Instances.GetGuestAttributes(d.projectId, zone, name).
+		QueryPath(namespace + "/").Context(ctx).Do())
+	var notFound *NotFoundError
+	if errors.As(err, &notFound) {
+		return map[string]string{}, nil
+	}
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	for _, ni := range instance.NetworkInterfaces {
-		if ni.AccessConfigs == nil {
-			continue
-		}
-		for _, ac := range ni.AccessConfigs {
-			if ac.NatIP != "" {
-				return ac.NatIP, nil
+	result := make(map[string]string)
+	if attributes.QueryValue != nil {
+		for _, item := range attributes.QueryValue.Items {
+			if item.Namespace == namespace {
+				result[item.Key] = item.Value
 			}
 		}
 	}
+	return result, nil
+}
+
+func (d *driverGCE) GetNatIP(ctx context.Context, zone, name string, nic int) (string, error) {
+	instance, err := decodeResult(d.service.Instances.Get(d.projectId, zone, name).Context(ctx).Do())
+	if err != nil {
+		return "", err
+	}
+
+	if nic >= len(instance.NetworkInterfaces) {
+		return "", fmt.Errorf("instance has no network interface nic%d", nic)
+	}
+
+	for _, ac := range instance.NetworkInterfaces[nic].AccessConfigs {
+		if ac.NatIP != "" {
+			return ac.NatIP, nil
+		}
+	}
 
 	return "", nil
 }
 
-func (d *driverGCE) GetInternalIP(zone, name string) (string, error) {
-	instance, err := d.service.Instances.Get(d.projectId, zone, name).Do()
+func (d *driverGCE) GetInternalIP(ctx context.Context, zone, name string, nic int) (string, error) {
+	instance, err := decodeResult(d.service.Instances.Get(d.projectId, zone, name).Context(ctx).Do())
 	if err != nil {
 		return "", err
 	}
 
-	for _, ni := range instance.NetworkInterfaces {
-		if ni.NetworkIP == "" {
-			continue
+	if nic >= len(instance.NetworkInterfaces) {
+		return "", fmt.Errorf("instance has no network interface nic%d", nic)
+	}
+
+	return instance.NetworkInterfaces[nic].NetworkIP, nil
+}
+
+func (d *driverGCE) GetInternalIPv6(ctx context.Context, zone, name string, nic int) (string, error) {
+	instance, err := decodeResult(d.service.Instances.Get(d.projectId, zone, name).Context(ctx).Do())
+	if err != nil {
+		return "", err
+	}
+
+	if nic >= len(instance.NetworkInterfaces) {
+		return "", fmt.Errorf("instance has no network interface nic%d", nic)
+	}
+
+	return instance.NetworkInterfaces[nic].Ipv6Address, nil
+}
+
+func (d *driverGCE) GetExternalIPv6(ctx context.Context, zone, name string, nic int) (string, error) {
+	instance, err := decodeResult(d.service.Instances.Get(d.projectId, zone, name).Context(ctx).Do())
+	if err != nil {
+		return "", err
+	}
+
+	if nic >= len(instance.NetworkInterfaces) {
+		return "", fmt.Errorf("instance has no network interface nic%d", nic)
+	}
+
+	for _, ac := range instance.NetworkInterfaces[nic].Ipv6AccessConfigs {
+		if ac.ExternalIpv6 != "" {
+			return ac.ExternalIpv6, nil
 		}
-		return ni.NetworkIP, nil
 	}
 
 	return "", nil
 }
 
-func (d *driverGCE) GetSerialPortOutput(zone, name string) (string, error) {
-	output, err := d.service.Instances.GetSerialPortOutput(d.projectId, zone, name).Do()
+func (d *driverGCE) GetSerialPortOutput(ctx context.Context, zone, name string) (string, error) {
+	output, err := decodeResult(d.service.Instances.GetSerialPortOutput(d.projectId, zone, name).Context(ctx).Do())
 	if err != nil {
 		return "", err
 	}
@@ -619,53 +863,136 @@ func (d *driverGCE) GetSerialPortOutput(zone, name string) (string, error) {
 	return output.Contents, nil
 }
 
-func (d *driverGCE) ImageExists(project, name string) bool {
-	_, err := d.GetImageFromProject(project, name, false)
+func (d *driverGCE) GetSerialPortOutputFrom(ctx context.Context, zone, name string, start int64) (string, int64, error) {
+	output, err := decodeResult(d.service.Instances.GetSerialPortOutput(d.projectId, zone, name).Start(start).Context(ctx).Do())
+	if err != nil {
+		return "", start, err
+	}
+
+	return output.Contents, output.Next, nil
+}
+
+func (d *driverGCE) InstancePreempted(ctx context.Context, zone, name string) (bool, error) {
+	instance, err := decodeResult(d.service.Instances.Get(d.projectId, zone, name).Context(ctx).Do())
+	var notFound *NotFoundError
+	if errors.As(err, &notFound) {
+		instance, err = nil, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	// A preempted instance is either stopped or deleted, depending on its
+	// termination action.
+	if instance != nil && instance.Status != "STOPPING" && instance.Status != "TERMINATED" {
+		return false, nil
+	}
+
+	// The instances of later attempts reuse the name, so the preemptions are
+	// matched by the ID of the current instance. A deleted instance is known
+	// by the last operation that inserted an instance of that name.
+	var id uint64
+	if instance != nil {
+		id = instance.Id
+	} else {
+		var inserted time.Time
+		err = d.service.ZoneOperations.List(d.projectId, zone).
+			Filter(`operationType="insert"`).
+			Pages(ctx, func(ops *compute.OperationList) error {
+				for _, op := range ops.Items {
+					if !strings.HasSuffix(op.TargetLink, "/instances/"+name) {
+						continue
+					}
+					insertTime, err := time.Parse(time.RFC3339, op.InsertTime)
+					if err == nil && insertTime.After(inserted) {
+						id, inserted = op.TargetId, insertTime
+					}
+				}
+				return nil
+			})
+		if err != nil {
+			return false, decodeAPIError(err)
+		}
+		if id == 0 {
+			return false, nil
+		}
+	}
+
+	preempted := false
+	err = d.service.ZoneOperations.List(d.projectId, zone).
+		Filter(`operationType="compute.instances.preempted"`).
+		Pages(ctx, func(ops *compute.OperationList) error {
+			for _, op := range ops.Items {
+				if op.TargetId == id {
+					preempted = true
+				}
+			}
+			return nil
+		})
+	return preempted, decodeAPIError(err)
+}
+
+func (d *driverGCE) ImageExists(ctx context.Context, project, name string) bool {
+	_, err := d.GetImageFromProject(ctx, project, name, false)
 	// The API may return an error for reasons other than the image not
 	// existing, but this heuristic is sufficient for now.
 	return err == nil
 }
 
-func (d *driverGCE) RunInstance(c *InstanceConfig) (<-chan error, error) {
+func (d *driverGCE) RunInstance(ctx context.Context, c *InstanceConfig) (<-chan error, error) {
 	// Get the zone
 	d.ui.Message(fmt.Sprintf("Loading zone: %s", c.Zone))
-	zone, err := d.service.Zones.Get(d.projectId, c.Zone).Do()
+	zone, err := decodeResult(d.service.Zones.Get(d.projectId, c.Zone).Context(ctx).Do())
 	if err != nil {
 		return nil, err
 	}
 
 	// Get the machine type
 	d.ui.Message(fmt.Sprintf("Loading machine type: %s", c.MachineType))
-	machineType, err := d.service.MachineTypes.Get(
-		d.projectId, zone.Name, c.MachineType).Do()
+	machineType, err := decodeResult(d.service.MachineTypes.Get(
+		d.projectId, zone.Name, c.MachineType).Context(ctx).Do())
 	if err != nil {
 		return nil, err
 	}
 	// TODO(mitchellh): deprecation warnings
 
-	networkId, subnetworkId, err := GetNetworking(c)
-	if err != nil {
-		return nil, err
+	networkInterfaces := c.NetworkInterfaces
+	if len(networkInterfaces) == 0 {
+		networkInterfaces = []NetworkInterface{
+			{
+				Network:          c.Network,
+				Subnetwork:       c.Subnetwork,
+				NetworkProjectId: c.NetworkProjectId,
+				NetworkIP:        c.NetworkIP,
+				// Use external IP if OmitExternalIP isn't set, and the
+				// interface has an IPv4 address
+				ExternalIP:   !c.OmitExternalIP && c.StackType != StackTypeIPv6Only,
+				Address:      c.Address,
+				ExternalIPv6: c.ExternalIPv6,
+				StackType:    c.StackType,
+			},
+		}
 	}
 
-	var accessconfig *compute.AccessConfig
-	// Use external IP if OmitExternalIP isn't set
-	if !c.OmitExternalIP {
-		accessconfig = &compute.AccessConfig{
-			Name: "AccessConfig created by Packer",
-			Type: "ONE_TO_ONE_NAT",
-		}
+	region_url := strings.Split(zone.Region, "/")
+	region := region_url[len(region_url)-1]
 
+	computeNetworkInterfaces := make([]*compute.NetworkInterface, 0, len(networkInterfaces))
+	for _, ni := range networkInterfaces {
 		// If given a static IP, use it
-		if c.Address != "" {
-			region_url := strings.Split(zone.Region, "/")
-			region := region_url[len(region_url)-1]
-			address, err := d.service.Addresses.Get(d.projectId, region, c.Address).Do()
+		var natIP string
+		if ni.ExternalIP && ni.Address != "" {
+			address, err := decodeResult(d.service.Addresses.Get(d.projectId, region, ni.Address).Context(ctx).Do())
 			if err != nil {
 				return nil, err
 			}
-			accessconfig.NatIP = address.Address
+			natIP = address.Address
+		}
+
+		computeNI, err := ni.ComputeType(c.Region, natIP)
+		if err != nil {
+			return nil, err
 		}
+		computeNetworkInterfaces = append(computeNetworkInterfaces, computeNI)
 	}
 
 	// Build up the metadata
@@ -710,23 +1037,51 @@ func (d *driverGCE) RunInstance(c *InstanceConfig) (<-chan error, error) {
 		log.Printf("[DEBUG] using google-managed encryption key for boot disk")
 	}
 
-	computeDisks := []*compute.AttachedDisk{
-		{
-			Type:              "PERSISTENT",
-			Mode:              "READ_WRITE",
-			Kind:              "compute#attachedDisk",
-			Boot:              true,
-			AutoDelete:        false,
-			DiskEncryptionKey: diskEncryptionKey,
-			InitializeParams: &compute.AttachedDiskInitializeParams{
-				SourceImage: c.Image.SelfLink,
-				DiskName:    c.DiskName,
-				DiskSizeGb:  c.DiskSizeGb,
-				DiskType:    fmt.Sprintf("zones/%s/diskTypes/%s", zone.Name, c.DiskType),
-			},
+	bootDisk := &compute.AttachedDisk{
+		Type:              "PERSISTENT",
+		Mode:              "READ_WRITE",
+		Kind:              "compute#attachedDisk",
+		Boot:              true,
+		AutoDelete:        false,
+		DiskEncryptionKey: diskEncryptionKey,
+		InitializeParams: &compute.AttachedDiskInitializeParams{
+			DiskName:              c.DiskName,
+			DiskSizeGb:            c.DiskSizeGb,
+			DiskType:              fmt.Sprintf("zones/%s/diskTypes/%s", zone.Name, c.DiskType),
+			Labels:                c.DiskLabels,
+			ProvisionedIops:       c.DiskProvisionedIops,
+			ProvisionedThroughput: c.DiskProvisionedThroughput,
+			StoragePool:           StoragePoolURL(c.DiskStoragePool, zone.Name),
 		},
 	}
+	bootDisk.InitializeParams.ResourcePolicies, err = ResourcePolicyURLs(c.DiskResourcePolicies, zone.Name)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case c.SourceSnapshot != "":
+		bootDisk.InitializeParams.SourceSnapshot = c.SourceSnapshot
+	case c.SourceDisk != "":
+		// Disks cannot be cloned while attaching them, so clone the source
+		// disk first and attach the clone.
+		d.ui.Message(fmt.Sprintf("Cloning disk %s...", c.Image.Name))
+		clone, err := d.cloneDisk(ctx, zone.Name, c, diskEncryptionKey)
+		if err != nil {
+			return nil, err
+		}
+		bootDisk.Source = clone.SelfLink
+		bootDisk.InitializeParams = nil
+	case c.SourceMachineImage != "":
+		// The disks of the instance come from the machine image.
+		bootDisk = nil
+	default:
+		bootDisk.InitializeParams.SourceImage = c.Image.SelfLink
+	}
 
+	var computeDisks []*compute.AttachedDisk
+	if bootDisk != nil {
+		computeDisks = append(computeDisks, bootDisk)
+	}
 	for _, disk := range c.ExtraBlockDevices {
 		computeDisks = append(computeDisks, disk.GenerateDiskAttachment())
 	}
@@ -736,27 +1091,22 @@ func (d *driverGCE) RunInstance(c *InstanceConfig) (<-chan error, error) {
 		AdvancedMachineFeatures: &compute.AdvancedMachineFeatures{
 			EnableNestedVirtualization: c.EnableNestedVirtualization,
 		},
-		Description:       c.Description,
-		Disks:             computeDisks,
-		GuestAccelerators: guestAccelerators,
-		Labels:            c.Labels,
-		MachineType:       machineType.SelfLink,
+		Description:        c.Description,
+		Disks:              computeDisks,
+		GuestAccelerators:  guestAccelerators,
+		SourceMachineImage: c.SourceMachineImage,
+		Labels:             c.Labels,
+		MachineType:        machineType.SelfLink,
 		Metadata: &compute.Metadata{
 			Items: metadata,
 		},
-		MinCpuPlatform: c.MinCpuPlatform,
-		Name:           c.Name,
-		NetworkInterfaces: []*compute.NetworkInterface{
-			{
-				AccessConfigs: []*compute.AccessConfig{accessconfig},
-				Network:       networkId,
-				Subnetwork:    subnetworkId,
-				NetworkIP:     c.NetworkIP,
-			},
-		},
+		MinCpuPlatform:    c.MinCpuPlatform,
+		Name:              c.Name,
+		NetworkInterfaces: computeNetworkInterfaces,
 		Scheduling: &compute.Scheduling{
 			OnHostMaintenance: c.OnHostMaintenance,
 			Preemptible:       c.Preemptible,
+			ProvisioningModel: c.ProvisioningModel,
 		},
 		ServiceAccounts: []*compute.ServiceAccount{
 			serviceAccount,
@@ -785,6 +1135,11 @@ func (d *driverGCE) RunInstance(c *InstanceConfig) (<-chan error, error) {
 		instance.Scheduling.InstanceTerminationAction = c.InstanceTerminationAction
 	}
 
+	if c.ProvisioningModel == ProvisioningModelSpot && c.InstanceTerminationAction != "" {
+		log.Printf("[DEBUG] setting Spot instance termination action to %s", c.InstanceTerminationAction)
+		instance.Scheduling.InstanceTerminationAction = c.InstanceTerminationAction
+	}
+
 	// Shielded VMs configuration. If the user has set at least one of the
 	// options, the shielded VM configuration will reflect that. If they
 	// don't set any of the options the settings will default to the ones
@@ -801,6 +1156,13 @@ func (d *driverGCE) RunInstance(c *InstanceConfig) (<-chan error, error) {
 		shieldedUiMessage = " Shielded VM"
 	}
 
+	if c.ConfidentialInstanceType != "" {
+		log.Printf("[DEBUG] creating %s confidential instance", c.ConfidentialInstanceType)
+		instance.ConfidentialInstanceConfig = &compute.ConfidentialInstanceConfig{
+			ConfidentialInstanceType: c.ConfidentialInstanceType,
+		}
+	}
+
 	// Node affinity configuration. For example, if you want to build on sole
 	// tenancy nodes.
 	if len(c.NodeAffinities) > 0 {
@@ -811,27 +1173,102 @@ func (d *driverGCE) RunInstance(c *InstanceConfig) (<-chan error, error) {
 	}
 
 	d.ui.Message(fmt.Sprintf("Requesting%s instance creation...", shieldedUiMessage))
-	op, err := d.service.Instances.Insert(d.projectId, zone.Name, &instance).Do()
+	// The clone of the source disk is deleted even once the build is
+	// cancelled, it would be left behind otherwise.
+	cleanupCtx := context.WithoutCancel(ctx)
+	op, err := decodeResult(d.service.Instances.Insert(d.projectId, zone.Name, &instance).RequestId(newRequestID()).Context(ctx).Do())
 	if err != nil {
+		if c.SourceDisk != "" {
+			<-d.deleteZonalDisk(cleanupCtx, zone.Name, c.DiskName)
+		}
 		return nil, err
 	}
 
 	errCh := make(chan error, 1)
+	if c.SourceDisk == "" {
+		go func() {
+			errCh <- d.waitForOperation(ctx, d.projectId, op)
+		}()
+		return errCh, nil
+	}
+
+	// The clone of the source disk is only deleted along with the instance,
+	// delete it if the instance could not be created.
 	go func() {
-		_ = waitForState(errCh, "DONE", d.refreshZoneOp(zone.Name, op))
+		err := d.waitForOperation(ctx, d.projectId, op)
+		if err != nil {
+			<-d.deleteZonalDisk(cleanupCtx, zone.Name, c.DiskName)
+		}
+		errCh <- err
 	}()
 	return errCh, nil
 }
 
-func (d *driverGCE) CreateOrResetWindowsPassword(instance, zone string, c *WindowsPasswordConfig) (<-chan error, error) {
+// cloneDisk creates the boot disk of the instance as a clone of its source
+// disk, and waits for it to be ready.
+func (d *driverGCE) cloneDisk(ctx context.Context, zone string, c *InstanceConfig, diskEncryptionKey *compute.CustomerEncryptionKey) (*compute.Disk, error) {
+	resourcePolicies, err := ResourcePolicyURLs(c.DiskResourcePolicies, zone)
+	if err != nil {
+		return nil, err
+	}
+	op, err := decodeResult(d.service.Disks.Insert(d.projectId, zone, &compute.Disk{
+		DiskEncryptionKey:     diskEncryptionKey,
+		Labels:                c.DiskLabels,
+		Name:                  c.DiskName,
+		ProvisionedIops:       c.DiskProvisionedIops,
+		ProvisionedThroughput: c.DiskProvisionedThroughput,
+		ResourcePolicies:      resourcePolicies,
+		SizeGb:                c.DiskSizeGb,
+		SourceDisk:            c.SourceDisk,
+		StoragePool:           StoragePoolURL(c.DiskStoragePool, zone),
+		Type:                  fmt.Sprintf("zones/%s/diskTypes/%s", zone, c.DiskType),
+	}).RequestId(newRequestID()).Context(ctx).Do())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := d.waitForOperation(ctx, d.projectId, op); err != nil {
+		return nil, err
+	}
+
+	return decodeResult(d.service.Disks.Get(d.projectId, zone, c.DiskName).Context(ctx).Do())
+}
+
+func (d *driverGCE) KeepBootDisk(ctx context.Context, zone, name string) (string, error) {
+	instance, err := decodeResult(d.service.Instances.Get(d.projectId, zone, name).Context(ctx).Do())
+	if err != nil {
+		return "", err
+	}
+
+	for _, disk := range instance.Disks {
+		if !disk.Boot {
+			continue
+		}
+		if disk.AutoDelete {
+			op, err := decodeResult(d.service.Instances.SetDiskAutoDelete(d.projectId, zone, name, false, disk.DeviceName).RequestId(newRequestID()).Context(ctx).Do())
+			if err != nil {
+				return "", err
+			}
+			if err := d.waitForOperation(ctx, d.projectId, op); err != nil {
+				return "", err
+			}
+		}
+		source := strings.Split(disk.Source, "/")
+		return source[len(source)-1], nil
+	}
+
+	return "", fmt.Errorf("instance %s has no boot disk", name)
+}
+
+func (d *driverGCE) CreateOrResetWindowsPassword(ctx context.Context, instance, zone string, c *WindowsPasswordConfig) (<-chan error, error) {
 
 	errCh := make(chan error, 1)
-	go d.createWindowsPassword(errCh, instance, zone, c)
+	go d.createWindowsPassword(ctx, errCh, instance, zone, c)
 
 	return errCh, nil
 }
 
-func (d *driverGCE) createWindowsPassword(errCh chan<- error, name, zone string, c *WindowsPasswordConfig) {
+func (d *driverGCE) createWindowsPassword(ctx context.Context, errCh chan<- error, name, zone string, c *WindowsPasswordConfig) {
 
 	data, err := json.Marshal(c)
 
@@ -841,33 +1278,29 @@ func (d *driverGCE) createWindowsPassword(errCh chan<- error, name, zone string,
 	}
 	dCopy := string(data)
 
-	instance, err := d.service.Instances.Get(d.projectId, zone, name).Do()
+	instance, err := decodeResult(d.service.Instances.Get(d.projectId, zone, name).Context(ctx).Do())
 	if err != nil {
 		errCh <- err
 		return
 	}
 	instance.Metadata.Items = append(instance.Metadata.Items, &compute.MetadataItems{Key: "windows-keys", Value: &dCopy})
 
-	op, err := d.service.Instances.SetMetadata(d.projectId, zone, name, &compute.Metadata{
+	op, err := decodeResult(d.service.Instances.SetMetadata(d.projectId, zone, name, &compute.Metadata{
 		Fingerprint: instance.Metadata.Fingerprint,
 		Items:       instance.Metadata.Items,
-	}).Do()
+	}).RequestId(newRequestID()).Context(ctx).Do())
 
 	if err != nil {
 		errCh <- err
 		return
 	}
 
-	newErrCh := make(chan error, 1)
-	go func() {
-		_ = waitForState(newErrCh, "DONE", d.refreshZoneOp(zone, op))
-	}()
-
-	select {
-	case err = <-newErrCh:
-	case <-time.After(time.Second * 30):
+	opCtx, cancel := context.WithTimeout(ctx, time.Second*30)
+	err = d.waitForOperation(opCtx, d.projectId, op)
+	if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
 		err = errors.New("time out while waiting for instance to create")
 	}
+	cancel()
 
 	if err != nil {
 		errCh <- err
@@ -879,7 +1312,7 @@ func (d *driverGCE) createWindowsPassword(errCh chan<- error, name, zone string,
 	random := rand.Reader
 
 	for time.Now().Before(timeout) {
-		if passwordResponses, err := d.getPasswordResponses(zone, name); err == nil {
+		if passwordResponses, err := d.getPasswordResponses(ctx, zone, name); err == nil {
 			for _, response := range passwordResponses {
 				if response.Modulus == c.Modulus {
 
@@ -903,15 +1336,18 @@ func (d *driverGCE) createWindowsPassword(errCh chan<- error, name, zone string,
 			}
 		}
 
-		time.Sleep(2 * time.Second)
+		if err := sleepContext(ctx, 2*time.Second); err != nil {
+			errCh <- err
+			return
+		}
 	}
 	err = errors.New("Could not retrieve password. Timed out.")
 
 	errCh <- err
 }
 
-func (d *driverGCE) getPasswordResponses(zone, instance string) ([]windowsPasswordResponse, error) {
-	output, err := d.service.Instances.GetSerialPortOutput(d.projectId, zone, instance).Port(4).Do()
+func (d *driverGCE) getPasswordResponses(ctx context.Context, zone, instance string) ([]windowsPasswordResponse, error) {
+	output, err := decodeResult(d.service.Instances.GetSerialPortOutput(d.projectId, zone, instance).Port(4).Context(ctx).Do())
 
 	if err != nil {
 		return nil, err
@@ -933,7 +1369,7 @@ func (d *driverGCE) getPasswordResponses(zone, instance string) ([]windowsPasswo
 	return passwordResponses, nil
 }
 
-func (d *driverGCE) ImportOSLoginSSHKey(user, sshPublicKey string, expirationTimeUsec *int64) (*oslogin.LoginProfile, error) {
+func (d *driverGCE) ImportOSLoginSSHKey(ctx context.Context, user, sshPublicKey string, expirationTimeUsec *int64) (*oslogin.LoginProfile, error) {
 	parent := fmt.Sprintf("users/%s", user)
 
 	sshKey := &oslogin.SshPublicKey{
@@ -946,16 +1382,19 @@ func (d *driverGCE) ImportOSLoginSSHKey(user, sshPublicKey string, expirationTim
 	const maxRetries = 10
 	var err error
 	for i := 0; i < maxRetries; i++ {
-		resp, err := d.osLoginService.Users.ImportSshPublicKey(parent, sshKey).Do()
+		resp, err := decodeResult(d.osLoginService.Users.ImportSshPublicKey(parent, sshKey).Context(ctx).Do())
 		if err == nil {
 			return resp.LoginProfile, nil
 		}
 		// Retry on concurrent mutation errors
-		if gErr, ok := err.(*googleapi.Error); ok && gErr.Code == 409 && (i+1 < maxRetries) {
+		var gErr *googleapi.Error
+		if errors.As(err, &gErr) && gErr.Code == 409 && (i+1 < maxRetries) {
 			log.Printf("ImportSshPublicKey conflict (try %d/%d): %v", i+1, maxRetries, err)
 			sleepSecs := retrySleepSeconds()
 			// Sleep between 5-15 seconds (randomly chosen) before retry
-			time.Sleep(time.Duration(sleepSecs) * time.Second)
+			if err := sleepContext(ctx, time.Duration(sleepSecs)*time.Second); err != nil {
+				return nil, err
+			}
 		} else {
 			break
 		}
@@ -970,9 +1409,22 @@ func retrySleepSeconds() int {
 	return int(offset.Int64()) + 5
 }
 
-func (d *driverGCE) DeleteOSLoginSSHKey(user, fingerprint string) error {
+// sleepContext pauses for the given duration, or until ctx is done, in which
+// case it returns the error of the context.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (d *driverGCE) DeleteOSLoginSSHKey(ctx context.Context, user, fingerprint string) error {
 	name := fmt.Sprintf("users/%s/sshPublicKeys/%s", user, fingerprint)
-	_, err := d.osLoginService.Users.SshPublicKeys.Delete(name).Do()
+	_, err := decodeResult(d.osLoginService.Users.SshPublicKeys.Delete(name).Context(ctx).Do())
 	if err != nil {
 		return err
 	}
@@ -980,17 +1432,17 @@ func (d *driverGCE) DeleteOSLoginSSHKey(user, fingerprint string) error {
 	return nil
 }
 
-func (d *driverGCE) WaitForInstance(state, zone, name string) <-chan error {
+func (d *driverGCE) WaitForInstance(ctx context.Context, state, zone, name string) <-chan error {
 	errCh := make(chan error, 1)
 	go func() {
-		_ = waitForState(errCh, state, d.refreshInstanceState(zone, name))
+		_ = waitForState(ctx, errCh, state, d.pollBackoff, d.refreshInstanceState(zone, name))
 	}()
 	return errCh
 }
 
 func (d *driverGCE) refreshInstanceState(zone, name string) stateRefreshFunc {
-	return func() (string, error) {
-		instance, err := d.service.Instances.Get(d.projectId, zone, name).Do()
+	return func(ctx context.Context) (string, error) {
+		instance, err := decodeResult(d.service.Instances.Get(d.projectId, zone, name).Context(ctx).Do())
 		if err != nil {
 			return "", err
 		}
@@ -998,101 +1450,9 @@ func (d *driverGCE) refreshInstanceState(zone, name string) stateRefreshFunc {
 	}
 }
 
-func (d *driverGCE) refreshGlobalOp(project string, op *compute.Operation) stateRefreshFunc {
-	return func() (string, error) {
-		newOp, err := d.service.GlobalOperations.Get(project, op.Name).Do()
-		if err != nil {
-			return "", err
-		}
-
-		// If the op is done, check for errors
-		err = nil
-		if newOp.Status == "DONE" {
-			if newOp.Error != nil {
-				for _, e := range newOp.Error.Errors {
-					err = packersdk.MultiErrorAppend(err, errors.New(e.Message))
-				}
-			}
-		}
-
-		return newOp.Status, err
-	}
-}
-
-func (d *driverGCE) refreshZoneOp(zone string, op *compute.Operation) stateRefreshFunc {
-	return func() (string, error) {
-		newOp, err := d.service.ZoneOperations.Get(d.projectId, zone, op.Name).Do()
-		if err != nil {
-			return "", err
-		}
-
-		// If the op is done, check for errors
-		err = nil
-		if newOp.Status == "DONE" {
-			if newOp.Error != nil {
-				for _, e := range newOp.Error.Errors {
-					err = packersdk.MultiErrorAppend(err, errors.New(e.Message))
-				}
-			}
-		}
-
-		return newOp.Status, err
-	}
-}
-
-func (d *driverGCE) refreshRegionOp(region string, op *compute.Operation) stateRefreshFunc {
-	return func() (string, error) {
-		newOp, err := d.service.RegionOperations.Get(d.projectId, region, op.Name).Do()
-		if err != nil {
-			return "", err
-		}
-
-		// If the op is done, check for errors
-		err = nil
-		if newOp.Status == "DONE" {
-			if newOp.Error != nil {
-				for _, e := range newOp.Error.Errors {
-					err = packersdk.MultiErrorAppend(err, errors.New(e.Message))
-				}
-			}
-		}
-
-		return newOp.Status, err
-	}
-}
+func (d *driverGCE) AddToInstanceMetadata(ctx context.Context, zone string, name string, metadata map[string]string) error {
 
-// used in conjunction with waitForState.
-type stateRefreshFunc func() (string, error)
-
-// waitForState will spin in a loop forever waiting for state to
-// reach a certain target.
-func waitForState(errCh chan<- error, target string, refresh stateRefreshFunc) error {
-	ctx := context.TODO()
-	err := retry.Config{
-		RetryDelay: (&retry.Backoff{InitialBackoff: 2 * time.Second, MaxBackoff: 2 * time.Second, Multiplier: 2}).Linear,
-	}.Run(ctx, func(ctx context.Context) error {
-		state, err := refresh()
-		if err != nil {
-			// If there is an error, but the state is done, the function will spin
-			// until timeout. Instead, emit the error immediately and return.
-			if state == target {
-				errCh <- err
-				return nil
-			}
-			return err
-		}
-		if state == target {
-			return nil
-		}
-		return fmt.Errorf("retrying for state %s, got %s", target, state)
-	})
-	errCh <- err
-	return err
-}
-
-func (d *driverGCE) AddToInstanceMetadata(zone string, name string, metadata map[string]string) error {
-
-	instance, err := d.service.Instances.Get(d.projectId, zone, name).Do()
+	instance, err := decodeResult(d.service.Instances.Get(d.projectId, zone, name).Context(ctx).Do())
 	if err != nil {
 		return err
 	}
@@ -1109,49 +1469,72 @@ func (d *driverGCE) AddToInstanceMetadata(zone string, name string, metadata map
 
 	instance.Metadata.Items = append(instance.Metadata.Items, metadataForInstance...)
 
-	op, err := d.service.Instances.SetMetadata(d.projectId, zone, name, &compute.Metadata{
+	op, err := decodeResult(d.service.Instances.SetMetadata(d.projectId, zone, name, &compute.Metadata{
 		Fingerprint: instance.Metadata.Fingerprint,
 		Items:       instance.Metadata.Items,
-	}).Do()
+	}).RequestId(newRequestID()).Context(ctx).Do())
 
 	if err != nil {
 		return err
 	}
 
-	newErrCh := make(chan error, 1)
+	opCtx, cancel := context.WithTimeout(ctx, time.Second*30)
+	defer cancel()
+	err = d.waitForOperation(opCtx, d.projectId, op)
+	if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+		err = errors.New("time out while waiting for instance metadata to update")
+	}
+	return err
+}
 
-	go func() {
-		err = waitForState(newErrCh, "DONE", d.refreshZoneOp(zone, op))
+// GetTokenInfo gets the information about the token used for authentication
+func (d *driverGCE) GetTokenInfo(ctx context.Context) (*oauth2_svc.Tokeninfo, error) {
+	return d.oauth2Service.Tokeninfo().Context(ctx).Do()
+}
 
-		select {
-		case err = <-newErrCh:
-		case <-time.After(time.Second * 30):
-			err = errors.New("time out while waiting for instance to create")
-		}
-	}()
+// GetTokenSource gets the token source resolved from the same client options
+// the Google API services were created with.
+func (d *driverGCE) GetTokenSource(ctx context.Context) (oauth2.TokenSource, error) {
+	creds, err := transport.Creds(ctx, d.clientOptions...)
+	if err != nil {
+		return nil, err
+	}
+	return creds.TokenSource, nil
+}
 
+func (d *driverGCE) UploadToBucket(ctx context.Context, bucket, objectName string, data io.Reader) (string, error) {
+	storageObject, err := decodeResult(d.storageService.Objects.Insert(bucket, &storage.Object{Name: objectName}).Media(data).Context(ctx).Do())
 	if err != nil {
-		newErrCh <- err
-		return err
+		return "", err
 	}
 
-	return nil
+	return storageObject.SelfLink, nil
 }
 
-// GetTokenInfo gets the information about the token used for authentication
-func (d *driverGCE) GetTokenInfo() (*oauth2_svc.Tokeninfo, error) {
-	return d.oauth2Service.Tokeninfo().Do()
+func (d *driverGCE) DeleteFromBucket(ctx context.Context, bucket, objectName string) error {
+	return decodeAPIError(d.storageService.Objects.Delete(bucket, objectName).Context(ctx).Do())
 }
 
-func (d *driverGCE) UploadToBucket(bucket, objectName string, data io.Reader) (string, error) {
-	storageObject, err := d.storageService.Objects.Insert(bucket, &storage.Object{Name: objectName}).Media(data).Do()
+func (d *driverGCE) DownloadFromBucket(ctx context.Context, bucket, objectName string) ([]byte, error) {
+	resp, err := d.storageService.Objects.Get(bucket, objectName).Context(ctx).Download()
 	if err != nil {
-		return "", err
+		return nil, err
 	}
+	defer resp.Body.Close()
 
-	return storageObject.SelfLink, nil
+	return io.ReadAll(resp.Body)
 }
 
-func (d *driverGCE) DeleteFromBucket(bucket, objectName string) error {
-	return d.storageService.Objects.Delete(bucket, objectName).Do()
+func (d *driverGCE) AccessSecretVersion(ctx context.Context, name string) ([]byte, error) {
+	client, err := secretmanager.NewClient(ctx, buildServiceSpecificOptions(d.clientOptions, d.customEndpoints, "secretmanager")...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create secret manager client: %w", err)
+	}
+	defer client.Close()
+
+	secret, err := client.AccessSecretVersion(ctx, &secretmanagerpb.AccessSecretVersionRequest{Name: name})
+	if err != nil {
+		return nil, err
+	}
+	return secret.GetPayload().GetData(), nil
 }
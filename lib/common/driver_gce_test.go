@@ -0,0 +1,59 @@
+// Copyright IBM Corp. 2013, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	compute "google.golang.org/api/compute/v1"
+	"google.golang.org/api/option"
+)
+
+func TestDriverGCE_InstancePreempted(t *testing.T) {
+	link := "https://compute.googleapis.com/compute/v1/projects/project/zones/zone/instances/packer"
+	// The instance of the previous attempt, 1, was preempted.
+	preemptions := response{status: 200, body: `{"items": [
+		{"operationType": "compute.instances.preempted", "targetLink": "` + link + `", "targetId": "1"}
+	]}`}
+	inserts := response{status: 200, body: `{"items": [
+		{"operationType": "insert", "targetLink": "` + link + `", "targetId": "1", "insertTime": "2026-01-01T10:00:00.000-08:00"},
+		{"operationType": "insert", "targetLink": "` + link + `", "targetId": "2", "insertTime": "2026-01-01T11:00:00.000-08:00"},
+		{"operationType": "insert", "targetLink": "` + link + `-other", "targetId": "3", "insertTime": "2026-01-01T12:00:00.000-08:00"}
+	]}`}
+
+	cases := []struct {
+		name      string
+		responses []response
+		preempted bool
+	}{
+		{"running", []response{{status: 200, body: `{"id": "2", "status": "RUNNING"}`}}, false},
+		{"preempted", []response{{status: 200, body: `{"id": "1", "status": "TERMINATED"}`}, preemptions}, true},
+		{"stopped after a preemption", []response{{status: 200, body: `{"id": "2", "status": "TERMINATED"}`}, preemptions}, false},
+		{"deleted after a preemption", []response{{status: 404}, inserts, preemptions}, false},
+		{"deleted by a preemption", []response{{status: 404}, inserts, {status: 200, body: `{"items": [
+			{"operationType": "compute.instances.preempted", "targetLink": "` + link + `", "targetId": "2"}
+		]}`}}, true},
+		{"never created", []response{{status: 404}, {status: 200, body: `{}`}}, false},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, requests, _ := flakyServer(t, tt.responses...)
+			service, err := compute.NewService(context.Background(),
+				option.WithEndpoint(srv.URL+"/compute/v1/"),
+				option.WithHTTPClient(&http.Client{Transport: srv.Client().Transport}),
+				option.WithoutAuthentication())
+			assert.NoError(t, err)
+			d := &driverGCE{projectId: "project", service: service}
+
+			preempted, err := d.InstancePreempted(context.Background(), "zone", "packer")
+			assert.NoError(t, err)
+			assert.Equal(t, tt.preempted, preempted)
+			assert.Len(t, *requests, len(tt.responses))
+		})
+	}
+}
@@ -4,9 +4,11 @@
 package common
 
 import (
+	"context"
 	"fmt"
 	"io"
 
+	"golang.org/x/oauth2"
 	compute "google.golang.org/api/compute/v1"
 	oauth2_svc "google.golang.org/api/oauth2/v2"
 	oslogin "google.golang.org/api/oslogin/v1"
@@ -21,6 +23,7 @@ type DriverMock struct {
 
 	CreateImageProjectId      string
 	CreateImageSpec           *compute.Image
+	CreateImageSpecs          []*compute.Image
 	CreateImageReturnDiskSize int64
 	CreateImageReturnSelfLink string
 	CreateImageErrCh          <-chan error
@@ -29,11 +32,66 @@ type DriverMock struct {
 	DeprecatedProjectName string
 	DeprecatedImageName   string
 	DeprecatedImageStatus *compute.DeprecationStatus
+	DeprecatedImages      map[string]*compute.DeprecationStatus
+
+	ListImagesInFamilyProjectId string
+	ListImagesInFamilyFamily    string
+	ListImagesInFamilyResult    []*compute.Image
+	ListImagesInFamilyErr       error
+
+	AddImageIAMBindingsProjectId string
+	AddImageIAMBindingsName      string
+	AddImageIAMBindingsBindings  []IAMBinding
+	AddImageIAMBindingsErr       error
+
+	RemoveImageIAMBindingsProjectId string
+	RemoveImageIAMBindingsName      string
+	RemoveImageIAMBindingsBindings  []IAMBinding
+	RemoveImageIAMBindingsErr       error
 
 	DeleteProjectId  string
 	DeleteImageName  string
+	DeleteImageNames []string
 	DeleteImageErrCh <-chan error
 
+	CreateMachineImageProjectId string
+	CreateMachineImageSpec      *compute.MachineImage
+	CreateMachineImageErrCh     <-chan error
+	CreateMachineImageResultCh  <-chan *compute.MachineImage
+
+	DeleteMachineImageProjectId string
+	DeleteMachineImageName      string
+	DeleteMachineImageErrCh     <-chan error
+
+	GetMachineImageProjectId string
+	GetMachineImageName      string
+	GetMachineImageResult    *compute.MachineImage
+	GetMachineImageErr       error
+
+	CreateSnapshotProjectId string
+	CreateSnapshotSpec      *compute.Snapshot
+	CreateSnapshotErrCh     <-chan error
+	CreateSnapshotResultCh  <-chan *compute.Snapshot
+
+	DeleteSnapshotProjectId string
+	DeleteSnapshotName      string
+	DeleteSnapshotErrCh     <-chan error
+
+	GetSnapshotProjectId string
+	GetSnapshotName      string
+	GetSnapshotResult    *compute.Snapshot
+	GetSnapshotErr       error
+
+	StopInstanceZone  string
+	StopInstanceName  string
+	StopInstanceErrCh <-chan error
+	StopInstanceErr   error
+
+	KeepBootDiskZone   string
+	KeepBootDiskName   string
+	KeepBootDiskResult string
+	KeepBootDiskErr    error
+
 	DeleteInstanceZone  string
 	DeleteInstanceName  string
 	DeleteInstanceErrCh <-chan error
@@ -48,6 +106,15 @@ type DriverMock struct {
 	DeleteFromBucketObjectName string
 	DeleteFromBucketErr        error
 
+	DownloadFromBucketBucket     string
+	DownloadFromBucketObjectName string
+	DownloadFromBucketResult     []byte
+	DownloadFromBucketErr        error
+
+	AccessSecretVersionName   string
+	AccessSecretVersionResult []byte
+	AccessSecretVersionErr    error
+
 	GetDiskName   string
 	GetDiskZone   string
 	GetDiskResult *compute.Disk
@@ -71,6 +138,12 @@ type DriverMock struct {
 	GetInstanceMetadataResult string
 	GetInstanceMetadataErr    error
 
+	GetGuestAttributesZone      string
+	GetGuestAttributesName      string
+	GetGuestAttributesNamespace string
+	GetGuestAttributesResult    map[string]string
+	GetGuestAttributesErr       error
+
 	GetProjectMetadataZone   string
 	GetProjectMetadataKey    string
 	GetProjectMetadataResult string
@@ -79,21 +152,48 @@ type DriverMock struct {
 	GetTokenInfoResult *oauth2_svc.Tokeninfo
 	GetTokenInfoErr    error
 
+	GetTokenSourceResult oauth2.TokenSource
+	GetTokenSourceErr    error
+
 	GetNatIPZone   string
 	GetNatIPName   string
+	GetNatIPNic    int
 	GetNatIPResult string
 	GetNatIPErr    error
 
 	GetInternalIPZone   string
 	GetInternalIPName   string
+	GetInternalIPNic    int
 	GetInternalIPResult string
 	GetInternalIPErr    error
 
+	GetInternalIPv6Zone   string
+	GetInternalIPv6Name   string
+	GetInternalIPv6Nic    int
+	GetInternalIPv6Result string
+	GetInternalIPv6Err    error
+
+	GetExternalIPv6Zone   string
+	GetExternalIPv6Name   string
+	GetExternalIPv6Nic    int
+	GetExternalIPv6Result string
+	GetExternalIPv6Err    error
+
 	GetSerialPortOutputZone   string
 	GetSerialPortOutputName   string
 	GetSerialPortOutputResult string
 	GetSerialPortOutputErr    error
 
+	// GetSerialPortOutputFromContents is the whole output of the serial
+	// port, returned from the requested offset.
+	GetSerialPortOutputFromContents string
+	GetSerialPortOutputFromErr      error
+
+	InstancePreemptedZone   string
+	InstancePreemptedName   string
+	InstancePreemptedResult bool
+	InstancePreemptedErr    error
+
 	ImageExistsProjectId string
 	ImageExistsName      string
 	ImageExistsResult    bool
@@ -101,6 +201,10 @@ type DriverMock struct {
 	RunInstanceConfig *InstanceConfig
 	RunInstanceErrCh  <-chan error
 	RunInstanceErr    error
+	// RunInstanceZones records the zone of every instance creation, and
+	// RunInstanceZoneErrs the error the creation reports in a given zone.
+	RunInstanceZones    []string
+	RunInstanceZoneErrs map[string]error
 
 	CreateOrResetWindowsPasswordZone     string
 	CreateOrResetWindowsPasswordInstance string
@@ -130,9 +234,10 @@ type DriverMock struct {
 	ImportOSLoginSSHKeyExpirationTime *int64
 }
 
-func (d *DriverMock) CreateImage(project string, imageSpec *compute.Image) (<-chan *Image, <-chan error) {
+func (d *DriverMock) CreateImage(ctx context.Context, project string, imageSpec *compute.Image) (<-chan *Image, <-chan error) {
 	d.CreateImageProjectId = project
 	d.CreateImageSpec = imageSpec
+	d.CreateImageSpecs = append(d.CreateImageSpecs, imageSpec)
 	resultCh := d.CreateImageResultCh
 	if resultCh == nil {
 		ch := make(chan *Image, 1)
@@ -190,16 +295,21 @@ func (d *DriverMock) CreateImageFromRaw(
 	return nil, nil
 }
 
-func (d *DriverMock) SetImageDeprecationStatus(project, name string, deprecationStatus *compute.DeprecationStatus) error {
+func (d *DriverMock) SetImageDeprecationStatus(ctx context.Context, project, name string, deprecationStatus *compute.DeprecationStatus) error {
 	d.DeprecatedProjectName = project
 	d.DeprecatedImageName = name
 	d.DeprecatedImageStatus = deprecationStatus
+	if d.DeprecatedImages == nil {
+		d.DeprecatedImages = make(map[string]*compute.DeprecationStatus)
+	}
+	d.DeprecatedImages[name] = deprecationStatus
 	return nil
 }
 
-func (d *DriverMock) DeleteImage(project, name string) <-chan error {
+func (d *DriverMock) DeleteImage(ctx context.Context, project, name string) <-chan error {
 	d.DeleteProjectId = project
 	d.DeleteImageName = name
+	d.DeleteImageNames = append(d.DeleteImageNames, name)
 
 	resultCh := d.DeleteImageErrCh
 	if resultCh == nil {
@@ -211,7 +321,139 @@ func (d *DriverMock) DeleteImage(project, name string) <-chan error {
 	return resultCh
 }
 
-func (d *DriverMock) DeleteInstance(zone, name string) (<-chan error, error) {
+func (d *DriverMock) ListImagesInFamily(ctx context.Context, project, family string) ([]*compute.Image, error) {
+	d.ListImagesInFamilyProjectId = project
+	d.ListImagesInFamilyFamily = family
+	return d.ListImagesInFamilyResult, d.ListImagesInFamilyErr
+}
+
+func (d *DriverMock) AddImageIAMBindings(ctx context.Context, project, name string, bindings []IAMBinding) error {
+	d.AddImageIAMBindingsProjectId = project
+	d.AddImageIAMBindingsName = name
+	d.AddImageIAMBindingsBindings = bindings
+	return d.AddImageIAMBindingsErr
+}
+
+func (d *DriverMock) RemoveImageIAMBindings(ctx context.Context, project, name string, bindings []IAMBinding) error {
+	d.RemoveImageIAMBindingsProjectId = project
+	d.RemoveImageIAMBindingsName = name
+	d.RemoveImageIAMBindingsBindings = bindings
+	return d.RemoveImageIAMBindingsErr
+}
+
+func (d *DriverMock) CreateMachineImage(ctx context.Context, project string, machineImageSpec *compute.MachineImage) (<-chan *compute.MachineImage, <-chan error) {
+	d.CreateMachineImageProjectId = project
+	d.CreateMachineImageSpec = machineImageSpec
+	resultCh := d.CreateMachineImageResultCh
+	if resultCh == nil {
+		ch := make(chan *compute.MachineImage, 1)
+		ch <- &compute.MachineImage{
+			Description:       machineImageSpec.Description,
+			Labels:            machineImageSpec.Labels,
+			Name:              machineImageSpec.Name,
+			SelfLink:          fmt.Sprintf("https://www.googleapis.com/compute/v1/projects/%s/global/machineImages/%s", project, machineImageSpec.Name),
+			SourceInstance:    machineImageSpec.SourceInstance,
+			StorageLocations:  machineImageSpec.StorageLocations,
+			TotalStorageBytes: 25 << 30,
+		}
+		close(ch)
+		resultCh = ch
+	}
+
+	errCh := d.CreateMachineImageErrCh
+	if errCh == nil {
+		ch := make(chan error)
+		close(ch)
+		errCh = ch
+	}
+
+	return resultCh, errCh
+}
+
+func (d *DriverMock) DeleteMachineImage(ctx context.Context, project, name string) <-chan error {
+	d.DeleteMachineImageProjectId = project
+	d.DeleteMachineImageName = name
+
+	resultCh := d.DeleteMachineImageErrCh
+	if resultCh == nil {
+		ch := make(chan error)
+		close(ch)
+		resultCh = ch
+	}
+
+	return resultCh
+}
+
+func (d *DriverMock) GetMachineImage(ctx context.Context, project, name string) (*compute.MachineImage, error) {
+	d.GetMachineImageProjectId = project
+	d.GetMachineImageName = name
+	return d.GetMachineImageResult, d.GetMachineImageErr
+}
+
+func (d *DriverMock) CreateSnapshot(ctx context.Context, project string, snapshotSpec *compute.Snapshot) (<-chan *compute.Snapshot, <-chan error) {
+	d.CreateSnapshotProjectId = project
+	d.CreateSnapshotSpec = snapshotSpec
+	resultCh := d.CreateSnapshotResultCh
+	if resultCh == nil {
+		ch := make(chan *compute.Snapshot, 1)
+		ch <- &compute.Snapshot{
+			Description:      snapshotSpec.Description,
+			DiskSizeGb:       25,
+			Labels:           snapshotSpec.Labels,
+			Name:             snapshotSpec.Name,
+			SelfLink:         fmt.Sprintf("https://www.googleapis.com/compute/v1/projects/%s/global/snapshots/%s", project, snapshotSpec.Name),
+			SourceDisk:       snapshotSpec.SourceDisk,
+			StorageLocations: snapshotSpec.StorageLocations,
+		}
+		close(ch)
+		resultCh = ch
+	}
+
+	errCh := d.CreateSnapshotErrCh
+	if errCh == nil {
+		ch := make(chan error)
+		close(ch)
+		errCh = ch
+	}
+
+	return resultCh, errCh
+}
+
+func (d *DriverMock) DeleteSnapshot(ctx context.Context, project, name string) <-chan error {
+	d.DeleteSnapshotProjectId = project
+	d.DeleteSnapshotName = name
+
+	resultCh := d.DeleteSnapshotErrCh
+	if resultCh == nil {
+		ch := make(chan error)
+		close(ch)
+		resultCh = ch
+	}
+
+	return resultCh
+}
+
+func (d *DriverMock) GetSnapshot(ctx context.Context, project, name string) (*compute.Snapshot, error) {
+	d.GetSnapshotProjectId = project
+	d.GetSnapshotName = name
+	return d.GetSnapshotResult, d.GetSnapshotErr
+}
+
+func (d *DriverMock) StopInstance(ctx context.Context, zone, name string) (<-chan error, error) {
+	d.StopInstanceZone = zone
+	d.StopInstanceName = name
+
+	resultCh := d.StopInstanceErrCh
+	if resultCh == nil {
+		ch := make(chan error)
+		close(ch)
+		resultCh = ch
+	}
+
+	return resultCh, d.StopInstanceErr
+}
+
+func (d *DriverMock) DeleteInstance(ctx context.Context, zone, name string) (<-chan error, error) {
 	d.DeleteInstanceZone = zone
 	d.DeleteInstanceName = name
 
@@ -225,14 +467,27 @@ func (d *DriverMock) DeleteInstance(zone, name string) (<-chan error, error) {
 	return resultCh, d.DeleteInstanceErr
 }
 
-func (d *DriverMock) DeleteFromBucket(bucket, objectName string) error {
+func (d *DriverMock) DeleteFromBucket(ctx context.Context, bucket, objectName string) error {
 	d.DeleteFromBucketBucket = bucket
 	d.DeleteFromBucketObjectName = objectName
 
 	return d.DeleteFromBucketErr
 }
 
-func (d *DriverMock) CreateDisk(diskConfig BlockDevice) (<-chan *compute.Disk, <-chan error) {
+func (d *DriverMock) DownloadFromBucket(ctx context.Context, bucket, objectName string) ([]byte, error) {
+	d.DownloadFromBucketBucket = bucket
+	d.DownloadFromBucketObjectName = objectName
+
+	return d.DownloadFromBucketResult, d.DownloadFromBucketErr
+}
+
+func (d *DriverMock) AccessSecretVersion(ctx context.Context, name string) ([]byte, error) {
+	d.AccessSecretVersionName = name
+
+	return d.AccessSecretVersionResult, d.AccessSecretVersionErr
+}
+
+func (d *DriverMock) CreateDisk(ctx context.Context, diskConfig BlockDevice) (<-chan *compute.Disk, <-chan error) {
 	d.CreateDiskConfig = diskConfig
 
 	resultCh := d.CreateDiskResultCh
@@ -252,7 +507,7 @@ func (d *DriverMock) CreateDisk(diskConfig BlockDevice) (<-chan *compute.Disk, <
 	return resultCh, errCh
 }
 
-func (d *DriverMock) DeleteDisk(zone, name string) <-chan error {
+func (d *DriverMock) DeleteDisk(ctx context.Context, zone, name string) <-chan error {
 	d.DeleteDiskZone = zone
 	d.DeleteDiskName = name
 
@@ -271,71 +526,127 @@ func (d *DriverMock) DeleteDisk(zone, name string) <-chan error {
 	return resultCh
 }
 
-func (d *DriverMock) GetDisk(zoneOrRegion, name string) (*compute.Disk, error) {
+func (d *DriverMock) GetDisk(ctx context.Context, zoneOrRegion, name string) (*compute.Disk, error) {
 	d.GetDiskZone = zoneOrRegion
 	d.GetDiskName = name
 
 	return d.GetDiskResult, d.GetDiskErr
 }
 
-func (d *DriverMock) GetImage(name string, fromFamily bool) (*Image, error) {
+func (d *DriverMock) GetImage(ctx context.Context, name string, fromFamily bool) (*Image, error) {
 	d.GetImageName = name
 	d.GetImageFromFamily = fromFamily
 	return d.GetImageResult, d.GetImageErr
 }
-func (d *DriverMock) GetImageFromProjects(projects []string, name string, fromFamily bool) (*Image, error) {
+func (d *DriverMock) GetImageFromProjects(ctx context.Context, projects []string, name string, fromFamily bool) (*Image, error) {
 	d.GetImageSourceProjects = projects
 	d.GetImageFromProjectName = name
 	d.GetImageFromProjectFromFamily = fromFamily
 	return d.GetImageFromProjectResult, d.GetImageFromProjectErr
 }
 
-func (d *DriverMock) GetImageFromProject(project, name string, fromFamily bool) (*Image, error) {
+func (d *DriverMock) GetImageFromProject(ctx context.Context, project, name string, fromFamily bool) (*Image, error) {
 	d.GetImageFromProjectProject = project
 	d.GetImageFromProjectName = name
 	d.GetImageFromProjectFromFamily = fromFamily
 	return d.GetImageFromProjectResult, d.GetImageFromProjectErr
 }
 
-func (d *DriverMock) GetProjectMetadata(zone, key string) (string, error) {
+func (d *DriverMock) GetProjectMetadata(ctx context.Context, zone, key string) (string, error) {
 	d.GetProjectMetadataZone = zone
 	d.GetProjectMetadataKey = key
 	return d.GetProjectMetadataResult, d.GetProjectMetadataErr
 }
 
-func (d *DriverMock) GetInstanceMetadata(zone, name, key string) (string, error) {
+func (d *DriverMock) GetInstanceMetadata(ctx context.Context, zone, name, key string) (string, error) {
 	d.GetInstanceMetadataZone = zone
 	d.GetInstanceMetadataName = name
 	d.GetInstanceMetadataKey = key
 	return d.GetInstanceMetadataResult, d.GetInstanceMetadataErr
 }
 
-func (d *DriverMock) GetNatIP(zone, name string) (string, error) {
+func (d *DriverMock) GetGuestAttributes(ctx context.Context, zone, name, namespace string) (map[string]string, error) {
+	d.GetGuestAttributesZone = zone
+	d.GetGuestAttributesName = name
+	d.GetGuestAttributesNamespace = namespace
+	return d.GetGuestAttributesResult, d.GetGuestAttributesErr
+}
+
+func (d *DriverMock) GetNatIP(ctx context.Context, zone, name string, nic int) (string, error) {
 	d.GetNatIPZone = zone
 	d.GetNatIPName = name
+	d.GetNatIPNic = nic
 	return d.GetNatIPResult, d.GetNatIPErr
 }
 
-func (d *DriverMock) GetInternalIP(zone, name string) (string, error) {
+func (d *DriverMock) GetInternalIP(ctx context.Context, zone, name string, nic int) (string, error) {
 	d.GetInternalIPZone = zone
 	d.GetInternalIPName = name
+	d.GetInternalIPNic = nic
 	return d.GetInternalIPResult, d.GetInternalIPErr
 }
 
-func (d *DriverMock) GetSerialPortOutput(zone, name string) (string, error) {
+func (d *DriverMock) GetInternalIPv6(ctx context.Context, zone, name string, nic int) (string, error) {
+	d.GetInternalIPv6Zone = zone
+	d.GetInternalIPv6Name = name
+	d.GetInternalIPv6Nic = nic
+	return d.GetInternalIPv6Result, d.GetInternalIPv6Err
+}
+
+func (d *DriverMock) GetExternalIPv6(ctx context.Context, zone, name string, nic int) (string, error) {
+	d.GetExternalIPv6Zone = zone
+	d.GetExternalIPv6Name = name
+	d.GetExternalIPv6Nic = nic
+	return d.GetExternalIPv6Result, d.GetExternalIPv6Err
+}
+
+func (d *DriverMock) GetSerialPortOutputFrom(ctx context.Context, zone, name string, start int64) (string, int64, error) {
+	d.GetSerialPortOutputZone = zone
+	d.GetSerialPortOutputName = name
+	if d.GetSerialPortOutputFromErr != nil {
+		return "", start, d.GetSerialPortOutputFromErr
+	}
+
+	contents := d.GetSerialPortOutputFromContents
+	if start > int64(len(contents)) {
+		start = int64(len(contents))
+	}
+	return contents[start:], int64(len(contents)), nil
+}
+
+func (d *DriverMock) GetSerialPortOutput(ctx context.Context, zone, name string) (string, error) {
 	d.GetSerialPortOutputZone = zone
 	d.GetSerialPortOutputName = name
 	return d.GetSerialPortOutputResult, d.GetSerialPortOutputErr
 }
 
-func (d *DriverMock) ImageExists(project, name string) bool {
+func (d *DriverMock) InstancePreempted(ctx context.Context, zone, name string) (bool, error) {
+	d.InstancePreemptedZone = zone
+	d.InstancePreemptedName = name
+	return d.InstancePreemptedResult, d.InstancePreemptedErr
+}
+
+func (d *DriverMock) ImageExists(ctx context.Context, project, name string) bool {
 	d.ImageExistsProjectId = project
 	d.ImageExistsName = name
 	return d.ImageExistsResult
 }
 
-func (d *DriverMock) RunInstance(c *InstanceConfig) (<-chan error, error) {
+func (d *DriverMock) KeepBootDisk(ctx context.Context, zone, name string) (string, error) {
+	d.KeepBootDiskZone = zone
+	d.KeepBootDiskName = name
+	return d.KeepBootDiskResult, d.KeepBootDiskErr
+}
+
+func (d *DriverMock) RunInstance(ctx context.Context, c *InstanceConfig) (<-chan error, error) {
 	d.RunInstanceConfig = c
+	d.RunInstanceZones = append(d.RunInstanceZones, c.Zone)
+
+	if err, ok := d.RunInstanceZoneErrs[c.Zone]; ok {
+		ch := make(chan error, 1)
+		ch <- err
+		return ch, d.RunInstanceErr
+	}
 
 	resultCh := d.RunInstanceErrCh
 	if resultCh == nil {
@@ -347,7 +658,7 @@ func (d *DriverMock) RunInstance(c *InstanceConfig) (<-chan error, error) {
 	return resultCh, d.RunInstanceErr
 }
 
-func (d *DriverMock) WaitForInstance(state, zone, name string) <-chan error {
+func (d *DriverMock) WaitForInstance(ctx context.Context, state, zone, name string) <-chan error {
 	d.WaitForInstanceState = state
 	d.WaitForInstanceZone = zone
 	d.WaitForInstanceName = name
@@ -366,7 +677,7 @@ func (d *DriverMock) GetWindowsPassword() (string, error) {
 	return "", nil
 }
 
-func (d *DriverMock) CreateOrResetWindowsPassword(instance, zone string, c *WindowsPasswordConfig) (<-chan error, error) {
+func (d *DriverMock) CreateOrResetWindowsPassword(ctx context.Context, instance, zone string, c *WindowsPasswordConfig) (<-chan error, error) {
 
 	d.CreateOrResetWindowsPasswordInstance = instance
 	d.CreateOrResetWindowsPasswordZone = zone
@@ -384,7 +695,7 @@ func (d *DriverMock) CreateOrResetWindowsPassword(instance, zone string, c *Wind
 	return resultCh, d.CreateOrResetWindowsPasswordErr
 }
 
-func (d *DriverMock) ImportOSLoginSSHKey(user, key string, expirationTimeUsec *int64) (*oslogin.LoginProfile, error) {
+func (d *DriverMock) ImportOSLoginSSHKey(ctx context.Context, user, key string, expirationTimeUsec *int64) (*oslogin.LoginProfile, error) {
 	d.ImportOSLoginSSHKeyUser = user
 	d.ImportOSLoginSSHKeyKey = key
 	d.ImportOSLoginSSHKeyExpirationTime = expirationTimeUsec
@@ -395,11 +706,11 @@ func (d *DriverMock) ImportOSLoginSSHKey(user, key string, expirationTimeUsec *i
 	return &profile, nil
 }
 
-func (d *DriverMock) DeleteOSLoginSSHKey(user, fingerprint string) error {
+func (d *DriverMock) DeleteOSLoginSSHKey(ctx context.Context, user, fingerprint string) error {
 	return nil
 }
 
-func (d *DriverMock) AddToInstanceMetadata(zone string, name string, metadata map[string]string) error {
+func (d *DriverMock) AddToInstanceMetadata(ctx context.Context, zone string, name string, metadata map[string]string) error {
 	d.AddToInstanceMetadataZone = zone
 	d.AddToInstanceMetadataName = name
 	d.AddToInstanceMetadataKVPairs = metadata
@@ -413,7 +724,7 @@ func (d *DriverMock) AddToInstanceMetadata(zone string, name string, metadata ma
 	return nil
 }
 
-func (d *DriverMock) GetTokenInfo() (*oauth2_svc.Tokeninfo, error) {
+func (d *DriverMock) GetTokenInfo(ctx context.Context) (*oauth2_svc.Tokeninfo, error) {
 	if d.GetTokenInfoResult == nil {
 		d.GetTokenInfoErr = fmt.Errorf("no token found")
 	}
@@ -421,7 +732,11 @@ func (d *DriverMock) GetTokenInfo() (*oauth2_svc.Tokeninfo, error) {
 	return d.GetTokenInfoResult, d.GetTokenInfoErr
 }
 
-func (d *DriverMock) UploadToBucket(bucket, object string, data io.Reader) (string, error) {
+func (d *DriverMock) GetTokenSource(ctx context.Context) (oauth2.TokenSource, error) {
+	return d.GetTokenSourceResult, d.GetTokenSourceErr
+}
+
+func (d *DriverMock) UploadToBucket(ctx context.Context, bucket, object string, data io.Reader) (string, error) {
 	d.UploadToBucketBucket = bucket
 	d.UploadToBucketObjectName = object
 	d.UploadToBucketData = data
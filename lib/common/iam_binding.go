@@ -0,0 +1,105 @@
+// Copyright IBM Corp. 2013, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc struct-markdown
+//go:generate packer-sdc mapstructure-to-hcl2 -type IAMBinding
+
+package common
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	compute "google.golang.org/api/compute/v1"
+)
+
+// IAMBinding grants a role on a resource to a list of principals.
+type IAMBinding struct {
+	// The role to grant, e.g. `roles/compute.imageUser`, or the full name of
+	// a custom role, e.g. `projects/my-project/roles/imageReader`.
+	Role string `mapstructure:"role" required:"true"`
+	// The principals to grant the role to, each prefixed by its kind, e.g.
+	// `user:jane@example.com`, `group:builders@example.com`,
+	// `serviceAccount:deployer@my-project.iam.gserviceaccount.com` or
+	// `domain:example.com`.
+	Members []string `mapstructure:"members" required:"true"`
+}
+
+// iamMemberPrefixes are the kinds of principals a role can be granted to.
+var iamMemberPrefixes = []string{"user:", "group:", "serviceAccount:", "domain:", "principal:", "principalSet:"}
+
+func (b *IAMBinding) Prepare() []error {
+	var errs []error
+
+	if !strings.HasPrefix(b.Role, "roles/") && !strings.HasPrefix(b.Role, "projects/") &&
+		!strings.HasPrefix(b.Role, "organizations/") {
+		errs = append(errs, fmt.Errorf("image_iam_binding: invalid role %q, it must start with roles/, "+
+			"projects/ or organizations/", b.Role))
+	}
+
+	if len(b.Members) == 0 {
+		errs = append(errs, fmt.Errorf("image_iam_binding: role %s must be granted to at least one member", b.Role))
+	}
+	for _, member := range b.Members {
+		if member == "allUsers" || member == "allAuthenticatedUsers" {
+			continue
+		}
+		if !slices.ContainsFunc(iamMemberPrefixes, func(prefix string) bool {
+			return strings.HasPrefix(member, prefix) && len(member) > len(prefix)
+		}) {
+			errs = append(errs, fmt.Errorf("image_iam_binding: invalid member %q, it must be prefixed by one of %s",
+				member, strings.Join(iamMemberPrefixes, " ")))
+		}
+	}
+
+	return errs
+}
+
+// AddIAMBindings adds the members of the bindings to the unconditional
+// bindings of their role in the policy, and returns whether it changed.
+func AddIAMBindings(policy *compute.Policy, bindings []IAMBinding) bool {
+	changed := false
+	for _, b := range bindings {
+		idx := slices.IndexFunc(policy.Bindings, func(pb *compute.Binding) bool {
+			return pb.Role == b.Role && pb.Condition == nil
+		})
+		if idx == -1 {
+			policy.Bindings = append(policy.Bindings, &compute.Binding{Role: b.Role})
+			idx = len(policy.Bindings) - 1
+		}
+
+		pb := policy.Bindings[idx]
+		for _, member := range b.Members {
+			if !slices.Contains(pb.Members, member) {
+				pb.Members = append(pb.Members, member)
+				changed = true
+			}
+		}
+	}
+	return changed
+}
+
+// RemoveIAMBindings removes the members of the bindings from the
+// unconditional bindings of their role in the policy, dropping the bindings
+// left without members, and returns whether it changed.
+func RemoveIAMBindings(policy *compute.Policy, bindings []IAMBinding) bool {
+	changed := false
+	for _, b := range bindings {
+		for _, pb := range policy.Bindings {
+			if pb.Role != b.Role || pb.Condition != nil {
+				continue
+			}
+			members := slices.DeleteFunc(pb.Members, func(member string) bool {
+				return slices.Contains(b.Members, member)
+			})
+			changed = changed || len(members) != len(pb.Members)
+			pb.Members = members
+		}
+	}
+
+	policy.Bindings = slices.DeleteFunc(policy.Bindings, func(pb *compute.Binding) bool {
+		return len(pb.Members) == 0
+	})
+	return changed
+}
@@ -0,0 +1,33 @@
+// Code generated by "packer-sdc mapstructure-to-hcl2"; DO NOT EDIT.
+
+package common
+
+import (
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// FlatIAMBinding is an auto-generated flat version of IAMBinding.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatIAMBinding struct {
+	Role    *string  `mapstructure:"role" required:"true" cty:"role" hcl:"role"`
+	Members []string `mapstructure:"members" required:"true" cty:"members" hcl:"members"`
+}
+
+// FlatMapstructure returns a new FlatIAMBinding.
+// FlatIAMBinding is an auto-generated flat version of IAMBinding.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*IAMBinding) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatIAMBinding)
+}
+
+// HCL2Spec returns the hcl spec of a IAMBinding.
+// This spec is used by HCL to read the fields of IAMBinding.
+// The decoded values from this spec will then be applied to a FlatIAMBinding.
+func (*FlatIAMBinding) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"role":    &hcldec.AttrSpec{Name: "role", Type: cty.String, Required: false},
+		"members": &hcldec.AttrSpec{Name: "members", Type: cty.List(cty.String), Required: false},
+	}
+	return s
+}
@@ -0,0 +1,82 @@
+// Copyright IBM Corp. 2013, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	compute "google.golang.org/api/compute/v1"
+)
+
+func TestIAMBinding_Prepare(t *testing.T) {
+	cases := []struct {
+		name    string
+		binding IAMBinding
+		errs    int
+	}{
+		{"valid", IAMBinding{Role: "roles/compute.imageUser", Members: []string{"user:jane@example.com", "allAuthenticatedUsers"}}, 0},
+		{"custom role", IAMBinding{Role: "projects/p/roles/imageReader", Members: []string{"domain:example.com"}}, 0},
+		{"invalid role", IAMBinding{Role: "compute.imageUser", Members: []string{"user:jane@example.com"}}, 1},
+		{"no members", IAMBinding{Role: "roles/compute.imageUser"}, 1},
+		{"unprefixed member", IAMBinding{Role: "roles/compute.imageUser", Members: []string{"jane@example.com", "group:"}}, 2},
+	}
+
+	for _, tc := range cases {
+		assert.Len(t, tc.binding.Prepare(), tc.errs, tc.name)
+	}
+}
+
+func TestAddIAMBindings(t *testing.T) {
+	policy := &compute.Policy{
+		Etag: "etag",
+		Bindings: []*compute.Binding{
+			{Role: "roles/compute.imageUser", Members: []string{"user:jane@example.com"}},
+			{
+				Role:      "roles/compute.imageUser",
+				Members:   []string{"user:joe@example.com"},
+				Condition: &compute.Expr{Expression: "request.time < timestamp('2030-01-01T00:00:00Z')"},
+			},
+		},
+	}
+
+	changed := AddIAMBindings(policy, []IAMBinding{
+		{Role: "roles/compute.imageUser", Members: []string{"user:jane@example.com", "group:builders@example.com"}},
+		{Role: "roles/compute.viewer", Members: []string{"domain:example.com"}},
+	})
+
+	assert.True(t, changed)
+	assert.Equal(t, "etag", policy.Etag, "The etag of the policy read should be kept.")
+	assert.Len(t, policy.Bindings, 3)
+	assert.Equal(t, []string{"user:jane@example.com", "group:builders@example.com"}, policy.Bindings[0].Members)
+	assert.Equal(t, []string{"user:joe@example.com"}, policy.Bindings[1].Members, "Conditional bindings should be left unchanged.")
+	assert.Equal(t, &compute.Binding{Role: "roles/compute.viewer", Members: []string{"domain:example.com"}}, policy.Bindings[2])
+
+	assert.False(t, AddIAMBindings(policy, []IAMBinding{
+		{Role: "roles/compute.imageUser", Members: []string{"group:builders@example.com"}},
+	}), "Adding existing members should not change the policy.")
+}
+
+func TestRemoveIAMBindings(t *testing.T) {
+	policy := &compute.Policy{
+		Bindings: []*compute.Binding{
+			{Role: "roles/compute.imageUser", Members: []string{"user:jane@example.com", "group:builders@example.com"}},
+			{Role: "roles/compute.viewer", Members: []string{"domain:example.com"}},
+		},
+	}
+
+	changed := RemoveIAMBindings(policy, []IAMBinding{
+		{Role: "roles/compute.imageUser", Members: []string{"group:builders@example.com"}},
+		{Role: "roles/compute.viewer", Members: []string{"domain:example.com"}},
+	})
+
+	assert.True(t, changed)
+	assert.Equal(t, []*compute.Binding{
+		{Role: "roles/compute.imageUser", Members: []string{"user:jane@example.com"}},
+	}, policy.Bindings)
+
+	assert.False(t, RemoveIAMBindings(policy, []IAMBinding{
+		{Role: "roles/compute.viewer", Members: []string{"domain:example.com"}},
+	}), "Removing missing members should not change the policy.")
+}
@@ -0,0 +1,397 @@
+// Copyright IBM Corp. 2013, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/hashicorp/packer-plugin-googlecompute/version"
+	"github.com/hashicorp/packer-plugin-sdk/useragent"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// DefaultIAPTunnelEndpoint is the websocket endpoint of the IAP TCP
+	// forwarding service.
+	DefaultIAPTunnelEndpoint = "wss://tunnel.cloudproxy.app/v4"
+
+	iapTunnelSubprotocol = "relay.tunnel.cloudproxy.app"
+	iapTunnelOrigin      = "bot:iap-tunneler"
+
+	// Every message exchanged on the websocket starts with a 2 byte tag
+	// describing its contents.
+	iapTagConnectSuccessSID   uint16 = 0x0001
+	iapTagReconnectSuccessAck uint16 = 0x0002
+	iapTagData                uint16 = 0x0004
+	iapTagAck                 uint16 = 0x0007
+
+	// iapMaxDataFrameSize is the largest payload IAP accepts in a single
+	// data frame.
+	iapMaxDataFrameSize = 16384
+)
+
+// IAPTunnelError is returned when the IAP endpoint refuses a tunnel, either
+// by failing the websocket handshake or by closing the websocket with an
+// IAP specific close code.
+type IAPTunnelError struct {
+	Code   int
+	Reason string
+}
+
+func (e *IAPTunnelError) Error() string {
+	return fmt.Sprintf("IAP tunnel error %d: %s", e.Code, e.Reason)
+}
+
+// Retryable returns true if the error is one IAP reports while the instance
+// is still booting, or while the permissions on a new instance settle.
+func (e *IAPTunnelError) Retryable() bool {
+	switch e.Code {
+	// 4003: "failed to connect to backend". Network blip, or the
+	// communicator is not listening yet.
+	// 4033: Either you don't have permission to access the instance, the
+	// instance doesn't exist, or the instance is stopped. This goes away
+	// after about a minute of retries on a freshly created instance.
+	// 4047: "Either the instance doesn't exist, or the instance is stopped".
+	// The instance has not completed its startup yet.
+	case 4003, 4033, 4047:
+		return true
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// IAPTunnel forwards the TCP connections accepted on a local port to a port
+// of a GCE instance, through Identity-Aware Proxy TCP forwarding.
+//
+// Each local connection is carried over its own websocket, using the same
+// protocol as `gcloud compute start-iap-tunnel`.
+type IAPTunnel struct {
+	// ProjectId, Zone and Instance designate the instance to connect to.
+	ProjectId string
+	Zone      string
+	Instance  string
+	// The network interface of the instance to connect to. Defaults to nic0.
+	Interface string
+	// The port to connect to on the instance.
+	Port int
+	// The port to listen on, on localhost.
+	LocalPort int
+	// TokenSource provides the access tokens used to authenticate to IAP.
+	TokenSource oauth2.TokenSource
+	// Endpoint of the IAP tunnel service. Defaults to DefaultIAPTunnelEndpoint.
+	Endpoint string
+
+	listener net.Listener
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+}
+
+// Start makes sure IAP is able to reach the instance, and then starts
+// listening on the local port.
+//
+// The tunnel is ready once Start returns without an error: IAP acknowledged
+// a connection to the instance within the given timeout. The tunnel runs
+// until Stop is called, or ctx is cancelled.
+func (t *IAPTunnel) Start(ctx context.Context, timeout time.Duration) error {
+	probeCtx, cancel := context.WithTimeout(ctx, timeout)
+	conn, err := t.dial(probeCtx)
+	cancel()
+	if err != nil {
+		return err
+	}
+	conn.Close()
+
+	l, err := net.Listen("tcp", fmt.Sprintf("localhost:%d", t.LocalPort))
+	if err != nil {
+		return fmt.Errorf("error listening on localhost:%d for IAP tunnel: %s", t.LocalPort, err)
+	}
+	t.listener = l
+
+	ctx, t.cancel = context.WithCancel(ctx)
+	t.wg.Add(1)
+	go t.serve(ctx)
+
+	log.Printf("[INFO] IAP tunnel to %s:%d listening on %s", t.Instance, t.Port, l.Addr())
+	return nil
+}
+
+// Stop closes the local listener and every connection forwarded through
+// the tunnel.
+func (t *IAPTunnel) Stop() {
+	if t.cancel == nil {
+		return
+	}
+	t.cancel()
+	t.wg.Wait()
+}
+
+func (t *IAPTunnel) serve(ctx context.Context) {
+	defer t.wg.Done()
+
+	stop := context.AfterFunc(ctx, func() { t.listener.Close() })
+	defer stop()
+
+	for {
+		local, err := t.listener.Accept()
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("[ERROR] IAP tunnel stopped accepting connections: %s", err)
+			}
+			return
+		}
+
+		t.wg.Add(1)
+		go func() {
+			defer t.wg.Done()
+			t.forward(ctx, local)
+		}()
+	}
+}
+
+func (t *IAPTunnel) forward(ctx context.Context, local net.Conn) {
+	defer local.Close()
+
+	remote, err := t.dial(ctx)
+	if err != nil {
+		log.Printf("[ERROR] Unable to open IAP tunnel connection: %s", err)
+		return
+	}
+	defer remote.Close()
+
+	stop := context.AfterFunc(ctx, func() {
+		local.Close()
+		remote.Close()
+	})
+	defer stop()
+
+	// Whichever side finishes first ends the forwarding, closing both
+	// connections unblocks the other copy.
+	done := make(chan struct{}, 2)
+	go func() {
+		_, _ = io.Copy(remote, local)
+		done <- struct{}{}
+	}()
+	go func() {
+		_, _ = io.Copy(local, remote)
+		done <- struct{}{}
+	}()
+	<-done
+}
+
+func (t *IAPTunnel) connectURL() string {
+	endpoint := t.Endpoint
+	if endpoint == "" {
+		endpoint = DefaultIAPTunnelEndpoint
+	}
+
+	iface := t.Interface
+	if iface == "" {
+		iface = "nic0"
+	}
+
+	query := url.Values{}
+	query.Set("project", t.ProjectId)
+	query.Set("zone", t.Zone)
+	query.Set("instance", t.Instance)
+	query.Set("interface", iface)
+	query.Set("port", strconv.Itoa(t.Port))
+	query.Set("newWebsocket", "true")
+
+	return fmt.Sprintf("%s/connect?%s", strings.TrimSuffix(endpoint, "/"), query.Encode())
+}
+
+// dial opens a websocket to IAP, and waits for IAP to confirm it connected
+// to the instance.
+func (t *IAPTunnel) dial(ctx context.Context) (*iapConn, error) {
+	token, err := t.TokenSource.Token()
+	if err != nil {
+		return nil, fmt.Errorf("error getting access token for IAP tunnel: %s", err)
+	}
+
+	header := http.Header{}
+	header.Set("Authorization", fmt.Sprintf("%s %s", token.Type(), token.AccessToken))
+	header.Set("Origin", iapTunnelOrigin)
+	header.Set("User-Agent", useragent.String(version.PluginVersion.FormattedVersion()))
+
+	dialer := websocket.Dialer{
+		Proxy:            http.ProxyFromEnvironment,
+		HandshakeTimeout: 45 * time.Second,
+		Subprotocols:     []string{iapTunnelSubprotocol},
+	}
+
+	ws, resp, err := dialer.DialContext(ctx, t.connectURL(), header)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("timed out connecting to IAP tunnel endpoint: %w", ctx.Err())
+		}
+		if resp != nil {
+			return nil, &IAPTunnelError{Code: resp.StatusCode, Reason: resp.Status}
+		}
+		return nil, fmt.Errorf("error connecting to IAP tunnel endpoint: %s", err)
+	}
+
+	conn := &iapConn{ws: ws}
+
+	// Unblock the read below if ctx expires before IAP answers.
+	stop := context.AfterFunc(ctx, func() { ws.Close() })
+
+	tag, _, err := conn.readFrame()
+	if !stop() {
+		ws.Close()
+		return nil, fmt.Errorf("timed out waiting for IAP to connect to the instance: %w", ctx.Err())
+	}
+	if err != nil {
+		ws.Close()
+		return nil, err
+	}
+	if tag != iapTagConnectSuccessSID {
+		ws.Close()
+		return nil, fmt.Errorf("unexpected IAP tunnel message with tag %#x, expected a connection confirmation", tag)
+	}
+
+	return conn, nil
+}
+
+// iapConn is a single connection to the instance, carried over an IAP
+// websocket.
+type iapConn struct {
+	ws *websocket.Conn
+
+	// gorilla/websocket supports one concurrent writer, acks are written
+	// while reading.
+	writeMu sync.Mutex
+
+	pending  []byte
+	received uint64
+	acked    uint64
+}
+
+func (c *iapConn) Read(p []byte) (int, error) {
+	for len(c.pending) == 0 {
+		tag, payload, err := c.readFrame()
+		if err != nil {
+			return 0, err
+		}
+
+		switch tag {
+		case iapTagData:
+			c.pending = payload
+			c.received += uint64(len(payload))
+			// IAP stops sending data until we acknowledge what we received.
+			if c.received-c.acked > 2*iapMaxDataFrameSize {
+				if err := c.writeAck(c.received); err != nil {
+					return 0, err
+				}
+				c.acked = c.received
+			}
+		case iapTagAck, iapTagReconnectSuccessAck:
+			// Acks are only useful to resume a broken connection, which
+			// the tunnel doesn't do.
+		default:
+			log.Printf("[DEBUG] Ignoring IAP tunnel message with tag %#x", tag)
+		}
+	}
+
+	n := copy(p, c.pending)
+	c.pending = c.pending[n:]
+	return n, nil
+}
+
+func (c *iapConn) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := min(len(p), iapMaxDataFrameSize)
+
+		frame := make([]byte, 6+n)
+		binary.BigEndian.PutUint16(frame, iapTagData)
+		binary.BigEndian.PutUint32(frame[2:], uint32(n))
+		copy(frame[6:], p[:n])
+
+		if err := c.writeFrame(frame); err != nil {
+			return written, err
+		}
+		written += n
+		p = p[n:]
+	}
+	return written, nil
+}
+
+func (c *iapConn) Close() error {
+	c.writeMu.Lock()
+	_ = c.ws.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""),
+		time.Now().Add(time.Second))
+	c.writeMu.Unlock()
+	return c.ws.Close()
+}
+
+func (c *iapConn) writeAck(ack uint64) error {
+	frame := make([]byte, 10)
+	binary.BigEndian.PutUint16(frame, iapTagAck)
+	binary.BigEndian.PutUint64(frame[2:], ack)
+	return c.writeFrame(frame)
+}
+
+func (c *iapConn) writeFrame(frame []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return c.ws.WriteMessage(websocket.BinaryMessage, frame)
+}
+
+// readFrame reads the next message from the websocket, and returns its tag
+// and payload. IAP close codes are returned as an IAPTunnelError.
+func (c *iapConn) readFrame() (uint16, []byte, error) {
+	_, msg, err := c.ws.ReadMessage()
+	if err != nil {
+		var closeErr *websocket.CloseError
+		if errors.As(err, &closeErr) {
+			if closeErr.Code == websocket.CloseNormalClosure {
+				return 0, nil, io.EOF
+			}
+			return 0, nil, &IAPTunnelError{Code: closeErr.Code, Reason: closeErr.Text}
+		}
+		return 0, nil, err
+	}
+
+	if len(msg) < 2 {
+		return 0, nil, fmt.Errorf("invalid IAP tunnel message: too short")
+	}
+	tag := binary.BigEndian.Uint16(msg)
+	msg = msg[2:]
+
+	switch tag {
+	case iapTagConnectSuccessSID, iapTagData:
+		if len(msg) < 4 {
+			return 0, nil, fmt.Errorf("invalid IAP tunnel message with tag %#x: missing length", tag)
+		}
+		length := binary.BigEndian.Uint32(msg)
+		msg = msg[4:]
+		if uint32(len(msg)) < length {
+			return 0, nil, fmt.Errorf("invalid IAP tunnel message with tag %#x: truncated payload", tag)
+		}
+		return tag, msg[:length], nil
+	case iapTagAck, iapTagReconnectSuccessAck:
+		if len(msg) < 8 {
+			return 0, nil, fmt.Errorf("invalid IAP tunnel message with tag %#x: missing ack", tag)
+		}
+		return tag, msg[:8], nil
+	default:
+		return tag, msg, nil
+	}
+}
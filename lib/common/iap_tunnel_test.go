@@ -0,0 +1,165 @@
+// Copyright IBM Corp. 2013, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+// fakeIAPServer stands in for the IAP tunnel endpoint. Unless closeCode is
+// set, it confirms the connection and echoes back every data frame.
+type fakeIAPServer struct {
+	t         *testing.T
+	closeCode int
+	requests  chan *http.Request
+}
+
+func (f *fakeIAPServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	select {
+	case f.requests <- r:
+	default:
+	}
+
+	upgrader := websocket.Upgrader{
+		Subprotocols: []string{iapTunnelSubprotocol},
+		CheckOrigin:  func(r *http.Request) bool { return r.Header.Get("Origin") == iapTunnelOrigin },
+	}
+	ws, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		f.t.Logf("fake IAP server failed to upgrade: %s", err)
+		return
+	}
+	defer ws.Close()
+
+	if f.closeCode != 0 {
+		_ = ws.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(f.closeCode, "refused by fake IAP"))
+		return
+	}
+
+	sid := []byte("fake-sid")
+	frame := make([]byte, 6+len(sid))
+	binary.BigEndian.PutUint16(frame, iapTagConnectSuccessSID)
+	binary.BigEndian.PutUint32(frame[2:], uint32(len(sid)))
+	copy(frame[6:], sid)
+	if err := ws.WriteMessage(websocket.BinaryMessage, frame); err != nil {
+		return
+	}
+
+	for {
+		_, msg, err := ws.ReadMessage()
+		if err != nil {
+			return
+		}
+		if binary.BigEndian.Uint16(msg) != iapTagData {
+			continue
+		}
+		if err := ws.WriteMessage(websocket.BinaryMessage, msg); err != nil {
+			return
+		}
+	}
+}
+
+func testIAPTunnel(t *testing.T, server *httptest.Server) *IAPTunnel {
+	l, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	port := l.Addr().(*net.TCPAddr).Port
+	l.Close()
+
+	return &IAPTunnel{
+		ProjectId:   "fake-project",
+		Zone:        "us-central1-a",
+		Instance:    "fake-instance",
+		Port:        22,
+		LocalPort:   port,
+		TokenSource: oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "fake-token"}),
+		Endpoint:    "ws" + strings.TrimPrefix(server.URL, "http") + "/v4",
+	}
+}
+
+func TestIAPTunnel_forwardsData(t *testing.T) {
+	fake := &fakeIAPServer{t: t, requests: make(chan *http.Request, 1)}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	tunnel := testIAPTunnel(t, server)
+	require.NoError(t, tunnel.Start(context.Background(), 5*time.Second))
+	defer tunnel.Stop()
+
+	req := <-fake.requests
+	assert.Equal(t, "/v4/connect", req.URL.Path)
+	assert.Equal(t, "fake-project", req.URL.Query().Get("project"))
+	assert.Equal(t, "us-central1-a", req.URL.Query().Get("zone"))
+	assert.Equal(t, "fake-instance", req.URL.Query().Get("instance"))
+	assert.Equal(t, "nic0", req.URL.Query().Get("interface"))
+	assert.Equal(t, "22", req.URL.Query().Get("port"))
+	assert.Equal(t, "Bearer fake-token", req.Header.Get("Authorization"))
+
+	conn, err := net.Dial("tcp", tunnel.listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// Larger than a single frame, to go through the chunking and acks.
+	payload := []byte(strings.Repeat("packer", 3*iapMaxDataFrameSize))
+	go func() {
+		_, _ = conn.Write(payload)
+	}()
+
+	received := make([]byte, len(payload))
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, err = io.ReadFull(conn, received)
+	require.NoError(t, err)
+	assert.Equal(t, payload, received)
+}
+
+func TestIAPTunnel_refused(t *testing.T) {
+	cases := []struct {
+		closeCode int
+		retryable bool
+	}{
+		{4003, true},
+		{4033, true},
+		{4047, true},
+		{4004, false},
+	}
+
+	for _, tc := range cases {
+		server := httptest.NewServer(&fakeIAPServer{t: t, closeCode: tc.closeCode})
+
+		tunnel := testIAPTunnel(t, server)
+		err := tunnel.Start(context.Background(), 5*time.Second)
+		server.Close()
+
+		var tunnelErr *IAPTunnelError
+		require.True(t, errors.As(err, &tunnelErr), "expected an IAPTunnelError, got %v", err)
+		assert.Equal(t, tc.closeCode, tunnelErr.Code)
+		assert.Equal(t, tc.retryable, tunnelErr.Retryable(), "close code %d", tc.closeCode)
+		assert.Nil(t, tunnel.listener, "should not listen when IAP refused the tunnel")
+	}
+}
+
+func TestIAPTunnel_stop(t *testing.T) {
+	server := httptest.NewServer(&fakeIAPServer{t: t, requests: make(chan *http.Request, 1)})
+	defer server.Close()
+
+	tunnel := testIAPTunnel(t, server)
+	require.NoError(t, tunnel.Start(context.Background(), 5*time.Second))
+	tunnel.Stop()
+
+	_, err := net.Dial("tcp", tunnel.listener.Addr().String())
+	assert.Error(t, err, "should not accept connections once stopped")
+}
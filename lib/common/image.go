@@ -38,3 +38,67 @@ func (i *Image) IsSecureBootCompatible() bool {
 	}
 	return false
 }
+
+// IsConfidentialCompatible returns true if the image can boot on the given
+// type of confidential instance.
+func (i *Image) IsConfidentialCompatible(instanceType string) bool {
+	feature := ConfidentialGuestOsFeature(instanceType)
+	for _, osFeature := range i.GuestOsFeatures {
+		if osFeature.Type == feature {
+			return true
+		}
+	}
+	return false
+}
+
+// ImageFromSnapshot describes the disk a snapshot of project restores as an
+// Image, so that the checks made on source images apply to it.
+func ImageFromSnapshot(snapshot *compute.Snapshot, project string) *Image {
+	return &Image{
+		Architecture:    snapshot.Architecture,
+		GuestOsFeatures: snapshot.GuestOsFeatures,
+		Labels:          snapshot.Labels,
+		Licenses:        snapshot.Licenses,
+		Name:            snapshot.Name,
+		ProjectId:       project,
+		SelfLink:        snapshot.SelfLink,
+		SizeGb:          snapshot.DiskSizeGb,
+	}
+}
+
+// ImageFromDisk describes a disk of project as an Image, so that the checks
+// made on source images apply to it.
+func ImageFromDisk(disk *compute.Disk, project string) *Image {
+	return &Image{
+		Architecture:    disk.Architecture,
+		GuestOsFeatures: disk.GuestOsFeatures,
+		Labels:          disk.Labels,
+		Licenses:        disk.Licenses,
+		Name:            disk.Name,
+		ProjectId:       project,
+		SelfLink:        disk.SelfLink,
+		SizeGb:          disk.SizeGb,
+	}
+}
+
+// ImageFromMachineImage describes the boot disk of a machine image of project
+// as an Image, so that the checks made on source images apply to it.
+func ImageFromMachineImage(machineImage *compute.MachineImage, project string) *Image {
+	image := &Image{
+		Labels:    machineImage.Labels,
+		Name:      machineImage.Name,
+		ProjectId: project,
+		SelfLink:  machineImage.SelfLink,
+	}
+	if machineImage.SourceInstanceProperties == nil {
+		return image
+	}
+	for _, disk := range machineImage.SourceInstanceProperties.Disks {
+		if disk.Boot {
+			image.GuestOsFeatures = disk.GuestOsFeatures
+			image.Licenses = disk.Licenses
+			image.SizeGb = disk.DiskSizeGb
+		}
+	}
+	return image
+}
@@ -7,16 +7,23 @@ type InstanceConfig struct {
 	AcceleratorType              string
 	AcceleratorCount             int64
 	Address                      string
+	ConfidentialInstanceType     string
 	Description                  string
 	DisableDefaultServiceAccount bool
 	DiskName                     string
 	DiskSizeGb                   int64
 	DiskType                     string
 	DiskEncryptionKey            *CustomerEncryptionKey
+	DiskProvisionedIops          int64
+	DiskProvisionedThroughput    int64
+	DiskLabels                   map[string]string
+	DiskStoragePool              string
+	DiskResourcePolicies         []string
 	EnableNestedVirtualization   bool
 	EnableSecureBoot             bool
 	EnableVtpm                   bool
 	EnableIntegrityMonitoring    bool
+	ExternalIPv6                 bool
 	ExtraBlockDevices            []BlockDevice
 	Image                        *Image
 	Labels                       map[string]string
@@ -26,16 +33,22 @@ type InstanceConfig struct {
 	Name                         string
 	Network                      string
 	NetworkProjectId             string
+	NetworkInterfaces            []NetworkInterface
 	OmitExternalIP               bool
 	OnHostMaintenance            string
 	MaxRunDurationInSeconds      int64
 	InstanceTerminationAction    string
 	Preemptible                  bool
+	ProvisioningModel            string
 	NodeAffinities               []NodeAffinity
 	ReservationAffinity          *ReservationAffinity
 	Region                       string
 	ServiceAccountEmail          string
 	Scopes                       []string
+	SourceDisk                   string
+	SourceMachineImage           string
+	SourceSnapshot               string
+	StackType                    string
 	Subnetwork                   string
 	Tags                         []string
 	ResourceManagerTags          map[string]string
@@ -0,0 +1,201 @@
+// Copyright IBM Corp. 2013, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc struct-markdown
+//go:generate packer-sdc mapstructure-to-hcl2 -type NetworkInterface,AliasIPRange
+
+package common
+
+import (
+	"fmt"
+	"net"
+
+	compute "google.golang.org/api/compute/v1"
+)
+
+// The IP stacks a network interface can have.
+const (
+	StackTypeIPv4Only = "IPV4_ONLY"
+	StackTypeIPv4IPv6 = "IPV4_IPV6"
+	StackTypeIPv6Only = "IPV6_ONLY"
+)
+
+// NetworkInterface is a network interface of the instance used to build the
+// image. The first one is the primary network interface, `nic0`.
+type NetworkInterface struct {
+	// The Google Compute network id or URL the interface is attached to.
+	// It can be omitted when `subnetwork` is set.
+	Network string `mapstructure:"network"`
+	// The Google Compute subnetwork id or URL the interface is attached to.
+	// It must be set when the network is in custom subnet mode.
+	Subnetwork string `mapstructure:"subnetwork"`
+	// The project ID of the network and subnetwork. Defaults to
+	// `network_project_id`, or to `project_id` if unset.
+	NetworkProjectId string `mapstructure:"network_project_id"`
+	// The static internal IP address of the interface. It must be available
+	// in the subnetwork. It can be an IPv6 address if `stack_type` is
+	// `IPV4_IPV6` or `IPV6_ONLY`.
+	NetworkIP string `mapstructure:"network_ip"`
+	// If true, give the interface an ephemeral external IPv4 address. It
+	// cannot be set if `stack_type` is `IPV6_ONLY`.
+	ExternalIP bool `mapstructure:"external_ip"`
+	// The name of a pre-allocated static external IP address to give the
+	// interface. Implies `external_ip`.
+	Address string `mapstructure:"address"`
+	// If true, give the interface an external IPv6 address from the
+	// subnetwork. Requires `stack_type` to be `IPV4_IPV6` or `IPV6_ONLY`, and
+	// the subnetwork to have an external IPv6 range.
+	ExternalIPv6 bool `mapstructure:"external_ipv6"`
+	// The alias IP ranges of the interface.
+	AliasIPRanges []AliasIPRange `mapstructure:"alias_ip_range"`
+	// The type of virtual network interface, either `GVNIC` or `VIRTIO_NET`.
+	// Defaults to the type the source image supports.
+	NicType string `mapstructure:"nic_type"`
+	// The IP stack of the interface, one of `IPV4_ONLY`, `IPV4_IPV6` for
+	// dual-stack, or `IPV6_ONLY`. Defaults to `IPV4_ONLY`.
+	StackType string `mapstructure:"stack_type"`
+	// The number of queues of the interface. Defaults to a number based on the
+	// vCPU count of the machine type.
+	QueueCount int64 `mapstructure:"queue_count"`
+}
+
+// AliasIPRange is an alias IP range of a network interface.
+type AliasIPRange struct {
+	// The IP CIDR range, e.g. `10.2.3.4/24`, a single address, or a netmask
+	// like `/24` for Compute Engine to pick a range of this size.
+	IPCidrRange string `mapstructure:"ip_cidr_range" required:"true"`
+	// The name of the secondary range of the subnetwork to allocate the
+	// range from. Defaults to the primary range.
+	SubnetworkRangeName string `mapstructure:"subnetwork_range_name"`
+}
+
+func (ni *NetworkInterface) Prepare() []error {
+	var errs []error
+
+	if ni.Network == "" && ni.Subnetwork == "" {
+		errs = append(errs, fmt.Errorf("network_interface: network or subnetwork must be set"))
+	}
+
+	if ni.Address != "" {
+		ni.ExternalIP = true
+	}
+
+	for _, err := range ValidateIPStack(ni.StackType, ni.NetworkIP, ni.ExternalIP, ni.ExternalIPv6) {
+		errs = append(errs, fmt.Errorf("network_interface: %w", err))
+	}
+
+	for _, r := range ni.AliasIPRanges {
+		if r.IPCidrRange == "" {
+			errs = append(errs, fmt.Errorf("network_interface: alias_ip_range must have an ip_cidr_range"))
+		}
+	}
+
+	switch ni.NicType {
+	case "", "GVNIC", "VIRTIO_NET":
+	default:
+		errs = append(errs, fmt.Errorf("network_interface: invalid nic_type %q, valid values are GVNIC or VIRTIO_NET", ni.NicType))
+	}
+
+	if ni.QueueCount < 0 {
+		errs = append(errs, fmt.Errorf("network_interface: queue_count must be positive"))
+	}
+
+	return errs
+}
+
+// HasIPv4 returns true if the interface gets an IPv4 address.
+func (ni *NetworkInterface) HasIPv4() bool {
+	return ni.StackType != StackTypeIPv6Only
+}
+
+// HasIPv6 returns true if the interface gets an IPv6 address.
+func (ni *NetworkInterface) HasIPv6() bool {
+	return ni.StackType == StackTypeIPv4IPv6 || ni.StackType == StackTypeIPv6Only
+}
+
+// ValidateIPStack checks that the addresses requested for a network interface
+// are available with its stack type.
+func ValidateIPStack(stackType, networkIP string, externalIP, externalIPv6 bool) []error {
+	var errs []error
+
+	ni := NetworkInterface{StackType: stackType}
+	switch stackType {
+	case "", StackTypeIPv4Only, StackTypeIPv4IPv6, StackTypeIPv6Only:
+	default:
+		errs = append(errs, fmt.Errorf("invalid stack_type %q, valid values are %s, %s or %s",
+			stackType, StackTypeIPv4Only, StackTypeIPv4IPv6, StackTypeIPv6Only))
+	}
+
+	if networkIP != "" {
+		ip := net.ParseIP(networkIP)
+		switch {
+		case (ip == nil || ip.To4() == nil) && !ni.HasIPv6():
+			errs = append(errs, fmt.Errorf("network_ip must be a valid IPv4 address"))
+		case ip == nil:
+			errs = append(errs, fmt.Errorf("network_ip must be a valid IP address"))
+		case ip.To4() != nil && !ni.HasIPv4():
+			errs = append(errs, fmt.Errorf("network_ip must be an IPv6 address when stack_type is %s", StackTypeIPv6Only))
+		}
+	}
+
+	if externalIP && !ni.HasIPv4() {
+		errs = append(errs, fmt.Errorf("an external IPv4 address cannot be requested when stack_type is %s", StackTypeIPv6Only))
+	}
+
+	if externalIPv6 && !ni.HasIPv6() {
+		errs = append(errs, fmt.Errorf("external_ipv6 requires stack_type to be %s or %s", StackTypeIPv4IPv6, StackTypeIPv6Only))
+	}
+
+	return errs
+}
+
+// ComputeType returns the interface to request from Compute Engine, given the
+// region of the instance and the static external address if any.
+func (ni *NetworkInterface) ComputeType(region, natIP string) (*compute.NetworkInterface, error) {
+	networkId, subnetworkId, err := GetInterfaceNetworking(ni, region)
+	if err != nil {
+		return nil, err
+	}
+
+	computeNI := &compute.NetworkInterface{
+		Network:    networkId,
+		Subnetwork: subnetworkId,
+		NicType:    ni.NicType,
+		StackType:  ni.StackType,
+		QueueCount: ni.QueueCount,
+	}
+
+	if ip := net.ParseIP(ni.NetworkIP); ip != nil && ip.To4() == nil {
+		computeNI.Ipv6Address = ni.NetworkIP
+	} else {
+		computeNI.NetworkIP = ni.NetworkIP
+	}
+
+	if ni.ExternalIP {
+		computeNI.AccessConfigs = []*compute.AccessConfig{
+			{
+				Name:  "AccessConfig created by Packer",
+				Type:  "ONE_TO_ONE_NAT",
+				NatIP: natIP,
+			},
+		}
+	}
+
+	if ni.ExternalIPv6 {
+		computeNI.Ipv6AccessConfigs = []*compute.AccessConfig{
+			{
+				Name: "IPv6 AccessConfig created by Packer",
+				Type: "DIRECT_IPV6",
+			},
+		}
+	}
+
+	for _, r := range ni.AliasIPRanges {
+		computeNI.AliasIpRanges = append(computeNI.AliasIpRanges, &compute.AliasIpRange{
+			IpCidrRange:         r.IPCidrRange,
+			SubnetworkRangeName: r.SubnetworkRangeName,
+		})
+	}
+
+	return computeNI, nil
+}
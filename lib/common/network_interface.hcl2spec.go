@@ -0,0 +1,76 @@
+// Code generated by "packer-sdc mapstructure-to-hcl2"; DO NOT EDIT.
+
+package common
+
+import (
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// FlatAliasIPRange is an auto-generated flat version of AliasIPRange.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatAliasIPRange struct {
+	IPCidrRange         *string `mapstructure:"ip_cidr_range" required:"true" cty:"ip_cidr_range" hcl:"ip_cidr_range"`
+	SubnetworkRangeName *string `mapstructure:"subnetwork_range_name" cty:"subnetwork_range_name" hcl:"subnetwork_range_name"`
+}
+
+// FlatMapstructure returns a new FlatAliasIPRange.
+// FlatAliasIPRange is an auto-generated flat version of AliasIPRange.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*AliasIPRange) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatAliasIPRange)
+}
+
+// HCL2Spec returns the hcl spec of a AliasIPRange.
+// This spec is used by HCL to read the fields of AliasIPRange.
+// The decoded values from this spec will then be applied to a FlatAliasIPRange.
+func (*FlatAliasIPRange) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"ip_cidr_range":         &hcldec.AttrSpec{Name: "ip_cidr_range", Type: cty.String, Required: false},
+		"subnetwork_range_name": &hcldec.AttrSpec{Name: "subnetwork_range_name", Type: cty.String, Required: false},
+	}
+	return s
+}
+
+// FlatNetworkInterface is an auto-generated flat version of NetworkInterface.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatNetworkInterface struct {
+	Network          *string            `mapstructure:"network" cty:"network" hcl:"network"`
+	Subnetwork       *string            `mapstructure:"subnetwork" cty:"subnetwork" hcl:"subnetwork"`
+	NetworkProjectId *string            `mapstructure:"network_project_id" cty:"network_project_id" hcl:"network_project_id"`
+	NetworkIP        *string            `mapstructure:"network_ip" cty:"network_ip" hcl:"network_ip"`
+	ExternalIP       *bool              `mapstructure:"external_ip" cty:"external_ip" hcl:"external_ip"`
+	Address          *string            `mapstructure:"address" cty:"address" hcl:"address"`
+	ExternalIPv6     *bool              `mapstructure:"external_ipv6" cty:"external_ipv6" hcl:"external_ipv6"`
+	AliasIPRanges    []FlatAliasIPRange `mapstructure:"alias_ip_range" cty:"alias_ip_range" hcl:"alias_ip_range"`
+	NicType          *string            `mapstructure:"nic_type" cty:"nic_type" hcl:"nic_type"`
+	StackType        *string            `mapstructure:"stack_type" cty:"stack_type" hcl:"stack_type"`
+	QueueCount       *int64             `mapstructure:"queue_count" cty:"queue_count" hcl:"queue_count"`
+}
+
+// FlatMapstructure returns a new FlatNetworkInterface.
+// FlatNetworkInterface is an auto-generated flat version of NetworkInterface.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*NetworkInterface) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatNetworkInterface)
+}
+
+// HCL2Spec returns the hcl spec of a NetworkInterface.
+// This spec is used by HCL to read the fields of NetworkInterface.
+// The decoded values from this spec will then be applied to a FlatNetworkInterface.
+func (*FlatNetworkInterface) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"network":            &hcldec.AttrSpec{Name: "network", Type: cty.String, Required: false},
+		"subnetwork":         &hcldec.AttrSpec{Name: "subnetwork", Type: cty.String, Required: false},
+		"network_project_id": &hcldec.AttrSpec{Name: "network_project_id", Type: cty.String, Required: false},
+		"network_ip":         &hcldec.AttrSpec{Name: "network_ip", Type: cty.String, Required: false},
+		"external_ip":        &hcldec.AttrSpec{Name: "external_ip", Type: cty.Bool, Required: false},
+		"address":            &hcldec.AttrSpec{Name: "address", Type: cty.String, Required: false},
+		"external_ipv6":      &hcldec.AttrSpec{Name: "external_ipv6", Type: cty.Bool, Required: false},
+		"alias_ip_range":     &hcldec.BlockListSpec{TypeName: "alias_ip_range", Nested: hcldec.ObjectSpec((*FlatAliasIPRange)(nil).HCL2Spec())},
+		"nic_type":           &hcldec.AttrSpec{Name: "nic_type", Type: cty.String, Required: false},
+		"stack_type":         &hcldec.AttrSpec{Name: "stack_type", Type: cty.String, Required: false},
+		"queue_count":        &hcldec.AttrSpec{Name: "queue_count", Type: cty.Number, Required: false},
+	}
+	return s
+}
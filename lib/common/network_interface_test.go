@@ -0,0 +1,102 @@
+// Copyright IBM Corp. 2013, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	compute "google.golang.org/api/compute/v1"
+)
+
+func TestNetworkInterface_Prepare(t *testing.T) {
+	cases := []struct {
+		name string
+		ni   NetworkInterface
+		errs int
+	}{
+		{"network", NetworkInterface{Network: "default"}, 0},
+		{"subnetwork", NetworkInterface{Subnetwork: "subnet"}, 0},
+		{"no network", NetworkInterface{}, 1},
+		{"invalid network_ip", NetworkInterface{Network: "default", NetworkIP: "10.0.0"}, 1},
+		{"alias range without cidr", NetworkInterface{Network: "default", AliasIPRanges: []AliasIPRange{{SubnetworkRangeName: "pods"}}}, 1},
+		{"nic_type", NetworkInterface{Network: "default", NicType: "GVNIC"}, 0},
+		{"invalid nic_type", NetworkInterface{Network: "default", NicType: "E1000"}, 1},
+		{"stack_type", NetworkInterface{Network: "default", StackType: "IPV4_IPV6"}, 0},
+		{"invalid stack_type", NetworkInterface{Network: "default", StackType: "IPV5"}, 1},
+		{"ipv6 only", NetworkInterface{Network: "default", StackType: "IPV6_ONLY", ExternalIPv6: true}, 0},
+		{"ipv6 only with external ipv4", NetworkInterface{Network: "default", StackType: "IPV6_ONLY", ExternalIP: true}, 1},
+		{"ipv6 only with ipv4 network_ip", NetworkInterface{Network: "default", StackType: "IPV6_ONLY", NetworkIP: "10.0.0.2"}, 1},
+		{"ipv6 network_ip", NetworkInterface{Network: "default", StackType: "IPV4_IPV6", NetworkIP: "fd20::2"}, 0},
+		{"ipv6 network_ip on ipv4 stack", NetworkInterface{Network: "default", NetworkIP: "fd20::2"}, 1},
+		{"external ipv6 on ipv4 stack", NetworkInterface{Network: "default", ExternalIPv6: true}, 1},
+		{"negative queue_count", NetworkInterface{Network: "default", QueueCount: -1}, 1},
+	}
+
+	for _, tc := range cases {
+		errs := tc.ni.Prepare()
+		assert.Len(t, errs, tc.errs, "%s: unexpected errors %v", tc.name, errs)
+	}
+
+	ni := NetworkInterface{Network: "default", Address: "my-address"}
+	assert.Empty(t, ni.Prepare())
+	assert.True(t, ni.ExternalIP, "address should imply an external IP")
+}
+
+func TestNetworkInterface_ComputeType(t *testing.T) {
+	ni := NetworkInterface{
+		Network:          "network-value",
+		Subnetwork:       "subnetwork-value",
+		NetworkProjectId: "project-id",
+		NetworkIP:        "10.0.0.2",
+		ExternalIP:       true,
+		AliasIPRanges: []AliasIPRange{
+			{IPCidrRange: "/24", SubnetworkRangeName: "pods"},
+		},
+		NicType:    "GVNIC",
+		QueueCount: 4,
+	}
+
+	computeNI, err := ni.ComputeType("region-id", "1.2.3.4")
+	require.NoError(t, err)
+	assert.Equal(t, &compute.NetworkInterface{
+		Network:    "projects/project-id/global/networks/network-value",
+		Subnetwork: "projects/project-id/regions/region-id/subnetworks/subnetwork-value",
+		NetworkIP:  "10.0.0.2",
+		AccessConfigs: []*compute.AccessConfig{
+			{Name: "AccessConfig created by Packer", Type: "ONE_TO_ONE_NAT", NatIP: "1.2.3.4"},
+		},
+		AliasIpRanges: []*compute.AliasIpRange{
+			{IpCidrRange: "/24", SubnetworkRangeName: "pods"},
+		},
+		NicType:    "GVNIC",
+		QueueCount: 4,
+	}, computeNI)
+
+	ni.ExternalIP = false
+	computeNI, err = ni.ComputeType("region-id", "")
+	require.NoError(t, err)
+	assert.Empty(t, computeNI.AccessConfigs, "interface without external IP should not have access configs")
+}
+
+func TestNetworkInterface_ComputeTypeIPv6(t *testing.T) {
+	ni := NetworkInterface{
+		Network:          "network-value",
+		NetworkProjectId: "project-id",
+		NetworkIP:        "fd20::2",
+		StackType:        StackTypeIPv6Only,
+		ExternalIPv6:     true,
+	}
+
+	computeNI, err := ni.ComputeType("region-id", "")
+	require.NoError(t, err)
+	assert.Equal(t, "IPV6_ONLY", computeNI.StackType)
+	assert.Equal(t, "fd20::2", computeNI.Ipv6Address)
+	assert.Empty(t, computeNI.NetworkIP, "an IPv6 network_ip should not be requested as the IPv4 address")
+	assert.Empty(t, computeNI.AccessConfigs)
+	assert.Equal(t, []*compute.AccessConfig{
+		{Name: "IPv6 AccessConfig created by Packer", Type: "DIRECT_IPV6"},
+	}, computeNI.Ipv6AccessConfigs)
+}
@@ -11,6 +11,16 @@ import (
 // This method will build a network and subnetwork ID from the provided
 // instance config, and return them in that order.
 func GetNetworking(c *InstanceConfig) (string, string, error) {
+	return GetInterfaceNetworking(&NetworkInterface{
+		Network:          c.Network,
+		Subnetwork:       c.Subnetwork,
+		NetworkProjectId: c.NetworkProjectId,
+	}, c.Region)
+}
+
+// GetInterfaceNetworking returns the network and subnetwork URLs of a network
+// interface of an instance in region.
+func GetInterfaceNetworking(c *NetworkInterface, region string) (string, string, error) {
 	networkId := c.Network
 	subnetworkId := c.Subnetwork
 
@@ -54,7 +64,7 @@ func GetNetworking(c *InstanceConfig) (string, string, error) {
 		// partial URL. We will expand it into a partial URL here and avoid
 		// making a call to discover the subnetwork.
 		if !strings.Contains(c.Subnetwork, "/") {
-			subnetworkId = "projects/" + c.NetworkProjectId + "/regions/" + c.Region + "/subnetworks/" + c.Subnetwork
+			subnetworkId = "projects/" + c.NetworkProjectId + "/regions/" + region + "/subnetworks/" + c.Subnetwork
 		}
 	}
 	return networkId, subnetworkId, nil
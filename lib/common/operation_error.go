@@ -0,0 +1,107 @@
+// Copyright IBM Corp. 2013, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"errors"
+	"net/http"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	compute "google.golang.org/api/compute/v1"
+	"google.golang.org/api/googleapi"
+)
+
+// OperationError is an error reported by a Compute Engine operation once it
+// is done.
+type OperationError struct {
+	// Code is the error type identifier, e.g. ZONE_RESOURCE_POOL_EXHAUSTED.
+	Code string
+	// Location is the field of the request that caused the error, if any.
+	Location string
+	Message  string
+}
+
+func (e *OperationError) Error() string {
+	return e.Message
+}
+
+// operationErrors returns the errors reported by a done operation, or nil if
+// it succeeded. Each error is decoded into a typed error when it matches one,
+// see decodeOperationError.
+func operationErrors(op *compute.Operation) error {
+	if op.Error == nil {
+		return nil
+	}
+
+	var err error
+	for _, e := range op.Error.Errors {
+		err = packersdk.MultiErrorAppend(err, decodeOperationError(e))
+	}
+	return err
+}
+
+// AsError is like errors.As, but also looks into the errors of a
+// *packersdk.MultiError, which errors.As does not unwrap. Steps use it to
+// branch on the typed errors returned by the driver.
+func AsError(err error, target any) bool {
+	if errors.As(err, target) {
+		return true
+	}
+
+	var multiErr *packersdk.MultiError
+	if errors.As(err, &multiErr) {
+		for _, e := range multiErr.Errors {
+			if AsError(e, target) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// capacityErrorCodes are the operation error codes reported when a zone
+// cannot fulfill a request for lack of resources or quota.
+var capacityErrorCodes = map[string]bool{
+	"ZONE_RESOURCE_POOL_EXHAUSTED":              true,
+	"ZONE_RESOURCE_POOL_EXHAUSTED_WITH_DETAILS": true,
+	"QUOTA_EXCEEDED":                            true,
+}
+
+// IsCapacityError reports whether err was caused by the zone lacking the
+// resources or quota to fulfill the request, in which case trying again in
+// another zone may succeed.
+func IsCapacityError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var multiErr *packersdk.MultiError
+	if errors.As(err, &multiErr) {
+		for _, e := range multiErr.Errors {
+			if IsCapacityError(e) {
+				return true
+			}
+		}
+		return false
+	}
+
+	var opErr *OperationError
+	if errors.As(err, &opErr) {
+		return capacityErrorCodes[opErr.Code]
+	}
+
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		if apiErr.Code != http.StatusForbidden && apiErr.Code != http.StatusServiceUnavailable {
+			return false
+		}
+		for _, item := range apiErr.Errors {
+			if item.Reason == "quotaExceeded" || capacityErrorCodes[item.Reason] {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,62 @@
+// Copyright IBM Corp. 2013, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	compute "google.golang.org/api/compute/v1"
+	"google.golang.org/api/googleapi"
+)
+
+func TestIsCapacityError(t *testing.T) {
+	operationErr := func(codes ...string) error {
+		op := &compute.Operation{Error: &compute.OperationError{}}
+		for _, code := range codes {
+			op.Error.Errors = append(op.Error.Errors, &compute.OperationErrorErrors{
+				Code:    code,
+				Message: "message for " + code,
+			})
+		}
+		return operationErrors(op)
+	}
+
+	cases := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{"nil", nil, false},
+		{"plain error", errors.New("ZONE_RESOURCE_POOL_EXHAUSTED"), false},
+		{"stockout", operationErr("ZONE_RESOURCE_POOL_EXHAUSTED"), true},
+		{"stockout with details", operationErr("ZONE_RESOURCE_POOL_EXHAUSTED_WITH_DETAILS"), true},
+		{"quota", operationErr("QUOTA_EXCEEDED"), true},
+		{"among other errors", operationErr("INVALID_USAGE", "QUOTA_EXCEEDED"), true},
+		{"other operation error", operationErr("INVALID_USAGE"), false},
+		{"wrapped", fmt.Errorf("creating instance: %w", operationErr("ZONE_RESOURCE_POOL_EXHAUSTED")), true},
+		{
+			"api quota",
+			&googleapi.Error{Code: 403, Errors: []googleapi.ErrorItem{{Reason: "quotaExceeded"}}},
+			true,
+		},
+		{
+			"api permission denied",
+			&googleapi.Error{Code: 403, Errors: []googleapi.ErrorItem{{Reason: "forbidden"}}},
+			false,
+		},
+		{
+			"api not found",
+			&googleapi.Error{Code: 404, Errors: []googleapi.ErrorItem{{Reason: "notFound"}}},
+			false,
+		},
+	}
+
+	for _, tc := range cases {
+		if got := IsCapacityError(tc.err); got != tc.expected {
+			t.Errorf("%s: expected IsCapacityError to be %t, got %t", tc.name, tc.expected, got)
+		}
+	}
+}
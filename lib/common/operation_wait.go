@@ -0,0 +1,138 @@
+// Copyright IBM Corp. 2013, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"path"
+	"time"
+
+	compute "google.golang.org/api/compute/v1"
+)
+
+// pollBackoff computes the delays between the polls of a long running
+// operation. They grow exponentially up to a maximum, and are randomized so
+// that concurrent waits do not poll in step.
+type pollBackoff struct {
+	// Initial is the delay before the second poll.
+	Initial time.Duration
+	// Max caps the delays.
+	Max time.Duration
+	// Multiplier is the growth of the delay from one poll to the next.
+	Multiplier float64
+	// Jitter is the fraction of each delay, both ways, that is randomized.
+	Jitter float64
+
+	next time.Duration
+}
+
+// defaultPollBackoff polls every second at first and slows down to every 30
+// seconds. The long polls of operations return as soon as they are done, and
+// only back off when the API fails.
+var defaultPollBackoff = pollBackoff{
+	Initial:    time.Second,
+	Max:        30 * time.Second,
+	Multiplier: 2,
+	Jitter:     0.2,
+}
+
+// Next returns the delay to wait before the next poll.
+func (b *pollBackoff) Next() time.Duration {
+	if b.next == 0 {
+		b.next = b.Initial
+	}
+	delay := b.next
+	b.next = time.Duration(float64(b.next) * b.Multiplier)
+	if b.Max > 0 && b.next > b.Max {
+		b.next = b.Max
+	}
+
+	if b.Jitter > 0 {
+		delay += time.Duration((rand.Float64()*2 - 1) * b.Jitter * float64(delay))
+	}
+	return delay
+}
+
+// waitForOperation waits for op to be done and returns the errors it
+// reported, if any. The operation is long polled with the Wait method of its
+// scope, which returns as soon as the operation is done or after about two
+// minutes. The progress of the operation is shown in the UI as it changes.
+//
+// The wait goes on until ctx is done; errors polling the operation are
+// logged and the poll retried after a backoff.
+func (d *driverGCE) waitForOperation(ctx context.Context, project string, op *compute.Operation) error {
+	wait := func(ctx context.Context) (*compute.Operation, error) {
+		switch {
+		case op.Zone != "":
+			return d.service.ZoneOperations.Wait(project, path.Base(op.Zone), op.Name).Context(ctx).Do()
+		case op.Region != "":
+			return d.service.RegionOperations.Wait(project, path.Base(op.Region), op.Name).Context(ctx).Do()
+		}
+		return d.service.GlobalOperations.Wait(project, op.Name).Context(ctx).Do()
+	}
+
+	backoff := d.pollBackoff
+	progress := op.Progress
+	for op.Status != "DONE" {
+		newOp, err := wait(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			log.Printf("[DEBUG] Error waiting for operation %s, retrying: %s", op.Name, err)
+			if err := sleepContext(ctx, backoff.Next()); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// A long poll that succeeds already waited, the operation is polled
+		// again right away. Only the failed polls back off.
+		backoff = d.pollBackoff
+		op = newOp
+		if op.Status != "DONE" && op.Progress > progress {
+			progress = op.Progress
+			d.reportProgress(op)
+		}
+	}
+
+	return operationErrors(op)
+}
+
+// reportProgress shows the progress of a running operation in the UI.
+func (d *driverGCE) reportProgress(op *compute.Operation) {
+	if d.ui == nil {
+		return
+	}
+	d.ui.Message(fmt.Sprintf("Operation %s on %s is %d%% done...", op.OperationType, path.Base(op.TargetLink), op.Progress))
+}
+
+// used in conjunction with waitForState.
+type stateRefreshFunc func(ctx context.Context) (string, error)
+
+// waitForState polls the state until it reaches target, sending the result
+// to errCh. The polls back off as in waitForOperation, until ctx is done.
+func waitForState(ctx context.Context, errCh chan<- error, target string, backoff pollBackoff, refresh stateRefreshFunc) error {
+	var err error
+	for {
+		var state string
+		state, err = refresh(ctx)
+		if err == nil && state == target {
+			break
+		}
+		if err != nil {
+			log.Printf("[DEBUG] Error waiting for state %s, retrying: %s", target, err)
+		}
+		if sleepErr := sleepContext(ctx, backoff.Next()); sleepErr != nil {
+			// Report why the wait was given up rather than the last state seen.
+			err = sleepErr
+			break
+		}
+	}
+	errCh <- err
+	return err
+}
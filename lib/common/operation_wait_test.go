@@ -0,0 +1,182 @@
+// Copyright IBM Corp. 2013, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/stretchr/testify/assert"
+	compute "google.golang.org/api/compute/v1"
+	"google.golang.org/api/option"
+)
+
+// testDriverGCE returns a driver whose Compute Engine API is served by
+// handler, and the buffer its UI writes to.
+func testDriverGCE(t *testing.T, handler http.Handler) (*driverGCE, *bytes.Buffer) {
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	service, err := compute.NewService(context.Background(),
+		option.WithEndpoint(srv.URL+"/compute/v1/"),
+		option.WithHTTPClient(srv.Client()),
+		option.WithoutAuthentication())
+	if err != nil {
+		t.Fatalf("failed to create the compute service: %s", err)
+	}
+
+	out := new(bytes.Buffer)
+	return &driverGCE{
+		projectId: "project",
+		service:   service,
+		ui: &packersdk.BasicUi{
+			Reader: new(bytes.Buffer),
+			Writer: out,
+		},
+		pollBackoff: pollBackoff{Initial: time.Millisecond, Max: time.Millisecond, Multiplier: 1},
+	}, out
+}
+
+// operationServer serves the given operations, one per call, to the long
+// polls of the path.
+func operationServer(t *testing.T, path string, ops ...*compute.Operation) http.Handler {
+	calls := 0
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != path || r.Method != http.MethodPost {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			http.NotFound(w, r)
+			return
+		}
+		if calls >= len(ops) {
+			t.Errorf("unexpected call %d to %s", calls+1, path)
+			http.NotFound(w, r)
+			return
+		}
+		op := ops[calls]
+		calls++
+		if op == nil {
+			http.Error(w, `{"error": {"code": 503, "message": "unavailable"}}`, http.StatusServiceUnavailable)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(op)
+	})
+}
+
+func TestDriverGCE_waitForOperation(t *testing.T) {
+	running := func(progress int64) *compute.Operation {
+		return &compute.Operation{
+			Name:          "op",
+			OperationType: "insert",
+			Progress:      progress,
+			Status:        "RUNNING",
+			TargetLink:    "https://compute.googleapis.com/compute/v1/projects/project/global/images/image",
+		}
+	}
+
+	d, out := testDriverGCE(t, operationServer(t, "/compute/v1/projects/project/global/operations/op/wait",
+		running(0), running(40), nil, running(40), &compute.Operation{Name: "op", Status: "DONE"}))
+
+	err := d.waitForOperation(context.Background(), "project", running(0))
+	assert.NoError(t, err)
+	assert.Equal(t, "Operation insert on image is 40% done...\n", out.String(),
+		"progress should be shown once per change")
+}
+
+func TestDriverGCE_waitForOperation_noBackoff(t *testing.T) {
+	running := &compute.Operation{Name: "op", Status: "RUNNING"}
+	d, _ := testDriverGCE(t, operationServer(t, "/compute/v1/projects/project/global/operations/op/wait",
+		running, running, &compute.Operation{Name: "op", Status: "DONE"}))
+	// The test would time out if the successful polls backed off.
+	d.pollBackoff = pollBackoff{Initial: time.Hour, Max: time.Hour, Multiplier: 1}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	assert.NoError(t, d.waitForOperation(ctx, "project", running))
+}
+
+func TestDriverGCE_waitForOperation_scopes(t *testing.T) {
+	cases := []struct {
+		name    string
+		project string
+		op      *compute.Operation
+		path    string
+	}{
+		{
+			"zone",
+			"project",
+			&compute.Operation{Name: "op", Zone: "https://compute.googleapis.com/compute/v1/projects/project/zones/us-central1-a"},
+			"/compute/v1/projects/project/zones/us-central1-a/operations/op/wait",
+		},
+		{
+			"region",
+			"project",
+			&compute.Operation{Name: "op", Region: "https://compute.googleapis.com/compute/v1/projects/project/regions/us-central1"},
+			"/compute/v1/projects/project/regions/us-central1/operations/op/wait",
+		},
+		{
+			"global",
+			"other",
+			&compute.Operation{Name: "op"},
+			"/compute/v1/projects/other/global/operations/op/wait",
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			d, _ := testDriverGCE(t, operationServer(t, tt.path, &compute.Operation{Name: "op", Status: "DONE"}))
+			assert.NoError(t, d.waitForOperation(context.Background(), tt.project, tt.op))
+		})
+	}
+}
+
+func TestDriverGCE_waitForOperation_error(t *testing.T) {
+	d, _ := testDriverGCE(t, operationServer(t, "/compute/v1/projects/project/global/operations/op/wait",
+		&compute.Operation{
+			Name:   "op",
+			Status: "DONE",
+			Error: &compute.OperationError{
+				Errors: []*compute.OperationErrorErrors{{Code: "QUOTA_EXCEEDED", Message: "quota exceeded"}},
+			},
+		}))
+
+	err := d.waitForOperation(context.Background(), "project", &compute.Operation{Name: "op"})
+	assert.ErrorContains(t, err, "quota exceeded")
+	assert.True(t, IsCapacityError(err), "the error of the operation should be kept")
+}
+
+func TestDriverGCE_waitForOperation_cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	d, _ := testDriverGCE(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The build is cancelled while the operation is long polled.
+		cancel()
+		<-r.Context().Done()
+	}))
+
+	err := d.waitForOperation(ctx, "project", &compute.Operation{Name: "op"})
+	assert.True(t, errors.Is(err, context.Canceled), "got %v", err)
+}
+
+func TestPollBackoff(t *testing.T) {
+	b := pollBackoff{Initial: time.Second, Max: 5 * time.Second, Multiplier: 2}
+	var delays []time.Duration
+	for i := 0; i < 5; i++ {
+		delays = append(delays, b.Next())
+	}
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}, delays)
+
+	jittered := pollBackoff{Initial: 10 * time.Second, Max: 10 * time.Second, Multiplier: 2, Jitter: 0.2}
+	for i := 0; i < 100; i++ {
+		delay := jittered.Next()
+		if delay < 8*time.Second || delay > 12*time.Second {
+			t.Fatalf("delay %s is out of the jitter range", delay)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright IBM Corp. 2013, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc struct-markdown
+//go:generate packer-sdc mapstructure-to-hcl2 -type RetryConfig
+
+package common
+
+import (
+	"errors"
+	"time"
+)
+
+// RetryConfig configures how the requests to Google Cloud APIs are retried
+// when they fail transiently.
+type RetryConfig struct {
+	// The number of times a request to a Google Cloud API is attempted when
+	// it fails transiently: a `429` or `5xx` response, a rate limit, or a
+	// network error. Requests are retried with an exponential backoff, or
+	// after the delay asked for by the API in a `Retry-After` header. Requests
+	// that create or change resources are only retried when they carry a
+	// request ID, so that the API does not apply them twice. Defaults to `5`,
+	// `1` disables retries.
+	APIRetryAttempts int `mapstructure:"api_retry_attempts" required:"false"`
+	// The delay before the first retry of a request, doubled for each of the
+	// next ones. Defaults to `1s`.
+	APIRetryInitialBackoff time.Duration `mapstructure:"api_retry_initial_backoff" required:"false"`
+	// The maximum delay between two retries of a request, which also caps the
+	// delay asked for in a `Retry-After` header. Defaults to `30s`.
+	APIRetryMaxBackoff time.Duration `mapstructure:"api_retry_max_backoff" required:"false"`
+}
+
+const (
+	defaultAPIRetryAttempts       = 5
+	defaultAPIRetryInitialBackoff = time.Second
+	defaultAPIRetryMaxBackoff     = 30 * time.Second
+)
+
+func (c *RetryConfig) Prepare() []error {
+	var errs []error
+	if c.APIRetryAttempts < 0 {
+		errs = append(errs, errors.New("api_retry_attempts must not be negative"))
+	}
+	if c.APIRetryInitialBackoff < 0 {
+		errs = append(errs, errors.New("api_retry_initial_backoff must not be negative"))
+	}
+	if c.APIRetryMaxBackoff < 0 {
+		errs = append(errs, errors.New("api_retry_max_backoff must not be negative"))
+	}
+	if c.APIRetryInitialBackoff > 0 && c.APIRetryMaxBackoff > 0 && c.APIRetryInitialBackoff > c.APIRetryMaxBackoff {
+		errs = append(errs, errors.New("api_retry_initial_backoff must not be greater than api_retry_max_backoff"))
+	}
+	return errs
+}
+
+// ApplyDriverConfig applies the retry configuration to the config for the GCE Driver
+func (c RetryConfig) ApplyDriverConfig(cfg *GCEDriverConfig) {
+	cfg.Retry = c
+}
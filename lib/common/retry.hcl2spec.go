@@ -0,0 +1,35 @@
+// Code generated by "packer-sdc mapstructure-to-hcl2"; DO NOT EDIT.
+
+package common
+
+import (
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// FlatRetryConfig is an auto-generated flat version of RetryConfig.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatRetryConfig struct {
+	APIRetryAttempts       *int    `mapstructure:"api_retry_attempts" required:"false" cty:"api_retry_attempts" hcl:"api_retry_attempts"`
+	APIRetryInitialBackoff *string `mapstructure:"api_retry_initial_backoff" required:"false" cty:"api_retry_initial_backoff" hcl:"api_retry_initial_backoff"`
+	APIRetryMaxBackoff     *string `mapstructure:"api_retry_max_backoff" required:"false" cty:"api_retry_max_backoff" hcl:"api_retry_max_backoff"`
+}
+
+// FlatMapstructure returns a new FlatRetryConfig.
+// FlatRetryConfig is an auto-generated flat version of RetryConfig.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*RetryConfig) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatRetryConfig)
+}
+
+// HCL2Spec returns the hcl spec of a RetryConfig.
+// This spec is used by HCL to read the fields of RetryConfig.
+// The decoded values from this spec will then be applied to a FlatRetryConfig.
+func (*FlatRetryConfig) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"api_retry_attempts":        &hcldec.AttrSpec{Name: "api_retry_attempts", Type: cty.Number, Required: false},
+		"api_retry_initial_backoff": &hcldec.AttrSpec{Name: "api_retry_initial_backoff", Type: cty.String, Required: false},
+		"api_retry_max_backoff":     &hcldec.AttrSpec{Name: "api_retry_max_backoff", Type: cty.String, Required: false},
+	}
+	return s
+}
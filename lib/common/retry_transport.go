@@ -0,0 +1,176 @@
+// Copyright IBM Corp. 2013, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+// retryTransport is an http.RoundTripper that retries the requests to Google
+// Cloud APIs failing transiently. It wraps the transport of the clients of the
+// driver, so that all their calls are retried the same way.
+type retryTransport struct {
+	next     http.RoundTripper
+	attempts int
+	backoff  pollBackoff
+}
+
+// newRetryTransport returns a retryTransport sending the requests with next,
+// configured by c with the defaults for the unset fields.
+func newRetryTransport(next http.RoundTripper, c RetryConfig) *retryTransport {
+	t := &retryTransport{
+		next:     next,
+		attempts: c.APIRetryAttempts,
+		backoff: pollBackoff{
+			Initial:    c.APIRetryInitialBackoff,
+			Max:        c.APIRetryMaxBackoff,
+			Multiplier: 2,
+			Jitter:     0.2,
+		},
+	}
+	if t.next == nil {
+		t.next = http.DefaultTransport
+	}
+	if t.attempts == 0 {
+		t.attempts = defaultAPIRetryAttempts
+	}
+	if t.backoff.Initial == 0 {
+		t.backoff.Initial = defaultAPIRetryInitialBackoff
+	}
+	if t.backoff.Max == 0 {
+		t.backoff.Max = defaultAPIRetryMaxBackoff
+	}
+	return t
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.attempts <= 1 || !isIdempotent(req) {
+		return t.next.RoundTrip(req)
+	}
+
+	ctx := req.Context()
+	backoff := t.backoff
+	r := req
+	for attempt := 1; ; attempt++ {
+		resp, err := t.next.RoundTrip(r)
+		if attempt >= t.attempts || ctx.Err() != nil {
+			return resp, err
+		}
+		reason, retry := isTransient(resp, err)
+		if !retry {
+			return resp, err
+		}
+
+		delay := backoff.Next()
+		if after, ok := retryAfter(resp); ok {
+			delay = min(after, backoff.Max)
+		}
+		if resp != nil {
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		log.Printf("[DEBUG] %s %s failed with %s, retrying in %s (attempt %d/%d)",
+			req.Method, req.URL.Path, reason, delay, attempt+1, t.attempts)
+		if err := sleepContext(ctx, delay); err != nil {
+			return nil, err
+		}
+
+		r = req.Clone(ctx)
+		if req.Body != nil && req.Body != http.NoBody {
+			if r.Body, err = req.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// isIdempotent reports whether req can be sent again without side effects.
+// Reads are, and so are the long polls of operations. Other requests are
+// only when they carry a request ID, which the API uses to ignore the
+// duplicates of a request it already received. Requests whose body cannot
+// be read again are never retried.
+func isIdempotent(req *http.Request) bool {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	switch {
+	case req.Method == http.MethodGet || req.Method == http.MethodHead:
+		return true
+	case req.Method == http.MethodPost && strings.HasSuffix(req.URL.Path, "/wait"):
+		return true
+	}
+	return req.URL.Query().Get("requestId") != ""
+}
+
+// rateLimitReasons are the reasons of the 403 responses to requests over a
+// rate limit, which are transient unlike the other 403 responses.
+var rateLimitReasons = []string{`"rateLimitExceeded"`, `"userRateLimitExceeded"`}
+
+// isTransient reports whether a request that got resp or err may succeed if
+// retried, and describes the failure.
+func isTransient(resp *http.Response, err error) (string, bool) {
+	if err != nil {
+		return err.Error(), true
+	}
+
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return resp.Status, true
+	case http.StatusForbidden:
+		// Read the body to find the reason, and put it back for the client.
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		if err != nil {
+			return "", false
+		}
+		for _, reason := range rateLimitReasons {
+			if bytes.Contains(body, []byte(reason)) {
+				return fmt.Sprintf("%s (%s)", resp.Status, strings.Trim(reason, `"`)), true
+			}
+		}
+	}
+	return "", false
+}
+
+// retryAfter returns the delay asked for by the Retry-After header of resp,
+// in seconds or as a date, if any.
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	if resp == nil {
+		return 0, false
+	}
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0), true
+	}
+	return 0, false
+}
+
+// newRequestID returns a request ID for a call that creates or changes a
+// resource. The API ignores the calls with the ID of a call it already
+// received, which lets retryTransport send them again.
+func newRequestID() string {
+	id, err := uuid.NewV4()
+	if err != nil {
+		// The request is then not retried, see isIdempotent.
+		return ""
+	}
+	return id.String()
+}
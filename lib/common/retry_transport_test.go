@@ -0,0 +1,199 @@
+// Copyright IBM Corp. 2013, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	compute "google.golang.org/api/compute/v1"
+	"google.golang.org/api/option"
+)
+
+// testRetryConfig retries without waiting between the attempts.
+var testRetryConfig = RetryConfig{
+	APIRetryAttempts:       3,
+	APIRetryInitialBackoff: time.Millisecond,
+	APIRetryMaxBackoff:     time.Millisecond,
+}
+
+// response is a canned response of a flakyServer.
+type response struct {
+	status int
+	header map[string]string
+	body   string
+}
+
+// flakyServer serves the given responses, one per request, and records the
+// requests it got with their bodies.
+func flakyServer(t *testing.T, responses ...response) (*httptest.Server, *[]*http.Request, *[]string) {
+	var requests []*http.Request
+	var bodies []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests = append(requests, r)
+		bodies = append(bodies, string(body))
+		if len(requests) > len(responses) {
+			t.Errorf("unexpected request %d %s %s", len(requests), r.Method, r.URL)
+			http.NotFound(w, r)
+			return
+		}
+		resp := responses[len(requests)-1]
+		for k, v := range resp.header {
+			w.Header().Set(k, v)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(resp.status)
+		_, _ = io.WriteString(w, resp.body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &requests, &bodies
+}
+
+func TestRetryTransport(t *testing.T) {
+	unavailable := response{status: 503, body: `{"error": {"code": 503, "message": "unavailable"}}`}
+	rateLimited := response{status: 403, body: `{"error": {"code": 403, "errors": [{"reason": "rateLimitExceeded"}]}}`}
+	forbidden := response{status: 403, body: `{"error": {"code": 403, "errors": [{"reason": "forbidden"}]}}`}
+	ok := response{status: 200, body: `{}`}
+
+	cases := []struct {
+		name      string
+		method    string
+		url       string
+		responses []response
+		status    int
+	}{
+		{"get unavailable", "GET", "/zones/z", []response{unavailable, ok}, 200},
+		{"get too many requests", "GET", "/zones/z", []response{{status: 429}, {status: 502}, ok}, 200},
+		{"get rate limited", "GET", "/zones/z", []response{rateLimited, ok}, 200},
+		{"get forbidden", "GET", "/zones/z", []response{forbidden}, 403},
+		{"get not found", "GET", "/zones/z", []response{{status: 404}}, 404},
+		{"attempts exhausted", "GET", "/zones/z", []response{unavailable, unavailable, unavailable}, 503},
+		{"insert without request ID", "POST", "/images", []response{unavailable}, 503},
+		{"insert with request ID", "POST", "/images?requestId=id", []response{unavailable, ok}, 200},
+		{"wait for operation", "POST", "/operations/op/wait", []response{unavailable, ok}, 200},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, requests, bodies := flakyServer(t, tt.responses...)
+			client := &http.Client{Transport: newRetryTransport(srv.Client().Transport, testRetryConfig)}
+
+			req, err := http.NewRequest(tt.method, srv.URL+tt.url, strings.NewReader(`{"name": "image"}`))
+			assert.NoError(t, err)
+			resp, err := client.Do(req)
+			assert.NoError(t, err)
+			defer resp.Body.Close()
+
+			assert.Equal(t, tt.status, resp.StatusCode)
+			assert.Len(t, *requests, len(tt.responses))
+			for _, body := range *bodies {
+				assert.Equal(t, `{"name": "image"}`, body, "the body should be sent again with each attempt")
+			}
+			if tt.status == 403 {
+				body, _ := io.ReadAll(resp.Body)
+				assert.Equal(t, forbidden.body, string(body), "the body should be left for the client")
+			}
+		})
+	}
+}
+
+func TestRetryTransport_retryAfter(t *testing.T) {
+	srv, requests, _ := flakyServer(t,
+		response{status: 429, header: map[string]string{"Retry-After": "0"}},
+		response{status: 200})
+	// The backoff alone would not retry before the test times out.
+	client := &http.Client{Transport: newRetryTransport(srv.Client().Transport, RetryConfig{
+		APIRetryInitialBackoff: time.Hour,
+	})}
+
+	resp, err := client.Get(srv.URL)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Len(t, *requests, 2)
+}
+
+func TestRetryTransport_retryAfterCapped(t *testing.T) {
+	srv, requests, _ := flakyServer(t,
+		response{status: 503, header: map[string]string{"Retry-After": "3600"}},
+		response{status: 200})
+	client := &http.Client{Transport: newRetryTransport(srv.Client().Transport, testRetryConfig)}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", srv.URL, nil)
+	resp, err := client.Do(req)
+	assert.NoError(t, err, "the delay should be capped at api_retry_max_backoff")
+	resp.Body.Close()
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Len(t, *requests, 2)
+}
+
+func TestRetryTransport_cancelled(t *testing.T) {
+	srv, requests, _ := flakyServer(t, response{status: 503})
+	client := &http.Client{Transport: newRetryTransport(srv.Client().Transport, RetryConfig{
+		APIRetryInitialBackoff: time.Hour,
+	})}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", srv.URL, nil)
+	_, err := client.Do(req)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), "got %v", err)
+	assert.Len(t, *requests, 1)
+}
+
+func TestRetryAfter(t *testing.T) {
+	cases := []struct {
+		value    string
+		expected time.Duration
+		ok       bool
+	}{
+		{"", 0, false},
+		{"5", 5 * time.Second, true},
+		{"-1", 0, false},
+		{"soon", 0, false},
+		{"Mon, 02 Jan 2006 15:04:05 GMT", 0, true},
+	}
+
+	for _, tt := range cases {
+		resp := &http.Response{Header: http.Header{}}
+		resp.Header.Set("Retry-After", tt.value)
+		delay, ok := retryAfter(resp)
+		assert.Equal(t, tt.ok, ok, tt.value)
+		assert.Equal(t, tt.expected, delay, tt.value)
+	}
+}
+
+func TestDriverGCE_retries(t *testing.T) {
+	srv, requests, _ := flakyServer(t,
+		response{status: 503},
+		response{status: 403, body: `{"error": {"code": 403, "errors": [{"reason": "userRateLimitExceeded"}]}}`},
+		response{status: 200, body: `{"name": "image"}`})
+
+	service, err := compute.NewService(context.Background(),
+		option.WithEndpoint(srv.URL+"/compute/v1/"),
+		option.WithHTTPClient(&http.Client{Transport: newRetryTransport(srv.Client().Transport, testRetryConfig)}),
+		option.WithoutAuthentication())
+	assert.NoError(t, err)
+	d := &driverGCE{projectId: "project", service: service}
+
+	err = d.SetImageDeprecationStatus(context.Background(), "project", "image", &compute.DeprecationStatus{State: "DEPRECATED"})
+	assert.NoError(t, err)
+	assert.Len(t, *requests, 3)
+
+	requestID := (*requests)[0].URL.Query().Get("requestId")
+	assert.NotEmpty(t, requestID, "the deprecation should carry a request ID")
+	for _, r := range *requests {
+		assert.Equal(t, requestID, r.URL.Query().Get("requestId"), "the retries should carry the same request ID")
+	}
+}
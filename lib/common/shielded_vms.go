@@ -3,39 +3,284 @@
 package common
 
 import (
+	"bytes"
+	"context"
 	"crypto/x509"
 	"encoding/base64"
+	"encoding/binary"
 	"encoding/pem"
+	"errors"
 	"fmt"
 	"os"
+	"strings"
+	"time"
 
 	"google.golang.org/api/compute/v1"
 )
 
-func FillFileContentBuffer(certOrKeyFile string) (*compute.FileContentBuffer, error) {
-	data, err := os.ReadFile(certOrKeyFile)
-	if err != nil {
-		err := fmt.Errorf("Unable to read Certificate or Key file %s", certOrKeyFile)
+// The formats of Secure Boot keys and certificates.
+const (
+	SecureBootKeyFormatDER = "DER"
+	SecureBootKeyFormatPEM = "PEM"
+	// An EFI signature list, as read from the UEFI variables or made by
+	// cert-to-efi-sig-list.
+	SecureBootKeyFormatESL = "EFI signature list"
+)
+
+// The file types of the Secure Boot keys sent to Compute Engine.
+const (
+	secureBootFileTypeX509 = "X509"
+	secureBootFileTypeBin  = "BIN"
+)
+
+// The sources Secure Boot keys can be read from, other than local paths.
+const (
+	secureBootSourceFile          = "file://"
+	secureBootSourceGCS           = "gs://"
+	secureBootSourceSecretManager = "secretmanager://"
+)
+
+// The GUIDs of the signature types of EFI signature lists, as stored on disk.
+var (
+	efiCertX509GUID   = efiGUID(0xa5c059a1, 0x94e4, 0x4aa7, [8]byte{0x87, 0xb5, 0xab, 0x15, 0x5c, 0x2b, 0xf0, 0x72})
+	efiCertSHA256GUID = efiGUID(0xc1c41626, 0x504c, 0x4092, [8]byte{0xac, 0xa9, 0x41, 0xf9, 0x36, 0x93, 0x43, 0x28})
+)
+
+// efiSignatureListHeaderSize is the size of the fixed header of an EFI
+// signature list, and efiSignatureOwnerSize the size of the owner GUID that
+// starts each signature.
+const (
+	efiSignatureListHeaderSize = 28
+	efiSignatureOwnerSize      = 16
+)
+
+// efiGUID returns the mixed-endian encoding of a GUID in EFI structures.
+func efiGUID(a uint32, b, c uint16, d [8]byte) [16]byte {
+	var guid [16]byte
+	binary.LittleEndian.PutUint32(guid[0:], a)
+	binary.LittleEndian.PutUint16(guid[4:], b)
+	binary.LittleEndian.PutUint16(guid[6:], c)
+	copy(guid[8:], d[:])
+	return guid
+}
+
+// SecureBootKeyReader reads the Secure Boot keys stored in Cloud Storage and
+// Secret Manager. The Driver implements it.
+type SecureBootKeyReader interface {
+	DownloadFromBucket(ctx context.Context, bucket, objectName string) ([]byte, error)
+	AccessSecretVersion(ctx context.Context, name string) ([]byte, error)
+}
+
+// ValidateSecureBootKeySource checks that source is a local path, or a
+// file://, gs:// or secretmanager:// URI.
+func ValidateSecureBootKeySource(source string) error {
+	switch {
+	case strings.HasPrefix(source, secureBootSourceFile):
+		if strings.TrimPrefix(source, secureBootSourceFile) == "" {
+			return fmt.Errorf("%q: the file:// URI has no path", source)
+		}
+	case strings.HasPrefix(source, secureBootSourceGCS):
+		bucket, object, _ := strings.Cut(strings.TrimPrefix(source, secureBootSourceGCS), "/")
+		if bucket == "" || object == "" {
+			return fmt.Errorf("%q: the gs:// URI must be of the form gs://<bucket>/<object>", source)
+		}
+	case strings.HasPrefix(source, secureBootSourceSecretManager):
+		if _, err := secretVersionName(source); err != nil {
+			return err
+		}
+	case strings.Contains(source, "://"):
+		return fmt.Errorf("%q: unsupported URI, keys are read from local paths, file://, gs:// or secretmanager:// URIs", source)
+	}
+	return nil
+}
+
+// secretVersionName returns the name of the secret version of a
+// secretmanager:// URI, the latest version by default.
+func secretVersionName(source string) (string, error) {
+	name := strings.TrimPrefix(source, secureBootSourceSecretManager)
+	parts := strings.Split(name, "/")
+	switch {
+	case len(parts) == 4 && parts[0] == "projects" && parts[2] == "secrets" && parts[1] != "" && parts[3] != "":
+		return name + "/versions/latest", nil
+	case len(parts) == 6 && parts[0] == "projects" && parts[2] == "secrets" && parts[4] == "versions" &&
+		parts[1] != "" && parts[3] != "" && parts[5] != "":
+		return name, nil
+	}
+	return "", fmt.Errorf("%q: the secretmanager:// URI must be of the form "+
+		"secretmanager://projects/<project>/secrets/<secret>[/versions/<version>]", source)
+}
+
+// SecureBootKeyLoader loads the Secure Boot keys and certificates of an image
+// from their sources, and checks their format.
+type SecureBootKeyLoader struct {
+	// Reader reads the keys of gs:// and secretmanager:// sources.
+	Reader SecureBootKeyReader
+	// Strict makes keys of an unknown format and expired certificates
+	// errors, instead of warnings.
+	Strict bool
+	// Warnings holds the problems found with the keys loaded, when not
+	// strict.
+	Warnings []string
+
+	// now returns the time certificates are checked against.
+	now func() time.Time
+}
+
+// read returns the content of the key at source.
+func (l *SecureBootKeyLoader) read(ctx context.Context, source string) ([]byte, error) {
+	if err := ValidateSecureBootKeySource(source); err != nil {
 		return nil, err
 	}
-	shield := &compute.FileContentBuffer{
+
+	switch {
+	case strings.HasPrefix(source, secureBootSourceGCS):
+		if l.Reader == nil {
+			return nil, fmt.Errorf("%q: cannot read keys from Cloud Storage", source)
+		}
+		bucket, object, _ := strings.Cut(strings.TrimPrefix(source, secureBootSourceGCS), "/")
+		return l.Reader.DownloadFromBucket(ctx, bucket, object)
+	case strings.HasPrefix(source, secureBootSourceSecretManager):
+		if l.Reader == nil {
+			return nil, fmt.Errorf("%q: cannot read keys from Secret Manager", source)
+		}
+		name, _ := secretVersionName(source)
+		return l.Reader.AccessSecretVersion(ctx, name)
+	}
+	return os.ReadFile(strings.TrimPrefix(source, secureBootSourceFile))
+}
+
+// problem reports a problem with a key, as an error when strict and as a
+// warning otherwise.
+func (l *SecureBootKeyLoader) problem(err error) error {
+	if l.Strict {
+		return err
+	}
+	l.Warnings = append(l.Warnings, err.Error())
+	return nil
+}
+
+// FillFileContentBuffer loads the key or certificate at source. X.509
+// certificates, either DER or PEM encoded, are sent as such, and EFI signature
+// lists as binary content.
+func (l *SecureBootKeyLoader) FillFileContentBuffer(ctx context.Context, source string) (*compute.FileContentBuffer, error) {
+	data, err := l.read(ctx, source)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read Certificate or Key %s: %s", source, err)
+	}
+
+	format, certs, err := detectSecureBootKeyFormat(data)
+	if err != nil {
+		// The key is sent as is, which Secure Boot may reject at boot time.
+		if err := l.problem(fmt.Errorf("Certificate or Key %s: %s", source, err)); err != nil {
+			return nil, err
+		}
+		return &compute.FileContentBuffer{
+			Content:  base64.StdEncoding.EncodeToString(data),
+			FileType: secureBootFileTypeBin,
+		}, nil
+	}
+
+	now := time.Now
+	if l.now != nil {
+		now = l.now
+	}
+	for _, cert := range certs {
+		if cert.NotAfter.Before(now()) {
+			err := fmt.Errorf("Certificate %s: %q expired on %s", source, cert.Subject.CommonName,
+				cert.NotAfter.Format(time.RFC3339))
+			if err := l.problem(err); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	fileType := secureBootFileTypeX509
+	if format == SecureBootKeyFormatESL {
+		fileType = secureBootFileTypeBin
+	}
+	return &compute.FileContentBuffer{
 		Content:  base64.StdEncoding.EncodeToString(data),
-		FileType: "X509",
+		FileType: fileType,
+	}, nil
+}
+
+// detectSecureBootKeyFormat returns the format of a key and the certificates
+// it holds.
+func detectSecureBootKeyFormat(data []byte) (string, []*x509.Certificate, error) {
+	if block, _ := pem.Decode(data); block != nil {
+		if block.Type != "CERTIFICATE" {
+			return "", nil, fmt.Errorf("PEM block of type %q is not a certificate", block.Type)
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid PEM certificate: %s", err)
+		}
+		return SecureBootKeyFormatPEM, []*x509.Certificate{cert}, nil
 	}
-	block, _ := pem.Decode(data)
 
-	if block == nil || block.Type != "CERTIFICATE" {
-		_, err = x509.ParseCertificate(data)
-	} else {
-		_, err = x509.ParseCertificate(block.Bytes)
+	if cert, err := x509.ParseCertificate(data); err == nil {
+		return SecureBootKeyFormatDER, []*x509.Certificate{cert}, nil
 	}
+
+	certs, err := parseEFISignatureLists(data)
 	if err != nil {
-		shield.FileType = "BIN"
+		return "", nil, fmt.Errorf("neither a DER or PEM encoded X.509 certificate nor an EFI signature list: %s", err)
+	}
+	return SecureBootKeyFormatESL, certs, nil
+}
+
+// parseEFISignatureLists checks that data is a sequence of EFI signature lists
+// of X.509 certificates or SHA-256 hashes, and returns their certificates.
+func parseEFISignatureLists(data []byte) ([]*x509.Certificate, error) {
+	if len(data) == 0 {
+		return nil, errors.New("empty content")
+	}
+
+	var certs []*x509.Certificate
+	for len(data) > 0 {
+		if len(data) < efiSignatureListHeaderSize {
+			return nil, errors.New("truncated signature list header")
+		}
+		var signatureType [16]byte
+		copy(signatureType[:], data)
+		listSize := binary.LittleEndian.Uint32(data[16:])
+		headerSize := binary.LittleEndian.Uint32(data[20:])
+		signatureSize := binary.LittleEndian.Uint32(data[24:])
+
+		if uint64(listSize) > uint64(len(data)) || uint64(listSize) < uint64(efiSignatureListHeaderSize)+uint64(headerSize) {
+			return nil, fmt.Errorf("invalid signature list size %d", listSize)
+		}
+		if signatureSize <= efiSignatureOwnerSize || (listSize-efiSignatureListHeaderSize-headerSize)%signatureSize != 0 {
+			return nil, fmt.Errorf("invalid signature size %d", signatureSize)
+		}
+
+		signatures := data[efiSignatureListHeaderSize+headerSize : listSize]
+		switch {
+		case bytes.Equal(signatureType[:], efiCertX509GUID[:]):
+			for ; len(signatures) > 0; signatures = signatures[signatureSize:] {
+				cert, err := x509.ParseCertificate(signatures[efiSignatureOwnerSize:signatureSize])
+				if err != nil {
+					return nil, fmt.Errorf("invalid certificate in signature list: %s", err)
+				}
+				certs = append(certs, cert)
+			}
+		case bytes.Equal(signatureType[:], efiCertSHA256GUID[:]):
+			if signatureSize != efiSignatureOwnerSize+32 {
+				return nil, fmt.Errorf("invalid SHA-256 signature size %d", signatureSize)
+			}
+		default:
+			return nil, fmt.Errorf("unsupported signature type %x", signatureType)
+		}
+
+		data = data[listSize:]
 	}
-	return shield, nil
+	return certs, nil
 }
 
-func CreateShieldedVMStateConfig(imagePlatformKey string, imageKeyExchangeKey []string, imageSignaturesDB []string, imageForbiddenSignaturesDB []string) (*compute.InitialStateConfig, error) {
+// CreateShieldedVMStateConfig loads the Secure Boot keys and certificates of
+// an image: the platform key, key exchange keys, and the allowed and
+// forbidden signature databases.
+func (l *SecureBootKeyLoader) CreateShieldedVMStateConfig(ctx context.Context, imagePlatformKey string, imageKeyExchangeKey []string, imageSignaturesDB []string, imageForbiddenSignaturesDB []string) (*compute.InitialStateConfig, error) {
 	// When no Secure Boot signature inputs are configured, return nil so the
 	// caller leaves ShieldedInstanceInitialState unset on the image payload.
 	// Sending an explicit (even empty) InitialStateConfig replaces the
@@ -48,28 +293,28 @@ func CreateShieldedVMStateConfig(imagePlatformKey string, imageKeyExchangeKey []
 
 	shieldedVMStateConfig := &compute.InitialStateConfig{}
 	if imagePlatformKey != "" {
-		shieldedData, err := FillFileContentBuffer(imagePlatformKey)
+		shieldedData, err := l.FillFileContentBuffer(ctx, imagePlatformKey)
 		if err != nil {
 			return nil, err
 		}
 		shieldedVMStateConfig.Pk = shieldedData
 	}
 	for _, v := range imageKeyExchangeKey {
-		shieldedData, err := FillFileContentBuffer(v)
+		shieldedData, err := l.FillFileContentBuffer(ctx, v)
 		if err != nil {
 			return nil, err
 		}
 		shieldedVMStateConfig.Keks = append(shieldedVMStateConfig.Keks, shieldedData)
 	}
 	for _, v := range imageSignaturesDB {
-		shieldedData, err := FillFileContentBuffer(v)
+		shieldedData, err := l.FillFileContentBuffer(ctx, v)
 		if err != nil {
 			return nil, err
 		}
 		shieldedVMStateConfig.Dbs = append(shieldedVMStateConfig.Dbs, shieldedData)
 	}
 	for _, v := range imageForbiddenSignaturesDB {
-		shieldedData, err := FillFileContentBuffer(v)
+		shieldedData, err := l.FillFileContentBuffer(ctx, v)
 		if err != nil {
 			return nil, err
 		}
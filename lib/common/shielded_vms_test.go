@@ -4,9 +4,20 @@
 package common
 
 import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/pem"
+	"math/big"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -17,11 +28,11 @@ import (
 // empty value overrides the PK/KEKs/db/dbx that would otherwise be inherited
 // from the source disk and breaks Secure Boot on the resulting image.
 func TestCreateShieldedVMStateConfig_NoInputsReturnsNil(t *testing.T) {
-	cfg, err := CreateShieldedVMStateConfig("", nil, nil, nil)
+	cfg, err := new(SecureBootKeyLoader).CreateShieldedVMStateConfig(context.Background(), "", nil, nil, nil)
 	assert.NoError(t, err)
 	assert.Nil(t, cfg, "expected nil config when no signature inputs are configured")
 
-	cfg, err = CreateShieldedVMStateConfig("", []string{}, []string{}, []string{})
+	cfg, err = new(SecureBootKeyLoader).CreateShieldedVMStateConfig(context.Background(), "", []string{}, []string{}, []string{})
 	assert.NoError(t, err)
 	assert.Nil(t, cfg, "expected nil config when signature inputs are empty slices")
 }
@@ -48,7 +59,7 @@ func TestCreateShieldedVMStateConfig_PopulatesFieldsWhenInputsProvided(t *testin
 
 	for _, tt := range testcases {
 		t.Run(tt.name, func(t *testing.T) {
-			cfg, err := CreateShieldedVMStateConfig(tt.pk, tt.keks, tt.dbs, tt.dbxs)
+			cfg, err := new(SecureBootKeyLoader).CreateShieldedVMStateConfig(context.Background(), tt.pk, tt.keks, tt.dbs, tt.dbxs)
 			assert.NoError(t, err)
 			assert.NotNil(t, cfg)
 
@@ -59,3 +70,164 @@ func TestCreateShieldedVMStateConfig_PopulatesFieldsWhenInputsProvided(t *testin
 		})
 	}
 }
+
+// testCertificate returns a DER encoded self-signed certificate expiring at
+// notAfter.
+func testCertificate(t *testing.T, notAfter time.Time) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %s", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "Packer Test PK"},
+		NotBefore:    notAfter.Add(-365 * 24 * time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %s", err)
+	}
+	return der
+}
+
+// testSignatureList returns an EFI signature list of the given type.
+func testSignatureList(signatureType [16]byte, signatures ...[]byte) []byte {
+	size := efiSignatureOwnerSize + len(signatures[0])
+	list := make([]byte, efiSignatureListHeaderSize, efiSignatureListHeaderSize+len(signatures)*size)
+	copy(list, signatureType[:])
+	binary.LittleEndian.PutUint32(list[16:], uint32(cap(list)))
+	binary.LittleEndian.PutUint32(list[24:], uint32(size))
+	for _, signature := range signatures {
+		list = append(list, make([]byte, efiSignatureOwnerSize)...)
+		list = append(list, signature...)
+	}
+	return list
+}
+
+func TestDetectSecureBootKeyFormat(t *testing.T) {
+	der := testCertificate(t, time.Now().Add(time.Hour))
+	pemCert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	hash := make([]byte, 32)
+
+	testcases := []struct {
+		name      string
+		data      []byte
+		format    string
+		certs     int
+		expectErr bool
+	}{
+		{name: "DER certificate", data: der, format: SecureBootKeyFormatDER, certs: 1},
+		{name: "PEM certificate", data: pemCert, format: SecureBootKeyFormatPEM, certs: 1},
+		{name: "signature list of certificates", data: testSignatureList(efiCertX509GUID, der),
+			format: SecureBootKeyFormatESL, certs: 1},
+		{name: "signature lists of hashes and certificates",
+			data:   append(testSignatureList(efiCertSHA256GUID, hash, hash), testSignatureList(efiCertX509GUID, der)...),
+			format: SecureBootKeyFormatESL, certs: 1},
+		{name: "corrupted PEM certificate", data: pemCert[:len(pemCert)-40], expectErr: true},
+		{name: "PEM private key", data: pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), expectErr: true},
+		{name: "truncated signature list", data: testSignatureList(efiCertX509GUID, der)[:100], expectErr: true},
+		{name: "signature list of an unknown type", data: testSignatureList([16]byte{1}, hash), expectErr: true},
+		{name: "garbage", data: []byte("fake key data"), expectErr: true},
+		{name: "empty", data: nil, expectErr: true},
+	}
+
+	for _, tt := range testcases {
+		t.Run(tt.name, func(t *testing.T) {
+			format, certs, err := detectSecureBootKeyFormat(tt.data)
+			if tt.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.format, format)
+			assert.Len(t, certs, tt.certs)
+		})
+	}
+}
+
+func TestSecureBootKeyLoader_strict(t *testing.T) {
+	dir := t.TempDir()
+	garbagePath := filepath.Join(dir, "garbage")
+	expiredPath := filepath.Join(dir, "expired.der")
+	validPath := filepath.Join(dir, "valid.der")
+	for path, data := range map[string][]byte{
+		garbagePath: []byte("fake key data"),
+		expiredPath: testCertificate(t, time.Now().Add(-time.Hour)),
+		validPath:   testCertificate(t, time.Now().Add(time.Hour)),
+	} {
+		if err := os.WriteFile(path, data, 0600); err != nil {
+			t.Fatalf("failed to write key: %v", err)
+		}
+	}
+
+	testcases := []struct {
+		name     string
+		source   string
+		fileType string
+		problem  bool
+	}{
+		{name: "valid certificate", source: validPath, fileType: "X509"},
+		{name: "valid certificate from a file URI", source: "file://" + validPath, fileType: "X509"},
+		{name: "unparseable key", source: garbagePath, fileType: "BIN", problem: true},
+		{name: "expired certificate", source: expiredPath, fileType: "X509", problem: true},
+	}
+
+	for _, tt := range testcases {
+		t.Run(tt.name, func(t *testing.T) {
+			loader := &SecureBootKeyLoader{}
+			buffer, err := loader.FillFileContentBuffer(context.Background(), tt.source)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.fileType, buffer.FileType)
+			assert.Equal(t, tt.problem, len(loader.Warnings) > 0, "unexpected warnings %v", loader.Warnings)
+
+			strictLoader := &SecureBootKeyLoader{Strict: true}
+			_, err = strictLoader.FillFileContentBuffer(context.Background(), tt.source)
+			assert.Equal(t, tt.problem, err != nil, "unexpected error %v", err)
+			assert.Empty(t, strictLoader.Warnings)
+		})
+	}
+}
+
+func TestSecureBootKeyLoader_remoteSources(t *testing.T) {
+	der := testCertificate(t, time.Now().Add(time.Hour))
+	driver := &DriverMock{
+		DownloadFromBucketResult:  der,
+		AccessSecretVersionResult: der,
+	}
+	loader := &SecureBootKeyLoader{Reader: driver, Strict: true}
+
+	cfg, err := loader.CreateShieldedVMStateConfig(context.Background(), "gs://keys/secure-boot/pk.der", nil,
+		[]string{"secretmanager://projects/p/secrets/db"}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, base64.StdEncoding.EncodeToString(der), cfg.Pk.Content)
+	assert.Len(t, cfg.Dbs, 1)
+	assert.Equal(t, "keys", driver.DownloadFromBucketBucket)
+	assert.Equal(t, "secure-boot/pk.der", driver.DownloadFromBucketObjectName)
+	assert.Equal(t, "projects/p/secrets/db/versions/latest", driver.AccessSecretVersionName)
+}
+
+func TestValidateSecureBootKeySource(t *testing.T) {
+	testcases := []struct {
+		source    string
+		expectErr bool
+	}{
+		{source: "keys/pk.der"},
+		{source: "file:///keys/pk.der"},
+		{source: "gs://keys/secure-boot/pk.der"},
+		{source: "secretmanager://projects/p/secrets/pk"},
+		{source: "secretmanager://projects/p/secrets/pk/versions/3"},
+		{source: "file://", expectErr: true},
+		{source: "gs://keys", expectErr: true},
+		{source: "secretmanager://pk", expectErr: true},
+		{source: "secretmanager://projects/p/secrets/pk/versions/", expectErr: true},
+		{source: "https://example.com/pk.der", expectErr: true},
+	}
+
+	for _, tt := range testcases {
+		t.Run(tt.source, func(t *testing.T) {
+			err := ValidateSecureBootKeySource(tt.source)
+			assert.Equal(t, tt.expectErr, err != nil, "unexpected error %v", err)
+		})
+	}
+}
@@ -29,6 +29,7 @@ import (
 type Config struct {
 	sdk_common.PackerConfig `mapstructure:",squash"`
 	common.Authentication   `mapstructure:",squash"`
+	common.RetryConfig      `mapstructure:",squash"`
 
 	// The service account scopes for launched exporter post-processor instance.
 	// Defaults to:
@@ -138,6 +139,9 @@ func (p *PostProcessor) Configure(raws ...interface{}) error {
 	if err != nil {
 		errs = packersdk.MultiErrorAppend(errs, err)
 	}
+	if retryErrs := p.config.RetryConfig.Prepare(); len(retryErrs) > 0 {
+		errs = packersdk.MultiErrorAppend(errs, retryErrs...)
+	}
 	for _, warn := range warns {
 		log.Printf("[WARN] - %s", warn)
 	}
@@ -168,6 +172,11 @@ func (p *PostProcessor) PostProcess(ctx context.Context, ui packersdk.Ui, artifa
 		return nil, false, false, err
 	}
 
+	if artifactType, ok := artifact.State("ArtifactType").(string); ok && artifactType != googlecompute.ArtifactTypeImage {
+		err := fmt.Errorf("Can only export disk images, not artifacts of type %s.", artifactType)
+		return nil, false, false, err
+	}
+
 	builderImageName := artifact.State("ImageName").(string)
 	builderProjectId := artifact.State("ProjectId").(string)
 	builderZone := artifact.State("BuildZone").(string)
@@ -223,6 +232,7 @@ func (p *PostProcessor) PostProcess(ctx context.Context, ui packersdk.Ui, artifa
 		CustomEndpoints: p.config.CustomEndpoints,
 	}
 	p.config.Authentication.ApplyDriverConfig(cfg)
+	p.config.RetryConfig.ApplyDriverConfig(cfg)
 
 	driver, err := common.NewDriverGCE(*cfg)
 	if err != nil {
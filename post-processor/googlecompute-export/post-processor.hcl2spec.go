@@ -24,6 +24,9 @@ type FlatConfig struct {
 	CredentialsJSON           *string           `mapstructure:"credentials_json" required:"false" cty:"credentials_json" hcl:"credentials_json"`
 	ImpersonateServiceAccount *string           `mapstructure:"impersonate_service_account" required:"false" cty:"impersonate_service_account" hcl:"impersonate_service_account"`
 	VaultGCPOauthEngine       *string           `mapstructure:"vault_gcp_oauth_engine" cty:"vault_gcp_oauth_engine" hcl:"vault_gcp_oauth_engine"`
+	APIRetryAttempts          *int              `mapstructure:"api_retry_attempts" required:"false" cty:"api_retry_attempts" hcl:"api_retry_attempts"`
+	APIRetryInitialBackoff    *string           `mapstructure:"api_retry_initial_backoff" required:"false" cty:"api_retry_initial_backoff" hcl:"api_retry_initial_backoff"`
+	APIRetryMaxBackoff        *string           `mapstructure:"api_retry_max_backoff" required:"false" cty:"api_retry_max_backoff" hcl:"api_retry_max_backoff"`
 	Scopes                    []string          `mapstructure:"scopes" required:"false" cty:"scopes" hcl:"scopes"`
 	DiskSizeGb                *int64            `mapstructure:"disk_size" cty:"disk_size" hcl:"disk_size"`
 	DiskType                  *string           `mapstructure:"disk_type" cty:"disk_type" hcl:"disk_type"`
@@ -65,6 +68,9 @@ func (*FlatConfig) HCL2Spec() map[string]hcldec.Spec {
 		"credentials_json":            &hcldec.AttrSpec{Name: "credentials_json", Type: cty.String, Required: false},
 		"impersonate_service_account": &hcldec.AttrSpec{Name: "impersonate_service_account", Type: cty.String, Required: false},
 		"vault_gcp_oauth_engine":      &hcldec.AttrSpec{Name: "vault_gcp_oauth_engine", Type: cty.String, Required: false},
+		"api_retry_attempts":          &hcldec.AttrSpec{Name: "api_retry_attempts", Type: cty.Number, Required: false},
+		"api_retry_initial_backoff":   &hcldec.AttrSpec{Name: "api_retry_initial_backoff", Type: cty.String, Required: false},
+		"api_retry_max_backoff":       &hcldec.AttrSpec{Name: "api_retry_max_backoff", Type: cty.String, Required: false},
 		"scopes":                      &hcldec.AttrSpec{Name: "scopes", Type: cty.List(cty.String), Required: false},
 		"disk_size":                   &hcldec.AttrSpec{Name: "disk_size", Type: cty.Number, Required: false},
 		"disk_type":                   &hcldec.AttrSpec{Name: "disk_type", Type: cty.String, Required: false},
@@ -12,6 +12,7 @@ import (
 	"io"
 	"log"
 	"os"
+	"slices"
 	"strings"
 
 	"google.golang.org/api/compute/v1"
@@ -28,6 +29,7 @@ import (
 type Config struct {
 	sdk_common.PackerConfig `mapstructure:",squash"`
 	common.Authentication   `mapstructure:",squash"`
+	common.RetryConfig      `mapstructure:",squash"`
 
 	// The service account scopes for launched importer post-processor instance.
 	// Defaults to:
@@ -78,6 +80,8 @@ type Config struct {
 	ImageSignaturesDB []string `mapstructure:"image_signatures_db"`
 	//A database of certificates that have been revoked and will cause the system to stop booting if a boot file is signed with one of them. You may specify single or multiple comma-separated values for this value.
 	ImageForbiddenSignaturesDB []string `mapstructure:"image_forbidden_signatures_db"`
+	//Fail the import if a Secure Boot key or certificate is neither a DER or PEM encoded X.509 certificate nor an EFI signature list, or if a certificate has expired. By default, these are reported as warnings, and keys of an unknown format are sent as binary content.
+	ImageSecureBootStrict bool `mapstructure:"image_secure_boot_strict"`
 
 	// Specify the GCP universe to deploy in. The default is "googleapis.com".
 	UniverseDomain string `mapstructure:"universe_domain"`
@@ -142,10 +146,23 @@ func (p *PostProcessor) Configure(raws ...interface{}) error {
 		}
 	}
 
+	secureBootKeys := slices.Concat(p.config.ImageKeyExchangeKey, p.config.ImageSignaturesDB, p.config.ImageForbiddenSignaturesDB)
+	if p.config.ImagePlatformKey != "" {
+		secureBootKeys = append(secureBootKeys, p.config.ImagePlatformKey)
+	}
+	for _, source := range secureBootKeys {
+		if err := common.ValidateSecureBootKeySource(source); err != nil {
+			errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("Invalid Secure Boot key: %s", err))
+		}
+	}
+
 	warns, err := p.config.Authentication.Prepare()
 	if err != nil {
 		errs = packersdk.MultiErrorAppend(errs, err)
 	}
+	if retryErrs := p.config.RetryConfig.Prepare(); len(retryErrs) > 0 {
+		errs = packersdk.MultiErrorAppend(errs, retryErrs...)
+	}
 	for _, warn := range warns {
 		log.Printf("[WARN] - %s", warn)
 	}
@@ -189,6 +206,7 @@ func (p *PostProcessor) PostProcess(ctx context.Context, ui packersdk.Ui, artifa
 		Scopes: p.config.Scopes,
 	}
 	p.config.Authentication.ApplyDriverConfig(cfg)
+	p.config.RetryConfig.ApplyDriverConfig(cfg)
 	driver, err := common.NewDriverGCE(*cfg)
 	if err != nil {
 		return nil, false, false, err
@@ -216,12 +234,18 @@ func (p *PostProcessor) PostProcess(ctx context.Context, ui packersdk.Ui, artifa
 		return nil, false, false, err
 	}
 
-	rawImageGcsPath, err := driver.UploadToBucket(p.config.Bucket, p.config.GCSObjectName, tarball)
+	// The keys are loaded before uploading the image, which is not imported
+	// if they are invalid.
+	keyLoader := &common.SecureBootKeyLoader{Reader: driver, Strict: p.config.ImageSecureBootStrict}
+	shieldedVMStateConfig, err := keyLoader.CreateShieldedVMStateConfig(ctx, p.config.ImagePlatformKey, p.config.ImageKeyExchangeKey, p.config.ImageSignaturesDB, p.config.ImageForbiddenSignaturesDB)
 	if err != nil {
-		return nil, false, false, err
+		return nil, false, false, fmt.Errorf("Error loading the Secure Boot keys: %s", err)
+	}
+	for _, warning := range keyLoader.Warnings {
+		ui.Message(fmt.Sprintf("Warning: %s", warning))
 	}
 
-	shieldedVMStateConfig, err := common.CreateShieldedVMStateConfig(p.config.ImagePlatformKey, p.config.ImageKeyExchangeKey, p.config.ImageSignaturesDB, p.config.ImageForbiddenSignaturesDB)
+	rawImageGcsPath, err := driver.UploadToBucket(ctx, p.config.Bucket, p.config.GCSObjectName, tarball)
 	if err != nil {
 		return nil, false, false, err
 	}
@@ -248,7 +272,7 @@ func (p *PostProcessor) PostProcess(ctx context.Context, ui packersdk.Ui, artifa
 		StorageLocations:             p.config.ImageStorageLocations,
 	}
 
-	imageCh, errCh := driver.CreateImage(p.config.ProjectId, imageSpec)
+	imageCh, errCh := driver.CreateImage(ctx, p.config.ProjectId, imageSpec)
 	select {
 	case img := <-imageCh:
 		retArtifact = &Artifact{
@@ -265,8 +289,9 @@ func (p *PostProcessor) PostProcess(ctx context.Context, ui packersdk.Ui, artifa
 	}
 
 	if !p.config.SkipClean {
+		// The object is deleted even when the build was cancelled.
 		ui.Say(fmt.Sprintf("deleting %s from bucket %s", p.config.GCSObjectName, p.config.Bucket))
-		err = driver.DeleteFromBucket(p.config.Bucket, p.config.GCSObjectName)
+		err = driver.DeleteFromBucket(context.WithoutCancel(ctx), p.config.Bucket, p.config.GCSObjectName)
 		if err != nil {
 			return nil, false, false, err
 		}
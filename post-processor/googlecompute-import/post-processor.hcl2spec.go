@@ -24,6 +24,9 @@ type FlatConfig struct {
 	CredentialsJSON            *string           `mapstructure:"credentials_json" required:"false" cty:"credentials_json" hcl:"credentials_json"`
 	ImpersonateServiceAccount  *string           `mapstructure:"impersonate_service_account" required:"false" cty:"impersonate_service_account" hcl:"impersonate_service_account"`
 	VaultGCPOauthEngine        *string           `mapstructure:"vault_gcp_oauth_engine" cty:"vault_gcp_oauth_engine" hcl:"vault_gcp_oauth_engine"`
+	APIRetryAttempts           *int              `mapstructure:"api_retry_attempts" required:"false" cty:"api_retry_attempts" hcl:"api_retry_attempts"`
+	APIRetryInitialBackoff     *string           `mapstructure:"api_retry_initial_backoff" required:"false" cty:"api_retry_initial_backoff" hcl:"api_retry_initial_backoff"`
+	APIRetryMaxBackoff         *string           `mapstructure:"api_retry_max_backoff" required:"false" cty:"api_retry_max_backoff" hcl:"api_retry_max_backoff"`
 	Scopes                     []string          `mapstructure:"scopes" required:"false" cty:"scopes" hcl:"scopes"`
 	ProjectId                  *string           `mapstructure:"project_id" required:"true" cty:"project_id" hcl:"project_id"`
 	IAP                        *bool             `mapstructure-to-hcl:",skip" cty:"iap" hcl:"iap"`
@@ -41,6 +44,7 @@ type FlatConfig struct {
 	ImageKeyExchangeKey        []string          `mapstructure:"image_key_exchange_key" cty:"image_key_exchange_key" hcl:"image_key_exchange_key"`
 	ImageSignaturesDB          []string          `mapstructure:"image_signatures_db" cty:"image_signatures_db" hcl:"image_signatures_db"`
 	ImageForbiddenSignaturesDB []string          `mapstructure:"image_forbidden_signatures_db" cty:"image_forbidden_signatures_db" hcl:"image_forbidden_signatures_db"`
+	ImageSecureBootStrict      *bool             `mapstructure:"image_secure_boot_strict" cty:"image_secure_boot_strict" hcl:"image_secure_boot_strict"`
 	UniverseDomain             *string           `mapstructure:"universe_domain" cty:"universe_domain" hcl:"universe_domain"`
 	CustomEndpoints            map[string]string `mapstructure:"custom_endpoints" cty:"custom_endpoints" hcl:"custom_endpoints"`
 }
@@ -71,6 +75,9 @@ func (*FlatConfig) HCL2Spec() map[string]hcldec.Spec {
 		"credentials_json":              &hcldec.AttrSpec{Name: "credentials_json", Type: cty.String, Required: false},
 		"impersonate_service_account":   &hcldec.AttrSpec{Name: "impersonate_service_account", Type: cty.String, Required: false},
 		"vault_gcp_oauth_engine":        &hcldec.AttrSpec{Name: "vault_gcp_oauth_engine", Type: cty.String, Required: false},
+		"api_retry_attempts":            &hcldec.AttrSpec{Name: "api_retry_attempts", Type: cty.Number, Required: false},
+		"api_retry_initial_backoff":     &hcldec.AttrSpec{Name: "api_retry_initial_backoff", Type: cty.String, Required: false},
+		"api_retry_max_backoff":         &hcldec.AttrSpec{Name: "api_retry_max_backoff", Type: cty.String, Required: false},
 		"scopes":                        &hcldec.AttrSpec{Name: "scopes", Type: cty.List(cty.String), Required: false},
 		"project_id":                    &hcldec.AttrSpec{Name: "project_id", Type: cty.String, Required: false},
 		"iap":                           &hcldec.AttrSpec{Name: "iap", Type: cty.Bool, Required: false},
@@ -88,6 +95,7 @@ func (*FlatConfig) HCL2Spec() map[string]hcldec.Spec {
 		"image_key_exchange_key":        &hcldec.AttrSpec{Name: "image_key_exchange_key", Type: cty.List(cty.String), Required: false},
 		"image_signatures_db":           &hcldec.AttrSpec{Name: "image_signatures_db", Type: cty.List(cty.String), Required: false},
 		"image_forbidden_signatures_db": &hcldec.AttrSpec{Name: "image_forbidden_signatures_db", Type: cty.List(cty.String), Required: false},
+		"image_secure_boot_strict":      &hcldec.AttrSpec{Name: "image_secure_boot_strict", Type: cty.Bool, Required: false},
 		"universe_domain":               &hcldec.AttrSpec{Name: "universe_domain", Type: cty.String, Required: false},
 		"custom_endpoints":              &hcldec.AttrSpec{Name: "custom_endpoints", Type: cty.Map(cty.String), Required: false},
 	}